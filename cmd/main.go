@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -14,8 +18,20 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"kubercode/internal/domain/auth"
+	"kubercode/internal/domain/auth/fieldcrypt"
+	"kubercode/internal/domain/auth/identity"
+	"kubercode/internal/domain/auth/password"
+	"kubercode/internal/domain/auth/saml"
+	"kubercode/internal/domain/auth/values"
+	"kubercode/internal/infrastructure/http/cors"
 	"kubercode/internal/infrastructure/http/handlers"
+	"kubercode/internal/infrastructure/http/headers"
 	"kubercode/internal/infrastructure/http/router"
+	"kubercode/internal/infrastructure/http/session"
+	"kubercode/internal/infrastructure/lib/mailer"
+	"kubercode/internal/infrastructure/lib/push"
+	"kubercode/internal/infrastructure/ratelimit"
+	"kubercode/internal/infrastructure/tlsutil"
 
 	_ "kubercode/docs" // импортируем сгенерированную документацию
 )
@@ -35,6 +51,10 @@ import (
 // @in header
 // @name Authorization
 // @description Type "Bearer" followed by a space and JWT token.
+// @securityDefinitions.apikey SCIMToken
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and SCIM token of the organization.
 
 type Config struct {
 	JWTSecret    string
@@ -42,6 +62,14 @@ type Config struct {
 	MongoURI     string
 	RedisAddr    string
 	RedisPass    string
+
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+
+	Auth auth.Settings
 }
 
 func getEnv(key, defaultValue string) string {
@@ -52,6 +80,345 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid duration in %s: %v", key, err)
+	}
+	return d
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid integer in %s: %v", key, err)
+	}
+	return n
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("Invalid boolean in %s: %v", key, err)
+	}
+	return b
+}
+
+// getEnvList читает список значений, разделенных запятыми
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, strings.ToLower(item))
+		}
+	}
+	return list
+}
+
+func loadAuthSettings() auth.Settings {
+	defaults := auth.DefaultSettings()
+	return auth.Settings{
+		AppURL:                          getEnv("APP_URL", defaults.AppURL),
+		EmailVerificationTTL:            getEnvDuration("EMAIL_VERIFICATION_TTL", defaults.EmailVerificationTTL),
+		EmailVerificationResendInterval: getEnvDuration("EMAIL_VERIFICATION_RESEND_INTERVAL", defaults.EmailVerificationResendInterval),
+		UnverifiedPolicy:                auth.UnverifiedPolicy(getEnv("UNVERIFIED_POLICY", string(defaults.UnverifiedPolicy))),
+		PasswordResetTTL:                getEnvDuration("PASSWORD_RESET_TTL", defaults.PasswordResetTTL),
+		PasswordResetResendInterval:     getEnvDuration("PASSWORD_RESET_RESEND_INTERVAL", defaults.PasswordResetResendInterval),
+		PasswordResetMaxAttempts:        getEnvInt("PASSWORD_RESET_MAX_ATTEMPTS", defaults.PasswordResetMaxAttempts),
+		EmailChangeTTL:                  getEnvDuration("EMAIL_CHANGE_TTL", defaults.EmailChangeTTL),
+		EmailChangeRevertTTL:            getEnvDuration("EMAIL_CHANGE_REVERT_TTL", defaults.EmailChangeRevertTTL),
+		EmailChangeMaxAttempts:          getEnvInt("EMAIL_CHANGE_MAX_ATTEMPTS", defaults.EmailChangeMaxAttempts),
+		AccountDeletionGracePeriod:      getEnvDuration("ACCOUNT_DELETION_GRACE_PERIOD", defaults.AccountDeletionGracePeriod),
+		AccountPurgeInterval:            getEnvDuration("ACCOUNT_PURGE_INTERVAL", defaults.AccountPurgeInterval),
+		ReauthMaxAge:                    getEnvDuration("REAUTH_MAX_AGE", defaults.ReauthMaxAge),
+		DataExportLinkTTL:               getEnvDuration("DATA_EXPORT_LINK_TTL", defaults.DataExportLinkTTL),
+		ProfileClaims:                   getEnvBool("PROFILE_CLAIMS", defaults.ProfileClaims),
+		ReservedUsernames:               getEnvList("RESERVED_USERNAMES", defaults.ReservedUsernames),
+		ProfanityBlocklist:              getEnvList("USERNAME_PROFANITY_BLOCKLIST", defaults.ProfanityBlocklist),
+		UsernameChangeCooldown:          getEnvDuration("USERNAME_CHANGE_COOLDOWN", defaults.UsernameChangeCooldown),
+		OAuthStateTTL:                   getEnvDuration("OAUTH_STATE_TTL", defaults.OAuthStateTTL),
+		OrgInvitationTTL:                getEnvDuration("ORG_INVITATION_TTL", defaults.OrgInvitationTTL),
+		SSORedirectURL:                  getEnv("SSO_REDIRECT_URL", defaults.SSORedirectURL),
+		SAMLRequestTTL:                  getEnvDuration("SAML_REQUEST_TTL", defaults.SAMLRequestTTL),
+		LoginBackoffAfter:               getEnvInt("LOGIN_BACKOFF_AFTER", defaults.LoginBackoffAfter),
+		LoginBackoffBase:                getEnvDuration("LOGIN_BACKOFF_BASE", defaults.LoginBackoffBase),
+		LoginBackoffMax:                 getEnvDuration("LOGIN_BACKOFF_MAX", defaults.LoginBackoffMax),
+		LoginLockThreshold:              getEnvInt("LOGIN_LOCK_THRESHOLD", defaults.LoginLockThreshold),
+		LoginLockDuration:               getEnvDuration("LOGIN_LOCK_DURATION", defaults.LoginLockDuration),
+		LoginFailureWindow:              getEnvDuration("LOGIN_FAILURE_WINDOW", defaults.LoginFailureWindow),
+		PasswordPolicy:                  loadPasswordPolicy(defaults.PasswordPolicy),
+		PasswordHashing:                 loadPasswordHashing(defaults.PasswordHashing),
+		PasswordHashingPool: password.PoolConfig{
+			Workers:      getEnvInt("PASSWORD_HASH_WORKERS", defaults.PasswordHashingPool.Workers),
+			QueueSize:    getEnvInt("PASSWORD_HASH_QUEUE_SIZE", defaults.PasswordHashingPool.QueueSize),
+			QueueTimeout: getEnvDuration("PASSWORD_HASH_QUEUE_TIMEOUT", defaults.PasswordHashingPool.QueueTimeout),
+		},
+		PasswordHistorySize:             getEnvInt("PASSWORD_HISTORY_SIZE", defaults.PasswordHistorySize),
+		BreachedPasswordMinCount:        getEnvInt("BREACHED_PASSWORD_MIN_COUNT", defaults.BreachedPasswordMinCount),
+		BreachedPasswordCheckOnLogin:    getEnvBool("BREACHED_PASSWORD_CHECK_ON_LOGIN", defaults.BreachedPasswordCheckOnLogin),
+		NewDeviceAlerts:                 getEnvBool("NEW_DEVICE_ALERTS", defaults.NewDeviceAlerts),
+		NewDeviceReportTTL:              getEnvDuration("NEW_DEVICE_REPORT_TTL", defaults.NewDeviceReportTTL),
+		KnownDeviceTTL:                  getEnvDuration("KNOWN_DEVICE_TTL", defaults.KnownDeviceTTL),
+		FieldReencryptionInterval:       getEnvDuration("FIELD_REENCRYPTION_INTERVAL", defaults.FieldReencryptionInterval),
+	}
+}
+
+// loadPasswordHashing читает алгоритм и параметры хеширования паролей. Хеши со старыми
+// параметрами пересоздаются при следующем входе пользователя
+func loadPasswordHashing(defaults password.HashConfig) password.HashConfig {
+	cfg := password.HashConfig{
+		Algorithm: getEnv("PASSWORD_HASH_ALGORITHM", defaults.Algorithm),
+		Argon2: password.Argon2Params{
+			Memory:      uint32(getEnvInt("ARGON2_MEMORY_KIB", int(defaults.Argon2.Memory))),
+			Iterations:  uint32(getEnvInt("ARGON2_ITERATIONS", int(defaults.Argon2.Iterations))),
+			Parallelism: uint8(getEnvInt("ARGON2_PARALLELISM", int(defaults.Argon2.Parallelism))),
+			SaltLength:  defaults.Argon2.SaltLength,
+			KeyLength:   defaults.Argon2.KeyLength,
+		},
+		BcryptCost: getEnvInt("BCRYPT_COST", defaults.BcryptCost),
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid password hashing settings: %v", err)
+	}
+	return cfg
+}
+
+// loadPasswordPolicy читает требования к паролю. PASSWORD_BANNED_WORDS заменяет список по умолчанию
+func loadPasswordPolicy(defaults password.Policy) password.Policy {
+	return password.Policy{
+		MinLength:            getEnvInt("PASSWORD_MIN_LENGTH", defaults.MinLength),
+		MaxLength:            getEnvInt("PASSWORD_MAX_LENGTH", defaults.MaxLength),
+		RequireUppercase:     getEnvBool("PASSWORD_REQUIRE_UPPERCASE", defaults.RequireUppercase),
+		RequireLowercase:     getEnvBool("PASSWORD_REQUIRE_LOWERCASE", defaults.RequireLowercase),
+		RequireDigit:         getEnvBool("PASSWORD_REQUIRE_DIGIT", defaults.RequireDigit),
+		RequireSymbol:        getEnvBool("PASSWORD_REQUIRE_SYMBOL", defaults.RequireSymbol),
+		BannedWords:          getEnvList("PASSWORD_BANNED_WORDS", defaults.BannedWords),
+		ForbidEmailLocalPart: getEnvBool("PASSWORD_FORBID_EMAIL_LOCAL_PART", defaults.ForbidEmailLocalPart),
+	}
+}
+
+// loadIdentityProviders подключает провайдеров, для которых задан client id.
+// Адреса провайдера можно переопределить, например для локального mock сервера
+func loadIdentityProviders() []*identity.Provider {
+	var providers []*identity.Provider
+	for _, base := range []identity.Config{
+		identity.GitHubConfig(os.Getenv("OAUTH_GITHUB_CLIENT_ID"), os.Getenv("OAUTH_GITHUB_CLIENT_SECRET"),
+			os.Getenv("OAUTH_GITHUB_REDIRECT_URL")),
+		identity.GoogleConfig(os.Getenv("OAUTH_GOOGLE_CLIENT_ID"), os.Getenv("OAUTH_GOOGLE_CLIENT_SECRET"),
+			os.Getenv("OAUTH_GOOGLE_REDIRECT_URL")),
+	} {
+		if base.ClientID == "" {
+			continue
+		}
+		prefix := "OAUTH_" + strings.ToUpper(base.Name) + "_"
+		base.AuthURL = getEnv(prefix+"AUTH_URL", base.AuthURL)
+		base.TokenURL = getEnv(prefix+"TOKEN_URL", base.TokenURL)
+		base.UserInfoURL = getEnv(prefix+"USERINFO_URL", base.UserInfoURL)
+		base.EmailsURL = getEnv(prefix+"EMAILS_URL", base.EmailsURL)
+		providers = append(providers, identity.NewProvider(base))
+		log.Printf("Identity provider %s enabled", base.Name)
+	}
+	return providers
+}
+
+// loadSAMLProvider создает SAML identity provider. Утверждения подписываются тем же RSA ключом,
+// что и JWT (JWT_PRIVATE_KEY_PATH). Без ключа SAML отключен
+func loadSAMLProvider() *saml.IdentityProvider {
+	keyPath := getEnv("JWT_PRIVATE_KEY_PATH", "certs/jwtRSA256-private.pem")
+	key, err := saml.LoadKey(keyPath)
+	if err != nil {
+		log.Printf("SAML identity provider disabled: %v", err)
+		return nil
+	}
+
+	var cert *x509.Certificate
+	if certPath := os.Getenv("SAML_CERT_PATH"); certPath != "" {
+		if cert, err = saml.LoadCertificate(certPath); err != nil {
+			log.Fatalf("Failed to load SAML certificate: %v", err)
+		}
+	}
+
+	baseURL := strings.TrimSuffix(getEnv("SAML_BASE_URL", "http://localhost:1488/api/v1/saml"), "/")
+	idp, err := saml.New(saml.Config{
+		EntityID:     getEnv("SAML_ENTITY_ID", baseURL+"/metadata"),
+		SSOURL:       baseURL + "/sso",
+		SLOURL:       baseURL + "/slo",
+		AssertionTTL: getEnvDuration("SAML_ASSERTION_TTL", 5*time.Minute),
+	}, key, cert)
+	if err != nil {
+		log.Fatalf("Failed to initialize SAML identity provider: %v", err)
+	}
+	log.Printf("SAML identity provider enabled: %s", idp.EntityID())
+	return idp
+}
+
+// loadRateLimitConfig читает политики ограничения частоты запросов. Правила маршрута задаются
+// в RATE_LIMIT_<ПОЛИТИКА> в формате "ip:30/1m,email:10/15m", пустое значение "-" снимает ограничения
+func loadRateLimitConfig() ratelimit.Config {
+	cfg := ratelimit.DefaultConfig()
+	cfg.Enabled = getEnvBool("RATE_LIMIT_ENABLED", cfg.Enabled)
+
+	for policy, rules := range cfg.Policies {
+		spec := getEnv("RATE_LIMIT_"+strings.ToUpper(policy), ratelimit.FormatRules(rules))
+		if spec == "-" {
+			spec = ""
+		}
+		parsed, err := ratelimit.ParseRules(spec)
+		if err != nil {
+			log.Fatalf("Invalid rate limit policy %s: %v", policy, err)
+		}
+		cfg.Policies[policy] = parsed
+	}
+
+	if allowlist := getEnvList("RATE_LIMIT_ALLOWLIST", nil); allowlist != nil {
+		networks, err := ratelimit.ParseAllowlist(allowlist)
+		if err != nil {
+			log.Fatalf("Invalid RATE_LIMIT_ALLOWLIST: %v", err)
+		}
+		cfg.Allowlist = networks
+	}
+	return cfg
+}
+
+// loadSessionCookies читает настройки режима cookie для браузерных клиентов
+func loadSessionCookies() *session.Cookies {
+	cfg := session.DefaultConfig()
+	cfg.Enabled = getEnvBool("SESSION_COOKIES", cfg.Enabled)
+	cfg.Domain = getEnv("SESSION_COOKIE_DOMAIN", cfg.Domain)
+	cfg.RefreshCookie = getEnv("SESSION_COOKIE_NAME", cfg.RefreshCookie)
+	cfg.CSRFCookie = getEnv("CSRF_COOKIE_NAME", cfg.CSRFCookie)
+	cfg.OAuthCookie = getEnv("OAUTH_COOKIE_NAME", cfg.OAuthCookie)
+	cfg.Secure = getEnvBool("SESSION_COOKIE_SECURE", cfg.Secure)
+	cfg.TrustedOrigins = getEnvList("CSRF_TRUSTED_ORIGINS", cfg.TrustedOrigins)
+	if value := os.Getenv("SESSION_COOKIE_SAMESITE"); value != "" {
+		sameSite, ok := session.ParseSameSite(value)
+		if !ok {
+			log.Fatalf("Invalid SESSION_COOKIE_SAMESITE: %s", value)
+		}
+		cfg.SameSite = sameSite
+	}
+	if cfg.Enabled {
+		log.Printf("Session cookies enabled, domain %q, trusted origins %v", cfg.Domain, cfg.TrustedOrigins)
+	}
+	return session.New(cfg)
+}
+
+// loadCORSConfig читает источники, которым разрешены запросы из браузера. Формат CORS_ORIGINS
+// описан в cors.ParseOrigins
+func loadCORSConfig() cors.Config {
+	cfg := cors.DefaultConfig()
+	origins, err := cors.ParseOrigins(os.Getenv("CORS_ORIGINS"))
+	if err != nil {
+		log.Fatalf("Invalid CORS_ORIGINS: %v", err)
+	}
+	cfg.Origins = origins
+	cfg.Enabled = len(origins) > 0
+	cfg.MaxAge = getEnvDuration("CORS_MAX_AGE", cfg.MaxAge)
+	return cfg
+}
+
+// loadSecurityHeaders читает настройки заголовков безопасности
+func loadSecurityHeaders() headers.Config {
+	cfg := headers.DefaultConfig()
+	cfg.Enabled = getEnvBool("SECURITY_HEADERS", cfg.Enabled)
+	cfg.HSTSMaxAge = getEnvDuration("HSTS_MAX_AGE", cfg.HSTSMaxAge)
+	cfg.HSTSIncludeSubdomains = getEnvBool("HSTS_INCLUDE_SUBDOMAINS", cfg.HSTSIncludeSubdomains)
+	cfg.Default.ContentSecurityPolicy = getEnv("CONTENT_SECURITY_POLICY", cfg.Default.ContentSecurityPolicy)
+	cfg.Default.ReferrerPolicy = getEnv("REFERRER_POLICY", cfg.Default.ReferrerPolicy)
+	for i := range cfg.Routes {
+		if cfg.Routes[i].PathPrefix == "/swagger/" {
+			cfg.Routes[i].ContentSecurityPolicy = getEnv("SWAGGER_CONTENT_SECURITY_POLICY", cfg.Routes[i].ContentSecurityPolicy)
+		}
+	}
+	return cfg
+}
+
+// loadTLS загружает сертификат сервера и следит за его обновлением. Без TLS_CERT_FILE сервер
+// работает по HTTP, TLS тогда завершается на балансировщике
+func loadTLS(ctx context.Context) *tls.Config {
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if certFile == "" {
+		return nil
+	}
+	reloader, err := tlsutil.NewReloader(certFile, keyFile, "")
+	if err != nil {
+		log.Fatalf("Failed to load TLS certificate: %v", err)
+	}
+	go reloader.Watch(ctx, getEnvDuration("TLS_RELOAD_INTERVAL", 30*time.Second))
+	log.Printf("TLS enabled: %s", certFile)
+	return reloader.ServerConfig()
+}
+
+// newInternalServer создает mTLS listener для внутренних сервисов, если задан MTLS_ADDR
+func newInternalServer(ctx context.Context, authHandler *handlers.AuthHandler,
+	adminHandler *handlers.AdminHandler) *http.Server {
+	addr := os.Getenv("MTLS_ADDR")
+	if addr == "" {
+		return nil
+	}
+	caFile := os.Getenv("MTLS_CLIENT_CA_FILE")
+	if caFile == "" {
+		log.Fatalf("MTLS_CLIENT_CA_FILE is required for MTLS_ADDR")
+	}
+	identities, err := tlsutil.ParseIdentities(os.Getenv("MTLS_IDENTITIES"))
+	if err != nil {
+		log.Fatalf("Invalid MTLS_IDENTITIES: %v", err)
+	}
+
+	reloader, err := tlsutil.NewReloader(getEnv("MTLS_CERT_FILE", os.Getenv("TLS_CERT_FILE")),
+		getEnv("MTLS_KEY_FILE", os.Getenv("TLS_KEY_FILE")), caFile)
+	if err != nil {
+		log.Fatalf("Failed to load mTLS certificates: %v", err)
+	}
+	go reloader.Watch(ctx, getEnvDuration("TLS_RELOAD_INTERVAL", 30*time.Second))
+
+	handler := router.NewInternalRouter(authHandler, adminHandler, identities, getEnvList("MTLS_ADMIN_SERVICES", nil))
+	log.Printf("mTLS listener enabled on %s for %d service identities", addr, len(identities))
+	return &http.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: reloader.ServerConfig(),
+	}
+}
+
+// loadFieldEncryption читает мастер-ключи шифрования полей из FIELD_ENCRYPTION_KEYS или из файла
+// FIELD_ENCRYPTION_KEYS_FILE, например смонтированного секрета. Без ключей поля хранятся открытыми
+func loadFieldEncryption() *fieldcrypt.Keyring {
+	spec := os.Getenv("FIELD_ENCRYPTION_KEYS")
+	if path := os.Getenv("FIELD_ENCRYPTION_KEYS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read FIELD_ENCRYPTION_KEYS_FILE: %v", err)
+		}
+		spec = string(data)
+	}
+	if spec == "" {
+		return nil
+	}
+	keyring, err := fieldcrypt.ParseKeyring(spec)
+	if err != nil {
+		log.Fatalf("Invalid field encryption keys: %v", err)
+	}
+	log.Printf("Field encryption enabled, master key version %d", keyring.CurrentVersion())
+	return keyring
+}
+
 func main() {
 	// Инициализация конфигурации
 	cfg := Config{
@@ -60,7 +427,17 @@ func main() {
 		MongoURI:     getEnv("MONGO_URI", "mongodb://localhost:27017"),
 		RedisAddr:    getEnv("REDIS_ADDR", "localhost:6379"),
 		RedisPass:    getEnv("REDIS_PASS", ""),
+
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnv("SMTP_PORT", "1025"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		MailFrom:     getEnv("MAIL_FROM", "no-reply@kubercode.com"),
+
+		Auth: loadAuthSettings(),
 	}
+	// Value object пароля проверяет пароли по той же политике, что и сервис
+	values.PasswordPolicy = cfg.Auth.PasswordPolicy
 
 	log.Printf("Starting server with config: MongoDB=%s, Redis=%s", cfg.MongoURI, cfg.RedisAddr)
 
@@ -95,29 +472,89 @@ func main() {
 
 	// Инициализация репозитория
 	authRepo := auth.NewRepository(client.Database("sso"))
+	if keyring := loadFieldEncryption(); keyring != nil {
+		authRepo.SetFieldEncryption(keyring)
+	}
+	if err := authRepo.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("Failed to create MongoDB indexes: %v", err)
+	}
+
+	// Инициализация отправки писем
+	smtpMailer := mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
 
 	// Инициализация сервиса
-	authService := auth.NewService(authRepo, cfg.JWTSecret, cfg.TokenExpiry, redisClient)
+	authService := auth.NewService(authRepo, cfg.JWTSecret, cfg.TokenExpiry, redisClient, smtpMailer, cfg.Auth)
+	authService.SetIdentityProviders(loadIdentityProviders()...)
+	authService.SetEventHistory(auth.NewDomainEventHistory(authRepo))
+	if idp := loadSAMLProvider(); idp != nil {
+		authService.SetSAMLProvider(idp)
+	}
+	if dir := os.Getenv("BREACHED_PASSWORDS_DIR"); dir != "" {
+		dataset, err := password.OpenBreachedDataset(dir)
+		if err != nil {
+			log.Fatalf("Failed to open breached passwords dataset: %v", err)
+		}
+		authService.SetBreachedPasswords(dataset)
+		log.Printf("Breached passwords check enabled: %s", dir)
+	}
+	// Push-уведомления отправляются через шлюз, который доставляет их в FCM или APNs
+	if gatewayURL := os.Getenv("PUSH_GATEWAY_URL"); gatewayURL != "" {
+		authService.SetPushSender(push.NewGatewaySender(gatewayURL, os.Getenv("PUSH_GATEWAY_TOKEN")))
+	}
+
+	// Фоновое удаление аккаунтов с истекшим периодом ожидания
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go authService.RunDeletionWorker(workersCtx)
+	go authService.RunReencryptionWorker(workersCtx)
 
 	// Инициализация обработчиков
 	authHandler := handlers.NewAuthHandler(authService)
+	adminHandler := handlers.NewAdminHandler(authService)
+	orgHandler := handlers.NewOrganizationHandler(authService)
+	scimHandler := handlers.NewSCIMHandler(authService)
+	sessionCookies := loadSessionCookies()
+	authHandler.SetSessionCookies(sessionCookies)
+	orgHandler.SetSessionCookies(sessionCookies)
 
 	// Инициализация роутера
-	router := router.NewRouter(authHandler, authService, redisClient)
+	router := router.NewRouter(authHandler, adminHandler, orgHandler, scimHandler, authService, redisClient,
+		loadRateLimitConfig(), sessionCookies, loadCORSConfig(), loadSecurityHeaders())
+	// IP клиента из X-Forwarded-For принимается только от перечисленных прокси,
+	// иначе клиент мог бы обойти ограничения частоты запросов, подменив заголовок
+	if err := router.SetTrustedProxies(getEnvList("TRUSTED_PROXIES", nil)); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	// Создаем HTTP сервер
 	srv := &http.Server{
-		Addr:    ":1488",
-		Handler: router,
+		Addr:      getEnv("HTTP_ADDR", ":1488"),
+		Handler:   router,
+		TLSConfig: loadTLS(workersCtx),
 	}
+	internalSrv := newInternalServer(workersCtx, authHandler, adminHandler)
 
 	// Запускаем сервер в отдельной горутине
 	go func() {
-		log.Printf("Server starting on port 1488")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("Server starting on %s", srv.Addr)
+		var err error
+		if srv.TLSConfig != nil {
+			// Сертификат отдает TLSConfig.GetCertificate, файлы здесь не нужны
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
+	if internalSrv != nil {
+		go func() {
+			if err := internalSrv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to start mTLS server: %v", err)
+			}
+		}()
+	}
 
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
@@ -127,6 +564,11 @@ func main() {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	if internalSrv != nil {
+		if err := internalSrv.Shutdown(ctx); err != nil {
+			log.Printf("mTLS server forced to shutdown: %v", err)
+		}
+	}
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal("Server forced to shutdown:", err)
 	}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrAccountDisabled       = errors.New("account is disabled")
+	ErrAccountLocked         = errors.New("account is locked")
+	ErrAccountBanned         = errors.New("account is banned")
+	ErrPasswordResetRequired = errors.New("password reset required")
+	ErrInvalidLockTime       = errors.New("lock time must be in the future")
+)
+
+// checkAccountRestrictions возвращает ошибку, если администратор запретил доступ к аккаунту
+// или пользователь должен восстановить пароль. Проверка выполняется на всех путях выдачи токенов
+func checkAccountRestrictions(user *User) error {
+	switch {
+	case user.Banned:
+		return ErrAccountBanned
+	case user.Disabled:
+		return ErrAccountDisabled
+	case time.Now().Before(user.LockedUntil):
+		return ErrAccountLocked
+	case user.PasswordResetRequired:
+		return ErrPasswordResetRequired
+	}
+	return nil
+}
+
+// DisableAccount отключает аккаунт до повторного включения и завершает все его сессии
+func (s *Service) DisableAccount(ctx context.Context, actorID, userID, reason string) error {
+	return s.restrictAccount(ctx, actorID, userID, EventAccountDisabled, reason, nil, func(r *AccountRestrictions) {
+		r.Disabled = true
+	})
+}
+
+// EnableAccount снимает отключение аккаунта
+func (s *Service) EnableAccount(ctx context.Context, actorID, userID string) error {
+	return s.liftRestriction(ctx, actorID, userID, EventAccountEnabled, func(r *AccountRestrictions) {
+		r.Disabled = false
+		r.DisabledByOrg = primitive.NilObjectID
+	})
+}
+
+// LockAccount запрещает вход в аккаунт до указанного момента и завершает все его сессии
+func (s *Service) LockAccount(ctx context.Context, actorID, userID string, until time.Time, reason string) error {
+	if !until.After(time.Now()) {
+		return ErrInvalidLockTime
+	}
+	return s.restrictAccount(ctx, actorID, userID, EventAccountLocked, reason,
+		map[string]interface{}{"locked_until": until}, func(r *AccountRestrictions) {
+			r.LockedUntil = until
+		})
+}
+
+// UnlockAccount снимает временную блокировку аккаунта
+func (s *Service) UnlockAccount(ctx context.Context, actorID, userID string) error {
+	return s.liftRestriction(ctx, actorID, userID, EventAccountUnlocked, func(r *AccountRestrictions) {
+		r.LockedUntil = time.Time{}
+	})
+}
+
+// BanAccount бессрочно блокирует аккаунт с указанием причины и завершает все его сессии
+func (s *Service) BanAccount(ctx context.Context, actorID, userID, reason string) error {
+	return s.restrictAccount(ctx, actorID, userID, EventAccountBanned, reason, nil, func(r *AccountRestrictions) {
+		r.Banned = true
+		r.BanReason = reason
+	})
+}
+
+// UnbanAccount снимает бессрочную блокировку аккаунта
+func (s *Service) UnbanAccount(ctx context.Context, actorID, userID string) error {
+	return s.liftRestriction(ctx, actorID, userID, EventAccountUnbanned, func(r *AccountRestrictions) {
+		r.Banned = false
+		r.BanReason = ""
+	})
+}
+
+// ForcePasswordReset завершает все сессии и требует восстановить пароль перед следующим входом
+func (s *Service) ForcePasswordReset(ctx context.Context, actorID, userID, reason string) error {
+	return s.restrictAccount(ctx, actorID, userID, EventPasswordResetForced, reason, nil, func(r *AccountRestrictions) {
+		r.PasswordResetRequired = true
+	})
+}
+
+// restrictAccount применяет ограничение, отзывает все токены аккаунта и публикует событие
+func (s *Service) restrictAccount(ctx context.Context, actorID, userID string, eventType DomainEventType,
+	reason string, data map[string]interface{}, apply func(*AccountRestrictions)) error {
+	user, actor, err := s.getAdminTarget(ctx, actorID, userID)
+	if err != nil {
+		return err
+	}
+
+	apply(&user.AccountRestrictions)
+	if err := s.repo.SetAccountRestrictions(ctx, user.ID, user.AccountRestrictions); err != nil {
+		return err
+	}
+
+	if err := s.revokeAllSessions(ctx, user); err != nil {
+		log.Printf("[restrictAccount] Ошибка отзыва сессий пользователя %s: %v", user.ID.Hex(), err)
+		return err
+	}
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    eventType,
+		UserID:  user.ID,
+		ActorID: actor,
+		Reason:  reason,
+		Data:    data,
+	})
+	log.Printf("[restrictAccount] %s: пользователь %s, администратор %s", eventType, user.ID.Hex(), actor.Hex())
+	return nil
+}
+
+// liftRestriction снимает ограничение с аккаунта и публикует событие
+func (s *Service) liftRestriction(ctx context.Context, actorID, userID string, eventType DomainEventType,
+	apply func(*AccountRestrictions)) error {
+	user, actor, err := s.getAdminTarget(ctx, actorID, userID)
+	if err != nil {
+		return err
+	}
+
+	apply(&user.AccountRestrictions)
+	if err := s.repo.SetAccountRestrictions(ctx, user.ID, user.AccountRestrictions); err != nil {
+		return err
+	}
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    eventType,
+		UserID:  user.ID,
+		ActorID: actor,
+	})
+	log.Printf("[liftRestriction] %s: пользователь %s, администратор %s", eventType, user.ID.Hex(), actor.Hex())
+	return nil
+}
+
+// getAdminTarget загружает аккаунт, над которым администратор выполняет действие. Внутренний
+// сервис действует без пользователя, тогда actorID пустой, а исполнитель берется из контекста
+func (s *Service) getAdminTarget(ctx context.Context, actorID, userID string) (*User, primitive.ObjectID, error) {
+	actor := primitive.NilObjectID
+	if actorID != "" || clientInfoFromContext(ctx).Service == "" {
+		var err error
+		if actor, err = primitive.ObjectIDFromHex(actorID); err != nil {
+			return nil, primitive.NilObjectID, err
+		}
+	}
+
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, primitive.NilObjectID, ErrUserNotFound
+	}
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, primitive.NilObjectID, err
+	}
+	return user, actor, nil
+}
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckAccountRestrictions(t *testing.T) {
+	tests := []struct {
+		name         string
+		restrictions AccountRestrictions
+		want         error
+	}{
+		{"no restrictions", AccountRestrictions{}, nil},
+		{"banned", AccountRestrictions{Banned: true, BanReason: "spam"}, ErrAccountBanned},
+		{"disabled", AccountRestrictions{Disabled: true}, ErrAccountDisabled},
+		{"locked", AccountRestrictions{LockedUntil: time.Now().Add(time.Hour)}, ErrAccountLocked},
+		{"lock expired", AccountRestrictions{LockedUntil: time.Now().Add(-time.Second)}, nil},
+		{"password reset required", AccountRestrictions{PasswordResetRequired: true}, ErrPasswordResetRequired},
+		{"ban takes precedence", AccountRestrictions{Banned: true, Disabled: true, PasswordResetRequired: true}, ErrAccountBanned},
+		{"disable takes precedence over lock", AccountRestrictions{Disabled: true, LockedUntil: time.Now().Add(time.Hour)}, ErrAccountDisabled},
+	}
+	for _, tt := range tests {
+		user := &User{AccountRestrictions: tt.restrictions}
+		if err := checkAccountRestrictions(user); !errors.Is(err, tt.want) {
+			t.Errorf("%s: checkAccountRestrictions = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"kubercode/internal/domain/auth/audit"
+)
+
+var (
+	ErrUserNotFound             = errors.New("user not found")
+	ErrDeletionNotScheduled     = errors.New("account deletion is not scheduled")
+	ErrDeletionAlreadyScheduled = errors.New("account deletion is already scheduled")
+	ErrOrganizationOwner        = errors.New("account owns organizations, ownership must be transferred first")
+)
+
+// RequestAccountDeletion планирует удаление аккаунта после повторной проверки пароля.
+// До окончания периода ожидания удаление можно отменить, все текущие сессии завершаются
+func (s *Service) RequestAccountDeletion(ctx context.Context, userID string, password string) (time.Time, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := s.checkPassword(ctx, user, password); err != nil {
+		return time.Time{}, err
+	}
+
+	if !user.DeletionScheduledAt.IsZero() {
+		return user.DeletionScheduledAt, ErrDeletionAlreadyScheduled
+	}
+	if err := s.checkNotOrganizationOwner(ctx, user.ID); err != nil {
+		return time.Time{}, err
+	}
+
+	scheduledAt := time.Now().Add(s.settings.AccountDeletionGracePeriod)
+	if err := s.repo.SetDeletionScheduledAt(ctx, user.ID, scheduledAt); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := s.revokeAllSessions(ctx, user); err != nil {
+		log.Printf("[RequestAccountDeletion] Ошибка отзыва сессий: %v", err)
+		return time.Time{}, err
+	}
+
+	s.recordEvent(ctx, &DomainEvent{
+		Type:    EventAccountDeletionScheduled,
+		UserID:  user.ID,
+		ActorID: user.ID,
+		Data:    map[string]interface{}{"scheduled_at": scheduledAt},
+	})
+
+	subject, body := accountDeletionScheduledEmail(scheduledAt)
+	s.sendEmail(user.Email, subject, body)
+
+	log.Printf("[RequestAccountDeletion] Удаление аккаунта %s запланировано на %s", user.ID.Hex(), scheduledAt)
+	return scheduledAt, nil
+}
+
+// CancelAccountDeletion отменяет запланированное удаление аккаунта
+func (s *Service) CancelAccountDeletion(ctx context.Context, userID string) error {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if user.DeletionScheduledAt.IsZero() {
+		return ErrDeletionNotScheduled
+	}
+
+	if err := s.repo.SetDeletionScheduledAt(ctx, user.ID, time.Time{}); err != nil {
+		return err
+	}
+	s.recordEvent(ctx, &DomainEvent{Type: EventAccountDeletionCancelled, UserID: user.ID, ActorID: user.ID})
+	return nil
+}
+
+// EraseAccount безвозвратно удаляет аккаунт и все связанные с ним данные, в том числе доменные
+// события. actorID - администратор, пустой при удалении по истечении периода ожидания. Записи
+// журнала аудита не удаляются, это разорвало бы хеш-цепочку: из них стираются персональные данные
+func (s *Service) EraseAccount(ctx context.Context, actorID, userID string) error {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	// Организация владельца принадлежит и остальным участникам, удалять ее вместе с аккаунтом нельзя
+	if err := s.checkNotOrganizationOwner(ctx, id); err != nil {
+		return err
+	}
+	if err := s.repo.EraseUserData(ctx, id); err != nil {
+		log.Printf("[EraseAccount] Ошибка удаления данных пользователя %s: %v", userID, err)
+		return err
+	}
+	s.recordAudit(ctx, AuditAccountErased, audit.OutcomeSuccess, actorID, userID, nil)
+
+	log.Printf("[EraseAccount] Данные пользователя %s удалены", userID)
+	return nil
+}
+
+// checkNotOrganizationOwner возвращает ErrOrganizationOwner, если пользователь владеет организацией
+func (s *Service) checkNotOrganizationOwner(ctx context.Context, id primitive.ObjectID) error {
+	owns, err := s.repo.OwnsOrganizations(ctx, id)
+	if err != nil {
+		return err
+	}
+	if owns {
+		return ErrOrganizationOwner
+	}
+	return nil
+}
+
+// PurgeScheduledDeletions удаляет аккаунты, период ожидания удаления которых истек
+func (s *Service) PurgeScheduledDeletions(ctx context.Context) error {
+	now := time.Now()
+	ids, err := s.repo.GetUsersScheduledForDeletion(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		// Пользователь мог отменить удаление после выборки
+		user, err := s.repo.GetUserByID(ctx, id)
+		if err != nil {
+			log.Printf("[PurgeScheduledDeletions] Ошибка загрузки аккаунта %s: %v", id.Hex(), err)
+			continue
+		}
+		if !deletionDue(user, now) {
+			continue
+		}
+		if err := s.EraseAccount(ctx, "", id.Hex()); err != nil {
+			log.Printf("[PurgeScheduledDeletions] Ошибка удаления аккаунта %s: %v", id.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// deletionDue сообщает, что удаление аккаунта запланировано и период ожидания истек к моменту now
+func deletionDue(user *User, now time.Time) bool {
+	return !user.DeletionScheduledAt.IsZero() && !now.Before(user.DeletionScheduledAt)
+}
+
+// RunDeletionWorker периодически удаляет аккаунты с истекшим периодом ожидания до отмены контекста
+func (s *Service) RunDeletionWorker(ctx context.Context) {
+	ticker := time.NewTicker(s.settings.AccountPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.PurgeScheduledDeletions(ctx); err != nil {
+				log.Printf("[RunDeletionWorker] Ошибка удаления аккаунтов: %v", err)
+			}
+		}
+	}
+}
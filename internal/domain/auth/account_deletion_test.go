@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeletionDue(t *testing.T) {
+	settings := DefaultSettings()
+	requestedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	scheduledAt := requestedAt.Add(settings.AccountDeletionGracePeriod)
+
+	tests := []struct {
+		name        string
+		scheduledAt time.Time
+		now         time.Time
+		want        bool
+	}{
+		{"right after request", scheduledAt, requestedAt, false},
+		{"grace period not over", scheduledAt, scheduledAt.Add(-time.Second), false},
+		{"grace period over", scheduledAt, scheduledAt, true},
+		{"long overdue", scheduledAt, scheduledAt.Add(24 * time.Hour), true},
+		// CancelAccountDeletion сбрасывает время удаления
+		{"cancelled", time.Time{}, scheduledAt.Add(time.Hour), false},
+	}
+	for _, tt := range tests {
+		user := &User{DeletionScheduledAt: tt.scheduledAt}
+		if got := deletionDue(user, tt.now); got != tt.want {
+			t.Errorf("%s: deletionDue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
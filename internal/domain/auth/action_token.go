@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Назначения одноразовых токенов, которые отправляются пользователю в письмах.
+// Такие токены подписываются тем же секретом, что и access токены, поэтому
+// ValidateToken их отклоняет, а проверяются они только через parseActionToken.
+const (
+	purposeEmailVerification = "email_verification"
+	purposeEmailChangeRevert = "email_change_revert"
+	purposeDataExport        = "data_export"
+	purposeLoginReport       = "login_report"
+)
+
+// generateActionToken создает подписанный токен для действия из письма
+func (s *Service) generateActionToken(user *User, purpose string, ttl time.Duration, extra jwt.MapClaims) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": user.ID.Hex(),
+		"email":   user.Email,
+		"purpose": purpose,
+		"iat":     time.Now().Unix(),
+		"exp":     time.Now().Add(ttl).Unix(),
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(s.jwtSecret)
+}
+
+// parseActionToken проверяет подпись и назначение токена и возвращает его claims
+func (s *Service) parseActionToken(tokenString, purpose string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return s.jwtSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if p, _ := claims["purpose"].(string); p != purpose {
+		return nil, ErrInvalidToken
+	}
+	if _, ok := claims["user_id"].(string); !ok {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
@@ -15,6 +15,7 @@ type Account struct {
 	Password  values.Password
 	IsMentor  values.IsMentor
 	DeviceToken string
+	Erased    bool
 }
 
 func NewAccountWithOnlyId(id uuid.UUID) *Account {
@@ -55,6 +56,9 @@ func (a *Account) When(event es.Event) {
 		}
 		a.Version++
 		fmt.Println(a.Version)
+	case events.AccountErased:
+		a.onAccountErased()
+		a.Version++
 	}
 }
 
@@ -92,3 +96,11 @@ func (a *Account) onChangeEmail(event es.Event) error {
 	a.Email = changeEmailEvent.Email
 	return nil
 }
+
+// onAccountErased сбрасывает персональные данные, события до удаления недоступны для чтения
+func (a *Account) onAccountErased() {
+	a.Email = values.Email{}
+	a.Password = values.Password{}
+	a.DeviceToken = ""
+	a.Erased = true
+}
@@ -0,0 +1,221 @@
+package audit
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrChainBroken = errors.New("audit log hash chain is broken")
+
+// Outcome - результат действия
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+	// OutcomeDenied - действие запрещено политикой: блокировка, ограничения аккаунта, частота запросов
+	OutcomeDenied Outcome = "denied"
+)
+
+// Record - запись журнала аудита. Журнал только дополняется: каждая запись содержит хеш
+// предыдущей, поэтому удаление или изменение записи обнаруживается при проверке цепочки
+type Record struct {
+	// Seq - номер записи, идет подряд с 1 и служит ключом записи
+	Seq     int64     `bson:"_id" json:"seq"`
+	Time    time.Time `bson:"time" json:"time"`
+	Action  string    `bson:"action" json:"action"`
+	Outcome Outcome   `bson:"outcome" json:"outcome"`
+	// ActorID - кто выполнил действие, TargetID - над чьим аккаунтом. Для неизвестного
+	// пользователя (вход с несуществующим логином) ActorID пустой
+	ActorID   string            `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	TargetID  string            `bson:"target_id,omitempty" json:"target_id,omitempty"`
+	IP        string            `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent string            `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	DeviceID  string            `bson:"device_id,omitempty" json:"device_id,omitempty"`
+	Details   map[string]string `bson:"details,omitempty" json:"details,omitempty"`
+	// Salt - случайное значение записи. Персональные данные (IP, User-Agent, устройство, детали)
+	// входят в хеш записи через хеш с солью, поэтому их можно стереть, не разрывая цепочку, см. Erase
+	Salt string `bson:"salt,omitempty" json:"salt,omitempty"`
+	// PersonalHash - хеш стертых персональных данных, задается только Erase
+	PersonalHash string `bson:"personal_hash,omitempty" json:"personal_hash,omitempty"`
+	PrevHash     string `bson:"prev_hash" json:"prev_hash"`
+	Hash         string `bson:"hash" json:"hash"`
+}
+
+// personalData - персональные данные записи вместе с солью, хешируются отдельно от записи
+type personalData struct {
+	Salt      string            `json:"salt"`
+	IP        string            `json:"ip"`
+	UserAgent string            `json:"user_agent"`
+	DeviceID  string            `json:"device_id"`
+	Details   map[string]string `json:"details"`
+}
+
+// hashedRecord - поля записи, которые входят в хеш, в фиксированном порядке. Время хранится
+// с точностью до миллисекунд, как в MongoDB, чтобы хеш совпадал после чтения из базы
+type hashedRecord struct {
+	Seq       int64             `json:"seq"`
+	Time      string            `json:"time"`
+	Action    string            `json:"action"`
+	Outcome   Outcome           `json:"outcome"`
+	ActorID   string            `json:"actor_id"`
+	TargetID  string            `json:"target_id"`
+	IP        string            `json:"ip"`
+	UserAgent string            `json:"user_agent"`
+	DeviceID  string            `json:"device_id"`
+	Details   map[string]string `json:"details"`
+	// Personal - хеш персональных данных. У записей без соли (созданных до ее появления)
+	// пустой, тогда персональные данные входят в хеш как есть
+	Personal string `json:"personal,omitempty"`
+	PrevHash string `json:"prev_hash"`
+}
+
+// Chain связывает запись с предыдущей (nil для первой записи журнала) и вычисляет ее хеш
+func (r *Record) Chain(prev *Record) {
+	r.Seq, r.PrevHash = 1, ""
+	if prev != nil {
+		r.Seq, r.PrevHash = prev.Seq+1, prev.Hash
+	}
+	r.Time = r.Time.UTC().Truncate(time.Millisecond)
+	if r.Salt == "" && r.PersonalHash == "" {
+		r.Salt = newSalt()
+	}
+	r.Hash = r.ComputeHash()
+}
+
+// Erase стирает персональные данные записи (IP, User-Agent, устройство, детали), оставляя их хеш,
+// поэтому хеш записи и цепочка не меняются. Возвращает false, если стирать нечего: запись уже
+// стерта или создана без соли и не может быть стерта без разрыва цепочки
+func (r *Record) Erase() bool {
+	if r.Salt == "" {
+		return false
+	}
+	r.PersonalHash = r.personalHash()
+	r.Salt, r.IP, r.UserAgent, r.DeviceID, r.Details = "", "", "", "", nil
+	return true
+}
+
+// Erased сообщает, что персональные данные записи стерты
+func (r *Record) Erased() bool {
+	return r.Salt == "" && r.PersonalHash != ""
+}
+
+func (r *Record) hasPersonalData() bool {
+	return r.IP != "" || r.UserAgent != "" || r.DeviceID != "" || len(r.Details) > 0
+}
+
+func (r *Record) personalHash() string {
+	details := r.Details
+	if len(details) == 0 {
+		details = nil
+	}
+	data, _ := json.Marshal(personalData{
+		Salt:      r.Salt,
+		IP:        r.IP,
+		UserAgent: r.UserAgent,
+		DeviceID:  r.DeviceID,
+		Details:   details,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func newSalt() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// ComputeHash вычисляет SHA-256 записи вместе с хешем предыдущей
+func (r *Record) ComputeHash() string {
+	details := r.Details
+	if len(details) == 0 {
+		details = nil
+	}
+	hashed := hashedRecord{
+		Seq:      r.Seq,
+		Time:     r.Time.UTC().Format(time.RFC3339Nano),
+		Action:   r.Action,
+		Outcome:  r.Outcome,
+		ActorID:  r.ActorID,
+		TargetID: r.TargetID,
+		PrevHash: r.PrevHash,
+	}
+	switch {
+	case r.Salt != "":
+		hashed.Personal = r.personalHash()
+	case r.PersonalHash != "":
+		hashed.Personal = r.PersonalHash
+	default:
+		hashed.IP, hashed.UserAgent, hashed.DeviceID, hashed.Details = r.IP, r.UserAgent, r.DeviceID, details
+	}
+	// json.Marshal сортирует ключи map, поэтому сериализация детерминирована
+	data, _ := json.Marshal(hashed)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ChainError указывает первую запись, на которой цепочка нарушена
+type ChainError struct {
+	Seq    int64
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("%v at record %d: %s", ErrChainBroken, e.Seq, e.Reason)
+}
+
+func (e *ChainError) Unwrap() error {
+	return ErrChainBroken
+}
+
+// Verifier проверяет цепочку записей, переданных по порядку номеров
+type Verifier struct {
+	prev    *Record
+	checked int64
+}
+
+// Next проверяет очередную запись: номер идет сразу за предыдущим, запись ссылается на хеш
+// предыдущей и ее собственный хеш не изменился
+func (v *Verifier) Next(r *Record) error {
+	wantSeq, wantPrev := int64(1), ""
+	if v.prev != nil {
+		wantSeq, wantPrev = v.prev.Seq+1, v.prev.Hash
+	}
+	switch {
+	case r.Seq != wantSeq:
+		return &ChainError{Seq: wantSeq, Reason: fmt.Sprintf("record missing, next record is %d", r.Seq)}
+	case r.PrevHash != wantPrev:
+		return &ChainError{Seq: r.Seq, Reason: "previous hash does not match"}
+	case r.Hash != r.ComputeHash():
+		return &ChainError{Seq: r.Seq, Reason: "record hash does not match its contents"}
+	case r.Erased() && r.hasPersonalData():
+		// Стертые данные не входят в хеш, поэтому их появление проверяется отдельно
+		return &ChainError{Seq: r.Seq, Reason: "erased record contains personal data"}
+	}
+	v.prev = r
+	v.checked++
+	return nil
+}
+
+// Checked возвращает число проверенных записей
+func (v *Verifier) Checked() int64 {
+	return v.checked
+}
+
+// Filter - условия выборки записей журнала, пустые поля не ограничивают выборку
+type Filter struct {
+	ActorID  string
+	TargetID string
+	Action   string
+	Outcome  Outcome
+	From     time.Time
+	To       time.Time
+}
@@ -0,0 +1,121 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func chain(n int) []*Record {
+	var records []*Record
+	var prev *Record
+	for i := 0; i < n; i++ {
+		r := &Record{
+			Time:     time.Date(2025, 3, 1, 12, 0, i, 123456789, time.FixedZone("MSK", 3*3600)),
+			Action:   "Login",
+			Outcome:  OutcomeSuccess,
+			ActorID:  "65f0c0ffee0000000000000" + string(rune('a'+i)),
+			TargetID: "65f0c0ffee0000000000000" + string(rune('a'+i)),
+			IP:       "203.0.113.7",
+			Details:  map[string]string{"method": "password"},
+		}
+		r.Chain(prev)
+		records = append(records, r)
+		prev = r
+	}
+	return records
+}
+
+func verify(records []*Record) error {
+	var v Verifier
+	for _, r := range records {
+		if err := v.Next(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestChainVerifies(t *testing.T) {
+	records := chain(4)
+	if records[0].Seq != 1 || records[0].PrevHash != "" || records[3].Seq != 4 {
+		t.Fatalf("unexpected numbering: first %d, last %d", records[0].Seq, records[3].Seq)
+	}
+	if records[0].Time.Location() != time.UTC || records[0].Time.Nanosecond() != 123000000 {
+		t.Errorf("time should be UTC with millisecond precision, got %s", records[0].Time)
+	}
+	if err := verify(records); err != nil {
+		t.Fatalf("intact chain: %v", err)
+	}
+
+	// Пустые детали после чтения из базы становятся nil, хеш не должен меняться
+	r := chain(1)[0]
+	r.Details = map[string]string{}
+	r.Chain(nil)
+	r.Details = nil
+	if r.Hash != r.ComputeHash() {
+		t.Error("empty and nil details should hash the same")
+	}
+}
+
+func TestChainDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]*Record) []*Record
+		seq    int64
+	}{
+		{"modified record", func(r []*Record) []*Record {
+			r[1].Outcome = OutcomeFailure
+			return r
+		}, 2},
+		{"deleted record", func(r []*Record) []*Record {
+			return append(r[:1], r[2:]...)
+		}, 2},
+		{"deleted and renumbered", func(r []*Record) []*Record {
+			r = append(r[:1], r[2:]...)
+			r[1].Seq, r[2].Seq = 2, 3
+			return r
+		}, 2},
+		{"rehashed record", func(r []*Record) []*Record {
+			r[2].IP = "198.51.100.1"
+			r[2].Hash = r[2].ComputeHash()
+			return r
+		}, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verify(tt.tamper(chain(4)))
+			var chainErr *ChainError
+			if !errors.As(err, &chainErr) || !errors.Is(err, ErrChainBroken) {
+				t.Fatalf("got %v, want ChainError", err)
+			}
+			if chainErr.Seq != tt.seq {
+				t.Errorf("broken at %d, want %d (%v)", chainErr.Seq, tt.seq, err)
+			}
+		})
+	}
+}
+
+func TestEraseKeepsChain(t *testing.T) {
+	records := chain(3)
+	hash := records[1].Hash
+	if !records[1].Erase() {
+		t.Fatal("record with salt should be erasable")
+	}
+	if records[1].IP != "" || records[1].Details != nil || records[1].Salt != "" || records[1].Hash != hash {
+		t.Fatalf("personal data should be cleared and hash kept: %+v", records[1])
+	}
+	if records[1].Erase() {
+		t.Error("erased record should not be erased twice")
+	}
+	if err := verify(records); err != nil {
+		t.Fatalf("chain with erased record: %v", err)
+	}
+
+	records[1].IP = "198.51.100.1"
+	var chainErr *ChainError
+	if err := verify(records); !errors.As(err, &chainErr) || chainErr.Seq != 2 {
+		t.Errorf("personal data written into erased record: got %v, want ChainError at 2", err)
+	}
+}
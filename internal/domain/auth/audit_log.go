@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"kubercode/internal/domain/auth/audit"
+)
+
+// Действия журнала аудита, которые не являются доменными событиями. Доменные события
+// попадают в журнал под своим типом
+const (
+	AuditLogin                = "Login"
+	AuditExternalLogin        = "ExternalLogin"
+	AuditNewDeviceLogin       = "NewDeviceLogin"
+	AuditTokenRefreshed       = "TokenRefreshed"
+	AuditLogout               = "Logout"
+	AuditLogoutAllDevices     = "LogoutAllDevices"
+	AuditPasswordChanged      = "PasswordChanged"
+	AuditPasswordReset        = "PasswordReset"
+	AuditEmailChangeRequested = "EmailChangeRequested"
+	AuditEmailChanged         = "EmailChanged"
+	AuditEmailChangeReverted  = "EmailChangeReverted"
+	AuditAccountErased        = "AccountErased"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+// recordAudit добавляет запись в журнал аудита. Клиент берется из контекста запроса.
+// Ошибка записи журнала не отменяет уже выполненное действие
+func (s *Service) recordAudit(ctx context.Context, action string, outcome audit.Outcome,
+	actorID, targetID string, details map[string]string) {
+	client := clientInfoFromContext(ctx)
+	if actorID == "" {
+		actorID = serviceActor(client)
+	}
+	record := &audit.Record{
+		Time:      time.Now(),
+		Action:    action,
+		Outcome:   outcome,
+		ActorID:   actorID,
+		TargetID:  targetID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		DeviceID:  client.DeviceID,
+		Details:   details,
+	}
+	if err := s.repo.AppendAuditRecord(ctx, record); err != nil {
+		log.Printf("[recordAudit] Ошибка записи действия %s пользователя %s в журнал аудита: %v", action, targetID, err)
+	}
+}
+
+// auditResult записывает результат действия пользователя над своим аккаунтом
+func (s *Service) auditResult(ctx context.Context, action, userID string, err error) {
+	if err == nil {
+		s.recordAudit(ctx, action, audit.OutcomeSuccess, userID, userID, nil)
+		return
+	}
+	s.recordAudit(ctx, action, auditOutcome(err), userID, userID, map[string]string{"error": err.Error()})
+}
+
+// auditOutcome отличает отказ по правилам сервиса от неудачной попытки
+func auditOutcome(err error) audit.Outcome {
+	var throttled *LoginThrottledError
+	switch {
+	case errors.As(err, &throttled),
+		errors.Is(err, ErrTooManyLoginAttempts),
+		errors.Is(err, ErrAccountDisabled),
+		errors.Is(err, ErrAccountLocked),
+		errors.Is(err, ErrAccountBanned),
+		errors.Is(err, ErrPasswordResetRequired),
+		errors.Is(err, ErrEmailNotVerified),
+		errors.Is(err, ErrSSORequired),
+		errors.Is(err, ErrTokenRevoked):
+		return audit.OutcomeDenied
+	}
+	return audit.OutcomeFailure
+}
+
+// auditAttempt записывает попытку входа или действия по ссылке, пользователь которой может быть
+// неизвестен. Для входа в details передается loginAuditDetails, чтобы перебор по чужим логинам
+// был виден в журнале
+func (s *Service) auditAttempt(ctx context.Context, action string, user *User, err error, details map[string]string) {
+	var userID string
+	if user != nil {
+		userID = user.ID.Hex()
+	}
+	outcome := audit.OutcomeSuccess
+	if err != nil {
+		outcome = auditOutcome(err)
+		if details == nil {
+			details = map[string]string{}
+		}
+		details["error"] = err.Error()
+	}
+	s.recordAudit(ctx, action, outcome, userID, userID, details)
+}
+
+// loginAuditDetails возвращает детали попытки входа для журнала. Журнал только дополняется,
+// поэтому введенный логин в него не пишется: для известного аккаунта достаточно его ID в записи,
+// для неизвестного сохраняется HMAC логина, тот же, что в ключе счетчика попыток
+func (s *Service) loginAuditDetails(user *User, login string) map[string]string {
+	if user != nil {
+		return nil
+	}
+	return map[string]string{"login_key": s.loginAttemptKey(nil, login)}
+}
+
+// auditEvent дублирует доменное событие в журнал аудита: так в журнал попадают действия
+// администраторов и изменения аккаунта, о которых публикуются события
+func (s *Service) auditEvent(ctx context.Context, event *DomainEvent) {
+	details := make(map[string]string, len(event.Data)+1)
+	for key, value := range event.Data {
+		details[key] = fmt.Sprint(value)
+	}
+	if event.Reason != "" {
+		details["reason"] = event.Reason
+	}
+	var actorID, targetID string
+	if !event.ActorID.IsZero() {
+		actorID = event.ActorID.Hex()
+	}
+	if !event.UserID.IsZero() {
+		targetID = event.UserID.Hex()
+	}
+	s.recordAudit(ctx, string(event.Type), audit.OutcomeSuccess, actorID, targetID, details)
+}
+
+// AuditPage - страница журнала аудита. NextCursor передается в before для следующей страницы,
+// 0 - записей больше нет
+type AuditPage struct {
+	Records    []*audit.Record `json:"records"`
+	NextCursor int64           `json:"next_cursor,omitempty"`
+}
+
+// AuditLog возвращает записи журнала аудита, начиная с последних
+func (s *Service) AuditLog(ctx context.Context, filter audit.Filter, before int64, limit int) (*AuditPage, error) {
+	if limit <= 0 {
+		limit = defaultAuditPageSize
+	}
+	limit = min(limit, maxAuditPageSize)
+
+	records, err := s.repo.GetAuditRecords(ctx, filter, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	page := &AuditPage{Records: records}
+	if page.Records == nil {
+		page.Records = []*audit.Record{}
+	}
+	if len(records) == limit && records[len(records)-1].Seq > 1 {
+		page.NextCursor = records[len(records)-1].Seq
+	}
+	return page, nil
+}
+
+// ExportAuditLog выгружает записи журнала аудита в w в формате JSON Lines по возрастанию номеров
+func (s *Service) ExportAuditLog(ctx context.Context, filter audit.Filter, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	return s.repo.StreamAuditRecords(ctx, filter, func(record *audit.Record) error {
+		return encoder.Encode(record)
+	})
+}
+
+// AuditVerification - результат проверки цепочки журнала аудита
+type AuditVerification struct {
+	Valid   bool  `json:"valid"`
+	Checked int64 `json:"checked"`
+	// BrokenAt - номер первой записи, на которой цепочка нарушена
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// VerifyAuditLog проверяет хеш-цепочку всего журнала: удаленная или измененная запись
+// обнаруживается по первому несовпадению
+func (s *Service) VerifyAuditLog(ctx context.Context) (*AuditVerification, error) {
+	var verifier audit.Verifier
+	err := s.repo.StreamAuditRecords(ctx, audit.Filter{}, verifier.Next)
+
+	result := &AuditVerification{Valid: err == nil, Checked: verifier.Checked()}
+	var chainErr *audit.ChainError
+	if errors.As(err, &chainErr) {
+		result.BrokenAt, result.Reason = chainErr.Seq, chainErr.Reason
+		log.Printf("[VerifyAuditLog] Цепочка журнала аудита нарушена: %v", err)
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,35 @@
+package auth
+
+import "context"
+
+// ClientInfo описывает клиента, от которого пришел запрос
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	// DeviceID - идентификатор устройства из заголовка X-Device-ID, если клиент его передает
+	DeviceID string
+	// Service - внутренний сервис, подтвержденный клиентским сертификатом mTLS
+	Service string
+}
+
+type clientInfoKey struct{}
+
+// WithClientInfo сохраняет информацию о клиенте в контексте запроса
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// clientInfoFromContext возвращает информацию о клиенте из контекста, если она есть
+func clientInfoFromContext(ctx context.Context) ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info
+}
+
+// serviceActor возвращает исполнителя действия для журнала аудита, когда запрос пришел
+// от внутреннего сервиса, а не от пользователя
+func serviceActor(client ClientInfo) string {
+	if client.Service == "" {
+		return ""
+	}
+	return "service:" + client.Service
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var ErrInvalidCode = errors.New("invalid or expired code")
+
+// codeLength - количество цифр в кодах подтверждения из писем
+const codeLength = 6
+
+// generateCode генерирует случайный числовой код подтверждения
+func generateCode() (string, error) {
+	var code string
+	for i := 0; i < codeLength; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate code: %v", err)
+		}
+		code += n.String()
+	}
+	return code, nil
+}
+
+// hashCode возвращает HMAC кода, чтобы в базе не хранились коды в открытом виде
+func (s *Service) hashCode(code string) string {
+	mac := hmac.New(sha256.New, s.jwtSecret)
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkCode сравнивает код с сохраненным хешем за постоянное время
+func (s *Service) checkCode(code, hash string) bool {
+	return hmac.Equal([]byte(s.hashCode(code)), []byte(hash))
+}
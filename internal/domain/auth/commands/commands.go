@@ -74,3 +74,17 @@ func NewRestorePasswordCommand(aggregateID uuid.UUID, password values.Password)
 		Password:    password,
 	}
 }
+
+type EraseAccountCommand struct {
+	es.BaseCommand
+}
+
+func NewEmptyEraseAccountCommand() *EraseAccountCommand {
+	return &EraseAccountCommand{}
+}
+
+func NewEraseAccountCommand(aggregateID uuid.UUID) *EraseAccountCommand {
+	return &EraseAccountCommand{
+		BaseCommand: es.NewBaseCommand(aggregateID),
+	}
+}
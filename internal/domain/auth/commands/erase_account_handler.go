@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"kubercode-sso/config"
+	aggregate2 "kubercode-sso/internal/domain/auth/aggregate"
+	"kubercode-sso/internal/domain/auth/events"
+	"kubercode-sso/internal/domain/auth/repository"
+	"kubercode-sso/internal/infrastructure/es"
+	"kubercode-sso/internal/infrastructure/es/store"
+	"log/slog"
+)
+
+// EraseAccountHandler удаляет персональные данные аккаунта из истории событий.
+// События в EventStoreDB неизменяемы, поэтому вместо их удаления уничтожается ключ
+// агрегата, которым они зашифрованы (crypto-shredding).
+type EraseAccountHandler struct {
+	es.CommandHandler[EraseAccountCommand]
+	log            *slog.Logger
+	cfg            *config.Config
+	eventStore     store.EventStore
+	aggregateStore store.AggregateStore
+	keys           repository.AggregateKeyRepository
+}
+
+func NewEraseAccountHandler(log *slog.Logger, cfg *config.Config, eventStore store.EventStore,
+	aggregateStore store.AggregateStore, keys repository.AggregateKeyRepository) *EraseAccountHandler {
+	return &EraseAccountHandler{log: log, cfg: cfg, eventStore: eventStore, aggregateStore: aggregateStore, keys: keys}
+}
+
+func (c *EraseAccountHandler) Handle(ctx context.Context, command es.Command) (es.Event, error) {
+	c.log.Info("EraseAccountHandler", "handle")
+	if command == nil {
+		return es.Event{}, fmt.Errorf("received nil command")
+	}
+
+	eraseAccountCommand, ok := command.(*EraseAccountCommand)
+	if !ok {
+		return es.Event{}, fmt.Errorf("invalid command type: expected *EraseAccountCommand, got %T", command)
+	}
+	aggregate := aggregate2.NewAccountWithOnlyId(eraseAccountCommand.AggregateID)
+	if err := c.aggregateStore.LoadAndApplyEvents(ctx, aggregate); err != nil {
+		return es.Event{}, err
+	}
+	if aggregate.Erased {
+		return es.Event{}, es.ErrPersonalDataErased
+	}
+
+	event, err := events.NewAccountErasedEvent(eraseAccountCommand.AggregateID, aggregate)
+	if err != nil {
+		return es.Event{}, err
+	}
+	// Событие сохраняется до удаления ключа, чтобы проекции успели очистить read-модели
+	err = c.eventStore.SaveEvents(ctx, eraseAccountCommand.AggregateID, event)
+	if err != nil {
+		return es.Event{}, err
+	}
+	if err := c.keys.DeleteKey(ctx, eraseAccountCommand.AggregateID); err != nil {
+		return es.Event{}, err
+	}
+	return event, nil
+}
@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// dataExportTimeout ограничивает время фоновой подготовки архива
+const dataExportTimeout = time.Minute
+
+// TimelineEntry - событие из истории аккаунта в читаемом виде
+type TimelineEntry struct {
+	Time        time.Time `json:"time"`
+	Event       string    `json:"event"`
+	Description string    `json:"description"`
+}
+
+// EventHistory предоставляет историю событий аккаунта
+type EventHistory interface {
+	AccountTimeline(ctx context.Context, userID primitive.ObjectID) ([]TimelineEntry, error)
+}
+
+// SetEventHistory подключает источник истории событий для выгрузки данных.
+// Без него раздел event_history в архиве остается пустым
+func (s *Service) SetEventHistory(history EventHistory) {
+	s.history = history
+}
+
+// DataExport - архив со всеми данными, которые сервис хранит о пользователе
+type DataExport struct {
+	GeneratedAt   time.Time              `json:"generated_at"`
+	Account       ExportedAccount        `json:"account"`
+	Sessions      []ExportedSession      `json:"sessions"`
+	LoginHistory  []ExportedLogin        `json:"login_history"`
+	KnownDevices  []ExportedKnownDevice  `json:"known_devices"`
+	Identities    []*ExternalIdentity    `json:"identities"`
+	Organizations []*OrgMembership       `json:"organizations"`
+	MFAEnrolments []ExportedMFAEnrolment `json:"mfa_enrolments"`
+	EventHistory  []TimelineEntry        `json:"event_history"`
+}
+
+// ExportedAccount - поля аккаунта без хеша пароля и служебных отметок
+type ExportedAccount struct {
+	ID                  string     `json:"id"`
+	Email               string     `json:"email"`
+	Username            string     `json:"username,omitempty"`
+	EmailVerified       bool       `json:"email_verified"`
+	IsMentor            bool       `json:"is_mentor"`
+	IsAdmin             bool       `json:"is_admin"`
+	DeviceToken         string     `json:"device_token"`
+	Profile             Profile    `json:"profile"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+}
+
+// ExportedSession - активная сессия (refresh токен) без значения самого токена
+type ExportedSession struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ExportedLogin - запись истории входов
+type ExportedLogin struct {
+	Time      time.Time `json:"time"`
+	Success   bool      `json:"success"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+}
+
+// ExportedKnownDevice - устройство, вход с которого не вызывает уведомления
+type ExportedKnownDevice struct {
+	DeviceID    string    `json:"device_id,omitempty"`
+	IPRange     string    `json:"ip_range"`
+	Agent       string    `json:"agent"`
+	LastIP      string    `json:"last_ip"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// ExportedMFAEnrolment - подключенный второй фактор без секретов
+type ExportedMFAEnrolment struct {
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RequestDataExport запускает фоновую подготовку архива с данными пользователя.
+// Когда архив готов, на email приходит короткоживущая ссылка на скачивание
+func (s *Service) RequestDataExport(ctx context.Context, userID string) error {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), dataExportTimeout)
+		defer cancel()
+
+		if err := s.generateDataExport(ctx, user); err != nil {
+			log.Printf("[RequestDataExport] Ошибка подготовки архива для пользователя %s: %v", user.ID.Hex(), err)
+		}
+	}()
+	return nil
+}
+
+// generateDataExport собирает архив, сохраняет его и отправляет ссылку на email
+func (s *Service) generateDataExport(ctx context.Context, user *User) error {
+	export, err := s.buildDataExport(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	archive := &DataExportArchive{
+		UserID:    user.ID,
+		Data:      data,
+		ExpiresAt: time.Now().Add(s.settings.DataExportLinkTTL),
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.SaveDataExport(ctx, archive); err != nil {
+		return err
+	}
+
+	token, err := s.generateActionToken(user, purposeDataExport, s.settings.DataExportLinkTTL,
+		jwt.MapClaims{"export_id": archive.ID.Hex()})
+	if err != nil {
+		return err
+	}
+
+	link := s.settings.AppURL + "/export/download?token=" + url.QueryEscape(token)
+	subject, body := dataExportReadyEmail(link, s.settings.DataExportLinkTTL)
+	s.sendEmail(user.Email, subject, body)
+
+	log.Printf("[generateDataExport] Архив для пользователя %s готов", user.ID.Hex())
+	return nil
+}
+
+// buildDataExport собирает данные пользователя из всех хранилищ
+func (s *Service) buildDataExport(ctx context.Context, user *User) (*DataExport, error) {
+	export := &DataExport{
+		GeneratedAt: time.Now(),
+		Account: ExportedAccount{
+			ID:            user.ID.Hex(),
+			Email:         user.Email,
+			Username:      user.Username,
+			EmailVerified: user.EmailVerified,
+			IsMentor:      user.IsMentor,
+			IsAdmin:       user.IsAdmin,
+			DeviceToken:   user.DeviceToken,
+			Profile:       user.Profile,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+		},
+		Sessions:     []ExportedSession{},
+		LoginHistory: []ExportedLogin{},
+		KnownDevices: []ExportedKnownDevice{},
+		Identities:   []*ExternalIdentity{},
+		// Второй фактор сейчас отправляется на email и не хранит привязок, поэтому раздел пуст
+		MFAEnrolments: []ExportedMFAEnrolment{},
+		EventHistory:  []TimelineEntry{},
+	}
+	if !user.DeletionScheduledAt.IsZero() {
+		scheduledAt := user.DeletionScheduledAt
+		export.Account.DeletionScheduledAt = &scheduledAt
+	}
+
+	tokens, err := s.repo.GetUserTokens(ctx, user.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		export.Sessions = append(export.Sessions, ExportedSession{
+			ID:        token.ID.Hex(),
+			CreatedAt: token.CreatedAt,
+			UpdatedAt: token.UpdatedAt,
+			ExpiresAt: token.ExpiresAt,
+		})
+	}
+
+	logins, err := s.repo.GetLoginHistory(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range logins {
+		export.LoginHistory = append(export.LoginHistory, ExportedLogin{
+			Time:      record.CreatedAt,
+			Success:   record.Success,
+			IP:        record.IP,
+			UserAgent: record.UserAgent,
+		})
+	}
+
+	devices, err := s.repo.GetKnownDevices(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, known := range devices {
+		export.KnownDevices = append(export.KnownDevices, ExportedKnownDevice{
+			DeviceID:    known.DeviceID,
+			IPRange:     known.IPRange,
+			Agent:       known.Agent,
+			LastIP:      known.IP,
+			FirstSeenAt: known.FirstSeenAt,
+			LastSeenAt:  known.LastSeenAt,
+		})
+	}
+
+	identities, err := s.repo.GetUserIdentities(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	export.Identities = identities
+
+	memberships, err := s.repo.GetUserMemberships(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	export.Organizations = memberships
+
+	if err := export.addEventHistory(ctx, s.history, user); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// addEventHistory добавляет в архив историю событий аккаунта, если источник подключен.
+// Аккаунты, созданные до записи события регистрации, получают его по дате создания аккаунта
+func (e *DataExport) addEventHistory(ctx context.Context, history EventHistory, user *User) error {
+	if history == nil {
+		return nil
+	}
+	timeline, err := history.AccountTimeline(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	registered := false
+	for _, entry := range timeline {
+		if entry.Event == string(EventAccountRegistered) {
+			registered = true
+			break
+		}
+	}
+	if !registered && !user.CreatedAt.IsZero() {
+		e.EventHistory = append(e.EventHistory, TimelineEntry{
+			Time:        user.CreatedAt,
+			Event:       string(EventAccountRegistered),
+			Description: eventDescriptions[EventAccountRegistered],
+		})
+	}
+	e.EventHistory = append(e.EventHistory, timeline...)
+	return nil
+}
+
+// DownloadDataExport возвращает архив по токену из письма
+func (s *Service) DownloadDataExport(ctx context.Context, token string) ([]byte, error) {
+	claims, err := s.parseActionToken(token, purposeDataExport)
+	if err != nil {
+		log.Printf("[DownloadDataExport] Невалидный токен скачивания: %v", err)
+		return nil, ErrInvalidToken
+	}
+
+	exportID, _ := claims["export_id"].(string)
+	id, err := primitive.ObjectIDFromHex(exportID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	archive, err := s.repo.GetDataExport(ctx, id)
+	if err != nil {
+		log.Printf("[DownloadDataExport] Архив не найден: %v", err)
+		return nil, ErrInvalidToken
+	}
+
+	if archive.UserID.Hex() != claims["user_id"].(string) || time.Now().After(archive.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	return archive.Data, nil
+}
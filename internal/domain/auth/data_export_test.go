@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type stubEventSource []*DomainEvent
+
+func (s stubEventSource) GetUserDomainEvents(_ context.Context, userID primitive.ObjectID) ([]*DomainEvent, error) {
+	var events []*DomainEvent
+	for _, event := range s {
+		if event.UserID == userID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func TestDataExportContainsEventHistory(t *testing.T) {
+	userID, otherID, legacyID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	createdAt := time.Date(2025, 9, 1, 8, 0, 0, 0, time.UTC)
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	history := &domainEventHistory{events: stubEventSource{
+		{Type: EventAccountRegistered, UserID: userID, OccurredAt: createdAt},
+		{Type: EventPasswordChanged, UserID: userID, OccurredAt: at.Add(-time.Hour)},
+		{Type: EventAccountLocked, UserID: userID, Reason: "too many failed logins", OccurredAt: at},
+		{Type: EventProfileChanged, UserID: otherID, OccurredAt: at},
+		{Type: EventUsernameChanged, UserID: userID, OccurredAt: at.Add(time.Hour)},
+		{Type: EventEmailChanged, UserID: legacyID, OccurredAt: at},
+	}}
+
+	tests := []struct {
+		name string
+		user *User
+		want []TimelineEntry
+	}{
+		{"recorded events", &User{ID: userID, CreatedAt: createdAt}, []TimelineEntry{
+			{Time: createdAt, Event: "AccountRegistered", Description: "Регистрация аккаунта"},
+			{Time: at.Add(-time.Hour), Event: "PasswordChanged", Description: "Пароль изменен"},
+			{Time: at, Event: "AccountLocked", Description: "Аккаунт заблокирован: too many failed logins"},
+			{Time: at.Add(time.Hour), Event: "UsernameChanged", Description: "Имя пользователя изменено"},
+		}},
+		{"registration before event recording", &User{ID: legacyID, CreatedAt: createdAt}, []TimelineEntry{
+			{Time: createdAt, Event: "AccountRegistered", Description: "Регистрация аккаунта"},
+			{Time: at, Event: "EmailChanged", Description: "Email изменен"},
+		}},
+	}
+	for _, tt := range tests {
+		export := &DataExport{EventHistory: []TimelineEntry{}}
+		if err := export.addEventHistory(context.Background(), history, tt.user); err != nil {
+			t.Fatal(err)
+		}
+		if len(export.EventHistory) != len(tt.want) {
+			t.Fatalf("%s: event_history = %+v, want %+v", tt.name, export.EventHistory, tt.want)
+		}
+		for i := range tt.want {
+			if export.EventHistory[i] != tt.want[i] {
+				t.Errorf("%s: event_history[%d] = %+v, want %+v", tt.name, i, export.EventHistory[i], tt.want[i])
+			}
+		}
+	}
+}
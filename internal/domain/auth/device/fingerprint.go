@@ -0,0 +1,102 @@
+package device
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strings"
+)
+
+// Fingerprint - признаки, по которым вход узнается как вход с уже известного устройства.
+// IP сводится к подсети, а User-Agent к браузеру и ОС, чтобы смена адреса у провайдера
+// или обновление браузера не считались новым устройством
+type Fingerprint struct {
+	// DeviceID - идентификатор, который присылает клиент, может быть пустым
+	DeviceID string
+	// IPRange - подсеть /24 для IPv4 и /48 для IPv6
+	IPRange string
+	// Agent - браузер и ОС, например "Chrome on Windows"
+	Agent string
+}
+
+// New строит отпечаток по данным клиента
+func New(ip, userAgent, deviceID string) Fingerprint {
+	return Fingerprint{
+		DeviceID: strings.TrimSpace(deviceID),
+		IPRange:  IPRange(ip),
+		Agent:    AgentFamily(userAgent),
+	}
+}
+
+// Key возвращает ключ сочетания признаков. Новый идентификатор устройства или новая подсеть
+// дают новый ключ
+func (f Fingerprint) Key() string {
+	sum := sha256.Sum256([]byte(f.DeviceID + "\x00" + f.IPRange + "\x00" + f.Agent))
+	return hex.EncodeToString(sum[:16])
+}
+
+// IPRange возвращает подсеть адреса или пустую строку для неразобранного адреса
+func IPRange(ip string) string {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// Порядок важен: Edge и Opera содержат "Chrome", а Chrome содержит "Safari"
+var browsers = []struct{ marker, name string }{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"YaBrowser/", "Yandex Browser"},
+	{"Firefox/", "Firefox"},
+	{"Chrome/", "Chrome"},
+	{"CriOS/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"okhttp/", "Android app"},
+	{"Dart/", "Mobile app"},
+	{"curl/", "curl"},
+}
+
+var systems = []struct{ marker, name string }{
+	{"Android", "Android"},
+	{"iPhone", "iOS"},
+	{"iPad", "iOS"},
+	{"Windows", "Windows"},
+	{"Mac OS X", "macOS"},
+	{"Macintosh", "macOS"},
+	{"CrOS", "ChromeOS"},
+	{"Linux", "Linux"},
+}
+
+// AgentFamily сводит User-Agent к браузеру и ОС, версии отбрасываются
+func AgentFamily(userAgent string) string {
+	if strings.TrimSpace(userAgent) == "" {
+		return "unknown"
+	}
+	browser, system := "", ""
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.marker) {
+			browser = b.name
+			break
+		}
+	}
+	for _, s := range systems {
+		if strings.Contains(userAgent, s.marker) {
+			system = s.name
+			break
+		}
+	}
+	switch {
+	case browser == "" && system == "":
+		return "other"
+	case system == "":
+		return browser
+	case browser == "":
+		return system
+	}
+	return browser + " on " + system
+}
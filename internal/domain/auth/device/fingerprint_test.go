@@ -0,0 +1,53 @@
+package device
+
+import "testing"
+
+func TestIPRange(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.77":        "203.0.113.0/24",
+		"::ffff:203.0.113.77": "203.0.113.0/24",
+		"2001:db8:abcd:12::1": "2001:db8:abcd::/48",
+		"not an ip":           "",
+		"":                    "",
+	}
+	for ip, want := range tests {
+		if got := IPRange(ip); got != want {
+			t.Errorf("IPRange(%q) = %q, want %q", ip, got, want)
+		}
+	}
+}
+
+func TestAgentFamily(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36":           "Chrome on Windows",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0": "Edge on Windows",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/604.1": "Safari on iOS",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0":                                                    "Firefox on Linux",
+		"okhttp/4.12.0": "Android app",
+		"":              "unknown",
+		"SomeBot":       "other",
+	}
+	for ua, want := range tests {
+		if got := AgentFamily(ua); got != want {
+			t.Errorf("AgentFamily(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
+
+func TestKeyIgnoresMinorChanges(t *testing.T) {
+	base := New("203.0.113.7", "Mozilla/5.0 (Windows NT 10.0) Chrome/120.0 Safari/537.36", "")
+
+	same := New("203.0.113.200", "Mozilla/5.0 (Windows NT 10.0) Chrome/121.0 Safari/537.36", "")
+	if same.Key() != base.Key() {
+		t.Error("same subnet and browser update should keep the key")
+	}
+	for name, other := range map[string]Fingerprint{
+		"new subnet": New("198.51.100.7", "Mozilla/5.0 (Windows NT 10.0) Chrome/120.0 Safari/537.36", ""),
+		"new device": New("203.0.113.7", "Mozilla/5.0 (Windows NT 10.0) Chrome/120.0 Safari/537.36", "device-1"),
+		"new agent":  New("203.0.113.7", "Mozilla/5.0 (X11; Linux x86_64) Firefox/121.0", ""),
+	} {
+		if other.Key() == base.Key() {
+			t.Errorf("%s should change the key", name)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DomainEventType - тип доменного события аккаунта
+type DomainEventType string
+
+const (
+	EventAccountDisabled     DomainEventType = "AccountDisabled"
+	EventAccountEnabled      DomainEventType = "AccountEnabled"
+	EventAccountLocked       DomainEventType = "AccountLocked"
+	EventAccountUnlocked     DomainEventType = "AccountUnlocked"
+	EventAccountBanned       DomainEventType = "AccountBanned"
+	EventAccountUnbanned     DomainEventType = "AccountUnbanned"
+	EventPasswordResetForced DomainEventType = "PasswordResetForced"
+	EventProfileChanged      DomainEventType = "ProfileChanged"
+	EventUsernameChanged     DomainEventType = "UsernameChanged"
+	EventIdentityLinked      DomainEventType = "IdentityLinked"
+	EventIdentityUnlinked    DomainEventType = "IdentityUnlinked"
+	EventOrganizationCreated DomainEventType = "OrganizationCreated"
+	EventOrgMemberInvited    DomainEventType = "OrgMemberInvited"
+	EventOrgMemberJoined     DomainEventType = "OrgMemberJoined"
+	EventOrgMemberRemoved    DomainEventType = "OrgMemberRemoved"
+	EventOrgSSOConfigured    DomainEventType = "OrgSSOConfigured"
+
+	EventOrgOwnershipTransferred DomainEventType = "OrgOwnershipTransferred"
+
+	// События жизненного цикла аккаунта попадают в журнал аудита отдельно, в domain_events
+	// они записываются для истории аккаунта
+	EventAccountRegistered        DomainEventType = "AccountRegistered"
+	EventEmailVerified            DomainEventType = "EmailVerified"
+	EventPasswordChanged          DomainEventType = "PasswordChanged"
+	EventPasswordReset            DomainEventType = "PasswordReset"
+	EventEmailChanged             DomainEventType = "EmailChanged"
+	EventEmailChangeReverted      DomainEventType = "EmailChangeReverted"
+	EventAccountDeletionScheduled DomainEventType = "AccountDeletionScheduled"
+	EventAccountDeletionCancelled DomainEventType = "AccountDeletionCancelled"
+
+	EventSAMLServiceProviderRegistered DomainEventType = "SAMLServiceProviderRegistered"
+	EventSAMLServiceProviderDeleted    DomainEventType = "SAMLServiceProviderDeleted"
+
+	// EventSuspiciousLoginReported - пользователь сообщил, что вход с нового устройства выполнил не он
+	EventSuspiciousLoginReported DomainEventType = "SuspiciousLoginReported"
+)
+
+// DomainEvent - запись журнала доменных событий. Журнал только дополняется
+// и служит источником для аудита действий с аккаунтами
+type DomainEvent struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	Type   DomainEventType    `bson:"type"`
+	UserID primitive.ObjectID `bson:"user_id"`
+	// ActorID - кто выполнил действие, для действий самого пользователя совпадает с UserID
+	ActorID    primitive.ObjectID     `bson:"actor_id"`
+	Reason     string                 `bson:"reason,omitempty"`
+	Data       map[string]interface{} `bson:"data,omitempty"`
+	OccurredAt time.Time              `bson:"occurred_at"`
+}
+
+// eventDescriptions - описания событий для истории аккаунта в выгрузке данных
+var eventDescriptions = map[DomainEventType]string{
+	EventAccountRegistered:        "Регистрация аккаунта",
+	EventEmailVerified:            "Email подтвержден",
+	EventPasswordChanged:          "Пароль изменен",
+	EventPasswordReset:            "Пароль восстановлен",
+	EventEmailChanged:             "Email изменен",
+	EventEmailChangeReverted:      "Смена email отменена",
+	EventAccountDeletionScheduled: "Запланировано удаление аккаунта",
+	EventAccountDeletionCancelled: "Удаление аккаунта отменено",
+	EventAccountDisabled:          "Аккаунт отключен",
+	EventAccountEnabled:           "Аккаунт включен",
+	EventAccountLocked:            "Аккаунт заблокирован",
+	EventAccountUnlocked:          "Аккаунт разблокирован",
+	EventAccountBanned:            "Аккаунт забанен",
+	EventAccountUnbanned:          "Бан аккаунта снят",
+	EventPasswordResetForced:      "Требуется смена пароля",
+	EventProfileChanged:           "Профиль изменен",
+	EventUsernameChanged:          "Имя пользователя изменено",
+	EventIdentityLinked:           "Привязан внешний провайдер",
+	EventIdentityUnlinked:         "Внешний провайдер отвязан",
+	EventOrganizationCreated:      "Создана организация",
+	EventOrgMemberInvited:         "Приглашение в организацию",
+	EventOrgMemberJoined:          "Вступление в организацию",
+	EventOrgMemberRemoved:         "Исключение из организации",
+	EventOrgSSOConfigured:         "Настроен корпоративный вход организации",
+	EventOrgOwnershipTransferred:  "Передача владения организацией",
+	EventSuspiciousLoginReported:  "Сообщение о чужом входе",
+}
+
+// eventSource - хранилище доменных событий, из которого строится история аккаунта
+type eventSource interface {
+	GetUserDomainEvents(ctx context.Context, userID primitive.ObjectID) ([]*DomainEvent, error)
+}
+
+// domainEventHistory строит историю аккаунта из журнала доменных событий
+type domainEventHistory struct {
+	events eventSource
+}
+
+// NewDomainEventHistory возвращает историю событий аккаунта из коллекции domain_events
+func NewDomainEventHistory(repo *Repository) EventHistory {
+	return &domainEventHistory{events: repo}
+}
+
+// AccountTimeline возвращает события аккаунта в порядке их появления
+func (h *domainEventHistory) AccountTimeline(ctx context.Context, userID primitive.ObjectID) ([]TimelineEntry, error) {
+	events, err := h.events.GetUserDomainEvents(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := make([]TimelineEntry, 0, len(events))
+	for _, event := range events {
+		description, ok := eventDescriptions[event.Type]
+		if !ok {
+			description = string(event.Type)
+		}
+		if event.Reason != "" {
+			description += ": " + event.Reason
+		}
+		timeline = append(timeline, TimelineEntry{
+			Time:        event.OccurredAt,
+			Event:       string(event.Type),
+			Description: description,
+		})
+	}
+	return timeline, nil
+}
+
+// publishEvent сохраняет доменное событие и дублирует его в журнал аудита.
+// Ошибка записи журнала не отменяет уже выполненное действие
+func (s *Service) publishEvent(ctx context.Context, event *DomainEvent) {
+	s.recordEvent(ctx, event)
+	s.auditEvent(ctx, event)
+}
+
+// recordEvent сохраняет доменное событие без записи в журнал аудита - для действий,
+// которые записываются в журнал аудита сами (регистрация, смена пароля и email)
+func (s *Service) recordEvent(ctx context.Context, event *DomainEvent) {
+	event.OccurredAt = time.Now()
+	if err := s.repo.SaveDomainEvent(ctx, event); err != nil {
+		log.Printf("[publishEvent] Ошибка сохранения события %s для пользователя %s: %v",
+			event.Type, event.UserID.Hex(), err)
+	}
+}
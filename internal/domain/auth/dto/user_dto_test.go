@@ -3,8 +3,6 @@ package dto
 import (
 	"testing"
 
-	"golang.org/x/crypto/bcrypt"
-
 	"kubercode-sso/internal/domain/auth/values"
 
 	"github.com/google/uuid"
@@ -43,5 +41,5 @@ func TestNewUserDTO(t *testing.T) {
 
 // Для сравнения паролей
 func ComparePassword(hashedPassword []byte, password string) error {
-	return bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	return values.PasswordHasher.Verify(string(hashedPassword), password)
 }
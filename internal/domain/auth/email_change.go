@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RequestEmailChange создает ожидающую смену email и отправляет код подтверждения на новый адрес.
+// Email пользователя не меняется до подтверждения кода
+func (s *Service) RequestEmailChange(ctx context.Context, userID string, newEmail, password string) (err error) {
+	defer func() { s.auditResult(ctx, AuditEmailChangeRequested, userID, err) }()
+
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkPassword(ctx, user, password); err != nil {
+		return err
+	}
+
+	existingUser, err := s.repo.GetUserByEmail(ctx, newEmail)
+	if err == nil && existingUser != nil {
+		return ErrUserAlreadyExists
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return err
+	}
+
+	change := &EmailChange{
+		UserID:    user.ID,
+		NewEmail:  newEmail,
+		CodeHash:  s.hashCode(code),
+		ExpiresAt: time.Now().Add(s.settings.EmailChangeTTL),
+	}
+	if err := s.repo.SaveEmailChange(ctx, change); err != nil {
+		return err
+	}
+
+	subject, body := emailChangeCodeEmail(code, s.settings.EmailChangeTTL)
+	s.sendEmail(newEmail, subject, body)
+	return nil
+}
+
+// ConfirmEmailChange применяет ожидающую смену email после проверки кода с нового адреса.
+// Старый адрес получает уведомление со ссылкой для отмены, а текущая сессия - новые токены
+func (s *Service) ConfirmEmailChange(ctx context.Context, userID string, code string) (resp *LoginResponse, err error) {
+	defer func() { s.auditResult(ctx, AuditEmailChanged, userID, err) }()
+
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Попытка расходуется до сравнения кода, иначе параллельные запросы обойдут лимит
+	change, err := s.repo.UseEmailChangeAttempt(ctx, user.ID, s.settings.EmailChangeMaxAttempts)
+	if err != nil {
+		log.Printf("[ConfirmEmailChange] Запрос не найден, код истек или превышено число попыток для пользователя %s: %v", user.ID.Hex(), err)
+		return nil, ErrInvalidCode
+	}
+
+	if !s.checkCode(code, change.CodeHash) {
+		log.Printf("[ConfirmEmailChange] Неверный код для пользователя %s", user.ID.Hex())
+		return nil, ErrInvalidCode
+	}
+
+	// Адрес мог быть занят, пока смена ожидала подтверждения
+	existingUser, err := s.repo.GetUserByEmail(ctx, change.NewEmail)
+	if err == nil && existingUser != nil {
+		_ = s.repo.DeleteEmailChange(ctx, user.ID)
+		return nil, ErrUserAlreadyExists
+	}
+
+	// Токен отмены выпускается для старого адреса до применения изменений
+	revertToken, err := s.generateActionToken(user, purposeEmailChangeRevert, s.settings.EmailChangeRevertTTL,
+		jwt.MapClaims{"new_email": change.NewEmail})
+	if err != nil {
+		return nil, err
+	}
+
+	oldEmail := user.Email
+	user.Email = change.NewEmail
+	// Владение новым адресом подтверждено кодом
+	user.EmailVerified = true
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.DeleteEmailChange(ctx, user.ID); err != nil {
+		log.Printf("[ConfirmEmailChange] Ошибка удаления запроса на смену email: %v", err)
+	}
+
+	// Остальные сессии получат токены с новым email при следующем refresh
+	if err := s.refreshSessionClaims(ctx, user); err != nil {
+		log.Printf("[ConfirmEmailChange] Ошибка обновления claims сессий: %v", err)
+		return nil, err
+	}
+
+	s.recordEvent(ctx, &DomainEvent{Type: EventEmailChanged, UserID: user.ID, ActorID: user.ID})
+
+	revertLink := s.settings.AppURL + "/change-email/revert?token=" + url.QueryEscape(revertToken)
+	subject, body := emailChangedEmail(user.Email, revertLink, s.settings.EmailChangeRevertTTL)
+	s.sendEmail(oldEmail, subject, body)
+
+	return s.issueTokens(ctx, user)
+}
+
+// RevertEmailChange возвращает прежний email по ссылке из уведомления на старый адрес,
+// завершает все сессии и требует восстановить пароль, так как смену мог выполнить
+// злоумышленник, знающий пароль
+func (s *Service) RevertEmailChange(ctx context.Context, token string) (err error) {
+	var user *User
+	defer func() { s.auditAttempt(ctx, AuditEmailChangeReverted, user, err, nil) }()
+
+	claims, err := s.parseActionToken(token, purposeEmailChangeRevert)
+	if err != nil {
+		log.Printf("[RevertEmailChange] Невалидный токен отмены: %v", err)
+		return ErrInvalidToken
+	}
+
+	userID, err := primitive.ObjectIDFromHex(claims["user_id"].(string))
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	user, err = s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("[RevertEmailChange] Пользователь не найден: %v", err)
+		return ErrInvalidToken
+	}
+
+	oldEmail, err := revertedEmail(claims, user)
+	if err != nil {
+		log.Printf("[RevertEmailChange] Email пользователя %s уже изменен повторно", user.ID.Hex())
+		return err
+	}
+
+	existingUser, err := s.repo.GetUserByEmail(ctx, oldEmail)
+	if err == nil && existingUser != nil {
+		return ErrUserAlreadyExists
+	}
+
+	user.Email = oldEmail
+	user.EmailVerified = true
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	user.PasswordResetRequired = true
+	if err := s.repo.SetAccountRestrictions(ctx, user.ID, user.AccountRestrictions); err != nil {
+		log.Printf("[RevertEmailChange] Ошибка сохранения требования смены пароля: %v", err)
+		return err
+	}
+
+	if err := s.revokeAllSessions(ctx, user); err != nil {
+		log.Printf("[RevertEmailChange] Ошибка отзыва сессий: %v", err)
+		return err
+	}
+
+	s.recordEvent(ctx, &DomainEvent{Type: EventEmailChangeReverted, UserID: user.ID, ActorID: user.ID})
+	s.publishEvent(ctx, &DomainEvent{
+		Type:   EventPasswordResetForced,
+		UserID: user.ID,
+		Reason: "email change reverted",
+	})
+
+	link := s.settings.AppURL + "/restore-password?email=" + url.QueryEscape(oldEmail)
+	subject, body := emailChangeRevertedEmail(link)
+	s.sendEmail(oldEmail, subject, body)
+	return nil
+}
+
+// revertedEmail возвращает прежний email из токена отмены. Отменить можно только ту смену,
+// для которой выпущен токен, поэтому текущий email пользователя должен совпадать с новым из токена
+func revertedEmail(claims jwt.MapClaims, user *User) (string, error) {
+	oldEmail, _ := claims["email"].(string)
+	newEmail, _ := claims["new_email"].(string)
+	if oldEmail == "" || newEmail == "" || user.Email != newEmail {
+		return "", ErrInvalidToken
+	}
+	return oldEmail, nil
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRevertEmailChangeToken(t *testing.T) {
+	s := &Service{jwtSecret: []byte("test-secret"), settings: DefaultSettings()}
+	id := primitive.NewObjectID()
+	// Токен отмены выпускается для старого адреса до применения смены
+	old := &User{ID: id, Email: "old@example.com"}
+	extra := jwt.MapClaims{"new_email": "new@example.com"}
+
+	tests := []struct {
+		name         string
+		purpose      string
+		ttl          time.Duration
+		currentEmail string
+		want         error
+	}{
+		{"revert applied change", purposeEmailChangeRevert, s.settings.EmailChangeRevertTTL, "new@example.com", nil},
+		{"email changed again", purposeEmailChangeRevert, s.settings.EmailChangeRevertTTL, "other@example.com", ErrInvalidToken},
+		{"already reverted", purposeEmailChangeRevert, s.settings.EmailChangeRevertTTL, "old@example.com", ErrInvalidToken},
+		{"expired", purposeEmailChangeRevert, -time.Minute, "new@example.com", ErrInvalidToken},
+		{"verification token", purposeEmailVerification, s.settings.EmailChangeRevertTTL, "new@example.com", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		token, err := s.generateActionToken(old, tt.purpose, tt.ttl, extra)
+		if err != nil {
+			t.Fatalf("%s: generateActionToken: %v", tt.name, err)
+		}
+		claims, err := s.parseActionToken(token, purposeEmailChangeRevert)
+		var email string
+		if err == nil {
+			email, err = revertedEmail(claims, &User{ID: id, Email: tt.currentEmail})
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		if err == nil && email != old.Email {
+			t.Errorf("%s: reverted email = %s, want %s", tt.name, email, old.Email)
+		}
+	}
+
+	if _, err := revertedEmail(jwt.MapClaims{"email": "old@example.com"}, &User{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token without new_email: err = %v, want ErrInvalidToken", err)
+	}
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"time"
+)
+
+// emailLayout оборачивает содержимое письма в общий HTML шаблон
+func emailLayout(title, content string) string {
+	return fmt.Sprintf(`<html>
+<body style="font-family: Arial, sans-serif; background-color: #f4f4f4; color: #333333;">
+	<div style="width: 80%%; margin: 20px auto; padding: 20px; background-color: #ffffff; border-radius: 10px;">
+		<h1 style="color: #4CAF50; text-align: center;">%s</h1>
+		%s
+		<p style="text-align: center; font-size: 12px; color: #777777;">Команда KuberCode</p>
+	</div>
+</body>
+</html>`, html.EscapeString(title), content)
+}
+
+// verificationEmail формирует письмо для подтверждения email
+func verificationEmail(link, token string) (string, string) {
+	subject := "Подтверждение email"
+	body := emailLayout(subject, fmt.Sprintf(`
+		<p>Привет!</p>
+		<p>Чтобы подтвердить адрес электронной почты, перейди по ссылке:</p>
+		<p><a href="%s">Подтвердить email</a></p>
+		<p>Или введи код подтверждения в приложении:</p>
+		<p style="word-break: break-all;"><code>%s</code></p>
+		<p>Если ты не регистрировался в KuberCode, просто проигнорируй это письмо.</p>`,
+		html.EscapeString(link), html.EscapeString(token)))
+	return subject, body
+}
+
+// passwordResetEmail формирует письмо с кодом восстановления пароля
+func passwordResetEmail(link, code string, ttl time.Duration) (string, string) {
+	subject := "Восстановление пароля"
+	body := emailLayout(subject, fmt.Sprintf(`
+		<p>Привет!</p>
+		<p>Твой код для восстановления пароля: <b>%s</b></p>
+		<p>Код действует %d мин. Также можно перейти по ссылке: <a href="%s">Восстановить пароль</a></p>
+		<p>Если ты не запрашивал восстановление пароля, просто проигнорируй это письмо.</p>`,
+		html.EscapeString(code), int(ttl.Minutes()), html.EscapeString(link)))
+	return subject, body
+}
+
+// passwordChangedEmail формирует уведомление о смене пароля
+func passwordChangedEmail() (string, string) {
+	subject := "Пароль изменен"
+	body := emailLayout(subject, `
+		<p>Привет!</p>
+		<p>Пароль от твоего аккаунта KuberCode был изменен, все активные сессии завершены.</p>
+		<p>Если это был не ты, срочно восстанови пароль и свяжись с поддержкой.</p>`)
+	return subject, body
+}
+
+// emailChangeCodeEmail формирует письмо с кодом подтверждения нового email
+func emailChangeCodeEmail(code string, ttl time.Duration) (string, string) {
+	subject := "Подтверждение нового email"
+	body := emailLayout(subject, fmt.Sprintf(`
+		<p>Привет!</p>
+		<p>Чтобы привязать этот адрес к аккаунту KuberCode, введи код: <b>%s</b></p>
+		<p>Код действует %d мин.</p>
+		<p>Если ты не запрашивал смену email, просто проигнорируй это письмо.</p>`,
+		html.EscapeString(code), int(ttl.Minutes())))
+	return subject, body
+}
+
+// emailChangedEmail формирует уведомление на старый адрес о смене email со ссылкой для отмены
+func emailChangedEmail(newEmail, revertLink string, ttl time.Duration) (string, string) {
+	subject := "Email аккаунта изменен"
+	body := emailLayout(subject, fmt.Sprintf(`
+		<p>Привет!</p>
+		<p>Email твоего аккаунта KuberCode был изменен на <b>%s</b>.</p>
+		<p>Если это был не ты, отмени изменение в течение %d ч. по ссылке:</p>
+		<p><a href="%s">Это был не я</a></p>`,
+		html.EscapeString(newEmail), int(ttl.Hours()), html.EscapeString(revertLink)))
+	return subject, body
+}
+
+// emailChangeRevertedEmail формирует уведомление об отмене смены email
+func emailChangeRevertedEmail(link string) (string, string) {
+	subject := "Смена email отменена"
+	body := emailLayout(subject, fmt.Sprintf(`
+		<p>Привет!</p>
+		<p>Смена email твоего аккаунта KuberCode отменена, все активные сессии завершены.</p>
+		<p>Тот, кто изменил email, мог знать текущий пароль, поэтому войти с ним больше нельзя. Задай новый пароль: <a href="%s">Восстановить пароль</a></p>`,
+		html.EscapeString(link)))
+	return subject, body
+}
+
+// accountDeletionScheduledEmail формирует уведомление о запланированном удалении аккаунта
+func accountDeletionScheduledEmail(scheduledAt time.Time) (string, string) {
+	subject := "Удаление аккаунта"
+	body := emailLayout(subject, fmt.Sprintf(`
+		<p>Привет!</p>
+		<p>Мы получили запрос на удаление твоего аккаунта KuberCode. Все данные будут безвозвратно удалены %s (UTC).</p>
+		<p>До этого момента удаление можно отменить: войди в аккаунт и отмени удаление в настройках профиля.</p>
+		<p>Если ты не запрашивал удаление, срочно войди в аккаунт, отмени удаление и смени пароль.</p>`,
+		scheduledAt.UTC().Format("02.01.2006 15:04")))
+	return subject, body
+}
+
+// dataExportReadyEmail формирует письмо со ссылкой на архив с данными пользователя
+func dataExportReadyEmail(link string, ttl time.Duration) (string, string) {
+	subject := "Архив с твоими данными готов"
+	body := emailLayout(subject, fmt.Sprintf(`
+		<p>Привет!</p>
+		<p>Мы подготовили архив со всеми данными, которые хранятся о тебе в KuberCode.</p>
+		<p><a href="%s">Скачать архив</a></p>
+		<p>Ссылка действует %d мин. Если ты не запрашивал выгрузку данных, срочно смени пароль.</p>`,
+		html.EscapeString(link), int(ttl.Minutes())))
+	return subject, body
+}
+
+// orgInvitationEmail формирует письмо с приглашением в организацию
+func orgInvitationEmail(orgName string, role OrgRole, link string, ttl time.Duration) (string, string) {
+	subject := "Приглашение в организацию " + orgName
+	body := emailLayout(subject, fmt.Sprintf(`
+		<p>Привет!</p>
+		<p>Тебя пригласили в организацию <b>%s</b> в KuberCode с ролью %s.</p>
+		<p><a href="%s">Принять или отклонить приглашение</a></p>
+		<p>Приглашение действует %d дн. Если ты не ждал приглашения, просто проигнорируй это письмо.</p>`,
+		html.EscapeString(orgName), html.EscapeString(string(role)), html.EscapeString(link), int(ttl.Hours()/24)))
+	return subject, body
+}
+
+// loginLockedEmail формирует уведомление о блокировке входа после неудачных попыток
+func loginLockedEmail(link string, lockedUntil time.Time) (string, string) {
+	subject := "Вход в аккаунт временно заблокирован"
+	body := emailLayout(subject, fmt.Sprintf(`
+		<p>Привет!</p>
+		<p>Кто-то несколько раз подряд ввел неверный пароль от твоего аккаунта KuberCode, поэтому вход заблокирован до %s (UTC).</p>
+		<p>Если это был ты, можно снять блокировку сразу: <a href="%s">Разблокировать вход</a></p>
+		<p>Если это был не ты, рекомендуем восстановить пароль.</p>`,
+		lockedUntil.UTC().Format("02.01.2006 15:04"), html.EscapeString(link)))
+	return subject, body
+}
+
+// breachedPasswordEmail формирует уведомление о том, что пароль найден в утечках
+func breachedPasswordEmail(link string) (string, string) {
+	subject := "Пароль нужно сменить"
+	body := emailLayout(subject, fmt.Sprintf(`
+		<p>Привет!</p>
+		<p>Пароль от твоего аккаунта KuberCode встречается в известных утечках данных других сервисов, поэтому им больше нельзя пользоваться. Все активные сессии завершены.</p>
+		<p>Чтобы снова войти, задай новый пароль: <a href="%s">Восстановить пароль</a></p>
+		<p>Если этот пароль используется где-то еще, смени его и там.</p>`,
+		html.EscapeString(link)))
+	return subject, body
+}
+
+// newDeviceLoginEmail формирует уведомление о входе с нового устройства
+func newDeviceLoginEmail(agent, ip string, at time.Time, reportLink string, ttl time.Duration) (string, string) {
+	subject := "Вход с нового устройства"
+	body := emailLayout(subject, fmt.Sprintf(`
+		<p>Привет!</p>
+		<p>В твой аккаунт KuberCode вошли с устройства, с которого раньше входов не было:</p>
+		<p><b>%s</b>, IP %s, %s (UTC)</p>
+		<p>Если это был ты, ничего делать не нужно.</p>
+		<p>Если это был не ты, нажми на ссылку в течение %d дн.: сессия будет завершена, а пароль нужно будет восстановить.</p>
+		<p><a href="%s">Это был не я</a></p>`,
+		html.EscapeString(agent), html.EscapeString(ip), at.UTC().Format("02.01.2006 15:04"),
+		int(ttl.Hours()/24), html.EscapeString(reportLink)))
+	return subject, body
+}
+
+// suspiciousLoginReportedEmail подтверждает, что после сообщения о чужом входе сессии завершены
+func suspiciousLoginReportedEmail(link string) (string, string) {
+	subject := "Сессии завершены"
+	body := emailLayout(subject, fmt.Sprintf(`
+		<p>Привет!</p>
+		<p>Мы завершили все сессии твоего аккаунта KuberCode, включая вход, о котором ты сообщил.</p>
+		<p>Чтобы снова войти, задай новый пароль: <a href="%s">Восстановить пароль</a></p>
+		<p>Если этот пароль используется где-то еще, смени его и там.</p>`,
+		html.EscapeString(link)))
+	return subject, body
+}
+
+// sendEmail отправляет письмо в фоне, ошибки отправки только логируются
+func (s *Service) sendEmail(to, subject, body string) {
+	if s.mailer == nil {
+		log.Printf("[sendEmail] Отправка писем не настроена, письмо \"%s\" для %s не отправлено", subject, to)
+		return
+	}
+	go func() {
+		if err := s.mailer.SendEmail(to, subject, body); err != nil {
+			log.Printf("[sendEmail] Ошибка отправки письма \"%s\" на %s: %v", subject, to, err)
+		}
+	}()
+}
@@ -0,0 +1,561 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"kubercode/internal/domain/auth/identity"
+)
+
+var (
+	ErrSSONotConfigured   = errors.New("enterprise sso is not configured")
+	ErrSSORequired        = errors.New("organization requires single sign-on for this email domain")
+	ErrSSODomainMismatch  = errors.New("identity provider returned an email outside verified domains")
+	ErrDomainInvalid      = errors.New("invalid domain")
+	ErrDomainTaken        = errors.New("domain is already verified by another organization")
+	ErrDomainNotFound     = errors.New("domain not found")
+	ErrDomainVerification = errors.New("domain verification record not found")
+)
+
+// domainVerificationPrefix - поддомен, в TXT записи которого ищется токен подтверждения домена
+const domainVerificationPrefix = "_kubercode-verification."
+
+// OrgSSOConfig - настройки корпоративного OIDC провайдера организации
+type OrgSSOConfig struct {
+	OrgID        primitive.ObjectID    `bson:"_id" json:"org_id"`
+	Issuer       string                `bson:"issuer" json:"issuer"`
+	ClientID     string                `bson:"client_id" json:"client_id"`
+	ClientSecret string                `bson:"client_secret" json:"-"`
+	AuthURL      string                `bson:"auth_url" json:"-"`
+	TokenURL     string                `bson:"token_url" json:"-"`
+	UserInfoURL  string                `bson:"userinfo_url" json:"-"`
+	Claims       identity.ClaimMapping `bson:"claims" json:"claims"`
+	// RoleMapping - роль в организации для группы пользователя у провайдера
+	RoleMapping map[string]OrgRole `bson:"role_mapping,omitempty" json:"role_mapping,omitempty"`
+	// DefaultRole - роль пользователя, для групп которого нет сопоставления
+	DefaultRole OrgRole `bson:"default_role" json:"default_role"`
+	// EnforceSSO запрещает вход по паролю и через GitHub/Google для подтвержденных доменов организации
+	EnforceSSO bool      `bson:"enforce_sso" json:"enforce_sso"`
+	UpdatedAt  time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// OrgSSOSettings - изменяемые администратором организации настройки провайдера
+type OrgSSOSettings struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Claims       identity.ClaimMapping
+	RoleMapping  map[string]OrgRole
+	DefaultRole  OrgRole
+	EnforceSSO   bool
+}
+
+// OrgDomain - email домен организации. Вход через провайдера организации определяется
+// по подтвержденным доменам, подтверждение - TXT запись с токеном
+type OrgDomain struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	OrgID             primitive.ObjectID `bson:"org_id" json:"org_id"`
+	Domain            string             `bson:"domain" json:"domain"`
+	VerificationToken string             `bson:"verification_token" json:"-"`
+	Verified          bool               `bson:"verified" json:"verified"`
+	VerifiedAt        time.Time          `bson:"verified_at,omitempty" json:"verified_at,omitempty"`
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// DomainVerification - DNS запись, которую нужно создать для подтверждения домена
+type DomainVerification struct {
+	OrgDomain
+	RecordName  string `json:"record_name"`
+	RecordValue string `json:"record_value"`
+}
+
+// SSODiscovery - результат проверки email на вход через провайдера организации
+type SSODiscovery struct {
+	SSO              bool   `json:"sso"`
+	Required         bool   `json:"required"`
+	AuthorizationURL string `json:"authorization_url,omitempty"`
+}
+
+// ConfigureOrgSSO сохраняет настройки провайдера организации. Адреса провайдера
+// определяются по issuer через OIDC discovery
+func (s *Service) ConfigureOrgSSO(ctx context.Context, userID, orgID string, settings OrgSSOSettings) (*OrgSSOConfig, error) {
+	admin, err := s.requireOrgAdmin(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	org := admin.OrgID
+
+	if settings.DefaultRole == "" {
+		settings.DefaultRole = OrgRoleMember
+	}
+	if !isAssignableOrgRole(settings.DefaultRole) {
+		return nil, ErrOrgRoleInvalid
+	}
+	for _, role := range settings.RoleMapping {
+		if !isAssignableOrgRole(role) {
+			return nil, ErrOrgRoleInvalid
+		}
+	}
+
+	existing, err := s.repo.GetOrgSSOConfig(ctx, org)
+	if err != nil && !errors.Is(err, ErrSSONotConfigured) {
+		return nil, err
+	}
+	// Секрет не возвращается клиенту, поэтому пустое значение оставляет сохраненный
+	if settings.ClientSecret == "" && existing != nil {
+		settings.ClientSecret = existing.ClientSecret
+	}
+
+	discovered, err := identity.Discover(ctx, identity.Config{}, settings.Issuer)
+	if err != nil {
+		log.Printf("[ConfigureOrgSSO] Ошибка discovery провайдера %s: %v", settings.Issuer, err)
+		return nil, err
+	}
+
+	cfg := &OrgSSOConfig{
+		OrgID:        org,
+		Issuer:       strings.TrimSuffix(settings.Issuer, "/"),
+		ClientID:     settings.ClientID,
+		ClientSecret: settings.ClientSecret,
+		AuthURL:      discovered.AuthURL,
+		TokenURL:     discovered.TokenURL,
+		UserInfoURL:  discovered.UserInfoURL,
+		Claims:       settings.Claims,
+		RoleMapping:  settings.RoleMapping,
+		DefaultRole:  settings.DefaultRole,
+		EnforceSSO:   settings.EnforceSSO,
+		UpdatedAt:    time.Now(),
+	}
+	if err := s.repo.SaveOrgSSOConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventOrgSSOConfigured,
+		UserID:  admin.UserID,
+		ActorID: admin.UserID,
+		Data:    map[string]interface{}{"org_id": orgID, "issuer": cfg.Issuer, "enforce_sso": cfg.EnforceSSO},
+	})
+	return cfg, nil
+}
+
+// GetOrgSSO возвращает настройки провайдера организации
+func (s *Service) GetOrgSSO(ctx context.Context, userID, orgID string) (*OrgSSOConfig, error) {
+	admin, err := s.requireOrgAdmin(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	org := admin.OrgID
+	return s.repo.GetOrgSSOConfig(ctx, org)
+}
+
+// DeleteOrgSSO отключает вход через провайдера организации
+func (s *Service) DeleteOrgSSO(ctx context.Context, userID, orgID string) error {
+	admin, err := s.requireOrgAdmin(ctx, userID, orgID)
+	if err != nil {
+		return err
+	}
+	org := admin.OrgID
+	return s.repo.DeleteOrgSSOConfig(ctx, org)
+}
+
+// GetOrgDomains возвращает домены организации
+func (s *Service) GetOrgDomains(ctx context.Context, userID, orgID string) ([]*OrgDomain, error) {
+	admin, err := s.requireOrgAdmin(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	org := admin.OrgID
+	return s.repo.GetOrgDomains(ctx, org)
+}
+
+// AddOrgDomain добавляет домен организации и возвращает TXT запись для его подтверждения
+func (s *Service) AddOrgDomain(ctx context.Context, userID, orgID, domain string) (*DomainVerification, error) {
+	admin, err := s.requireOrgAdmin(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	org := admin.OrgID
+
+	domain, err = normalizeDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := generateInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+	orgDomain := &OrgDomain{
+		OrgID:             org,
+		Domain:            domain,
+		VerificationToken: "kubercode-verification=" + token,
+		CreatedAt:         time.Now(),
+	}
+	if err := s.repo.SaveOrgDomain(ctx, orgDomain); err != nil {
+		return nil, err
+	}
+
+	return &DomainVerification{
+		OrgDomain:   *orgDomain,
+		RecordName:  domainVerificationPrefix + domain,
+		RecordValue: orgDomain.VerificationToken,
+	}, nil
+}
+
+// VerifyOrgDomain проверяет TXT запись домена. Подтвержденный домен может принадлежать только одной организации
+func (s *Service) VerifyOrgDomain(ctx context.Context, userID, orgID, domain string) (*OrgDomain, error) {
+	admin, err := s.requireOrgAdmin(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	org := admin.OrgID
+	domain, err = normalizeDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	orgDomain, err := s.repo.GetOrgDomain(ctx, org, domain)
+	if err != nil {
+		return nil, err
+	}
+	if orgDomain.Verified {
+		return orgDomain, nil
+	}
+
+	records, err := s.lookupTXT(ctx, domainVerificationPrefix+domain)
+	if err != nil {
+		log.Printf("[VerifyOrgDomain] Ошибка получения TXT записей домена %s: %v", domain, err)
+		return nil, ErrDomainVerification
+	}
+	found := false
+	for _, record := range records {
+		if strings.TrimSpace(record) == orgDomain.VerificationToken {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrDomainVerification
+	}
+
+	orgDomain.Verified = true
+	orgDomain.VerifiedAt = time.Now()
+	if err := s.repo.SetOrgDomainVerified(ctx, orgDomain.ID, orgDomain.VerifiedAt); err != nil {
+		return nil, err
+	}
+	log.Printf("[VerifyOrgDomain] Домен %s подтвержден организацией %s", domain, orgID)
+	return orgDomain, nil
+}
+
+// RemoveOrgDomain удаляет домен организации
+func (s *Service) RemoveOrgDomain(ctx context.Context, userID, orgID, domain string) error {
+	admin, err := s.requireOrgAdmin(ctx, userID, orgID)
+	if err != nil {
+		return err
+	}
+	org := admin.OrgID
+	domain, err = normalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteOrgDomain(ctx, org, domain)
+}
+
+// DiscoverSSO определяет по домену email, входит ли пользователь через провайдера организации,
+// и в этом случае возвращает адрес авторизации. Ответ не зависит от существования аккаунта
+func (s *Service) DiscoverSSO(ctx context.Context, email string) (*SSODiscovery, error) {
+	cfg, err := s.ssoConfigForEmail(ctx, email)
+	if errors.Is(err, ErrSSONotConfigured) {
+		return &SSODiscovery{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := identity.GenerateState()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := identity.GenerateVerifier()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveOAuthState(ctx, &OAuthState{
+		State:     state,
+		Provider:  ssoProviderName(cfg.OrgID),
+		Verifier:  verifier,
+		OrgID:     cfg.OrgID,
+		ExpiresAt: time.Now().Add(s.settings.OAuthStateTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &SSODiscovery{
+		SSO:              true,
+		Required:         cfg.EnforceSSO,
+		AuthorizationURL: s.ssoProvider(cfg).AuthCodeURL(state, verifier),
+	}, nil
+}
+
+// CompleteSSOLogin завершает вход через провайдера организации. Пользователь при первом
+// входе создается (just-in-time) и добавляется в организацию, токены выпускаются сразу
+// в контексте организации
+func (s *Service) CompleteSSOLogin(ctx context.Context, code, state string) (resp *LoginResponse, err error) {
+	var user *User
+	details := map[string]string{}
+	defer func() { s.auditAttempt(ctx, AuditExternalLogin, user, err, details) }()
+
+	pending, err := s.repo.TakeOAuthState(ctx, state)
+	if err != nil || pending.OrgID.IsZero() || time.Now().After(pending.ExpiresAt) {
+		return nil, ErrInvalidOAuthState
+	}
+	cfg, err := s.repo.GetOrgSSOConfig(ctx, pending.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	details["provider"] = ssoProviderName(cfg.OrgID)
+	provider := s.ssoProvider(cfg)
+	accessToken, err := provider.Exchange(ctx, code, pending.Verifier)
+	if err != nil {
+		log.Printf("[CompleteSSOLogin] Ошибка обмена кода организации %s: %v", cfg.OrgID.Hex(), err)
+		return nil, err
+	}
+	external, err := provider.FetchUser(ctx, accessToken)
+	if err != nil {
+		log.Printf("[CompleteSSOLogin] Ошибка получения пользователя организации %s: %v", cfg.OrgID.Hex(), err)
+		return nil, err
+	}
+	external.Email = strings.ToLower(strings.TrimSpace(external.Email))
+
+	// Провайдер организации может подтверждать только адреса ее доменов, иначе через
+	// свой IdP организация получила бы доступ к чужим аккаунтам
+	if _, err := s.repo.GetVerifiedDomain(ctx, cfg.OrgID, emailDomain(external.Email)); err != nil {
+		log.Printf("[CompleteSSOLogin] Провайдер организации %s вернул email вне ее доменов", cfg.OrgID.Hex())
+		return nil, ErrSSODomainMismatch
+	}
+
+	user, err = s.provisionSSOUser(ctx, cfg, external)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAccountRestrictions(user); err != nil {
+		log.Printf("[CompleteSSOLogin] Вход в аккаунт %s запрещен: %v", user.ID.Hex(), err)
+		s.recordLogin(ctx, user, false)
+		return nil, err
+	}
+
+	membership, err := s.syncSSOMembership(ctx, cfg, user, external.Groups)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err = s.issueTokensWithContext(ctx, user, time.Now(),
+		&OrgContext{OrgID: membership.OrgID, Role: membership.Role})
+	if err != nil {
+		return nil, err
+	}
+	s.recordLogin(ctx, user, true)
+	s.checkNewDevice(ctx, user, resp)
+	return resp, nil
+}
+
+// provisionSSOUser находит аккаунт по привязке или email либо создает его.
+// Email подтвержден владением доменом, поэтому аккаунт с тем же адресом привязывается сразу
+func (s *Service) provisionSSOUser(ctx context.Context, cfg *OrgSSOConfig, external *identity.ExternalUser) (*User, error) {
+	providerName := ssoProviderName(cfg.OrgID)
+
+	linked, err := s.repo.GetIdentity(ctx, providerName, external.Subject)
+	if err == nil {
+		return s.repo.GetUserByID(ctx, linked.UserID)
+	}
+	if !errors.Is(err, ErrIdentityNotLinked) {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserByEmail(ctx, external.Email)
+	switch {
+	case err == nil:
+		if !user.EmailVerified {
+			if err := s.repo.SetEmailVerified(ctx, user.ID); err != nil {
+				return nil, err
+			}
+			user.EmailVerified = true
+		}
+	case errors.Is(err, ErrUserNotFound):
+		user = &User{
+			ID:            primitive.NewObjectID(),
+			Email:         external.Email,
+			EmailVerified: true,
+			Profile:       Profile{DisplayName: external.Name},
+		}
+		if err := s.repo.CreateUser(ctx, user); err != nil {
+			return nil, err
+		}
+		s.recordEvent(ctx, &DomainEvent{
+			Type:    EventAccountRegistered,
+			UserID:  user.ID,
+			ActorID: user.ID,
+			Data:    map[string]interface{}{"method": "sso", "org_id": cfg.OrgID.Hex()},
+		})
+		log.Printf("[provisionSSOUser] Создан аккаунт %s через провайдера организации %s",
+			user.ID.Hex(), cfg.OrgID.Hex())
+	default:
+		return nil, err
+	}
+
+	if err := s.saveIdentity(ctx, user, providerName, external); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// syncSSOMembership добавляет пользователя в организацию и приводит его роль к группам провайдера.
+// Роль владельца провайдером не меняется
+func (s *Service) syncSSOMembership(ctx context.Context, cfg *OrgSSOConfig, user *User, groups []string) (*OrgMembership, error) {
+	role := mapSSORole(cfg, groups)
+
+	membership, err := s.repo.GetMembership(ctx, cfg.OrgID, user.ID)
+	if errors.Is(err, ErrNotOrgMember) {
+		membership = &OrgMembership{OrgID: cfg.OrgID, UserID: user.ID, Role: role, JoinedAt: time.Now()}
+		if err := s.repo.SaveMembership(ctx, membership); err != nil {
+			return nil, err
+		}
+		s.publishEvent(ctx, &DomainEvent{
+			Type:    EventOrgMemberJoined,
+			UserID:  user.ID,
+			ActorID: user.ID,
+			Data:    map[string]interface{}{"org_id": cfg.OrgID.Hex(), "role": string(role), "sso": true},
+		})
+		return membership, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.RoleMapping) > 0 && membership.Role != OrgRoleOwner && membership.Role != role {
+		if err := s.repo.SetMembershipRole(ctx, cfg.OrgID, user.ID, role); err != nil {
+			return nil, err
+		}
+		membership.Role = role
+	}
+	return membership, nil
+}
+
+// mapSSORole выбирает самую высокую роль среди сопоставленных групп пользователя
+func mapSSORole(cfg *OrgSSOConfig, groups []string) OrgRole {
+	role := cfg.DefaultRole
+	for _, group := range groups {
+		if mapped, ok := cfg.RoleMapping[group]; ok && orgRoleRank[mapped] > orgRoleRank[role] {
+			role = mapped
+		}
+	}
+	return role
+}
+
+// checkSSOEnforced возвращает ErrSSORequired, если домен email пользователя обязан входить
+// через провайдера организации. Владелец организации может войти по паролю, чтобы
+// исправить настройки при недоступном провайдере
+func (s *Service) checkSSOEnforced(ctx context.Context, email string, user *User) error {
+	cfg, err := s.ssoConfigForEmail(ctx, email)
+	if err != nil || !cfg.EnforceSSO {
+		return nil
+	}
+	if user != nil {
+		if membership, err := s.repo.GetMembership(ctx, cfg.OrgID, user.ID); err == nil && membership.Role == OrgRoleOwner {
+			return nil
+		}
+	}
+	return ErrSSORequired
+}
+
+// ssoConfigForEmail находит настройки провайдера организации по подтвержденному домену email
+func (s *Service) ssoConfigForEmail(ctx context.Context, email string) (*OrgSSOConfig, error) {
+	domain := emailDomain(email)
+	if domain == "" {
+		return nil, ErrSSONotConfigured
+	}
+	orgDomain, err := s.repo.FindVerifiedDomain(ctx, domain)
+	if err != nil {
+		if errors.Is(err, ErrDomainNotFound) {
+			return nil, ErrSSONotConfigured
+		}
+		return nil, err
+	}
+	return s.repo.GetOrgSSOConfig(ctx, orgDomain.OrgID)
+}
+
+func (s *Service) ssoProvider(cfg *OrgSSOConfig) *identity.Provider {
+	return identity.NewProvider(identity.Config{
+		Name:         ssoProviderName(cfg.OrgID),
+		Kind:         identity.KindOIDC,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  s.settings.SSORedirectURL,
+		AuthURL:      cfg.AuthURL,
+		TokenURL:     cfg.TokenURL,
+		UserInfoURL:  cfg.UserInfoURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		Claims:       cfg.Claims,
+	})
+}
+
+// requireOrgAdmin проверяет, что пользователь - владелец или администратор организации
+func (s *Service) requireOrgAdmin(ctx context.Context, userID, orgID string) (*OrgMembership, error) {
+	membership, _, err := s.getOrgMembership(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if membership.Role != OrgRoleOwner && membership.Role != OrgRoleAdmin {
+		return nil, ErrOrgPermissionDenied
+	}
+	return membership, nil
+}
+
+// ssoProviderName - имя провайдера организации в коллекции identities
+func ssoProviderName(orgID primitive.ObjectID) string {
+	return "sso:" + orgID.Hex()
+}
+
+func isAssignableOrgRole(role OrgRole) bool {
+	_, ok := orgRoleRank[role]
+	return ok && role != OrgRoleOwner
+}
+
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[at+1:]))
+}
+
+func normalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if len(domain) > 253 || !strings.Contains(domain, ".") || strings.ContainsAny(domain, "@/: ") {
+		return "", ErrDomainInvalid
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 {
+			return "", ErrDomainInvalid
+		}
+	}
+	return domain, nil
+}
+
+// defaultLookupTXT получает TXT записи через системный резолвер
+func defaultLookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, err := net.DefaultResolver.LookupTXT(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s: %w", name, err)
+	}
+	return records, nil
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+		err    error
+	}{
+		{" Example.COM. ", "example.com", nil},
+		{"corp.example.com", "corp.example.com", nil},
+		{"localhost", "", ErrDomainInvalid},
+		{"user@example.com", "", ErrDomainInvalid},
+		{"example..com", "", ErrDomainInvalid},
+		{"https://example.com", "", ErrDomainInvalid},
+	}
+	for _, tt := range tests {
+		got, err := normalizeDomain(tt.domain)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("normalizeDomain(%q) = %q, %v, want %q, %v", tt.domain, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestMapSSORole(t *testing.T) {
+	cfg := &OrgSSOConfig{
+		DefaultRole: OrgRoleMember,
+		RoleMapping: map[string]OrgRole{"teachers": OrgRoleMentor, "it-admins": OrgRoleAdmin},
+	}
+
+	tests := []struct {
+		groups []string
+		want   OrgRole
+	}{
+		{nil, OrgRoleMember},
+		{[]string{"students"}, OrgRoleMember},
+		{[]string{"teachers"}, OrgRoleMentor},
+		{[]string{"teachers", "it-admins"}, OrgRoleAdmin},
+	}
+	for _, tt := range tests {
+		if got := mapSSORole(cfg, tt.groups); got != tt.want {
+			t.Errorf("mapSSORole(%v) = %s, want %s", tt.groups, got, tt.want)
+		}
+	}
+}
@@ -15,6 +15,7 @@ const (
 	ChangePassword  = "ChangePassword"
 	RestorePassword = "RestorePassword"
 	SendEmail       = "SendEmail"
+	AccountErased   = "AccountErased"
 )
 
 type RegisterAccountEvent struct {
@@ -96,6 +97,24 @@ func NewRestorePasswordEvent(id uuid.UUID, password values.Password, aggregate e
 	return event, nil
 }
 
+// AccountErasedEvent хранится в открытом виде и не содержит персональных данных:
+// остальные события аккаунта становятся нечитаемыми после удаления ключа агрегата
+type AccountErasedEvent struct {
+	Id uuid.UUID `json:"id"`
+}
+
+func NewAccountErasedEvent(id uuid.UUID, aggregate es.IAggregateRoot) (es.Event, error) {
+	accountErased := &AccountErasedEvent{
+		Id: id,
+	}
+	event := es.NewBaseEvent(aggregate, AccountErased)
+	err := event.SetJsonData(&accountErased)
+	if err != nil {
+		return es.Event{}, err
+	}
+	return event, nil
+}
+
 type SendEmailEvent struct {
 	Email   values.Email `json:"email"`
 	Subject string       `json:"subject"`
@@ -0,0 +1,359 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"kubercode/internal/domain/auth/identity"
+)
+
+var (
+	ErrUnknownProvider       = errors.New("unknown identity provider")
+	ErrInvalidOAuthState     = errors.New("invalid or expired oauth state")
+	ErrExternalEmailMissing  = errors.New("identity provider did not return an email")
+	ErrIdentityLinkRequired  = errors.New("account with this email exists, sign in and link the provider")
+	ErrIdentityAlreadyLinked = errors.New("identity is already linked to another account")
+	ErrIdentityNotLinked     = errors.New("identity is not linked")
+	ErrLastLoginMethod       = errors.New("account must keep at least one login method")
+)
+
+// ExternalIdentity связывает аккаунт с пользователем внешнего провайдера
+type ExternalIdentity struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID   primitive.ObjectID `bson:"user_id" json:"-"`
+	Provider string             `bson:"provider" json:"provider"`
+	// Subject - идентификатор пользователя у провайдера, в отличие от email не меняется
+	Subject   string    `bson:"subject" json:"-"`
+	Email     string    `bson:"email" json:"email"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// OAuthState - незавершенная авторизация через провайдера. Хранится на сервере,
+// чтобы PKCE verifier не проходил через браузер
+type OAuthState struct {
+	State    string `bson:"_id"`
+	Provider string `bson:"provider"`
+	Verifier string `bson:"verifier"`
+	// LinkUserID задан, если пользователь привязывает провайдера к уже открытой сессии
+	LinkUserID primitive.ObjectID `bson:"link_user_id,omitempty"`
+	// OrgID задан для входа через корпоративного провайдера организации
+	OrgID primitive.ObjectID `bson:"org_id,omitempty"`
+	// BindingHash - хеш секрета из HttpOnly cookie браузера, начавшего авторизацию.
+	// Без него state, подсунутый жертве, завершил бы вход в чужой аккаунт
+	BindingHash string    `bson:"binding_hash,omitempty"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// ExternalAuthStart - начало авторизации у внешнего провайдера
+type ExternalAuthStart struct {
+	AuthorizationURL string
+	// Binding сохраняется в HttpOnly cookie и передается в CompleteExternalAuth
+	Binding string
+	// TTL - время, за которое нужно завершить авторизацию
+	TTL time.Duration
+}
+
+// SetIdentityProviders подключает внешних провайдеров входа
+func (s *Service) SetIdentityProviders(providers ...*identity.Provider) {
+	s.providers = make(map[string]*identity.Provider, len(providers))
+	for _, provider := range providers {
+		s.providers[provider.Name()] = provider
+	}
+}
+
+// IdentityProviders возвращает имена подключенных провайдеров
+func (s *Service) IdentityProviders() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// StartExternalLogin возвращает адрес авторизации у провайдера для входа или регистрации
+func (s *Service) StartExternalLogin(ctx context.Context, providerName string) (*ExternalAuthStart, error) {
+	return s.startExternalAuth(ctx, providerName, primitive.NilObjectID)
+}
+
+// StartIdentityLink возвращает адрес авторизации у провайдера для привязки к аккаунту пользователя
+func (s *Service) StartIdentityLink(ctx context.Context, userID, providerName string) (*ExternalAuthStart, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return s.startExternalAuth(ctx, providerName, id)
+}
+
+func (s *Service) startExternalAuth(ctx context.Context, providerName string, linkUserID primitive.ObjectID) (*ExternalAuthStart, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	state, err := identity.GenerateState()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := identity.GenerateVerifier()
+	if err != nil {
+		return nil, err
+	}
+	binding, err := identity.GenerateState()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SaveOAuthState(ctx, &OAuthState{
+		State:       state,
+		Provider:    providerName,
+		Verifier:    verifier,
+		LinkUserID:  linkUserID,
+		BindingHash: s.hashCode(binding),
+		ExpiresAt:   time.Now().Add(s.settings.OAuthStateTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &ExternalAuthStart{
+		AuthorizationURL: provider.AuthCodeURL(state, verifier),
+		Binding:          binding,
+		TTL:              s.settings.OAuthStateTTL,
+	}, nil
+}
+
+// CompleteExternalAuth завершает авторизацию у провайдера. При входе находит аккаунт по
+// привязке или подтвержденному email либо создает новый, при привязке добавляет провайдера
+// к аккаунту, начавшему авторизацию. В обоих случаях выпускает токены.
+// binding - секрет из cookie браузера, начавшего авторизацию. callerID - пользователь
+// аутентифицированного запроса, привязка завершается только им самим
+func (s *Service) CompleteExternalAuth(ctx context.Context, providerName, code, state, binding, callerID string) (resp *LoginResponse, err error) {
+	var user *User
+	defer func() { s.auditAttempt(ctx, AuditExternalLogin, user, err, map[string]string{"provider": providerName}) }()
+
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	// Состояние одноразовое: повторное использование кода с тем же state невозможно
+	pending, err := s.repo.TakeOAuthState(ctx, state)
+	if err != nil || pending.Provider != providerName || time.Now().After(pending.ExpiresAt) {
+		return nil, ErrInvalidOAuthState
+	}
+	// state должен вернуться в тот же браузер, который начал авторизацию
+	if pending.BindingHash == "" || !s.checkCode(binding, pending.BindingHash) {
+		log.Printf("[CompleteExternalAuth] state провайдера %s не привязан к этому браузеру", providerName)
+		return nil, ErrInvalidOAuthState
+	}
+	if !pending.LinkUserID.IsZero() && callerID != pending.LinkUserID.Hex() {
+		log.Printf("[CompleteExternalAuth] Привязку к аккаунту %s завершает другой пользователь", pending.LinkUserID.Hex())
+		return nil, ErrInvalidOAuthState
+	}
+
+	accessToken, err := provider.Exchange(ctx, code, pending.Verifier)
+	if err != nil {
+		log.Printf("[CompleteExternalAuth] Ошибка обмена кода провайдера %s: %v", providerName, err)
+		return nil, err
+	}
+	external, err := provider.FetchUser(ctx, accessToken)
+	if err != nil {
+		log.Printf("[CompleteExternalAuth] Ошибка получения пользователя провайдера %s: %v", providerName, err)
+		return nil, err
+	}
+	external.Email = strings.ToLower(strings.TrimSpace(external.Email))
+
+	if pending.LinkUserID.IsZero() {
+		user, err = s.resolveExternalUser(ctx, providerName, external)
+	} else {
+		user, err = s.linkExternalIdentity(ctx, pending.LinkUserID, providerName, external)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Для доменов с обязательным корпоративным входом GitHub и Google не должны его обходить
+	if err := s.checkSSOEnforced(ctx, user.Email, user); err != nil {
+		return nil, err
+	}
+
+	if err := checkAccountRestrictions(user); err != nil {
+		log.Printf("[CompleteExternalAuth] Вход в аккаунт %s запрещен: %v", user.ID.Hex(), err)
+		s.recordLogin(ctx, user, false)
+		return nil, err
+	}
+	if err := s.checkEmailVerified(user); err != nil {
+		return nil, err
+	}
+
+	resp, err = s.issueTokens(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	s.recordLogin(ctx, user, true)
+	s.checkNewDevice(ctx, user, resp)
+	return resp, nil
+}
+
+// resolveExternalUser находит или создает аккаунт для пользователя провайдера
+func (s *Service) resolveExternalUser(ctx context.Context, providerName string, external *identity.ExternalUser) (*User, error) {
+	linked, err := s.repo.GetIdentity(ctx, providerName, external.Subject)
+	if err == nil {
+		return s.repo.GetUserByID(ctx, linked.UserID)
+	}
+	if !errors.Is(err, ErrIdentityNotLinked) {
+		return nil, err
+	}
+
+	if external.Email == "" {
+		return nil, ErrExternalEmailMissing
+	}
+
+	user, err := s.repo.GetUserByEmail(ctx, external.Email)
+	switch {
+	case err == nil:
+		// Автоматическая привязка возможна, только если email подтвержден с обеих сторон.
+		// Иначе владелец неподтвержденного аккаунта мог бы получить доступ к чужому входу через провайдера
+		if !external.EmailVerified || !user.EmailVerified {
+			return nil, ErrIdentityLinkRequired
+		}
+	case errors.Is(err, ErrUserNotFound):
+		user = &User{
+			ID:            primitive.NewObjectID(),
+			Email:         external.Email,
+			EmailVerified: external.EmailVerified,
+			Profile:       Profile{DisplayName: external.Name},
+		}
+		if strings.HasPrefix(external.AvatarURL, "https://") {
+			user.Profile.AvatarURL = external.AvatarURL
+		}
+		if err := s.repo.CreateUser(ctx, user); err != nil {
+			return nil, err
+		}
+		s.recordEvent(ctx, &DomainEvent{
+			Type:    EventAccountRegistered,
+			UserID:  user.ID,
+			ActorID: user.ID,
+			Data:    map[string]interface{}{"method": providerName},
+		})
+		if !user.EmailVerified {
+			if err := s.sendVerificationEmail(ctx, user); err != nil {
+				log.Printf("[resolveExternalUser] Ошибка отправки письма подтверждения: %v", err)
+			}
+		}
+		log.Printf("[resolveExternalUser] Создан аккаунт %s через провайдера %s", user.ID.Hex(), providerName)
+	default:
+		return nil, err
+	}
+
+	if err := s.saveIdentity(ctx, user, providerName, external); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// linkExternalIdentity привязывает провайдера к аккаунту, начавшему авторизацию
+func (s *Service) linkExternalIdentity(ctx context.Context, userID primitive.ObjectID, providerName string,
+	external *identity.ExternalUser) (*User, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	linked, err := s.repo.GetIdentity(ctx, providerName, external.Subject)
+	if err == nil {
+		if linked.UserID != user.ID {
+			return nil, ErrIdentityAlreadyLinked
+		}
+		return user, nil
+	}
+	if !errors.Is(err, ErrIdentityNotLinked) {
+		return nil, err
+	}
+
+	if err := s.saveIdentity(ctx, user, providerName, external); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *Service) saveIdentity(ctx context.Context, user *User, providerName string, external *identity.ExternalUser) error {
+	if err := s.repo.SaveIdentity(ctx, &ExternalIdentity{
+		UserID:    user.ID,
+		Provider:  providerName,
+		Subject:   external.Subject,
+		Email:     external.Email,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventIdentityLinked,
+		UserID:  user.ID,
+		ActorID: user.ID,
+		Data:    map[string]interface{}{"provider": providerName},
+	})
+	log.Printf("[saveIdentity] Провайдер %s привязан к аккаунту %s", providerName, user.ID.Hex())
+	return nil
+}
+
+// GetIdentities возвращает привязанных к аккаунту провайдеров
+func (s *Service) GetIdentities(ctx context.Context, userID string) ([]*ExternalIdentity, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return s.repo.GetUserIdentities(ctx, id)
+}
+
+// UnlinkIdentity отвязывает провайдера. У аккаунта должен остаться пароль или другой провайдер
+func (s *Service) UnlinkIdentity(ctx context.Context, userID, providerName string) error {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	identities, err := s.repo.GetUserIdentities(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	linked := false
+	for _, ident := range identities {
+		if ident.Provider == providerName {
+			linked = true
+		}
+	}
+	if !linked {
+		return ErrIdentityNotLinked
+	}
+
+	methods := len(identities)
+	if user.Password != "" {
+		methods++
+	}
+	if methods <= 1 {
+		return ErrLastLoginMethod
+	}
+
+	if err := s.repo.DeleteIdentity(ctx, user.ID, providerName); err != nil {
+		return err
+	}
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventIdentityUnlinked,
+		UserID:  user.ID,
+		ActorID: user.ID,
+		Data:    map[string]interface{}{"provider": providerName},
+	})
+	return nil
+}
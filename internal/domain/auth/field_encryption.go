@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunReencryptionWorker после ротации мастер-ключа перешифровывает поля аккаунтов текущим ключом:
+// сразу при запуске и затем с периодом FieldReencryptionInterval до отмены контекста.
+// Старый мастер-ключ можно убрать из конфигурации, когда проход завершится без ошибок
+func (s *Service) RunReencryptionWorker(ctx context.Context) {
+	s.reencryptAccounts(ctx)
+
+	ticker := time.NewTicker(s.settings.FieldReencryptionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reencryptAccounts(ctx)
+		}
+	}
+}
+
+func (s *Service) reencryptAccounts(ctx context.Context) {
+	updated, failed, err := s.repo.ReencryptAccounts(ctx)
+	if err != nil {
+		log.Printf("[RunReencryptionWorker] Ошибка перешифрования аккаунтов: %v", err)
+	}
+	if updated > 0 || failed > 0 {
+		log.Printf("[RunReencryptionWorker] Перешифровано аккаунтов: %d, не удалось расшифровать: %d", updated, failed)
+	}
+}
@@ -0,0 +1,217 @@
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidKeyring    = errors.New("invalid field encryption keyring")
+	ErrUnknownKeyVersion = errors.New("unknown master key version")
+	ErrMalformed         = errors.New("malformed encrypted value")
+)
+
+// prefix отличает зашифрованное значение от открытого, записанного до включения шифрования
+const prefix = "enc:"
+
+const keySize = 32
+
+// Keyring - мастер-ключи шифрования полей по версиям и ключ слепого индекса.
+//
+// Значение шифруется своим случайным ключом данных (AES-256-GCM), а ключ данных - мастер-ключом
+// текущей версии (envelope encryption). Зашифрованное значение хранит версию мастер-ключа, поэтому
+// после ротации старые значения читаются старым ключом, пока фоновая задача их не перешифрует
+type Keyring struct {
+	current int
+	masters map[int]cipher.AEAD
+	index   []byte
+}
+
+// ParseKeyring разбирает ключи в формате "1:<base64>,2:<base64>,index:<base64>", элементы
+// разделяются запятыми или переводами строк. Ключи - 32 байта в base64. Текущим считается ключ
+// с наибольшей версией. Ключ слепого индекса не ротируется: от него зависят значения индекса
+func ParseKeyring(spec string) (*Keyring, error) {
+	k := &Keyring{masters: map[int]cipher.AEAD{}}
+	for _, item := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		item = strings.TrimSpace(item)
+		if item == "" || strings.HasPrefix(item, "#") {
+			continue
+		}
+		name, encoded, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: expected <version>:<key>", ErrInvalidKeyring)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("%w: key %q must be %d bytes in base64", ErrInvalidKeyring, name, keySize)
+		}
+
+		name = strings.TrimSpace(name)
+		if name == "index" {
+			k.index = key
+			continue
+		}
+		version, err := strconv.Atoi(name)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: version %q must be a positive number", ErrInvalidKeyring, name)
+		}
+		if _, exists := k.masters[version]; exists {
+			return nil, fmt.Errorf("%w: duplicate version %d", ErrInvalidKeyring, version)
+		}
+		if k.masters[version], err = newAEAD(key); err != nil {
+			return nil, err
+		}
+		if version > k.current {
+			k.current = version
+		}
+	}
+	if k.current == 0 {
+		return nil, fmt.Errorf("%w: no master keys", ErrInvalidKeyring)
+	}
+	if k.index == nil {
+		return nil, fmt.Errorf("%w: no blind index key", ErrInvalidKeyring)
+	}
+	return k, nil
+}
+
+// CurrentVersion возвращает версию мастер-ключа, которым шифруются новые значения
+func (k *Keyring) CurrentVersion() int {
+	return k.current
+}
+
+// Encrypt шифрует значение поля записи. field и record (идентификатор записи) связывают шифротекст
+// с полем конкретной записи, чтобы значение нельзя было перенести в другое поле или другую запись.
+// Пустое значение остается пустым
+func (k *Keyring) Encrypt(field, record, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	additional := associatedData(field, record)
+	wrapped, err := seal(k.masters[k.current], dataKey, additional)
+	if err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataAEAD, []byte(plaintext), additional)
+	if err != nil {
+		return "", err
+	}
+	payload := append(wrapped, ciphertext...)
+	return prefix + strconv.Itoa(k.current) + ":" + base64.RawStdEncoding.EncodeToString(payload), nil
+}
+
+// Decrypt расшифровывает значение поля записи. Открытое значение, записанное до включения
+// шифрования, возвращается как есть
+func (k *Keyring) Decrypt(field, record, value string) (string, error) {
+	version, payload, encrypted, err := parse(value)
+	if err != nil || !encrypted {
+		return value, err
+	}
+	master, ok := k.masters[version]
+	if !ok {
+		return "", fmt.Errorf("%w: %d", ErrUnknownKeyVersion, version)
+	}
+
+	wrappedSize := master.NonceSize() + keySize + master.Overhead()
+	if len(payload) < wrappedSize {
+		return "", ErrMalformed
+	}
+	additional := associatedData(field, record)
+	dataKey, err := open(master, payload[:wrappedSize], additional)
+	if err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataAEAD, payload[wrappedSize:], additional)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// BlindIndex возвращает слепой индекс значения поля: по нему можно искать точное совпадение,
+// не храня значение открытым. Пустое значение дает пустой индекс
+func (k *Keyring) BlindIndex(field, value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted сообщает, что значение зашифровано
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func parse(value string) (version int, payload []byte, encrypted bool, err error) {
+	if !IsEncrypted(value) {
+		return 0, nil, false, nil
+	}
+	versionPart, encoded, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return 0, nil, true, ErrMalformed
+	}
+	if version, err = strconv.Atoi(versionPart); err != nil {
+		return 0, nil, true, ErrMalformed
+	}
+	if payload, err = base64.RawStdEncoding.DecodeString(encoded); err != nil {
+		return 0, nil, true, ErrMalformed
+	}
+	return version, payload, true, nil
+}
+
+// associatedData собирает дополнительные данные AEAD из имени поля и идентификатора записи
+func associatedData(field, record string) []byte {
+	return []byte(field + "\x00" + record)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal шифрует данные, перед шифротекстом записывается nonce
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, data, additional []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additional)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,95 @@
+package fieldcrypt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k, err := ParseKeyring("1:" + newKey(t) + ",index:" + newKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := k.Encrypt("device_token", "acc-1", "fcm-token-123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "fcm-token-123") {
+		t.Fatalf("value is not encrypted: %q", encrypted)
+	}
+	other, _ := k.Encrypt("device_token", "acc-1", "fcm-token-123")
+	if other == encrypted {
+		t.Error("encryption should be randomized")
+	}
+
+	if got, err := k.Decrypt("device_token", "acc-1", encrypted); err != nil || got != "fcm-token-123" {
+		t.Errorf("Decrypt = %q, %v", got, err)
+	}
+	if _, err := k.Decrypt("phone", "acc-1", encrypted); !errors.Is(err, ErrMalformed) {
+		t.Errorf("value moved to another field should not decrypt, got %v", err)
+	}
+	if _, err := k.Decrypt("device_token", "acc-2", encrypted); !errors.Is(err, ErrMalformed) {
+		t.Errorf("value moved to another record should not decrypt, got %v", err)
+	}
+	if got, err := k.Decrypt("device_token", "acc-1", "legacy-plaintext"); err != nil || got != "legacy-plaintext" {
+		t.Errorf("plaintext should pass through, got %q, %v", got, err)
+	}
+	if got, _ := k.Encrypt("device_token", "acc-1", ""); got != "" {
+		t.Errorf("empty value should stay empty, got %q", got)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	v1, index := newKey(t), newKey(t)
+	old, _ := ParseKeyring("1:" + v1 + ",index:" + index)
+	encrypted, _ := old.Encrypt("device_token", "acc-1", "fcm-token-123")
+
+	rotated, err := ParseKeyring("2:" + newKey(t) + "\n1:" + v1 + "\nindex:" + index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.CurrentVersion() != 2 {
+		t.Errorf("CurrentVersion = %d, want 2", rotated.CurrentVersion())
+	}
+	if got, err := rotated.Decrypt("device_token", "acc-1", encrypted); err != nil || got != "fcm-token-123" {
+		t.Errorf("old value should decrypt after rotation, got %q, %v", got, err)
+	}
+	if old.BlindIndex("device_token", "fcm-token-123") != rotated.BlindIndex("device_token", "fcm-token-123") {
+		t.Error("blind index should survive master key rotation")
+	}
+
+	withoutV1, _ := ParseKeyring("2:" + newKey(t) + ",index:" + index)
+	if _, err := withoutV1.Decrypt("device_token", "acc-1", encrypted); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("retired key: err = %v, want ErrUnknownKeyVersion", err)
+	}
+}
+
+func TestParseKeyringErrors(t *testing.T) {
+	key := newKey(t)
+	for _, spec := range []string{
+		"",
+		"1:" + key,
+		"index:" + key,
+		"1:" + key + ",1:" + key + ",index:" + key,
+		"0:" + key + ",index:" + key,
+		"1:c2hvcnQ=,index:" + key,
+		"1" + key,
+	} {
+		if _, err := ParseKeyring(spec); !errors.Is(err, ErrInvalidKeyring) {
+			t.Errorf("ParseKeyring(%q) error = %v, want ErrInvalidKeyring", spec, err)
+		}
+	}
+}
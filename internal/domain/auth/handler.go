@@ -52,7 +52,7 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := h.service.Login(c.Request.Context(), req.Email, req.Password)
+	token, err := h.service.Login(c.Request.Context(), req.Login, req.Password)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -86,7 +86,7 @@ func (h *Handler) Logout(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.service.Logout(c.Request.Context(), req.RefreshToken)
+	resp, err := h.service.Logout(c.Request.Context(), c.GetString("userID"), req.RefreshToken)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,366 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind определяет, как провайдер отдает данные пользователя
+type Kind string
+
+const (
+	// KindGitHub - OAuth2 GitHub: профиль из /user, подтвержденные адреса из /user/emails
+	KindGitHub Kind = "github"
+	// KindOIDC - OpenID Connect провайдер (например, Google): профиль из userinfo endpoint
+	KindOIDC Kind = "oidc"
+)
+
+var (
+	ErrExchangeFailed  = errors.New("failed to exchange authorization code")
+	ErrUserInfoFailed  = errors.New("failed to fetch external user")
+	ErrDiscoveryFailed = errors.New("failed to discover oidc provider")
+)
+
+// Config описывает OAuth2/OIDC провайдера. URL можно переопределить, например для локального mock провайдера
+type Config struct {
+	Name         string
+	Kind         Kind
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	// EmailsURL используется только провайдером GitHub
+	EmailsURL string
+	Scopes    []string
+	// Claims - имена claims userinfo для OIDC провайдера, пустые поля берутся по умолчанию
+	Claims ClaimMapping
+}
+
+// ClaimMapping задает, из каких claims OIDC провайдера читать данные пользователя
+type ClaimMapping struct {
+	Subject       string `bson:"subject,omitempty" json:"subject,omitempty"`
+	Email         string `bson:"email,omitempty" json:"email,omitempty"`
+	EmailVerified string `bson:"email_verified,omitempty" json:"email_verified,omitempty"`
+	Name          string `bson:"name,omitempty" json:"name,omitempty"`
+	Groups        string `bson:"groups,omitempty" json:"groups,omitempty"`
+}
+
+func (m ClaimMapping) withDefaults() ClaimMapping {
+	if m.Subject == "" {
+		m.Subject = "sub"
+	}
+	if m.Email == "" {
+		m.Email = "email"
+	}
+	if m.EmailVerified == "" {
+		m.EmailVerified = "email_verified"
+	}
+	if m.Name == "" {
+		m.Name = "name"
+	}
+	if m.Groups == "" {
+		m.Groups = "groups"
+	}
+	return m
+}
+
+// GitHubConfig возвращает настройки GitHub по умолчанию
+func GitHubConfig(clientID, clientSecret, redirectURL string) Config {
+	return Config{
+		Name:         "github",
+		Kind:         KindGitHub,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		AuthURL:      "https://github.com/login/oauth/authorize",
+		TokenURL:     "https://github.com/login/oauth/access_token",
+		UserInfoURL:  "https://api.github.com/user",
+		EmailsURL:    "https://api.github.com/user/emails",
+		Scopes:       []string{"read:user", "user:email"},
+	}
+}
+
+// GoogleConfig возвращает настройки Google по умолчанию
+func GoogleConfig(clientID, clientSecret, redirectURL string) Config {
+	return Config{
+		Name:         "google",
+		Kind:         KindOIDC,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		AuthURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:     "https://oauth2.googleapis.com/token",
+		UserInfoURL:  "https://openidconnect.googleapis.com/v1/userinfo",
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
+
+// ExternalUser - пользователь внешнего провайдера
+type ExternalUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	AvatarURL     string
+	// Groups - группы пользователя у корпоративного провайдера, если он их передает
+	Groups []string
+}
+
+// Provider выполняет authorization code flow с PKCE для одного провайдера
+type Provider struct {
+	cfg    Config
+	client *http.Client
+}
+
+func NewProvider(cfg Config) *Provider {
+	return &Provider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Name возвращает имя провайдера
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL возвращает адрес страницы авторизации провайдера
+func (p *Provider) AuthCodeURL(state, verifier string) string {
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	return p.cfg.AuthURL + "?" + params.Encode()
+}
+
+// Exchange обменивает код авторизации на access token провайдера
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Без этого заголовка GitHub отвечает в формате form-urlencoded
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := p.doJSON(req, &token); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrExchangeFailed, err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("%w: %s", ErrExchangeFailed, token.Error)
+	}
+	return token.AccessToken, nil
+}
+
+// FetchUser получает данные пользователя по access token провайдера
+func (p *Provider) FetchUser(ctx context.Context, accessToken string) (*ExternalUser, error) {
+	var (
+		user *ExternalUser
+		err  error
+	)
+	switch p.cfg.Kind {
+	case KindGitHub:
+		user, err = p.fetchGitHubUser(ctx, accessToken)
+	default:
+		user, err = p.fetchOIDCUser(ctx, accessToken)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUserInfoFailed, err)
+	}
+	if user.Subject == "" {
+		return nil, fmt.Errorf("%w: empty subject", ErrUserInfoFailed)
+	}
+	return user, nil
+}
+
+func (p *Provider) fetchOIDCUser(ctx context.Context, accessToken string) (*ExternalUser, error) {
+	var claims map[string]interface{}
+	if err := p.get(ctx, p.cfg.UserInfoURL, accessToken, &claims); err != nil {
+		return nil, err
+	}
+
+	mapping := p.cfg.Claims.withDefaults()
+	user := &ExternalUser{
+		Subject:   stringClaim(claims, mapping.Subject),
+		Email:     stringClaim(claims, mapping.Email),
+		Name:      stringClaim(claims, mapping.Name),
+		AvatarURL: stringClaim(claims, "picture"),
+	}
+	// Некоторые провайдеры передают email_verified строкой
+	switch v := claims[mapping.EmailVerified].(type) {
+	case bool:
+		user.EmailVerified = v
+	case string:
+		user.EmailVerified, _ = strconv.ParseBool(v)
+	}
+	switch v := claims[mapping.Groups].(type) {
+	case []interface{}:
+		for _, group := range v {
+			if g, ok := group.(string); ok {
+				user.Groups = append(user.Groups, g)
+			}
+		}
+	case string:
+		user.Groups = []string{v}
+	}
+	return user, nil
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	switch v := claims[name].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// Discover заполняет адреса OIDC провайдера из документа /.well-known/openid-configuration
+func Discover(ctx context.Context, cfg Config, issuer string) (Config, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return cfg, err
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	if err := NewProvider(cfg).doJSON(req, &doc); err != nil {
+		return cfg, fmt.Errorf("%w: %v", ErrDiscoveryFailed, err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return cfg, fmt.Errorf("%w: issuer mismatch %q", ErrDiscoveryFailed, doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.UserinfoEndpoint == "" {
+		return cfg, fmt.Errorf("%w: missing endpoints", ErrDiscoveryFailed)
+	}
+
+	cfg.Kind = KindOIDC
+	cfg.AuthURL = doc.AuthorizationEndpoint
+	cfg.TokenURL = doc.TokenEndpoint
+	cfg.UserInfoURL = doc.UserinfoEndpoint
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return cfg, nil
+}
+
+func (p *Provider) fetchGitHubUser(ctx context.Context, accessToken string) (*ExternalUser, error) {
+	var info struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := p.get(ctx, p.cfg.UserInfoURL, accessToken, &info); err != nil {
+		return nil, err
+	}
+
+	// Публичный email в профиле может быть не подтвержден, поэтому берем основной подтвержденный адрес
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(ctx, p.cfg.EmailsURL, accessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	user := &ExternalUser{Name: info.Name, AvatarURL: info.AvatarURL}
+	if info.ID != 0 {
+		user.Subject = strconv.FormatInt(info.ID, 10)
+	}
+	if user.Name == "" {
+		user.Name = info.Login
+	}
+	for _, e := range emails {
+		if e.Primary {
+			user.Email = e.Email
+			user.EmailVerified = e.Verified
+			break
+		}
+	}
+	return user, nil
+}
+
+func (p *Provider) get(ctx context.Context, endpoint, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return p.doJSON(req, out)
+}
+
+func (p *Provider) doJSON(req *http.Request, out interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL.Host)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// GenerateVerifier создает случайный PKCE code verifier
+func GenerateVerifier() (string, error) {
+	return randomString(32)
+}
+
+// GenerateState создает случайное значение параметра state
+func GenerateState() (string, error) {
+	return randomString(24)
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,145 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// newMockProvider поднимает локальный провайдер, который выдает токен только при верном PKCE verifier
+func newMockProvider(t *testing.T, kind Kind) (*Provider, *string) {
+	t.Helper()
+	var challenge string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("code") != "good-code" ||
+			codeChallenge(r.Form.Get("code_verifier")) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "mock-token"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer mock-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if kind == KindGitHub {
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 42, "login": "gopher"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sub": "g-42", "email": "gopher@example.com", "email_verified": true, "name": "Gopher",
+		})
+	})
+	mux.HandleFunc("/emails", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"email": "old@example.com", "primary": false, "verified": true},
+			{"email": "gopher@example.com", "primary": true, "verified": true},
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	provider := NewProvider(Config{
+		Name:        string(kind),
+		Kind:        kind,
+		ClientID:    "client",
+		RedirectURL: "http://localhost:3000/oauth/callback",
+		AuthURL:     server.URL + "/authorize",
+		TokenURL:    server.URL + "/token",
+		UserInfoURL: server.URL + "/userinfo",
+		EmailsURL:   server.URL + "/emails",
+	})
+	return provider, &challenge
+}
+
+func TestProviderFlow(t *testing.T) {
+	tests := []struct {
+		kind    Kind
+		subject string
+		name    string
+	}{
+		{KindGitHub, "42", "gopher"},
+		{KindOIDC, "g-42", "Gopher"},
+	}
+	for _, tt := range tests {
+		provider, challenge := newMockProvider(t, tt.kind)
+
+		verifier, err := GenerateVerifier()
+		if err != nil {
+			t.Fatal(err)
+		}
+		authURL, err := url.Parse(provider.AuthCodeURL("state", verifier))
+		if err != nil {
+			t.Fatal(err)
+		}
+		*challenge = authURL.Query().Get("code_challenge")
+
+		if _, err := provider.Exchange(context.Background(), "good-code", "wrong-verifier"); err == nil {
+			t.Errorf("%s: exchange with wrong verifier should fail", tt.kind)
+		}
+
+		token, err := provider.Exchange(context.Background(), "good-code", verifier)
+		if err != nil {
+			t.Fatalf("%s: exchange failed: %v", tt.kind, err)
+		}
+		user, err := provider.FetchUser(context.Background(), token)
+		if err != nil {
+			t.Fatalf("%s: fetch user failed: %v", tt.kind, err)
+		}
+		if user.Subject != tt.subject || user.Name != tt.name ||
+			user.Email != "gopher@example.com" || !user.EmailVerified {
+			t.Errorf("%s: unexpected user %+v", tt.kind, user)
+		}
+	}
+}
+
+func TestDiscoverAndClaimMapping(t *testing.T) {
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": issuer + "/authorize",
+			"token_endpoint":         issuer + "/token",
+			"userinfo_endpoint":      issuer + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"oid": "emp-7", "upn": "ivan@corp.example", "email_verified": "true", "roles": []string{"teachers"},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	issuer = server.URL
+
+	cfg, err := Discover(context.Background(), Config{
+		Claims: ClaimMapping{Subject: "oid", Email: "upn", Groups: "roles"},
+	}, issuer+"/")
+	if err != nil {
+		t.Fatalf("discover failed: %v", err)
+	}
+	if cfg.UserInfoURL != issuer+"/userinfo" || cfg.Kind != KindOIDC {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+
+	user, err := NewProvider(cfg).FetchUser(context.Background(), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Subject != "emp-7" || user.Email != "ivan@corp.example" || !user.EmailVerified ||
+		len(user.Groups) != 1 || user.Groups[0] != "teachers" {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	if _, err := Discover(context.Background(), Config{}, "http://127.0.0.1:1"); err == nil {
+		t.Error("discover of unavailable issuer should fail")
+	}
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginRecord - запись истории входов пользователя
+type LoginRecord struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Success   bool               `bson:"success"`
+	IP        string             `bson:"ip"`
+	UserAgent string             `bson:"user_agent"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// recordLogin сохраняет попытку входа в историю. Ошибка записи не должна мешать входу, поэтому только логируется
+func (s *Service) recordLogin(ctx context.Context, user *User, success bool) {
+	client := clientInfoFromContext(ctx)
+	record := &LoginRecord{
+		UserID:    user.ID,
+		Success:   success,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.SaveLoginRecord(ctx, record); err != nil {
+		log.Printf("[recordLogin] Ошибка сохранения истории входа пользователя %s: %v", user.ID.Hex(), err)
+	}
+}
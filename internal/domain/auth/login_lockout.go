@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrTooManyLoginAttempts = errors.New("too many failed login attempts")
+
+const purposeLoginUnlock = "login_unlock"
+
+// LoginThrottledError - вход временно недоступен после неудачных попыток. Возвращается
+// одинаково для существующих и несуществующих аккаунтов, чтобы не раскрывать регистрацию email
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%v, retry after %s", ErrTooManyLoginAttempts, e.RetryAfter)
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrTooManyLoginAttempts
+}
+
+// LoginAttempts - неудачные попытки входа подряд. Для существующего аккаунта ключ - его ID,
+// для несуществующего - хеш логина, поэтому задержки и блокировка работают для них одинаково
+type LoginAttempts struct {
+	Key           string             `bson:"_id"`
+	UserID        primitive.ObjectID `bson:"user_id,omitempty"`
+	Failures      int                `bson:"failures"`
+	LastFailureAt time.Time          `bson:"last_failure_at"`
+	LockedUntil   time.Time          `bson:"locked_until,omitempty"`
+	// ExpiresAt - счетчик сбрасывается, если неудачных попыток не было LoginFailureWindow
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// loginDelay возвращает, сколько осталось ждать до следующей попытки входа. После
+// LoginBackoffAfter неудач подряд задержка удваивается с каждой попыткой до LoginBackoffMax,
+// после LoginLockThreshold вход блокируется на LoginLockDuration
+func loginDelay(settings Settings, attempts *LoginAttempts, now time.Time) time.Duration {
+	if attempts == nil {
+		return 0
+	}
+	if now.Before(attempts.LockedUntil) {
+		return attempts.LockedUntil.Sub(now)
+	}
+	if settings.LoginBackoffAfter <= 0 || attempts.Failures < settings.LoginBackoffAfter {
+		return 0
+	}
+
+	delay := settings.LoginBackoffBase
+	for i := settings.LoginBackoffAfter; i < attempts.Failures && delay < settings.LoginBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > settings.LoginBackoffMax {
+		delay = settings.LoginBackoffMax
+	}
+	if wait := attempts.LastFailureAt.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// checkLoginThrottle возвращает LoginThrottledError, если попытка входа пришла раньше окончания задержки
+func (s *Service) checkLoginThrottle(ctx context.Context, key string) error {
+	attempts, err := s.repo.GetLoginAttempts(ctx, key)
+	if err != nil {
+		// Без журнала попыток вход не блокируется, иначе сбой базы закрыл бы вход всем
+		log.Printf("[checkLoginThrottle] Ошибка чтения попыток входа: %v", err)
+		return nil
+	}
+	if wait := loginDelay(s.settings, attempts, time.Now()); wait > 0 {
+		// Округляем вверх, чтобы клиент не повторил попытку раньше срока
+		return &LoginThrottledError{RetryAfter: (wait + time.Second - 1).Truncate(time.Second)}
+	}
+	return nil
+}
+
+// recordLoginFailure учитывает неудачную попытку и блокирует вход при достижении порога.
+// О блокировке существующего аккаунта пользователь узнает из письма со ссылкой для разблокировки
+func (s *Service) recordLoginFailure(ctx context.Context, key string, user *User) {
+	now := time.Now()
+	var userID primitive.ObjectID
+	if user != nil {
+		userID = user.ID
+	}
+
+	attempts, err := s.repo.AddLoginFailure(ctx, key, userID, now, now.Add(s.settings.LoginFailureWindow))
+	if err != nil {
+		log.Printf("[recordLoginFailure] Ошибка сохранения попытки входа: %v", err)
+		return
+	}
+	if s.settings.LoginLockThreshold <= 0 || attempts.Failures < s.settings.LoginLockThreshold ||
+		now.Before(attempts.LockedUntil) {
+		return
+	}
+
+	lockedUntil := now.Add(s.settings.LoginLockDuration).Truncate(time.Second)
+	if err := s.repo.SetLoginLock(ctx, key, lockedUntil); err != nil {
+		log.Printf("[recordLoginFailure] Ошибка блокировки входа: %v", err)
+		return
+	}
+	if user == nil {
+		return
+	}
+
+	log.Printf("[recordLoginFailure] Вход в аккаунт %s заблокирован до %s после %d неудачных попыток",
+		user.ID.Hex(), lockedUntil.Format(time.RFC3339), attempts.Failures)
+	s.publishEvent(ctx, &DomainEvent{
+		Type:   EventAccountLocked,
+		UserID: user.ID,
+		Reason: "too many failed login attempts",
+		Data:   map[string]interface{}{"locked_until": lockedUntil, "failed_attempts": attempts.Failures},
+	})
+
+	token, err := s.generateActionToken(user, purposeLoginUnlock, s.settings.LoginLockDuration,
+		jwt.MapClaims{"locked_until": lockedUntil.Unix()})
+	if err != nil {
+		log.Printf("[recordLoginFailure] Ошибка генерации ссылки разблокировки: %v", err)
+		return
+	}
+	link := strings.TrimSuffix(s.settings.AppURL, "/") + "/unlock?token=" + url.QueryEscape(token)
+	subject, body := loginLockedEmail(link, lockedUntil)
+	s.sendEmail(user.Email, subject, body)
+}
+
+// resetLoginFailures сбрасывает счетчик неудачных попыток после успешной проверки пароля
+func (s *Service) resetLoginFailures(ctx context.Context, key string) {
+	if err := s.repo.DeleteLoginAttempts(ctx, key); err != nil {
+		log.Printf("[resetLoginFailures] Ошибка сброса попыток входа: %v", err)
+	}
+}
+
+// UnlockLogin снимает блокировку входа по ссылке из письма. Ссылка действует только
+// для той блокировки, о которой сообщало письмо
+func (s *Service) UnlockLogin(ctx context.Context, token string) error {
+	claims, err := s.parseActionToken(token, purposeLoginUnlock)
+	if err != nil {
+		return err
+	}
+	id, err := primitive.ObjectIDFromHex(claims["user_id"].(string))
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	key := userLoginKey(id)
+	attempts, err := s.repo.GetLoginAttempts(ctx, key)
+	if err != nil {
+		return err
+	}
+	lockedUntil, _ := claims["locked_until"].(float64)
+	if attempts == nil || attempts.LockedUntil.Unix() != int64(lockedUntil) {
+		return ErrInvalidToken
+	}
+
+	if err := s.repo.DeleteLoginAttempts(ctx, key); err != nil {
+		return err
+	}
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventAccountUnlocked,
+		UserID:  id,
+		ActorID: id,
+		Reason:  "unlock link",
+	})
+	log.Printf("[UnlockLogin] Пользователь %s снял блокировку входа по ссылке из письма", id.Hex())
+	return nil
+}
+
+// loginAttemptKey - ключ счетчика попыток: ID аккаунта или HMAC логина, если аккаунт не найден
+func (s *Service) loginAttemptKey(user *User, login string) string {
+	if user != nil {
+		return userLoginKey(user.ID)
+	}
+	return "login:" + s.hashCode(strings.ToLower(strings.TrimSpace(login)))
+}
+
+func userLoginKey(id primitive.ObjectID) string {
+	return "user:" + id.Hex()
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginDelay(t *testing.T) {
+	settings := DefaultSettings()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		attempts *LoginAttempts
+		want     time.Duration
+	}{
+		{"no failures", nil, 0},
+		{"below backoff threshold", &LoginAttempts{Failures: 2, LastFailureAt: now}, 0},
+		{"first delay", &LoginAttempts{Failures: 3, LastFailureAt: now}, time.Second},
+		{"doubled delay", &LoginAttempts{Failures: 5, LastFailureAt: now.Add(-time.Second)}, 3 * time.Second},
+		{"capped delay", &LoginAttempts{Failures: 9, LastFailureAt: now}, time.Minute},
+		{"delay elapsed", &LoginAttempts{Failures: 4, LastFailureAt: now.Add(-time.Minute)}, 0},
+		{"locked", &LoginAttempts{LockedUntil: now.Add(10 * time.Minute)}, 10 * time.Minute},
+		{"lock expired", &LoginAttempts{Failures: 1, LockedUntil: now.Add(-time.Second)}, 0},
+	}
+	for _, tt := range tests {
+		if got := loginDelay(settings, tt.attempts, now); got != tt.want {
+			t.Errorf("%s: loginDelay = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
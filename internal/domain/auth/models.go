@@ -12,10 +12,51 @@ type User struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Email       string            `bson:"email" json:"email"`
 	Password    string            `bson:"password" json:"-"`
+	// PasswordHistory - хеши предыдущих паролей, новый пароль не может их повторять
+	PasswordHistory []string `bson:"password_history,omitempty" json:"-"`
 	IsMentor    bool              `bson:"is_mentor" json:"is_mentor"`
 	DeviceToken string            `bson:"device_token" json:"device_token"`
 	CreatedAt   time.Time         `bson:"created_at" json:"created_at"`
 	UpdatedAt   time.Time         `bson:"updated_at" json:"updated_at"`
+
+	EmailVerified      bool      `bson:"email_verified" json:"email_verified"`
+	VerificationSentAt time.Time `bson:"verification_sent_at,omitempty" json:"-"`
+
+	// TokensValidAfter - токены, выпущенные раньше этого момента, считаются отозванными
+	TokensValidAfter time.Time `bson:"tokens_valid_after,omitempty" json:"-"`
+	// ClaimsChangedAt - access токены, выпущенные раньше этого момента, содержат устаревшие claims
+	ClaimsChangedAt time.Time `bson:"claims_changed_at,omitempty" json:"-"`
+
+	// Username - необязательное имя пользователя для входа, UsernameNormalized - оно же в нижнем регистре
+	Username           string    `bson:"username,omitempty" json:"username,omitempty"`
+	UsernameNormalized string    `bson:"username_normalized,omitempty" json:"-"`
+	UsernameChangedAt  time.Time `bson:"username_changed_at,omitempty" json:"-"`
+
+	IsAdmin bool    `bson:"is_admin" json:"is_admin"`
+	Profile Profile `bson:"profile" json:"profile"`
+	// DeletionScheduledAt - момент окончательного удаления аккаунта, если пользователь запросил удаление
+	DeletionScheduledAt time.Time `bson:"deletion_scheduled_at,omitempty" json:"-"`
+
+	// DeviceTokenIndex - слепой индекс DeviceToken для поиска, когда DeviceToken хранится зашифрованным.
+	// EncryptionKeyVersion - версия мастер-ключа, которой зашифрованы поля аккаунта. Оба поля
+	// заполняет репозиторий
+	DeviceTokenIndex     string `bson:"device_token_bidx,omitempty" json:"-"`
+	EncryptionKeyVersion int    `bson:"encryption_key_version,omitempty" json:"-"`
+
+	AccountRestrictions `bson:",inline" json:"-"`
+}
+
+// AccountRestrictions - ограничения, наложенные на аккаунт администратором
+type AccountRestrictions struct {
+	Disabled bool `bson:"disabled"`
+	// DisabledByOrg - организация, каталог которой отключил аккаунт через SCIM
+	DisabledByOrg primitive.ObjectID `bson:"disabled_by_org,omitempty"`
+	// LockedUntil - до этого момента вход в аккаунт запрещен
+	LockedUntil time.Time `bson:"locked_until"`
+	Banned      bool      `bson:"banned"`
+	BanReason   string    `bson:"ban_reason"`
+	// PasswordResetRequired - перед следующим входом пользователь должен восстановить пароль
+	PasswordResetRequired bool `bson:"password_reset_required"`
 }
 
 // UserRepository определяет интерфейс для работы с пользователями
@@ -29,7 +70,8 @@ type UserRepository interface {
 // SignUpRequest представляет запрос на регистрацию
 type SignUpRequest struct {
 	Email       string `json:"email" binding:"required,email"`
-	Password    string `json:"password" binding:"required,min=6"`
+	Username    string `json:"username"`
+	Password    string `json:"password" binding:"required"`
 	IsMentor    bool   `json:"is_mentor"`
 	DeviceToken string `json:"deviceToken" binding:"required"`
 }
@@ -44,22 +86,34 @@ type SignUpResponse struct {
 
 // LoginRequest представляет запрос на вход
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
+	// Login - email или имя пользователя
+	Login    string `json:"login" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
 
 // UserInfo представляет информацию о пользователе
 type UserInfo struct {
-	ID       primitive.ObjectID `json:"id"`
-	Email    string            `json:"email"`
-	IsMentor bool              `json:"is_mentor"`
+	ID                  primitive.ObjectID `json:"id"`
+	Email               string             `json:"email"`
+	Username            string             `json:"username,omitempty"`
+	IsMentor            bool               `json:"is_mentor"`
+	EmailVerified       bool               `json:"email_verified"`
+	IsAdmin             bool               `json:"is_admin,omitempty"`
+	DeletionScheduledAt *time.Time         `json:"deletion_scheduled_at,omitempty"`
+	// OrgID и OrgRole - выбранная организация и роль в ней, пусто в личном контексте
+	OrgID   string  `json:"org_id,omitempty"`
+	OrgRole OrgRole `json:"org_role,omitempty"`
+	// AuthTime - момент последнего ввода учетных данных, известен только при проверке токена
+	AuthTime time.Time `json:"-"`
 }
 
 // LoginResponse представляет ответ на вход
 type LoginResponse struct {
 	AccessToken  string    `json:"access_token"`
-	RefreshToken string    `json:"refresh_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
 	User         UserInfo  `json:"user"`
+	// sessionID - идентификатор сохраненного refresh токена, по нему сессию можно отозвать
+	sessionID primitive.ObjectID
 }
 
 // RefreshTokenRequest представляет запрос на обновление токена
@@ -80,7 +134,7 @@ type LogoutRequest struct {
 // ChangePasswordRequest представляет запрос на изменение пароля
 type ChangePasswordRequest struct {
 	OldPassword string `json:"oldPassword" binding:"required"`
-	NewPassword string `json:"newPassword" binding:"required,min=6"`
+	NewPassword string `json:"newPassword" binding:"required"`
 }
 
 // ChangeEmailRequest представляет запрос на изменение email
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"kubercode/internal/domain/auth/audit"
+	"kubercode/internal/domain/auth/device"
+	"kubercode/internal/domain/auth/push"
+)
+
+// KnownDevice - сочетание устройства, подсети и браузера, с которого пользователь уже входил
+type KnownDevice struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	UserID   primitive.ObjectID `bson:"user_id"`
+	Key      string             `bson:"key"`
+	DeviceID string             `bson:"device_id,omitempty"`
+	IPRange  string             `bson:"ip_range"`
+	Agent    string             `bson:"agent"`
+	// IP - адрес последнего входа
+	IP          string    `bson:"ip"`
+	FirstSeenAt time.Time `bson:"first_seen_at"`
+	LastSeenAt  time.Time `bson:"last_seen_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// SetPushSender включает push-уведомления на DeviceToken аккаунта
+func (s *Service) SetPushSender(sender push.Sender) {
+	s.push = sender
+}
+
+// checkNewDevice после успешного входа запоминает устройство и, если пользователь раньше с него
+// не входил, отправляет уведомление со ссылкой "это был не я". Ошибки только логируются,
+// чтобы не мешать входу
+func (s *Service) checkNewDevice(ctx context.Context, user *User, resp *LoginResponse) {
+	if !s.settings.NewDeviceAlerts {
+		return
+	}
+	client := clientInfoFromContext(ctx)
+	fp := device.New(client.IP, client.UserAgent, client.DeviceID)
+	now := time.Now()
+
+	hadDevices, err := s.repo.HasKnownDevices(ctx, user.ID)
+	if err != nil {
+		log.Printf("[checkNewDevice] Ошибка чтения устройств пользователя %s: %v", user.ID.Hex(), err)
+		return
+	}
+	isNew, err := s.repo.TouchKnownDevice(ctx, &KnownDevice{
+		UserID:      user.ID,
+		Key:         fp.Key(),
+		DeviceID:    fp.DeviceID,
+		IPRange:     fp.IPRange,
+		Agent:       fp.Agent,
+		IP:          client.IP,
+		FirstSeenAt: now,
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(s.settings.KnownDeviceTTL),
+	})
+	if err != nil {
+		log.Printf("[checkNewDevice] Ошибка сохранения устройства пользователя %s: %v", user.ID.Hex(), err)
+		return
+	}
+	// При первом входе сравнивать не с чем, устройство только запоминается
+	if !isNew || !hadDevices {
+		return
+	}
+
+	log.Printf("[checkNewDevice] Вход пользователя %s с нового устройства: %s, %s", user.ID.Hex(), fp.Agent, fp.IPRange)
+	s.recordAudit(ctx, AuditNewDeviceLogin, audit.OutcomeSuccess, user.ID.Hex(), user.ID.Hex(), map[string]string{
+		"agent":    fp.Agent,
+		"ip_range": fp.IPRange,
+	})
+	s.notifyNewDevice(user, fp, client.IP, resp.sessionID, now)
+}
+
+// notifyNewDevice отправляет письмо и push-уведомление о входе с нового устройства
+func (s *Service) notifyNewDevice(user *User, fp device.Fingerprint, ip string, sessionID primitive.ObjectID, at time.Time) {
+	token, err := s.generateActionToken(user, purposeLoginReport, s.settings.NewDeviceReportTTL, jwt.MapClaims{
+		"session_id": sessionID.Hex(),
+		"device_key": fp.Key(),
+		"ip":         ip,
+		"agent":      fp.Agent,
+	})
+	if err != nil {
+		log.Printf("[notifyNewDevice] Ошибка генерации ссылки для пользователя %s: %v", user.ID.Hex(), err)
+		return
+	}
+	link := s.settings.AppURL + "/login-report?token=" + url.QueryEscape(token)
+
+	subject, body := newDeviceLoginEmail(fp.Agent, ip, at, link, s.settings.NewDeviceReportTTL)
+	s.sendEmail(user.Email, subject, body)
+	s.sendPush(user, push.Notification{
+		Title: subject,
+		Body:  fmt.Sprintf("%s, IP %s. Если это был не ты, открой уведомление", fp.Agent, ip),
+		Data:  map[string]string{"type": "new_device_login", "report_url": link},
+	})
+}
+
+// ReportSuspiciousLogin обрабатывает ссылку "это был не я" из уведомления о новом устройстве:
+// удаляет сохраненный refresh токен этого входа (RefreshToken принимает только сохраненные токены),
+// забывает устройство и требует восстановить пароль. Пароль известен злоумышленнику, поэтому
+// завершаются и все остальные сессии, а access токены отзываются по времени выпуска
+func (s *Service) ReportSuspiciousLogin(ctx context.Context, token string) error {
+	claims, err := s.parseActionToken(token, purposeLoginReport)
+	if err != nil {
+		log.Printf("[ReportSuspiciousLogin] Невалидный токен: %v", err)
+		return ErrInvalidToken
+	}
+
+	userID, err := primitive.ObjectIDFromHex(claims["user_id"].(string))
+	if err != nil {
+		return ErrInvalidToken
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("[ReportSuspiciousLogin] Пользователь не найден: %v", err)
+		return ErrInvalidToken
+	}
+	// После отзыва сессий ссылка перестает действовать, поэтому ее нельзя использовать повторно
+	if err := checkTokenIssuedAt(claims, user); err != nil {
+		log.Printf("[ReportSuspiciousLogin] Сессии пользователя %s уже отозваны после уведомления", user.ID.Hex())
+		return ErrInvalidToken
+	}
+
+	sessionHex, _ := claims["session_id"].(string)
+	sessionRevoked := false
+	if sessionID, err := primitive.ObjectIDFromHex(sessionHex); err == nil {
+		if err := s.repo.DeleteSession(ctx, user.ID, sessionID); err != nil {
+			log.Printf("[ReportSuspiciousLogin] Ошибка отзыва сессии %s: %v", sessionHex, err)
+		} else {
+			sessionRevoked = true
+		}
+	}
+	deviceKey, _ := claims["device_key"].(string)
+	if err := s.repo.DeleteKnownDevice(ctx, user.ID, deviceKey); err != nil {
+		log.Printf("[ReportSuspiciousLogin] Ошибка удаления устройства пользователя %s: %v", user.ID.Hex(), err)
+	}
+
+	user.PasswordResetRequired = true
+	if err := s.repo.SetAccountRestrictions(ctx, user.ID, user.AccountRestrictions); err != nil {
+		return err
+	}
+	if err := s.revokeAllSessions(ctx, user); err != nil {
+		log.Printf("[ReportSuspiciousLogin] Ошибка отзыва сессий пользователя %s: %v", user.ID.Hex(), err)
+		return err
+	}
+
+	ip, _ := claims["ip"].(string)
+	agent, _ := claims["agent"].(string)
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventSuspiciousLoginReported,
+		UserID:  user.ID,
+		ActorID: user.ID,
+		Reason:  "login from a new device reported by the account owner",
+		Data: map[string]interface{}{
+			"session_id":      sessionHex,
+			"session_revoked": sessionRevoked,
+			"ip":              ip,
+			"agent":           agent,
+		},
+	})
+	log.Printf("[ReportSuspiciousLogin] Пользователь %s сообщил о чужом входе, требуется смена пароля", user.ID.Hex())
+
+	link := s.settings.AppURL + "/restore-password?email=" + url.QueryEscape(user.Email)
+	subject, body := suspiciousLoginReportedEmail(link)
+	s.sendEmail(user.Email, subject, body)
+	return nil
+}
+
+// sendPush отправляет push-уведомление на устройство аккаунта в фоне, ошибки только логируются
+func (s *Service) sendPush(user *User, notification push.Notification) {
+	if s.push == nil || user.DeviceToken == "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.push.Send(ctx, user.DeviceToken, notification); err != nil {
+			log.Printf("[sendPush] Ошибка отправки уведомления \"%s\" пользователю %s: %v", notification.Title, user.ID.Hex(), err)
+		}
+	}()
+}
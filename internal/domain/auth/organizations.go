@@ -0,0 +1,441 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrOrgNameInvalid       = errors.New("organization name must be between 2 and 100 characters")
+	ErrOrgRoleInvalid       = errors.New("invalid organization role")
+	ErrNotOrgMember         = errors.New("user is not a member of the organization")
+	ErrOrgPermissionDenied  = errors.New("insufficient organization role")
+	ErrAlreadyOrgMember     = errors.New("user is already a member of the organization")
+	ErrInvitationNotFound   = errors.New("invitation not found or expired")
+	ErrInvitationEmail      = errors.New("invitation was sent to another email")
+	ErrOwnerRemoval         = errors.New("organization owner cannot be removed")
+	ErrOwnershipTransfer    = errors.New("ownership can only be transferred by the owner to another member")
+)
+
+// OrgRole - роль пользователя в организации
+type OrgRole string
+
+const (
+	OrgRoleOwner  OrgRole = "owner"
+	OrgRoleAdmin  OrgRole = "admin"
+	OrgRoleMentor OrgRole = "mentor"
+	OrgRoleMember OrgRole = "member"
+)
+
+// orgRoleRank упорядочивает роли: управлять можно только участниками с ролью ниже своей
+var orgRoleRank = map[OrgRole]int{
+	OrgRoleOwner:  4,
+	OrgRoleAdmin:  3,
+	OrgRoleMentor: 2,
+	OrgRoleMember: 1,
+}
+
+// canManageRole проверяет, может ли участник с ролью actor приглашать и удалять участников с ролью target.
+// Приглашать и удалять могут только владелец и администраторы, владелец у организации один
+func canManageRole(actor, target OrgRole) bool {
+	if actor != OrgRoleOwner && actor != OrgRoleAdmin {
+		return false
+	}
+	if target == OrgRoleOwner {
+		return false
+	}
+	return orgRoleRank[actor] > orgRoleRank[target]
+}
+
+// Organization - аккаунт компании, объединяющий пользователей
+type Organization struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	OwnerID   primitive.ObjectID `bson:"owner_id" json:"owner_id"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// OrgMembership - участие пользователя в организации
+type OrgMembership struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	OrgID    primitive.ObjectID `bson:"org_id" json:"org_id"`
+	UserID   primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Role     OrgRole            `bson:"role" json:"role"`
+	JoinedAt time.Time          `bson:"joined_at" json:"joined_at"`
+	// ExternalID - идентификатор пользователя в каталоге организации, заданный через SCIM
+	ExternalID string `bson:"external_id,omitempty" json:"-"`
+}
+
+// OrgInvitation - приглашение в организацию по email. В базе хранится только хеш токена из письма
+type OrgInvitation struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID     primitive.ObjectID `bson:"org_id" json:"org_id"`
+	Email     string             `bson:"email" json:"email"`
+	Role      OrgRole            `bson:"role" json:"role"`
+	InvitedBy primitive.ObjectID `bson:"invited_by" json:"invited_by"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// UserOrganization - организация пользователя вместе с его ролью
+type UserOrganization struct {
+	Organization
+	Role OrgRole `json:"role"`
+}
+
+// OrgContext - организация, от имени которой действует пользователь. Попадает в claims org_id и org_role
+type OrgContext struct {
+	OrgID primitive.ObjectID
+	Role  OrgRole
+}
+
+// CreateOrganization создает организацию, создатель становится ее владельцем
+func (s *Service) CreateOrganization(ctx context.Context, userID, name string) (*Organization, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	name = strings.TrimSpace(name)
+	if n := len([]rune(name)); n < 2 || n > 100 {
+		return nil, ErrOrgNameInvalid
+	}
+
+	org := &Organization{
+		ID:        primitive.NewObjectID(),
+		Name:      name,
+		OwnerID:   id,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.CreateOrganization(ctx, org); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveMembership(ctx, &OrgMembership{
+		OrgID:    org.ID,
+		UserID:   id,
+		Role:     OrgRoleOwner,
+		JoinedAt: org.CreatedAt,
+	}); err != nil {
+		return nil, err
+	}
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventOrganizationCreated,
+		UserID:  id,
+		ActorID: id,
+		Data:    map[string]interface{}{"org_id": org.ID.Hex(), "name": org.Name},
+	})
+	log.Printf("[CreateOrganization] Пользователь %s создал организацию %s", userID, org.ID.Hex())
+	return org, nil
+}
+
+// GetUserOrganizations возвращает организации, в которых состоит пользователь
+func (s *Service) GetUserOrganizations(ctx context.Context, userID string) ([]*UserOrganization, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	memberships, err := s.repo.GetUserMemberships(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := []*UserOrganization{}
+	for _, membership := range memberships {
+		org, err := s.repo.GetOrganization(ctx, membership.OrgID)
+		if err != nil {
+			log.Printf("[GetUserOrganizations] Организация %s не найдена: %v", membership.OrgID.Hex(), err)
+			continue
+		}
+		orgs = append(orgs, &UserOrganization{Organization: *org, Role: membership.Role})
+	}
+	return orgs, nil
+}
+
+// GetOrganizationMembers возвращает участников организации. Доступно только участникам
+func (s *Service) GetOrganizationMembers(ctx context.Context, userID, orgID string) ([]*OrgMembership, error) {
+	_, org, err := s.getOrgMembership(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetOrganizationMembers(ctx, org)
+}
+
+// InviteToOrganization отправляет приглашение в организацию на email
+func (s *Service) InviteToOrganization(ctx context.Context, userID, orgID, email string, role OrgRole) (*OrgInvitation, error) {
+	actor, org, err := s.getOrgMembership(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := orgRoleRank[role]; !ok {
+		return nil, ErrOrgRoleInvalid
+	}
+	if !canManageRole(actor.Role, role) {
+		return nil, ErrOrgPermissionDenied
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	if invitee, err := s.repo.GetUserByEmail(ctx, email); err == nil {
+		if _, err := s.repo.GetMembership(ctx, org, invitee.ID); err == nil {
+			return nil, ErrAlreadyOrgMember
+		}
+	}
+
+	token, err := generateInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+	invitation := &OrgInvitation{
+		OrgID:     org,
+		Email:     email,
+		Role:      role,
+		InvitedBy: actor.UserID,
+		TokenHash: s.hashCode(token),
+		ExpiresAt: time.Now().Add(s.settings.OrgInvitationTTL),
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.SaveInvitation(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	organization, err := s.repo.GetOrganization(ctx, org)
+	if err != nil {
+		return nil, err
+	}
+	subject, body := orgInvitationEmail(organization.Name, role,
+		s.settings.AppURL+"/orgs/invitations?token="+token, s.settings.OrgInvitationTTL)
+	s.sendEmail(email, subject, body)
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventOrgMemberInvited,
+		UserID:  actor.UserID,
+		ActorID: actor.UserID,
+		Data:    map[string]interface{}{"org_id": orgID, "email": email, "role": string(role)},
+	})
+	return invitation, nil
+}
+
+// AcceptInvitation добавляет пользователя в организацию по токену из письма.
+// Принять приглашение может только владелец адреса, на который оно отправлено
+func (s *Service) AcceptInvitation(ctx context.Context, userID, token string) (*OrgMembership, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation, err := s.repo.GetInvitationByTokenHash(ctx, s.hashCode(token))
+	if err != nil || time.Now().After(invitation.ExpiresAt) {
+		return nil, ErrInvitationNotFound
+	}
+	if !strings.EqualFold(invitation.Email, user.Email) || !user.EmailVerified {
+		return nil, ErrInvitationEmail
+	}
+
+	membership := &OrgMembership{
+		OrgID:    invitation.OrgID,
+		UserID:   user.ID,
+		Role:     invitation.Role,
+		JoinedAt: time.Now(),
+	}
+	if err := s.repo.SaveMembership(ctx, membership); err != nil {
+		return nil, err
+	}
+	if err := s.repo.DeleteInvitation(ctx, invitation.ID); err != nil {
+		log.Printf("[AcceptInvitation] Ошибка удаления приглашения %s: %v", invitation.ID.Hex(), err)
+	}
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventOrgMemberJoined,
+		UserID:  user.ID,
+		ActorID: user.ID,
+		Data:    map[string]interface{}{"org_id": invitation.OrgID.Hex(), "role": string(invitation.Role)},
+	})
+	return membership, nil
+}
+
+// DeclineInvitation отклоняет приглашение по токену из письма, вход для этого не нужен
+func (s *Service) DeclineInvitation(ctx context.Context, token string) error {
+	invitation, err := s.repo.GetInvitationByTokenHash(ctx, s.hashCode(token))
+	if err != nil {
+		return ErrInvitationNotFound
+	}
+	return s.repo.DeleteInvitation(ctx, invitation.ID)
+}
+
+// RemoveOrganizationMember удаляет участника из организации. Участник может выйти сам,
+// остальных удаляют владелец и администраторы. Токены удаленного участника с контекстом
+// организации перестают приниматься
+func (s *Service) RemoveOrganizationMember(ctx context.Context, userID, orgID, memberID string) error {
+	actor, org, err := s.getOrgMembership(ctx, userID, orgID)
+	if err != nil {
+		return err
+	}
+	targetID, err := primitive.ObjectIDFromHex(memberID)
+	if err != nil {
+		return ErrNotOrgMember
+	}
+	target, err := s.repo.GetMembership(ctx, org, targetID)
+	if err != nil {
+		return err
+	}
+
+	if target.Role == OrgRoleOwner {
+		return ErrOwnerRemoval
+	}
+	if target.UserID != actor.UserID && !canManageRole(actor.Role, target.Role) {
+		return ErrOrgPermissionDenied
+	}
+
+	if err := s.repo.DeleteMembership(ctx, org, target.UserID); err != nil {
+		return err
+	}
+	if err := s.repo.PullGroupMember(ctx, org, target.UserID); err != nil {
+		log.Printf("[RemoveOrganizationMember] Ошибка удаления пользователя %s из групп: %v", memberID, err)
+	}
+
+	if user, err := s.repo.GetUserByID(ctx, target.UserID); err == nil {
+		if err := s.refreshSessionClaims(ctx, user); err != nil {
+			log.Printf("[RemoveOrganizationMember] Ошибка обновления claims пользователя %s: %v", memberID, err)
+		}
+	}
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventOrgMemberRemoved,
+		UserID:  target.UserID,
+		ActorID: actor.UserID,
+		Data:    map[string]interface{}{"org_id": orgID},
+	})
+	return nil
+}
+
+// TransferOrganizationOwnership передает владение организацией другому участнику.
+// Прежний владелец остается в организации администратором
+func (s *Service) TransferOrganizationOwnership(ctx context.Context, userID, orgID, newOwnerID string) error {
+	actor, org, err := s.getOrgMembership(ctx, userID, orgID)
+	if err != nil {
+		return err
+	}
+	if actor.Role != OrgRoleOwner {
+		return ErrOrgPermissionDenied
+	}
+	targetID, err := primitive.ObjectIDFromHex(newOwnerID)
+	if err != nil {
+		return ErrNotOrgMember
+	}
+	if targetID == actor.UserID {
+		return ErrOwnershipTransfer
+	}
+	target, err := s.repo.GetMembership(ctx, org, targetID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.SetOrganizationOwner(ctx, org, target.UserID); err != nil {
+		return err
+	}
+	if err := s.repo.SetMembershipRole(ctx, org, target.UserID, OrgRoleOwner); err != nil {
+		return err
+	}
+	if err := s.repo.SetMembershipRole(ctx, org, actor.UserID, OrgRoleAdmin); err != nil {
+		return err
+	}
+
+	// Роли в контексте организации изменились у обоих участников
+	for _, id := range []primitive.ObjectID{actor.UserID, target.UserID} {
+		if user, err := s.repo.GetUserByID(ctx, id); err == nil {
+			if err := s.refreshSessionClaims(ctx, user); err != nil {
+				log.Printf("[TransferOrganizationOwnership] Ошибка обновления claims пользователя %s: %v", id.Hex(), err)
+			}
+		}
+	}
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventOrgOwnershipTransferred,
+		UserID:  target.UserID,
+		ActorID: actor.UserID,
+		Data:    map[string]interface{}{"org_id": orgID},
+	})
+	log.Printf("[TransferOrganizationOwnership] Владение организацией %s передано пользователю %s", orgID, newOwnerID)
+	return nil
+}
+
+// SwitchOrganization выпускает токены с контекстом организации. Пустой orgID возвращает
+// пользователя в личный контекст. Время аутентификации сохраняется, повторный вход не нужен
+func (s *Service) SwitchOrganization(ctx context.Context, userID, orgID string, authTime time.Time) (*LoginResponse, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var org *OrgContext
+	if orgID != "" {
+		membership, _, err := s.getOrgMembership(ctx, userID, orgID)
+		if err != nil {
+			return nil, err
+		}
+		org = &OrgContext{OrgID: membership.OrgID, Role: membership.Role}
+	}
+
+	return s.issueTokensWithContext(ctx, user, authTime, org)
+}
+
+// orgContextFromClaims восстанавливает контекст организации из refresh токена,
+// проверяя, что пользователь по-прежнему в ней состоит. Роль берется актуальная
+func (s *Service) orgContextFromClaims(ctx context.Context, user *User, orgID string) *OrgContext {
+	if orgID == "" {
+		return nil
+	}
+	id, err := primitive.ObjectIDFromHex(orgID)
+	if err != nil {
+		return nil
+	}
+	membership, err := s.repo.GetMembership(ctx, id, user.ID)
+	if err != nil {
+		return nil
+	}
+	return &OrgContext{OrgID: membership.OrgID, Role: membership.Role}
+}
+
+// getOrgMembership возвращает участие пользователя в организации или ErrNotOrgMember
+func (s *Service) getOrgMembership(ctx context.Context, userID, orgID string) (*OrgMembership, primitive.ObjectID, error) {
+	uid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, primitive.NilObjectID, ErrUserNotFound
+	}
+	oid, err := primitive.ObjectIDFromHex(orgID)
+	if err != nil {
+		return nil, primitive.NilObjectID, ErrOrganizationNotFound
+	}
+	membership, err := s.repo.GetMembership(ctx, oid, uid)
+	if err != nil {
+		return nil, oid, err
+	}
+	return membership, oid, nil
+}
+
+// generateInvitationToken создает случайный токен для ссылки из приглашения
+func generateInvitationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,23 @@
+package auth
+
+import "testing"
+
+func TestCanManageRole(t *testing.T) {
+	tests := []struct {
+		actor, target OrgRole
+		want          bool
+	}{
+		{OrgRoleOwner, OrgRoleAdmin, true},
+		{OrgRoleOwner, OrgRoleMember, true},
+		{OrgRoleOwner, OrgRoleOwner, false},
+		{OrgRoleAdmin, OrgRoleMentor, true},
+		{OrgRoleAdmin, OrgRoleAdmin, false},
+		{OrgRoleMentor, OrgRoleMember, false},
+		{OrgRoleMember, OrgRoleMember, false},
+	}
+	for _, tt := range tests {
+		if got := canManageRole(tt.actor, tt.target); got != tt.want {
+			t.Errorf("canManageRole(%s, %s) = %v, want %v", tt.actor, tt.target, got, tt.want)
+		}
+	}
+}
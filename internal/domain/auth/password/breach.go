@@ -0,0 +1,78 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RuleBreached - пароль найден в базе утекших паролей
+const RuleBreached = "breached"
+
+// prefixLength - длина префикса SHA-1, по которому разбиты файлы диапазонов
+const prefixLength = 5
+
+// BreachedDataset - локальная копия базы утекших паролей в формате диапазонов Have I Been Pwned:
+// файл с именем из первых пяти символов SHA-1 (с расширением .txt или без) содержит строки
+// "ОСТАТОК_ХЕША:КОЛИЧЕСТВО". Сам пароль и полный хеш никуда не передаются
+type BreachedDataset struct {
+	dir string
+}
+
+// OpenBreachedDataset открывает каталог с файлами диапазонов
+func OpenBreachedDataset(dir string) (*BreachedDataset, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached passwords dataset %s is not a directory", dir)
+	}
+	return &BreachedDataset{dir: dir}, nil
+}
+
+// Count возвращает, сколько раз пароль встречался в утечках. Отсутствующий файл диапазона
+// означает, что паролей с таким префиксом в базе нет
+func (d *BreachedDataset) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	file, err := d.openRange(prefix)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		candidate, count, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(candidate, suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, fmt.Errorf("invalid count in range %s: %q", prefix, line)
+		}
+		return n, nil
+	}
+	return 0, scanner.Err()
+}
+
+func (d *BreachedDataset) openRange(prefix string) (*os.File, error) {
+	file, err := os.Open(filepath.Join(d.dir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return os.Open(filepath.Join(d.dir, prefix))
+	}
+	return file, err
+}
@@ -0,0 +1,49 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBreachedDatasetCount(t *testing.T) {
+	dir := t.TempDir()
+	// SHA-1("password") = 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	if err := os.WriteFile(filepath.Join(dir, "5BAA6.txt"),
+		[]byte("003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// SHA-1("hunter2") = F3BBBD66A63D4BF1747940578EC3D0103530E21D, файл без расширения
+	if err := os.WriteFile(filepath.Join(dir, "F3BBB"),
+		[]byte("d66a63d4bf1747940578ec3d0103530e21d:24230\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dataset, err := OpenBreachedDataset(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{"password", 9545824},
+		{"hunter2", 24230},
+		{"Password", 0},                 // другой префикс, файла нет
+		{"correct horse battery 42", 0}, // отсутствующий диапазон
+	}
+	for _, tt := range tests {
+		got, err := dataset.Count(tt.password)
+		if err != nil {
+			t.Fatalf("Count(%q) error: %v", tt.password, err)
+		}
+		if got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.password, got, tt.want)
+		}
+	}
+
+	if _, err := OpenBreachedDataset(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for missing dataset directory")
+	}
+}
@@ -0,0 +1,187 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrMismatch      = errors.New("password does not match hash")
+	ErrUnknownHash   = errors.New("unknown password hash format")
+	ErrInvalidParams = errors.New("invalid password hashing parameters")
+)
+
+// Алгоритмы хеширования паролей
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// Argon2Params - параметры argon2id. Memory задается в КиБ
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// HashConfig - алгоритм и параметры для новых хешей. Хеши с другим алгоритмом
+// или параметрами по-прежнему проверяются, но считаются устаревшими
+type HashConfig struct {
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+}
+
+// DefaultHashConfig возвращает argon2id с параметрами из рекомендаций OWASP
+func DefaultHashConfig() HashConfig {
+	return HashConfig{
+		Algorithm: AlgorithmArgon2id,
+		Argon2: Argon2Params{
+			Memory:      64 * 1024,
+			Iterations:  3,
+			Parallelism: 2,
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+		BcryptCost: bcrypt.DefaultCost,
+	}
+}
+
+// Validate проверяет, что с параметрами можно создавать хеши
+func (c HashConfig) Validate() error {
+	switch c.Algorithm {
+	case AlgorithmArgon2id:
+		p := c.Argon2
+		if p.Iterations < 1 || p.Parallelism < 1 || p.Memory < 8*uint32(p.Parallelism) ||
+			p.SaltLength < 8 || p.KeyLength < 16 {
+			return fmt.Errorf("%w: argon2id m=%d t=%d p=%d salt=%d key=%d", ErrInvalidParams,
+				p.Memory, p.Iterations, p.Parallelism, p.SaltLength, p.KeyLength)
+		}
+	case AlgorithmBcrypt:
+		if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("%w: bcrypt cost %d", ErrInvalidParams, c.BcryptCost)
+		}
+	default:
+		return fmt.Errorf("%w: unknown algorithm %q", ErrInvalidParams, c.Algorithm)
+	}
+	return nil
+}
+
+// Hasher создает хеши паролей в формате PHC ($argon2id$v=19$m=...,t=...,p=...$соль$хеш)
+// или bcrypt ($2a$...) и проверяет пароли по хешу любого из этих алгоритмов
+type Hasher struct {
+	cfg HashConfig
+}
+
+// NewHasher создает Hasher. Параметры должны пройти HashConfig.Validate
+func NewHasher(cfg HashConfig) *Hasher {
+	return &Hasher{cfg: cfg}
+}
+
+// Hash возвращает хеш пароля по текущей конфигурации
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+		return string(hash), err
+	}
+
+	p := h.cfg.Argon2
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return encodeArgon2(p, salt, key), nil
+}
+
+// Verify проверяет пароль по хешу, алгоритм определяется по самому хешу. Возвращает ErrMismatch,
+// если пароль не совпадает, и ErrUnknownHash для нераспознанного формата
+func (h *Hasher) Verify(encoded, password string) error {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		p, salt, key, err := decodeArgon2(encoded)
+		if err != nil {
+			return err
+		}
+		candidate := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		if subtle.ConstantTimeCompare(key, candidate) != 1 {
+			return ErrMismatch
+		}
+		return nil
+	case isBcrypt(encoded):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatch
+		}
+		return err
+	}
+	return ErrUnknownHash
+}
+
+// NeedsRehash сообщает, создан ли хеш другим алгоритмом или с другими параметрами,
+// чем задано в конфигурации
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	switch h.cfg.Algorithm {
+	case AlgorithmArgon2id:
+		if !strings.HasPrefix(encoded, "$argon2id$") {
+			return true
+		}
+		p, salt, key, err := decodeArgon2(encoded)
+		if err != nil {
+			return true
+		}
+		want := h.cfg.Argon2
+		return p.Memory != want.Memory || p.Iterations != want.Iterations || p.Parallelism != want.Parallelism ||
+			uint32(len(salt)) != want.SaltLength || uint32(len(key)) != want.KeyLength
+	case AlgorithmBcrypt:
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != h.cfg.BcryptCost
+	}
+	return false
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+func encodeArgon2(p Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations,
+		p.Parallelism, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2(encoded string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", соль, хеш
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnknownHash
+	}
+	p.SaltLength, p.KeyLength = uint32(len(salt)), uint32(len(key))
+	return p, salt, key, nil
+}
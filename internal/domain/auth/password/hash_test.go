@@ -0,0 +1,93 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testHashConfig() HashConfig {
+	cfg := DefaultHashConfig()
+	// Минимальные параметры, чтобы тест не тратил 64 МиБ на каждый хеш
+	cfg.Argon2.Memory = 64
+	cfg.Argon2.Iterations = 1
+	cfg.Argon2.Parallelism = 1
+	cfg.BcryptCost = bcrypt.MinCost
+	return cfg
+}
+
+func TestHasherArgon2id(t *testing.T) {
+	hasher := NewHasher(testHashConfig())
+	hash, err := hasher.Hash("correct horse 42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("unexpected PHC string %q", hash)
+	}
+	if err := hasher.Verify(hash, "correct horse 42"); err != nil {
+		t.Errorf("Verify with correct password: %v", err)
+	}
+	if err := hasher.Verify(hash, "correct horse 43"); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Verify with wrong password = %v, want ErrMismatch", err)
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Error("fresh hash should not need rehash")
+	}
+
+	stronger := testHashConfig()
+	stronger.Argon2.Iterations = 2
+	if !NewHasher(stronger).NeedsRehash(hash) {
+		t.Error("hash with outdated parameters should need rehash")
+	}
+	// Проверка использует параметры из хеша, а не из конфигурации
+	if err := NewHasher(stronger).Verify(hash, "correct horse 42"); err != nil {
+		t.Errorf("Verify with changed config: %v", err)
+	}
+}
+
+func TestHasherBcryptMigration(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse 42"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hasher := NewHasher(testHashConfig())
+	if err := hasher.Verify(string(legacy), "correct horse 42"); err != nil {
+		t.Errorf("Verify bcrypt hash: %v", err)
+	}
+	if err := hasher.Verify(string(legacy), "wrong"); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Verify bcrypt with wrong password = %v, want ErrMismatch", err)
+	}
+	if !hasher.NeedsRehash(string(legacy)) {
+		t.Error("bcrypt hash should need rehash when argon2id is configured")
+	}
+
+	bcryptCfg := testHashConfig()
+	bcryptCfg.Algorithm = AlgorithmBcrypt
+	if NewHasher(bcryptCfg).NeedsRehash(string(legacy)) {
+		t.Error("bcrypt hash with configured cost should not need rehash")
+	}
+
+	if err := hasher.Verify("plaintext", "plaintext"); !errors.Is(err, ErrUnknownHash) {
+		t.Errorf("Verify unknown format = %v, want ErrUnknownHash", err)
+	}
+}
+
+func TestHashConfigValidate(t *testing.T) {
+	if err := DefaultHashConfig().Validate(); err != nil {
+		t.Errorf("default config: %v", err)
+	}
+	cfg := DefaultHashConfig()
+	cfg.Argon2.Parallelism = 0
+	if err := cfg.Validate(); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("zero parallelism = %v, want ErrInvalidParams", err)
+	}
+	cfg = DefaultHashConfig()
+	cfg.Algorithm = "md5"
+	if err := cfg.Validate(); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("unknown algorithm = %v, want ErrInvalidParams", err)
+	}
+}
@@ -0,0 +1,127 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Правила политики паролей, в ответе API они приходят в поле rule
+const (
+	RuleMinLength      = "min_length"
+	RuleMaxLength      = "max_length"
+	RuleUppercase      = "uppercase"
+	RuleLowercase      = "lowercase"
+	RuleDigit          = "digit"
+	RuleSymbol         = "symbol"
+	RuleBannedWord     = "banned_word"
+	RuleEmailLocalPart = "email_local_part"
+	RuleReused         = "reused"
+)
+
+// minEmailLocalPart - более короткие локальные части email (например, "an") встречаются
+// в паролях случайно и не проверяются
+const minEmailLocalPart = 3
+
+// Policy - требования к паролю. Одна политика применяется при регистрации, смене
+// и восстановлении пароля
+type Policy struct {
+	// MinLength - минимальная длина в символах, 0 отключает ограничение
+	MinLength int
+	// MaxLength - максимальная длина в байтах UTF-8, а не в символах: bcrypt принимает
+	// не больше 72 байт. 0 отключает ограничение
+	MaxLength int
+
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+
+	// BannedWords - слова, которые не могут входить в пароль (без учета регистра)
+	BannedWords []string
+	// ForbidEmailLocalPart - пароль не может содержать часть email до @
+	ForbidEmailLocalPart bool
+}
+
+// DefaultBannedWords - самые частые основы паролей из утечек
+var DefaultBannedWords = []string{
+	"password", "passw0rd", "qwerty", "123456", "12345678", "abc123", "111111",
+	"iloveyou", "letmein", "welcome", "admin", "monkey", "dragon", "kubercode",
+}
+
+// DefaultPolicy возвращает политику по умолчанию
+func DefaultPolicy() Policy {
+	return Policy{
+		MinLength:            8,
+		MaxLength:            72,
+		RequireLowercase:     true,
+		RequireDigit:         true,
+		BannedWords:          DefaultBannedWords,
+		ForbidEmailLocalPart: true,
+	}
+}
+
+// Violation - нарушенное правило политики
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Validate проверяет пароль по всем правилам и возвращает все нарушения, чтобы клиент
+// мог показать их сразу. email может быть пустым, если он неизвестен
+func (p Policy) Validate(password, email string) []Violation {
+	var violations []Violation
+	add := func(rule, message string) {
+		violations = append(violations, Violation{Rule: rule, Message: message})
+	}
+
+	if p.MinLength > 0 && utf8.RuneCountInString(password) < p.MinLength {
+		add(RuleMinLength, fmt.Sprintf("password must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		add(RuleMaxLength, fmt.Sprintf("password must be at most %d bytes long", p.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsNumber(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUppercase && !hasUpper {
+		add(RuleUppercase, "password must contain an uppercase letter")
+	}
+	if p.RequireLowercase && !hasLower {
+		add(RuleLowercase, "password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		add(RuleDigit, "password must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		add(RuleSymbol, "password must contain a symbol")
+	}
+
+	lower := strings.ToLower(password)
+	for _, word := range p.BannedWords {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" && strings.Contains(lower, word) {
+			add(RuleBannedWord, fmt.Sprintf("password must not contain %q", word))
+			break
+		}
+	}
+
+	if p.ForbidEmailLocalPart {
+		local, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+		if utf8.RuneCountInString(local) >= minEmailLocalPart && strings.Contains(lower, local) {
+			add(RuleEmailLocalPart, "password must not contain your email address")
+		}
+	}
+	return violations
+}
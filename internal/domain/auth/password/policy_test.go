@@ -0,0 +1,57 @@
+package password
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func rules(violations []Violation) []string {
+	var result []string
+	for _, v := range violations {
+		result = append(result, v.Rule)
+	}
+	return result
+}
+
+func TestPolicyValidate(t *testing.T) {
+	strict := Policy{
+		MinLength:            8,
+		MaxLength:            16,
+		RequireUppercase:     true,
+		RequireLowercase:     true,
+		RequireDigit:         true,
+		RequireSymbol:        true,
+		BannedWords:          []string{"qwerty"},
+		ForbidEmailLocalPart: true,
+	}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		email    string
+		want     []string
+	}{
+		{"valid", strict, "Str0ng-pass", "ivan@example.com", nil},
+		{"too short", strict, "S0-p", "", []string{RuleMinLength}},
+		{"too long", strict, "Str0ng-passphrase-x", "", []string{RuleMaxLength}},
+		{"missing classes", strict, "lowercaseonly", "", []string{RuleUppercase, RuleDigit, RuleSymbol}},
+		{"banned word in any case", strict, "MyQWERTY-1x", "", []string{RuleBannedWord}},
+		{"email local part", strict, "Ivan-2024pw", "Ivan@example.com", []string{RuleEmailLocalPart}},
+		{"short local part ignored", strict, "Jo-2024pass", "jo@example.com", nil},
+		{"length counts runes", Policy{MinLength: 8}, "пароль12", "", nil},
+		{"max length counts bytes", Policy{MaxLength: 16}, "пароль12345", "", []string{RuleMaxLength}},
+		{"default rejects over 72 bytes", DefaultPolicy(), strings.Repeat("пароль", 6) + "42", "", []string{RuleMaxLength}},
+		{"default rejects common password", DefaultPolicy(), "password123", "", []string{RuleBannedWord}},
+		{"default accepts passphrase", DefaultPolicy(), "correct horse 42", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules(tt.policy.Validate(tt.password, tt.email)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+var ErrBusy = errors.New("password hashing is overloaded")
+
+// BusyError - очередь на хеширование переполнена или ожидание в ней превысило QueueTimeout
+type BusyError struct {
+	RetryAfter time.Duration
+}
+
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("%v, retry after %s", ErrBusy, e.RetryAfter)
+}
+
+func (e *BusyError) Unwrap() error {
+	return ErrBusy
+}
+
+// PoolConfig - ограничения пула хеширования
+type PoolConfig struct {
+	// Workers - сколько хешей считается одновременно, 0 - по числу процессоров
+	Workers int
+	// QueueSize - сколько запросов может ждать свободного места, остальные сразу получают BusyError
+	QueueSize int
+	// QueueTimeout - максимальное время ожидания в очереди
+	QueueTimeout time.Duration
+}
+
+// DefaultPoolConfig оставляет половину процессоров под остальные запросы
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		Workers:      max(runtime.GOMAXPROCS(0)/2, 1),
+		QueueSize:    64,
+		QueueTimeout: 2 * time.Second,
+	}
+}
+
+// hashLatencyBuckets - верхние границы корзин гистограммы времени хеширования
+var hashLatencyBuckets = []time.Duration{
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond,
+	250 * time.Millisecond, 500 * time.Millisecond, time.Second,
+}
+
+// Pool ограничивает число одновременных хеширований, чтобы всплеск входов не занимал
+// все процессоры и проверка токенов оставалась быстрой. Хеш считается в горутине вызывающего,
+// пул только выдает места
+type Pool struct {
+	cfg   PoolConfig
+	slots chan struct{}
+
+	queued    atomic.Int64
+	completed atomic.Uint64
+	rejected  atomic.Uint64
+	waitTotal atomic.Int64
+	hashTotal atomic.Int64
+	hashMax   atomic.Int64
+	histogram []atomic.Uint64
+}
+
+// NewPool создает пул
+func NewPool(cfg PoolConfig) *Pool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.GOMAXPROCS(0)
+	}
+	return &Pool{
+		cfg:       cfg,
+		slots:     make(chan struct{}, cfg.Workers),
+		histogram: make([]atomic.Uint64, len(hashLatencyBuckets)+1),
+	}
+}
+
+// Do выполняет fn, когда освободится место. Если очередь полна или место не освободилось
+// за QueueTimeout, возвращает BusyError, не выполняя fn
+func (p *Pool) Do(ctx context.Context, fn func() error) error {
+	if p == nil {
+		return fn()
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		if err := p.wait(ctx); err != nil {
+			return err
+		}
+	}
+	defer func() { <-p.slots }()
+
+	start := time.Now()
+	err := fn()
+	p.observe(time.Since(start))
+	return err
+}
+
+func (p *Pool) wait(ctx context.Context) error {
+	if p.queued.Add(1) > int64(p.cfg.QueueSize) {
+		p.queued.Add(-1)
+		p.rejected.Add(1)
+		return p.busy()
+	}
+	defer p.queued.Add(-1)
+
+	start := time.Now()
+	timer := time.NewTimer(p.cfg.QueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case p.slots <- struct{}{}:
+		p.waitTotal.Add(int64(time.Since(start)))
+		return nil
+	case <-timer.C:
+		p.rejected.Add(1)
+		return p.busy()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// busy предлагает повторить запрос не раньше, чем истечет время ожидания в очереди
+func (p *Pool) busy() error {
+	retryAfter := max(p.cfg.QueueTimeout, time.Second)
+	return &BusyError{RetryAfter: (retryAfter + time.Second - 1).Truncate(time.Second)}
+}
+
+func (p *Pool) observe(d time.Duration) {
+	p.completed.Add(1)
+	p.hashTotal.Add(int64(d))
+	for {
+		current := p.hashMax.Load()
+		if int64(d) <= current || p.hashMax.CompareAndSwap(current, int64(d)) {
+			break
+		}
+	}
+
+	bucket := len(hashLatencyBuckets)
+	for i, upper := range hashLatencyBuckets {
+		if d <= upper {
+			bucket = i
+			break
+		}
+	}
+	p.histogram[bucket].Add(1)
+}
+
+// LatencyBucket - число хеширований, занявших не больше UpToMs миллисекунд и больше
+// границы предыдущей корзины. У последней корзины UpToMs нет
+type LatencyBucket struct {
+	UpToMs int64  `json:"up_to_ms,omitempty"`
+	Count  uint64 `json:"count"`
+}
+
+// PoolStats - состояние и метрики пула с момента запуска
+type PoolStats struct {
+	Workers     int             `json:"workers"`
+	QueueSize   int             `json:"queue_size"`
+	InFlight    int             `json:"in_flight"`
+	QueueDepth  int64           `json:"queue_depth"`
+	Completed   uint64          `json:"completed"`
+	Rejected    uint64          `json:"rejected"`
+	AvgWaitMs   float64         `json:"avg_wait_ms"`
+	AvgHashMs   float64         `json:"avg_hash_ms"`
+	MaxHashMs   float64         `json:"max_hash_ms"`
+	HashLatency []LatencyBucket `json:"hash_latency"`
+}
+
+// Stats возвращает текущие метрики пула
+func (p *Pool) Stats() PoolStats {
+	stats := PoolStats{
+		Workers:    p.cfg.Workers,
+		QueueSize:  p.cfg.QueueSize,
+		InFlight:   len(p.slots),
+		QueueDepth: p.queued.Load(),
+		Completed:  p.completed.Load(),
+		Rejected:   p.rejected.Load(),
+		MaxHashMs:  milliseconds(p.hashMax.Load()),
+	}
+	if stats.Completed > 0 {
+		stats.AvgWaitMs = milliseconds(p.waitTotal.Load()) / float64(stats.Completed)
+		stats.AvgHashMs = milliseconds(p.hashTotal.Load()) / float64(stats.Completed)
+	}
+	for i := range p.histogram {
+		bucket := LatencyBucket{Count: p.histogram[i].Load()}
+		if i < len(hashLatencyBuckets) {
+			bucket.UpToMs = hashLatencyBuckets[i].Milliseconds()
+		}
+		stats.HashLatency = append(stats.HashLatency, bucket)
+	}
+	return stats
+}
+
+func milliseconds(nanos int64) float64 {
+	return float64(nanos) / float64(time.Millisecond)
+}
@@ -0,0 +1,86 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolRejectsWhenSaturated(t *testing.T) {
+	pool := NewPool(PoolConfig{Workers: 1, QueueSize: 1, QueueTimeout: 50 * time.Millisecond})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- pool.Do(context.Background(), func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	// Единственное место в очереди занимает второй запрос
+	queued := make(chan error)
+	go func() {
+		queued <- pool.Do(context.Background(), func() error { return nil })
+	}()
+	for pool.Stats().QueueDepth != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Третьему места в очереди нет
+	var busy *BusyError
+	if err := pool.Do(context.Background(), func() error { return nil }); !errors.As(err, &busy) {
+		t.Fatalf("full queue: got %v, want BusyError", err)
+	}
+	if busy.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %s, want 1s", busy.RetryAfter)
+	}
+
+	// Второй не дождался места за QueueTimeout
+	if err := <-queued; !errors.Is(err, ErrBusy) {
+		t.Errorf("queue timeout: got %v, want ErrBusy", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	stats := pool.Stats()
+	if stats.Completed != 1 || stats.Rejected != 2 || stats.QueueDepth != 0 || stats.InFlight != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	var counted uint64
+	for _, bucket := range stats.HashLatency {
+		counted += bucket.Count
+	}
+	if counted != 1 {
+		t.Errorf("histogram counted %d hashes, want 1", counted)
+	}
+}
+
+func TestPoolWaitsForFreeSlot(t *testing.T) {
+	pool := NewPool(PoolConfig{Workers: 1, QueueSize: 4, QueueTimeout: time.Second})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go pool.Do(context.Background(), func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	want := errors.New("hash failed")
+	if err := pool.Do(context.Background(), func() error { return want }); err != want {
+		t.Errorf("queued call: got %v, want result of fn", err)
+	}
+}
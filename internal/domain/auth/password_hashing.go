@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"kubercode/internal/domain/auth/password"
+)
+
+// verifyPassword проверяет пароль по хешу в пуле хеширования. Если пул перегружен,
+// возвращает password.BusyError, и проверка не выполняется
+func (s *Service) verifyPassword(ctx context.Context, hash, pw string) error {
+	return s.hashPool.Do(ctx, func() error {
+		return s.hasher.Verify(hash, pw)
+	})
+}
+
+// isHashingBusy сообщает, что пароль не проверен из-за перегрузки пула хеширования
+func isHashingBusy(err error) bool {
+	return errors.Is(err, password.ErrBusy)
+}
+
+// checkPassword проверяет пароль пользователя перед чувствительной операцией. Неверный пароль
+// возвращается как ErrInvalidCredentials, перегрузка пула - как есть
+func (s *Service) checkPassword(ctx context.Context, user *User, pw string) error {
+	err := s.verifyPassword(ctx, user.Password, pw)
+	if err == nil || isHashingBusy(err) || ctx.Err() != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// hashPassword хеширует пароль в пуле хеширования. Ограничение bcrypt в 72 байта возвращается
+// как нарушение политики, если PASSWORD_MAX_LENGTH задан больше 72 или отключен
+func (s *Service) hashPassword(ctx context.Context, newPassword string) (string, error) {
+	var hash string
+	err := s.hashPool.Do(ctx, func() error {
+		var err error
+		hash, err = s.hasher.Hash(newPassword)
+		return err
+	})
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", &PasswordPolicyError{Violations: []password.Violation{{
+			Rule:    password.RuleMaxLength,
+			Message: "password is too long",
+		}}}
+	}
+	return hash, err
+}
+
+// rehashPassword после успешного входа пересоздает хеш, созданный устаревшим алгоритмом
+// или параметрами. Так хеши переходят на новые настройки постепенно, без массовой смены паролей
+func (s *Service) rehashPassword(ctx context.Context, user *User, pw string) {
+	if !s.hasher.NeedsRehash(user.Password) {
+		return
+	}
+	// При перегрузке пула хеш пересоздается при одном из следующих входов
+	hash, err := s.hashPassword(ctx, pw)
+	if err != nil {
+		log.Printf("[rehashPassword] Ошибка хеширования пароля пользователя %s: %v", user.ID.Hex(), err)
+		return
+	}
+	// Хеш меняется, только если пароль не сменили параллельно
+	if err := s.repo.ReplacePasswordHash(ctx, user.ID, user.Password, hash); err != nil {
+		log.Printf("[rehashPassword] Ошибка сохранения хеша пользователя %s: %v", user.ID.Hex(), err)
+		return
+	}
+	user.Password = hash
+}
+
+// HashingStats возвращает метрики пула хеширования паролей
+func (s *Service) HashingStats() password.PoolStats {
+	return s.hashPool.Stats()
+}
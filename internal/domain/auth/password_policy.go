@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"kubercode/internal/domain/auth/password"
+)
+
+var ErrWeakPassword = errors.New("password does not meet the password policy")
+
+// PasswordPolicyError перечисляет все нарушенные правила политики паролей
+type PasswordPolicyError struct {
+	Violations []password.Violation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	rules := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		rules[i] = v.Rule
+	}
+	return fmt.Sprintf("%v: %s", ErrWeakPassword, strings.Join(rules, ", "))
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// SetBreachedPasswords подключает локальную базу утекших паролей
+func (s *Service) SetBreachedPasswords(dataset *password.BreachedDataset) {
+	s.breached = dataset
+}
+
+// validatePassword проверяет новый пароль по политике из настроек и базе утекших паролей
+func (s *Service) validatePassword(newPassword, email string) error {
+	violations := s.settings.PasswordPolicy.Validate(newPassword, email)
+	if s.passwordBreached(newPassword) {
+		violations = append(violations, password.Violation{
+			Rule:    password.RuleBreached,
+			Message: "password appears in known data breaches",
+		})
+	}
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// passwordBreached сообщает, встречался ли пароль в утечках не меньше BreachedPasswordMinCount раз.
+// Без базы или при ошибке ее чтения пароль считается безопасным, чтобы не блокировать регистрацию
+func (s *Service) passwordBreached(pw string) bool {
+	if s.breached == nil {
+		return false
+	}
+	count, err := s.breached.Count(pw)
+	if err != nil {
+		log.Printf("[passwordBreached] Ошибка чтения базы утекших паролей: %v", err)
+		return false
+	}
+	return count > 0 && count >= s.settings.BreachedPasswordMinCount
+}
+
+// requireBreachedPasswordChange требует смены пароля, если пароль, с которым пользователь
+// только что вошел, найден в утечках. Все сессии завершаются, пользователь получает письмо
+func (s *Service) requireBreachedPasswordChange(ctx context.Context, user *User, pw string) {
+	if !s.settings.BreachedPasswordCheckOnLogin || user.PasswordResetRequired || !s.passwordBreached(pw) {
+		return
+	}
+
+	user.PasswordResetRequired = true
+	if err := s.repo.SetAccountRestrictions(ctx, user.ID, user.AccountRestrictions); err != nil {
+		log.Printf("[requireBreachedPasswordChange] Ошибка сохранения требования смены пароля: %v", err)
+		user.PasswordResetRequired = false
+		return
+	}
+	if err := s.revokeAllSessions(ctx, user); err != nil {
+		log.Printf("[requireBreachedPasswordChange] Ошибка отзыва сессий пользователя %s: %v", user.ID.Hex(), err)
+	}
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:   EventPasswordResetForced,
+		UserID: user.ID,
+		Reason: "password found in data breaches",
+	})
+	log.Printf("[requireBreachedPasswordChange] Пароль пользователя %s найден в утечках, требуется смена", user.ID.Hex())
+
+	link := s.settings.AppURL + "/restore-password?email=" + url.QueryEscape(user.Email)
+	subject, body := breachedPasswordEmail(link)
+	s.sendEmail(user.Email, subject, body)
+}
+
+// setPassword проверяет новый пароль по политике и истории и устанавливает его пользователю.
+// Прежний хеш уходит в историю, сохранение пользователя остается за вызывающим
+func (s *Service) setPassword(ctx context.Context, user *User, newPassword string) error {
+	if err := s.validatePassword(newPassword, user.Email); err != nil {
+		return err
+	}
+	reused, err := s.passwordReused(ctx, user, newPassword)
+	if err != nil {
+		return err
+	}
+	if reused {
+		return &PasswordPolicyError{Violations: []password.Violation{{
+			Rule:    password.RuleReused,
+			Message: fmt.Sprintf("password must differ from the last %d passwords", s.settings.PasswordHistorySize),
+		}}}
+	}
+
+	hash, err := s.hashPassword(ctx, newPassword)
+	if err != nil {
+		return err
+	}
+	// Вместе с текущим хешем история покрывает PasswordHistorySize последних паролей
+	if user.Password != "" {
+		user.PasswordHistory = append([]string{user.Password}, user.PasswordHistory...)
+	}
+	if keep := s.settings.PasswordHistorySize - 1; len(user.PasswordHistory) > keep {
+		user.PasswordHistory = user.PasswordHistory[:max(keep, 0)]
+	}
+	user.Password = hash
+	return nil
+}
+
+// passwordReused сообщает, совпадает ли пароль с одним из PasswordHistorySize последних паролей,
+// включая текущий. При PasswordHistorySize = 0 повтор разрешен
+func (s *Service) passwordReused(ctx context.Context, user *User, newPassword string) (bool, error) {
+	if s.settings.PasswordHistorySize <= 0 {
+		return false, nil
+	}
+	hashes := append([]string{user.Password}, user.PasswordHistory...)
+	if len(hashes) > s.settings.PasswordHistorySize {
+		hashes = hashes[:s.settings.PasswordHistorySize]
+	}
+	for _, hash := range hashes {
+		if hash == "" {
+			continue
+		}
+		err := s.verifyPassword(ctx, hash, newPassword)
+		if err == nil {
+			return true, nil
+		}
+		if isHashingBusy(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
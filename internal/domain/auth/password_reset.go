@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"time"
+)
+
+// RequestPasswordReset отправляет код восстановления пароля на email пользователя.
+// Не сообщает, существует ли пользователь: для неизвестного адреса письмо просто не отправляется
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		log.Printf("[RequestPasswordReset] Пользователь не найден: %v", err)
+		return nil
+	}
+
+	existing, err := s.repo.GetPasswordReset(ctx, user.ID)
+	if err == nil && time.Since(existing.CreatedAt) < s.settings.PasswordResetResendInterval {
+		log.Printf("[RequestPasswordReset] Слишком частый запрос для пользователя %s", user.ID.Hex())
+		return nil
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return err
+	}
+
+	reset := &PasswordReset{
+		UserID:    user.ID,
+		CodeHash:  s.hashCode(code),
+		ExpiresAt: time.Now().Add(s.settings.PasswordResetTTL),
+	}
+	if err := s.repo.SavePasswordReset(ctx, reset); err != nil {
+		return err
+	}
+
+	link := s.settings.AppURL + "/restore-password?email=" + url.QueryEscape(user.Email) + "&code=" + code
+	subject, body := passwordResetEmail(link, code, s.settings.PasswordResetTTL)
+	s.sendEmail(user.Email, subject, body)
+	return nil
+}
+
+// ConfirmPasswordReset устанавливает новый пароль по коду из письма и завершает все сессии пользователя
+func (s *Service) ConfirmPasswordReset(ctx context.Context, email, code, newPassword string) (err error) {
+	var user *User
+	defer func() { s.auditAttempt(ctx, AuditPasswordReset, user, err, s.loginAuditDetails(user, email)) }()
+
+	user, err = s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		log.Printf("[ConfirmPasswordReset] Пользователь не найден: %v", err)
+		return ErrInvalidCode
+	}
+
+	// Попытка расходуется до сравнения кода, иначе параллельные запросы обойдут лимит
+	reset, err := s.repo.UsePasswordResetAttempt(ctx, user.ID, s.settings.PasswordResetMaxAttempts)
+	if err != nil {
+		log.Printf("[ConfirmPasswordReset] Запрос не найден, код истек или превышено число попыток для пользователя %s: %v", user.ID.Hex(), err)
+		return ErrInvalidCode
+	}
+
+	if !s.checkCode(code, reset.CodeHash) {
+		log.Printf("[ConfirmPasswordReset] Неверный код для пользователя %s", user.ID.Hex())
+		return ErrInvalidCode
+	}
+
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+	// Восстановление пароля выполняет требование администратора о смене пароля
+	user.PasswordResetRequired = false
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeletePasswordReset(ctx, user.ID); err != nil {
+		log.Printf("[ConfirmPasswordReset] Ошибка удаления кода восстановления: %v", err)
+	}
+	// После смены пароля блокировка входа из-за неудачных попыток больше не нужна
+	s.resetLoginFailures(ctx, userLoginKey(user.ID))
+
+	// Пароль мог быть скомпрометирован, поэтому завершаем все сессии
+	if err := s.revokeAllSessions(ctx, user); err != nil {
+		log.Printf("[ConfirmPasswordReset] Ошибка отзыва сессий: %v", err)
+		return err
+	}
+
+	s.recordEvent(ctx, &DomainEvent{Type: EventPasswordReset, UserID: user.ID, ActorID: user.ID})
+
+	subject, body := passwordChangedEmail()
+	s.sendEmail(user.Email, subject, body)
+	return nil
+}
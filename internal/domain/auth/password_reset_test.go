@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCodeAttemptFilter(t *testing.T) {
+	settings := DefaultSettings()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	userID := primitive.NewObjectID()
+
+	filter := codeAttemptFilter(userID, settings.PasswordResetMaxAttempts, now)
+	if filter["_id"] != userID {
+		t.Fatalf("_id = %v, want %v", filter["_id"], userID)
+	}
+	maxAttempts, _ := filter["attempts"].(bson.M)["$lt"].(int)
+	notExpiredAt, _ := filter["expires_at"].(bson.M)["$gt"].(time.Time)
+
+	tests := []struct {
+		name      string
+		attempts  int
+		expiresAt time.Time
+		want      bool
+	}{
+		{"first attempt", 0, now.Add(settings.PasswordResetTTL), true},
+		{"last attempt", settings.PasswordResetMaxAttempts - 1, now.Add(time.Minute), true},
+		{"attempts exhausted", settings.PasswordResetMaxAttempts, now.Add(time.Minute), false},
+		{"code expired", 0, now.Add(-time.Second), false},
+		{"expires now", 0, now, false},
+	}
+	for _, tt := range tests {
+		got := tt.attempts < maxAttempts && tt.expiresAt.After(notExpiredAt)
+		if got != tt.want {
+			t.Errorf("%s: filter matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckCode(t *testing.T) {
+	s := &Service{jwtSecret: []byte("test-secret")}
+	other := &Service{jwtSecret: []byte("other-secret")}
+
+	tests := []struct {
+		name string
+		code string
+		hash string
+		want bool
+	}{
+		{"correct code", "123456", s.hashCode("123456"), true},
+		{"wrong code", "654321", s.hashCode("123456"), false},
+		{"empty code", "", s.hashCode("123456"), false},
+		{"hash with another secret", "123456", other.hashCode("123456"), false},
+	}
+	for _, tt := range tests {
+		if got := s.checkCode(tt.code, tt.hash); got != tt.want {
+			t.Errorf("%s: checkCode = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordResetRevokesSessions(t *testing.T) {
+	s := &Service{jwtSecret: []byte("test-secret"), settings: DefaultSettings()}
+	user := &User{ID: primitive.NewObjectID(), Email: "user@example.com", EmailVerified: true}
+
+	before, err := s.generateToken(user, true, time.Now(), nil)
+	if err != nil {
+		t.Fatalf("generateToken: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	// Так же, как revokeAllSessions при восстановлении пароля
+	user.TokensValidAfter = time.Now().Truncate(time.Millisecond)
+	after, err := s.generateToken(user, true, time.Now(), nil)
+	if err != nil {
+		t.Fatalf("generateToken: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"issued before reset", before, ErrTokenRevoked},
+		{"issued after reset", after, nil},
+	}
+	for _, tt := range tests {
+		token, err := s.ValidateToken(tt.token)
+		if err != nil {
+			t.Fatalf("%s: ValidateToken: %v", tt.name, err)
+		}
+		claims, _ := token.Claims.(jwt.MapClaims)
+		if err := checkTokenIssuedAt(claims, user); !errors.Is(err, tt.want) {
+			t.Errorf("%s: checkTokenIssuedAt = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/text/language"
+)
+
+const (
+	maxDisplayNameLength = 64
+	maxAvatarURLLength   = 2048
+	maxMentorBioLength   = 1000
+)
+
+// Profile - данные профиля пользователя, которые он заполняет сам
+type Profile struct {
+	DisplayName string `bson:"display_name,omitempty" json:"display_name,omitempty"`
+	AvatarURL   string `bson:"avatar_url,omitempty" json:"avatar_url,omitempty"`
+	Locale      string `bson:"locale,omitempty" json:"locale,omitempty"`
+	Timezone    string `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	MentorBio   string `bson:"mentor_bio,omitempty" json:"mentor_bio,omitempty"`
+}
+
+// ProfileUpdate - частичное изменение профиля. nil означает, что поле не меняется,
+// пустая строка - что поле очищается
+type ProfileUpdate struct {
+	DisplayName *string
+	AvatarURL   *string
+	Locale      *string
+	Timezone    *string
+	MentorBio   *string
+}
+
+// MeResponse - данные текущего пользователя вместе с профилем
+type MeResponse struct {
+	UserInfo
+	Profile Profile `json:"profile"`
+}
+
+// ProfileValidationError содержит ошибки валидации по каждому полю профиля
+type ProfileValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ProfileValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field, message := range e.Fields {
+		fields = append(fields, field+": "+message)
+	}
+	sort.Strings(fields)
+	return "invalid profile: " + strings.Join(fields, "; ")
+}
+
+// GetMe возвращает данные текущего пользователя и его профиль
+func (s *Service) GetMe(ctx context.Context, userID string) (*MeResponse, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MeResponse{UserInfo: s.userInfo(user), Profile: user.Profile}, nil
+}
+
+// UpdateProfile проверяет и применяет изменения профиля
+func (s *Service) UpdateProfile(ctx context.Context, userID string, update ProfileUpdate) (*MeResponse, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, changed, err := applyProfileUpdate(user.Profile, update, user.IsMentor)
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) == 0 {
+		return &MeResponse{UserInfo: s.userInfo(user), Profile: user.Profile}, nil
+	}
+
+	if err := s.repo.SetProfile(ctx, user.ID, profile); err != nil {
+		return nil, err
+	}
+	user.Profile = profile
+
+	// Имя и локаль входят в claims токенов, поэтому выданные access токены устаревают
+	if s.settings.ProfileClaims && (contains(changed, "display_name") || contains(changed, "locale")) {
+		if err := s.refreshSessionClaims(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventProfileChanged,
+		UserID:  user.ID,
+		ActorID: user.ID,
+		Data:    map[string]interface{}{"fields": changed},
+	})
+
+	return &MeResponse{UserInfo: s.userInfo(user), Profile: user.Profile}, nil
+}
+
+// applyProfileUpdate валидирует изменения и возвращает новый профиль и список измененных полей
+func applyProfileUpdate(profile Profile, update ProfileUpdate, isMentor bool) (Profile, []string, error) {
+	errs := map[string]string{}
+	var changed []string
+
+	set := func(field string, value *string, current *string, normalize func(string) (string, error)) {
+		if value == nil {
+			return
+		}
+		normalized := strings.TrimSpace(*value)
+		if normalized != "" {
+			var err error
+			if normalized, err = normalize(normalized); err != nil {
+				errs[field] = err.Error()
+				return
+			}
+		}
+		if normalized != *current {
+			*current = normalized
+			changed = append(changed, field)
+		}
+	}
+
+	set("display_name", update.DisplayName, &profile.DisplayName, normalizeDisplayName)
+	set("avatar_url", update.AvatarURL, &profile.AvatarURL, normalizeAvatarURL)
+	set("locale", update.Locale, &profile.Locale, normalizeLocale)
+	set("timezone", update.Timezone, &profile.Timezone, normalizeTimezone)
+	set("mentor_bio", update.MentorBio, &profile.MentorBio, func(bio string) (string, error) {
+		if !isMentor {
+			return "", fmt.Errorf("available only for mentors")
+		}
+		return normalizeMentorBio(bio)
+	})
+
+	if len(errs) > 0 {
+		return Profile{}, nil, &ProfileValidationError{Fields: errs}
+	}
+	return profile, changed, nil
+}
+
+func normalizeDisplayName(name string) (string, error) {
+	if utf8.RuneCountInString(name) > maxDisplayNameLength {
+		return "", fmt.Errorf("must be at most %d characters", maxDisplayNameLength)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("must not contain control characters")
+		}
+	}
+	return name, nil
+}
+
+func normalizeAvatarURL(rawURL string) (string, error) {
+	if len(rawURL) > maxAvatarURLLength {
+		return "", fmt.Errorf("must be at most %d characters", maxAvatarURLLength)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("must be an absolute https URL")
+	}
+	return u.String(), nil
+}
+
+func normalizeLocale(locale string) (string, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", fmt.Errorf("must be a valid BCP 47 language tag")
+	}
+	return tag.String(), nil
+}
+
+func normalizeTimezone(timezone string) (string, error) {
+	if timezone == "Local" {
+		return "", fmt.Errorf("must be an IANA time zone name")
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return "", fmt.Errorf("must be an IANA time zone name")
+	}
+	return timezone, nil
+}
+
+func normalizeMentorBio(bio string) (string, error) {
+	if utf8.RuneCountInString(bio) > maxMentorBioLength {
+		return "", fmt.Errorf("must be at most %d characters", maxMentorBioLength)
+	}
+	return bio, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
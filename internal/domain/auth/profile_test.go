@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestApplyProfileUpdate_Valid(t *testing.T) {
+	update := ProfileUpdate{
+		DisplayName: strPtr("  Иван  "),
+		AvatarURL:   strPtr("https://cdn.example.com/avatar.png"),
+		Locale:      strPtr("ru-ru"),
+		Timezone:    strPtr("Europe/Moscow"),
+		MentorBio:   strPtr("Go и Kubernetes"),
+	}
+	profile, changed, err := applyProfileUpdate(Profile{}, update, true)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if profile.DisplayName != "Иван" {
+		t.Errorf("expected trimmed display name, got %q", profile.DisplayName)
+	}
+	if profile.Locale != "ru-RU" {
+		t.Errorf("expected canonical locale ru-RU, got %q", profile.Locale)
+	}
+	if len(changed) != 5 {
+		t.Errorf("expected 5 changed fields, got %v", changed)
+	}
+}
+
+func TestApplyProfileUpdate_Invalid(t *testing.T) {
+	update := ProfileUpdate{
+		DisplayName: strPtr(strings.Repeat("a", maxDisplayNameLength+1)),
+		AvatarURL:   strPtr("http://example.com/avatar.png"),
+		Locale:      strPtr("not a locale"),
+		Timezone:    strPtr("Mars/Olympus"),
+		MentorBio:   strPtr("bio"),
+	}
+	_, _, err := applyProfileUpdate(Profile{}, update, false)
+	validationErr, ok := err.(*ProfileValidationError)
+	if !ok {
+		t.Fatalf("expected ProfileValidationError, got %v", err)
+	}
+	for _, field := range []string{"display_name", "avatar_url", "locale", "timezone", "mentor_bio"} {
+		if _, ok := validationErr.Fields[field]; !ok {
+			t.Errorf("expected error for field %s", field)
+		}
+	}
+}
+
+func TestApplyProfileUpdate_ClearAndUnchanged(t *testing.T) {
+	current := Profile{DisplayName: "Иван", Locale: "ru"}
+	profile, changed, err := applyProfileUpdate(current, ProfileUpdate{DisplayName: strPtr(""), Locale: strPtr("ru")}, false)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if profile.DisplayName != "" {
+		t.Errorf("expected display name to be cleared, got %q", profile.DisplayName)
+	}
+	if len(changed) != 1 || changed[0] != "display_name" {
+		t.Errorf("expected only display_name to change, got %v", changed)
+	}
+}
@@ -19,13 +19,18 @@ type ProjectionProcessor interface {
 
 type projectionProcessor struct {
 	accountRepo repository.AccountRepository
+	tokenRepo   repository.AbstractTokenRepository
+	otpRepo     repository.OTPRepository
 	log         *slog.Logger
 }
 
 // NewProjectionProcessor создает новый экземпляр projectionProcessor.
-func NewProjectionProcessor(accountRepo repository.AccountRepository, log *slog.Logger) *projectionProcessor {
+func NewProjectionProcessor(accountRepo repository.AccountRepository, tokenRepo repository.AbstractTokenRepository,
+	otpRepo repository.OTPRepository, log *slog.Logger) *projectionProcessor {
 	return &projectionProcessor{
 		accountRepo: accountRepo,
+		tokenRepo:   tokenRepo,
+		otpRepo:     otpRepo,
 		log:         log,
 	}
 }
@@ -55,6 +60,11 @@ func (p *projectionProcessor) ProcessEvent(ctx context.Context, event es.Event)
 		if err != nil {
 			return err
 		}
+	case events.AccountErased:
+		err := p.handleAccountErased(ctx, event)
+		if err != nil {
+			return err
+		}
 	default:
 		p.log.Warn("Unhandled event type", slog.String("eventType", string(event.EventType)))
 	}
@@ -148,6 +158,27 @@ func (p *projectionProcessor) handleRestorePassword(ctx context.Context, event e
 	return nil
 }
 
+// Обработка события AccountErased: удаляет аккаунт и связанные с ним данные из read-моделей.
+func (p *projectionProcessor) handleAccountErased(ctx context.Context, event es.Event) error {
+	user, err := p.accountRepo.GetById(ctx, event.AggregateID)
+	if err != nil {
+		// Проекция могла быть уже очищена при повторной обработке события
+		p.log.Warn("Erased account not found", slog.String("id", event.AggregateID.String()))
+		return nil
+	}
+	if _, err := p.tokenRepo.RevokeAllTokens(ctx, user.Email.ToString()); err != nil {
+		return err
+	}
+	if err := p.otpRepo.DeleteOTP(ctx, event.AggregateID); err != nil {
+		return err
+	}
+	if err := p.accountRepo.Delete(ctx, event.AggregateID); err != nil {
+		return err
+	}
+	p.log.Info("Account erased", slog.String("id", event.AggregateID.String()))
+	return nil
+}
+
 type AccountProjection struct {
 	Id          uuid.UUID
 	Email       values.Email
@@ -0,0 +1,15 @@
+package push
+
+import "context"
+
+// Notification - push-уведомление на устройство пользователя
+type Notification struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// Sender доставляет push-уведомления по токену устройства
+type Sender interface {
+	Send(ctx context.Context, deviceToken string, notification Notification) error
+}
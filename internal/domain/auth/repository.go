@@ -3,22 +3,164 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"kubercode/internal/domain/auth/audit"
+	"kubercode/internal/domain/auth/fieldcrypt"
 )
 
 type Repository struct {
 	db *mongo.Database
+	// crypt шифрует чувствительные поля аккаунтов, nil - поля хранятся открытыми
+	crypt *fieldcrypt.Keyring
 }
 
 func NewRepository(db *mongo.Database) *Repository {
 	return &Repository{db: db}
 }
 
+// EnsureIndexes создает индексы коллекций сервиса
+func (r *Repository) EnsureIndexes(ctx context.Context) error {
+	// Имя пользователя необязательно, поэтому уникальность проверяется только среди заданных имен
+	_, err := r.db.Collection("accounts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.M{"username_normalized": 1},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"username_normalized": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Аккаунты ищутся по слепому индексу токена устройства
+	_, err = r.db.Collection("accounts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"device_token_bidx": 1},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Один пользователь провайдера может быть привязан только к одному аккаунту
+	_, err = r.db.Collection("identities").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "subject", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Незавершенные авторизации у провайдеров удаляются после истечения срока
+	_, err = r.db.Collection("oauth_states").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expires_at": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Collection("organization_members").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Просроченные приглашения удаляются автоматически
+	_, err = r.db.Collection("organization_invitations").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expires_at": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Домен может добавить несколько организаций, но подтвердить - только одна
+	_, err = r.db.Collection("organization_domains").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "domain", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.M{"domain": 1},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"verified": true}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Collection("saml_service_providers").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"entity_id": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Collection("scim_tokens").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"token_hash": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Collection("organization_groups").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "display_name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Сочетание признаков устройства учитывается у аккаунта один раз, давно не использованные устройства забываются
+	_, err = r.db.Collection("known_devices").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.M{"expires_at": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Записи журнала аудита выбираются по участникам, действию и времени, порядок задает номер записи
+	_, err = r.db.Collection("audit_log").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.M{"time": 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Незавершенные запросы SP, истекшие SAML сессии и старые неудачные попытки входа удаляются автоматически
+	for _, name := range []string{"saml_requests", "saml_sessions", "login_attempts"} {
+		_, err = r.db.Collection(name).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.M{"expires_at": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *Repository) CreateUser(ctx context.Context, user *User) error {
 	collection := r.db.Collection("accounts")
 
@@ -34,7 +176,14 @@ func (r *Repository) CreateUser(ctx context.Context, user *User) error {
 	user.UpdatedAt = time.Now()
 
 	// Создаем пользователя
-	_, err = collection.InsertOne(ctx, user)
+	sealed, err := r.sealUser(user)
+	if err != nil {
+		return err
+	}
+	_, err = collection.InsertOne(ctx, sealed)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrUsernameTaken
+	}
 	return err
 }
 
@@ -45,13 +194,29 @@ func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*User, e
 	err := collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		log.Printf("[Repository] Ошибка при поиске пользователя: %v", err)
 		return nil, err
 	}
 
-	return &user, nil
+	return &user, r.openUser(&user)
+}
+
+// GetUserByUsername ищет пользователя по имени в нижнем регистре
+func (r *Repository) GetUserByUsername(ctx context.Context, normalized string) (*User, error) {
+	collection := r.db.Collection("accounts")
+
+	var user User
+	err := collection.FindOne(ctx, bson.M{"username_normalized": normalized}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, r.openUser(&user)
 }
 
 func (r *Repository) GetUserByID(ctx context.Context, id primitive.ObjectID) (*User, error) {
@@ -61,27 +226,239 @@ func (r *Repository) GetUserByID(ctx context.Context, id primitive.ObjectID) (*U
 	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
 
-	return &user, nil
+	return &user, r.openUser(&user)
 }
 
 func (r *Repository) UpdateUser(ctx context.Context, user *User) error {
 	collection := r.db.Collection("accounts")
 
 	user.UpdatedAt = time.Now()
+	sealed, err := r.sealUser(user)
+	if err != nil {
+		return err
+	}
 
-	_, err := collection.UpdateOne(
+	_, err = collection.UpdateOne(
 		ctx,
 		bson.M{"_id": user.ID},
-		bson.M{"$set": user},
+		bson.M{"$set": sealed},
+	)
+	return err
+}
+
+// ReplacePasswordHash заменяет хеш пароля, если он не менялся с момента чтения пользователя
+func (r *Repository) ReplacePasswordHash(ctx context.Context, id primitive.ObjectID, oldHash, newHash string) error {
+	collection := r.db.Collection("accounts")
+
+	_, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "password": oldHash},
+		bson.M{"$set": bson.M{"password": newHash, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+// SetEmailVerified отмечает email пользователя как подтвержденный
+func (r *Repository) SetEmailVerified(ctx context.Context, id primitive.ObjectID) error {
+	collection := r.db.Collection("accounts")
+
+	_, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"email_verified": true, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+// SetTokensValidAfter сохраняет момент, раньше которого выпущенные токены недействительны
+func (r *Repository) SetTokensValidAfter(ctx context.Context, id primitive.ObjectID, validAfter time.Time) error {
+	collection := r.db.Collection("accounts")
+
+	_, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"tokens_valid_after": validAfter, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+// SetClaimsChangedAt сохраняет момент, раньше которого access токены содержат устаревшие claims
+func (r *Repository) SetClaimsChangedAt(ctx context.Context, id primitive.ObjectID, changedAt time.Time) error {
+	collection := r.db.Collection("accounts")
+
+	_, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"claims_changed_at": changedAt, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+// SetVerificationSentAt сохраняет время последней отправки письма подтверждения
+func (r *Repository) SetVerificationSentAt(ctx context.Context, id primitive.ObjectID, sentAt time.Time) error {
+	collection := r.db.Collection("accounts")
+
+	_, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"verification_sent_at": sentAt}},
+	)
+	return err
+}
+
+// SetDeletionScheduledAt планирует удаление аккаунта. Нулевое время отменяет удаление
+func (r *Repository) SetDeletionScheduledAt(ctx context.Context, id primitive.ObjectID, scheduledAt time.Time) error {
+	collection := r.db.Collection("accounts")
+
+	update := bson.M{"$set": bson.M{"deletion_scheduled_at": scheduledAt, "updated_at": time.Now()}}
+	if scheduledAt.IsZero() {
+		update = bson.M{
+			"$unset": bson.M{"deletion_scheduled_at": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		}
+	}
+
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// SetUsername сохраняет имя пользователя. Если имя уже занято, возвращает ErrUsernameTaken
+func (r *Repository) SetUsername(ctx context.Context, id primitive.ObjectID, username, normalized string, changedAt time.Time) error {
+	collection := r.db.Collection("accounts")
+
+	_, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{
+			"username":            username,
+			"username_normalized": normalized,
+			"username_changed_at": changedAt,
+			"updated_at":          time.Now(),
+		}},
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrUsernameTaken
+	}
+	return err
+}
+
+// SetProfile сохраняет профиль пользователя
+func (r *Repository) SetProfile(ctx context.Context, id primitive.ObjectID, profile Profile) error {
+	collection := r.db.Collection("accounts")
+
+	_, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"profile": profile, "updated_at": time.Now()}},
 	)
 	return err
 }
 
+// SetAccountRestrictions сохраняет ограничения, наложенные на аккаунт
+func (r *Repository) SetAccountRestrictions(ctx context.Context, id primitive.ObjectID, restrictions AccountRestrictions) error {
+	collection := r.db.Collection("accounts")
+
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{
+			"disabled":                restrictions.Disabled,
+			"disabled_by_org":         restrictions.DisabledByOrg,
+			"locked_until":            restrictions.LockedUntil,
+			"banned":                  restrictions.Banned,
+			"ban_reason":              restrictions.BanReason,
+			"password_reset_required": restrictions.PasswordResetRequired,
+			"updated_at":              time.Now(),
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// GetUsersScheduledForDeletion возвращает ID пользователей, срок удаления которых наступил
+func (r *Repository) GetUsersScheduledForDeletion(ctx context.Context, before time.Time) ([]primitive.ObjectID, error) {
+	filter := bson.M{"deletion_scheduled_at": bson.M{"$lte": before}}
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+
+	cursor, err := r.db.Collection("accounts").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	return ids, nil
+}
+
+// EraseUserData удаляет аккаунт и все связанные с ним данные
+func (r *Repository) EraseUserData(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := r.db.Collection("tokens").DeleteMany(ctx, bson.M{"user_id": id}); err != nil {
+		return err
+	}
+	if _, err := r.db.Collection("password_resets").DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return err
+	}
+	if _, err := r.db.Collection("email_changes").DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return err
+	}
+	if _, err := r.db.Collection("login_history").DeleteMany(ctx, bson.M{"user_id": id}); err != nil {
+		return err
+	}
+	if _, err := r.db.Collection("data_exports").DeleteMany(ctx, bson.M{"user_id": id}); err != nil {
+		return err
+	}
+	if _, err := r.db.Collection("identities").DeleteMany(ctx, bson.M{"user_id": id}); err != nil {
+		return err
+	}
+	if _, err := r.db.Collection("saml_sessions").DeleteMany(ctx, bson.M{"user_id": id}); err != nil {
+		return err
+	}
+	if _, err := r.db.Collection("login_attempts").DeleteMany(ctx, bson.M{"user_id": id}); err != nil {
+		return err
+	}
+	if _, err := r.db.Collection("known_devices").DeleteMany(ctx, bson.M{"user_id": id}); err != nil {
+		return err
+	}
+	if _, err := r.db.Collection("domain_events").DeleteMany(ctx, bson.M{"user_id": id}); err != nil {
+		return err
+	}
+	if err := r.eraseAuditPersonalData(ctx, id.Hex()); err != nil {
+		return err
+	}
+	if err := r.eraseOrganizationData(ctx, id); err != nil {
+		return err
+	}
+
+	result, err := r.db.Collection("accounts").DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
 func (r *Repository) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
 	collection := r.db.Collection("accounts")
 
@@ -161,6 +538,14 @@ func (r *Repository) GetUserTokens(ctx context.Context, userID string) ([]*Token
 	return tokens, nil
 }
 
+// DeleteSession удаляет refresh токен одной сессии пользователя
+func (r *Repository) DeleteSession(ctx context.Context, userID, sessionID primitive.ObjectID) error {
+	collection := r.db.Collection("tokens")
+
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": sessionID, "user_id": userID})
+	return err
+}
+
 // DeleteUserTokens удаляет все токены пользователя
 func (r *Repository) DeleteUserTokens(ctx context.Context, userID string) error {
 	id, err := primitive.ObjectIDFromHex(userID)
@@ -171,4 +556,1290 @@ func (r *Repository) DeleteUserTokens(ctx context.Context, userID string) error
 	filter := bson.M{"user_id": id}
 	_, err = r.db.Collection("tokens").DeleteMany(ctx, filter)
 	return err
-} 
\ No newline at end of file
+} 
+
+// PasswordReset представляет собой активный запрос на восстановление пароля
+type PasswordReset struct {
+	UserID    primitive.ObjectID `bson:"_id"`
+	CodeHash  string             `bson:"code_hash"`
+	Attempts  int                `bson:"attempts"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// SavePasswordReset сохраняет запрос на восстановление, заменяя предыдущий
+func (r *Repository) SavePasswordReset(ctx context.Context, reset *PasswordReset) error {
+	collection := r.db.Collection("password_resets")
+
+	reset.CreatedAt = time.Now()
+	reset.Attempts = 0
+
+	_, err := collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": reset.UserID},
+		reset,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// GetPasswordReset возвращает активный запрос на восстановление пароля пользователя
+func (r *Repository) GetPasswordReset(ctx context.Context, userID primitive.ObjectID) (*PasswordReset, error) {
+	collection := r.db.Collection("password_resets")
+
+	var reset PasswordReset
+	err := collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&reset)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("password reset not found")
+		}
+		return nil, err
+	}
+
+	return &reset, nil
+}
+
+// codeAttemptFilter выбирает запрос с кодом подтверждения, который не истек к моменту now
+// и у которого остались попытки ввода
+func codeAttemptFilter(userID primitive.ObjectID, maxAttempts int, now time.Time) bson.M {
+	return bson.M{
+		"_id":        userID,
+		"attempts":   bson.M{"$lt": maxAttempts},
+		"expires_at": bson.M{"$gt": now},
+	}
+}
+
+// UsePasswordResetAttempt атомарно расходует попытку ввода кода: счетчик увеличивается, только
+// если запрос не истек и попытки не исчерпаны, поэтому параллельные запросы не превысят maxAttempts.
+// Если попытки не осталось, возвращается ошибка "password reset not found"
+func (r *Repository) UsePasswordResetAttempt(ctx context.Context, userID primitive.ObjectID, maxAttempts int) (*PasswordReset, error) {
+	collection := r.db.Collection("password_resets")
+
+	filter := codeAttemptFilter(userID, maxAttempts, time.Now())
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var reset PasswordReset
+	err := collection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"attempts": 1}}, opts).Decode(&reset)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("password reset not found")
+		}
+		return nil, err
+	}
+
+	return &reset, nil
+}
+
+// DeletePasswordReset удаляет запрос на восстановление пароля
+func (r *Repository) DeletePasswordReset(ctx context.Context, userID primitive.ObjectID) error {
+	collection := r.db.Collection("password_resets")
+
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
+
+// EmailChange представляет собой ожидающую подтверждения смену email
+type EmailChange struct {
+	UserID    primitive.ObjectID `bson:"_id"`
+	NewEmail  string             `bson:"new_email"`
+	CodeHash  string             `bson:"code_hash"`
+	Attempts  int                `bson:"attempts"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// SaveEmailChange сохраняет запрос на смену email, заменяя предыдущий
+func (r *Repository) SaveEmailChange(ctx context.Context, change *EmailChange) error {
+	collection := r.db.Collection("email_changes")
+
+	change.CreatedAt = time.Now()
+	change.Attempts = 0
+
+	_, err := collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": change.UserID},
+		change,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// GetEmailChange возвращает ожидающую подтверждения смену email пользователя
+func (r *Repository) GetEmailChange(ctx context.Context, userID primitive.ObjectID) (*EmailChange, error) {
+	collection := r.db.Collection("email_changes")
+
+	var change EmailChange
+	err := collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&change)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("email change not found")
+		}
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+// UseEmailChangeAttempt атомарно расходует попытку ввода кода, как UsePasswordResetAttempt.
+// Если попытки не осталось, возвращается ошибка "email change not found"
+func (r *Repository) UseEmailChangeAttempt(ctx context.Context, userID primitive.ObjectID, maxAttempts int) (*EmailChange, error) {
+	collection := r.db.Collection("email_changes")
+
+	filter := codeAttemptFilter(userID, maxAttempts, time.Now())
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var change EmailChange
+	err := collection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"attempts": 1}}, opts).Decode(&change)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("email change not found")
+		}
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+// DeleteEmailChange удаляет запрос на смену email
+func (r *Repository) DeleteEmailChange(ctx context.Context, userID primitive.ObjectID) error {
+	collection := r.db.Collection("email_changes")
+
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
+
+// SaveLoginRecord добавляет запись в историю входов
+func (r *Repository) SaveLoginRecord(ctx context.Context, record *LoginRecord) error {
+	collection := r.db.Collection("login_history")
+
+	_, err := collection.InsertOne(ctx, record)
+	return err
+}
+
+// GetLoginHistory возвращает историю входов пользователя, начиная с последних
+func (r *Repository) GetLoginHistory(ctx context.Context, userID primitive.ObjectID) ([]*LoginRecord, error) {
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+
+	cursor, err := r.db.Collection("login_history").Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []*LoginRecord
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// DataExportArchive - подготовленный архив с данными пользователя
+type DataExportArchive struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Data      []byte             `bson:"data"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// SaveDataExport сохраняет архив и удаляет архивы с истекшим сроком хранения
+func (r *Repository) SaveDataExport(ctx context.Context, archive *DataExportArchive) error {
+	collection := r.db.Collection("data_exports")
+
+	if _, err := collection.DeleteMany(ctx, bson.M{"expires_at": bson.M{"$lt": time.Now()}}); err != nil {
+		return err
+	}
+
+	result, err := collection.InsertOne(ctx, archive)
+	if err != nil {
+		return err
+	}
+	archive.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// GetDataExport возвращает архив по ID
+func (r *Repository) GetDataExport(ctx context.Context, id primitive.ObjectID) (*DataExportArchive, error) {
+	collection := r.db.Collection("data_exports")
+
+	var archive DataExportArchive
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&archive)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("data export not found")
+		}
+		return nil, err
+	}
+	return &archive, nil
+}
+
+// SaveDomainEvent добавляет доменное событие в журнал
+func (r *Repository) SaveDomainEvent(ctx context.Context, event *DomainEvent) error {
+	collection := r.db.Collection("domain_events")
+
+	_, err := collection.InsertOne(ctx, event)
+	return err
+}
+
+// GetUserDomainEvents возвращает доменные события аккаунта в порядке их появления
+func (r *Repository) GetUserDomainEvents(ctx context.Context, userID primitive.ObjectID) ([]*DomainEvent, error) {
+	opts := options.Find().SetSort(bson.M{"occurred_at": 1})
+
+	cursor, err := r.db.Collection("domain_events").Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []*DomainEvent{}
+	if err = cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// SaveOAuthState сохраняет незавершенную авторизацию у внешнего провайдера
+func (r *Repository) SaveOAuthState(ctx context.Context, state *OAuthState) error {
+	collection := r.db.Collection("oauth_states")
+
+	_, err := collection.InsertOne(ctx, state)
+	return err
+}
+
+// TakeOAuthState возвращает и удаляет незавершенную авторизацию, поэтому state можно использовать один раз
+func (r *Repository) TakeOAuthState(ctx context.Context, state string) (*OAuthState, error) {
+	collection := r.db.Collection("oauth_states")
+
+	var pending OAuthState
+	err := collection.FindOneAndDelete(ctx, bson.M{"_id": state}).Decode(&pending)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidOAuthState
+		}
+		return nil, err
+	}
+	return &pending, nil
+}
+
+// SaveIdentity привязывает пользователя провайдера к аккаунту
+func (r *Repository) SaveIdentity(ctx context.Context, ident *ExternalIdentity) error {
+	collection := r.db.Collection("identities")
+
+	result, err := collection.InsertOne(ctx, ident)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrIdentityAlreadyLinked
+		}
+		return err
+	}
+	ident.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// GetIdentity возвращает привязку по провайдеру и идентификатору пользователя у провайдера
+func (r *Repository) GetIdentity(ctx context.Context, provider, subject string) (*ExternalIdentity, error) {
+	collection := r.db.Collection("identities")
+
+	var ident ExternalIdentity
+	err := collection.FindOne(ctx, bson.M{"provider": provider, "subject": subject}).Decode(&ident)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrIdentityNotLinked
+		}
+		return nil, err
+	}
+	return &ident, nil
+}
+
+// GetUserIdentities возвращает все привязки аккаунта
+func (r *Repository) GetUserIdentities(ctx context.Context, userID primitive.ObjectID) ([]*ExternalIdentity, error) {
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+
+	cursor, err := r.db.Collection("identities").Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	identities := []*ExternalIdentity{}
+	if err = cursor.All(ctx, &identities); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// DeleteIdentity удаляет привязку провайдера к аккаунту
+func (r *Repository) DeleteIdentity(ctx context.Context, userID primitive.ObjectID, provider string) error {
+	collection := r.db.Collection("identities")
+
+	result, err := collection.DeleteOne(ctx, bson.M{"user_id": userID, "provider": provider})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrIdentityNotLinked
+	}
+	return nil
+}
+
+// CreateOrganization сохраняет новую организацию
+func (r *Repository) CreateOrganization(ctx context.Context, org *Organization) error {
+	collection := r.db.Collection("organizations")
+
+	_, err := collection.InsertOne(ctx, org)
+	return err
+}
+
+// GetOrganization возвращает организацию по ID
+func (r *Repository) GetOrganization(ctx context.Context, id primitive.ObjectID) (*Organization, error) {
+	collection := r.db.Collection("organizations")
+
+	var org Organization
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&org)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+// SetOrganizationOwner назначает владельца организации
+func (r *Repository) SetOrganizationOwner(ctx context.Context, orgID, ownerID primitive.ObjectID) error {
+	collection := r.db.Collection("organizations")
+
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": orgID}, bson.M{"$set": bson.M{"owner_id": ownerID}})
+	return err
+}
+
+// OwnsOrganizations проверяет, владеет ли пользователь хотя бы одной организацией
+func (r *Repository) OwnsOrganizations(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	collection := r.db.Collection("organizations")
+
+	count, err := collection.CountDocuments(ctx, bson.M{"owner_id": userID}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// SaveMembership добавляет пользователя в организацию
+func (r *Repository) SaveMembership(ctx context.Context, membership *OrgMembership) error {
+	collection := r.db.Collection("organization_members")
+
+	_, err := collection.InsertOne(ctx, membership)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyOrgMember
+	}
+	return err
+}
+
+// GetMembership возвращает участие пользователя в организации
+func (r *Repository) GetMembership(ctx context.Context, orgID, userID primitive.ObjectID) (*OrgMembership, error) {
+	collection := r.db.Collection("organization_members")
+
+	var membership OrgMembership
+	err := collection.FindOne(ctx, bson.M{"org_id": orgID, "user_id": userID}).Decode(&membership)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotOrgMember
+		}
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// GetUserMemberships возвращает все организации, в которых состоит пользователь
+func (r *Repository) GetUserMemberships(ctx context.Context, userID primitive.ObjectID) ([]*OrgMembership, error) {
+	return r.findMemberships(ctx, bson.M{"user_id": userID})
+}
+
+// GetOrganizationMembers возвращает участников организации
+func (r *Repository) GetOrganizationMembers(ctx context.Context, orgID primitive.ObjectID) ([]*OrgMembership, error) {
+	return r.findMemberships(ctx, bson.M{"org_id": orgID})
+}
+
+func (r *Repository) findMemberships(ctx context.Context, filter bson.M) ([]*OrgMembership, error) {
+	opts := options.Find().SetSort(bson.M{"joined_at": 1})
+
+	cursor, err := r.db.Collection("organization_members").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	memberships := []*OrgMembership{}
+	if err = cursor.All(ctx, &memberships); err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// DeleteMembership удаляет пользователя из организации
+func (r *Repository) DeleteMembership(ctx context.Context, orgID, userID primitive.ObjectID) error {
+	collection := r.db.Collection("organization_members")
+
+	result, err := collection.DeleteOne(ctx, bson.M{"org_id": orgID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotOrgMember
+	}
+	return nil
+}
+
+// SaveInvitation сохраняет приглашение в организацию
+func (r *Repository) SaveInvitation(ctx context.Context, invitation *OrgInvitation) error {
+	collection := r.db.Collection("organization_invitations")
+
+	result, err := collection.InsertOne(ctx, invitation)
+	if err != nil {
+		return err
+	}
+	invitation.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// GetInvitationByTokenHash возвращает приглашение по хешу токена из письма
+func (r *Repository) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*OrgInvitation, error) {
+	collection := r.db.Collection("organization_invitations")
+
+	var invitation OrgInvitation
+	err := collection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&invitation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// DeleteInvitation удаляет приглашение
+func (r *Repository) DeleteInvitation(ctx context.Context, id primitive.ObjectID) error {
+	collection := r.db.Collection("organization_invitations")
+
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// eraseOrganizationData удаляет участие пользователя в организациях и их группах.
+// Владельца организации удалить нельзя, сначала он должен передать владение
+func (r *Repository) eraseOrganizationData(ctx context.Context, userID primitive.ObjectID) error {
+	if _, err := r.db.Collection("organization_groups").UpdateMany(ctx,
+		bson.M{"members": userID}, bson.M{"$pull": bson.M{"members": userID}}); err != nil {
+		return err
+	}
+
+	_, err := r.db.Collection("organization_members").DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+// SetMembershipRole меняет роль участника организации
+func (r *Repository) SetMembershipRole(ctx context.Context, orgID, userID primitive.ObjectID, role OrgRole) error {
+	collection := r.db.Collection("organization_members")
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"org_id": orgID, "user_id": userID},
+		bson.M{"$set": bson.M{"role": role}},
+	)
+	return err
+}
+
+// SaveOrgSSOConfig создает или заменяет настройки провайдера организации
+func (r *Repository) SaveOrgSSOConfig(ctx context.Context, cfg *OrgSSOConfig) error {
+	collection := r.db.Collection("organization_sso")
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": cfg.OrgID}, cfg, options.Replace().SetUpsert(true))
+	return err
+}
+
+// GetOrgSSOConfig возвращает настройки провайдера организации
+func (r *Repository) GetOrgSSOConfig(ctx context.Context, orgID primitive.ObjectID) (*OrgSSOConfig, error) {
+	collection := r.db.Collection("organization_sso")
+
+	var cfg OrgSSOConfig
+	err := collection.FindOne(ctx, bson.M{"_id": orgID}).Decode(&cfg)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSSONotConfigured
+		}
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// DeleteOrgSSOConfig удаляет настройки провайдера организации
+func (r *Repository) DeleteOrgSSOConfig(ctx context.Context, orgID primitive.ObjectID) error {
+	collection := r.db.Collection("organization_sso")
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": orgID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrSSONotConfigured
+	}
+	return nil
+}
+
+// SaveOrgDomain добавляет домен организации
+func (r *Repository) SaveOrgDomain(ctx context.Context, domain *OrgDomain) error {
+	collection := r.db.Collection("organization_domains")
+
+	result, err := collection.InsertOne(ctx, domain)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDomainTaken
+		}
+		return err
+	}
+	domain.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// GetOrgDomains возвращает домены организации
+func (r *Repository) GetOrgDomains(ctx context.Context, orgID primitive.ObjectID) ([]*OrgDomain, error) {
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+
+	cursor, err := r.db.Collection("organization_domains").Find(ctx, bson.M{"org_id": orgID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	domains := []*OrgDomain{}
+	if err = cursor.All(ctx, &domains); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// GetOrgDomain возвращает домен организации
+func (r *Repository) GetOrgDomain(ctx context.Context, orgID primitive.ObjectID, domain string) (*OrgDomain, error) {
+	return r.findOrgDomain(ctx, bson.M{"org_id": orgID, "domain": domain})
+}
+
+// GetVerifiedDomain возвращает подтвержденный домен организации
+func (r *Repository) GetVerifiedDomain(ctx context.Context, orgID primitive.ObjectID, domain string) (*OrgDomain, error) {
+	return r.findOrgDomain(ctx, bson.M{"org_id": orgID, "domain": domain, "verified": true})
+}
+
+// FindVerifiedDomain ищет организацию, подтвердившую домен
+func (r *Repository) FindVerifiedDomain(ctx context.Context, domain string) (*OrgDomain, error) {
+	return r.findOrgDomain(ctx, bson.M{"domain": domain, "verified": true})
+}
+
+func (r *Repository) findOrgDomain(ctx context.Context, filter bson.M) (*OrgDomain, error) {
+	collection := r.db.Collection("organization_domains")
+
+	var domain OrgDomain
+	err := collection.FindOne(ctx, filter).Decode(&domain)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrDomainNotFound
+		}
+		return nil, err
+	}
+	return &domain, nil
+}
+
+// SetOrgDomainVerified отмечает домен подтвержденным
+func (r *Repository) SetOrgDomainVerified(ctx context.Context, id primitive.ObjectID, verifiedAt time.Time) error {
+	collection := r.db.Collection("organization_domains")
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"verified": true, "verified_at": verifiedAt}},
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDomainTaken
+	}
+	return err
+}
+
+// DeleteOrgDomain удаляет домен организации
+func (r *Repository) DeleteOrgDomain(ctx context.Context, orgID primitive.ObjectID, domain string) error {
+	collection := r.db.Collection("organization_domains")
+
+	result, err := collection.DeleteOne(ctx, bson.M{"org_id": orgID, "domain": domain})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrDomainNotFound
+	}
+	return nil
+}
+
+// SaveSAMLServiceProvider регистрирует SAML SP
+func (r *Repository) SaveSAMLServiceProvider(ctx context.Context, sp *SAMLServiceProvider) error {
+	collection := r.db.Collection("saml_service_providers")
+
+	result, err := collection.InsertOne(ctx, sp)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrSAMLSPExists
+		}
+		return err
+	}
+	sp.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// GetSAMLServiceProviders возвращает все зарегистрированные SP
+func (r *Repository) GetSAMLServiceProviders(ctx context.Context) ([]*SAMLServiceProvider, error) {
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+
+	cursor, err := r.db.Collection("saml_service_providers").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	providers := []*SAMLServiceProvider{}
+	if err = cursor.All(ctx, &providers); err != nil {
+		return nil, err
+	}
+	return providers, nil
+}
+
+// GetSAMLServiceProvider возвращает SP по ID
+func (r *Repository) GetSAMLServiceProvider(ctx context.Context, id primitive.ObjectID) (*SAMLServiceProvider, error) {
+	return r.findSAMLServiceProvider(ctx, bson.M{"_id": id})
+}
+
+// GetSAMLServiceProviderByEntityID возвращает SP по entity id из сообщения SP
+func (r *Repository) GetSAMLServiceProviderByEntityID(ctx context.Context, entityID string) (*SAMLServiceProvider, error) {
+	return r.findSAMLServiceProvider(ctx, bson.M{"entity_id": entityID})
+}
+
+func (r *Repository) findSAMLServiceProvider(ctx context.Context, filter bson.M) (*SAMLServiceProvider, error) {
+	var sp SAMLServiceProvider
+	err := r.db.Collection("saml_service_providers").FindOne(ctx, filter).Decode(&sp)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSAMLUnknownSP
+		}
+		return nil, err
+	}
+	return &sp, nil
+}
+
+// DeleteSAMLServiceProvider удаляет SP и сессии пользователей в нем
+func (r *Repository) DeleteSAMLServiceProvider(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := r.db.Collection("saml_sessions").DeleteMany(ctx, bson.M{"sp_id": id}); err != nil {
+		return err
+	}
+
+	result, err := r.db.Collection("saml_service_providers").DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrSAMLUnknownSP
+	}
+	return nil
+}
+
+// SaveSAMLRequest сохраняет запрос SP до входа пользователя
+func (r *Repository) SaveSAMLRequest(ctx context.Context, request *SAMLLoginRequest) error {
+	collection := r.db.Collection("saml_requests")
+
+	_, err := collection.InsertOne(ctx, request)
+	return err
+}
+
+// TakeSAMLRequest возвращает и удаляет запрос SP, поэтому на него можно ответить один раз
+func (r *Repository) TakeSAMLRequest(ctx context.Context, id string) (*SAMLLoginRequest, error) {
+	collection := r.db.Collection("saml_requests")
+
+	var request SAMLLoginRequest
+	err := collection.FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&request)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSAMLRequestExpired
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// SaveSAMLSession сохраняет сессию пользователя у SP
+func (r *Repository) SaveSAMLSession(ctx context.Context, session *SAMLSession) error {
+	collection := r.db.Collection("saml_sessions")
+
+	_, err := collection.InsertOne(ctx, session)
+	return err
+}
+
+// GetUserSAMLSessions возвращает сессии пользователя у SP
+func (r *Repository) GetUserSAMLSessions(ctx context.Context, userID primitive.ObjectID) ([]*SAMLSession, error) {
+	cursor, err := r.db.Collection("saml_sessions").Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []*SAMLSession{}
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// DeleteUserSAMLSessions удаляет все сессии пользователя у SP
+func (r *Repository) DeleteUserSAMLSessions(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.db.Collection("saml_sessions").DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+// DeleteSAMLSessions удаляет сессии пользователя с nameID у SP из списка ids и возвращает
+// число удаленных. Пустой список ничего не удаляет
+func (r *Repository) DeleteSAMLSessions(ctx context.Context, spID primitive.ObjectID, nameID string, ids []primitive.ObjectID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result, err := r.db.Collection("saml_sessions").DeleteMany(ctx, bson.M{
+		"_id":     bson.M{"$in": ids},
+		"sp_id":   spID,
+		"name_id": nameID,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// SaveSCIMToken сохраняет токен SCIM организации
+func (r *Repository) SaveSCIMToken(ctx context.Context, token *SCIMToken) error {
+	collection := r.db.Collection("scim_tokens")
+
+	result, err := collection.InsertOne(ctx, token)
+	if err != nil {
+		return err
+	}
+	token.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// GetSCIMTokens возвращает токены SCIM организации
+func (r *Repository) GetSCIMTokens(ctx context.Context, orgID primitive.ObjectID) ([]*SCIMToken, error) {
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+
+	cursor, err := r.db.Collection("scim_tokens").Find(ctx, bson.M{"org_id": orgID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tokens := []*SCIMToken{}
+	if err = cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// GetSCIMTokenByHash возвращает токен SCIM по хешу
+func (r *Repository) GetSCIMTokenByHash(ctx context.Context, tokenHash string) (*SCIMToken, error) {
+	collection := r.db.Collection("scim_tokens")
+
+	var token SCIMToken
+	err := collection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSCIMTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// TouchSCIMToken сохраняет время последнего использования токена
+func (r *Repository) TouchSCIMToken(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error {
+	collection := r.db.Collection("scim_tokens")
+
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": usedAt}})
+	return err
+}
+
+// DeleteSCIMToken удаляет токен SCIM организации
+func (r *Repository) DeleteSCIMToken(ctx context.Context, orgID, id primitive.ObjectID) error {
+	collection := r.db.Collection("scim_tokens")
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id, "org_id": orgID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrSCIMTokenNotFound
+	}
+	return nil
+}
+
+// GetUsersByIDs возвращает аккаунты по списку ID
+func (r *Repository) GetUsersByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*User, error) {
+	cursor, err := r.db.Collection("accounts").Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []*User{}
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if err := r.openUser(user); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+// SetMembershipExternalID сохраняет идентификатор участника в каталоге организации
+func (r *Repository) SetMembershipExternalID(ctx context.Context, orgID, userID primitive.ObjectID, externalID string) error {
+	collection := r.db.Collection("organization_members")
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"org_id": orgID, "user_id": userID},
+		bson.M{"$set": bson.M{"external_id": externalID}},
+	)
+	return err
+}
+
+// SaveOrgGroup сохраняет новую группу организации
+func (r *Repository) SaveOrgGroup(ctx context.Context, group *OrgGroup) error {
+	collection := r.db.Collection("organization_groups")
+
+	result, err := collection.InsertOne(ctx, group)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrSCIMGroupExists
+	}
+	if err != nil {
+		return err
+	}
+	group.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// ReplaceOrgGroup сохраняет название и состав группы
+func (r *Repository) ReplaceOrgGroup(ctx context.Context, group *OrgGroup) error {
+	collection := r.db.Collection("organization_groups")
+
+	result, err := collection.ReplaceOne(ctx, bson.M{"_id": group.ID, "org_id": group.OrgID}, group)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrSCIMGroupExists
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrSCIMGroupNotFound
+	}
+	return nil
+}
+
+// GetOrgGroup возвращает группу организации
+func (r *Repository) GetOrgGroup(ctx context.Context, orgID, id primitive.ObjectID) (*OrgGroup, error) {
+	collection := r.db.Collection("organization_groups")
+
+	var group OrgGroup
+	err := collection.FindOne(ctx, bson.M{"_id": id, "org_id": orgID}).Decode(&group)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSCIMGroupNotFound
+		}
+		return nil, err
+	}
+	return &group, nil
+}
+
+// GetOrgGroups возвращает группы организации
+func (r *Repository) GetOrgGroups(ctx context.Context, orgID primitive.ObjectID) ([]*OrgGroup, error) {
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+
+	cursor, err := r.db.Collection("organization_groups").Find(ctx, bson.M{"org_id": orgID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	groups := []*OrgGroup{}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// DeleteOrgGroup удаляет группу организации
+func (r *Repository) DeleteOrgGroup(ctx context.Context, orgID, id primitive.ObjectID) error {
+	collection := r.db.Collection("organization_groups")
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id, "org_id": orgID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrSCIMGroupNotFound
+	}
+	return nil
+}
+
+// PullGroupMember удаляет пользователя из всех групп организации
+func (r *Repository) PullGroupMember(ctx context.Context, orgID, userID primitive.ObjectID) error {
+	collection := r.db.Collection("organization_groups")
+
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"org_id": orgID, "members": userID},
+		bson.M{"$pull": bson.M{"members": userID}},
+	)
+	return err
+}
+
+// GetLoginAttempts возвращает неудачные попытки входа по ключу или nil, если их нет
+func (r *Repository) GetLoginAttempts(ctx context.Context, key string) (*LoginAttempts, error) {
+	collection := r.db.Collection("login_attempts")
+
+	var attempts LoginAttempts
+	err := collection.FindOne(ctx, bson.M{"_id": key}).Decode(&attempts)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+// AddLoginFailure увеличивает счетчик неудачных попыток и возвращает его новое значение
+func (r *Repository) AddLoginFailure(ctx context.Context, key string, userID primitive.ObjectID,
+	failedAt, expiresAt time.Time) (*LoginAttempts, error) {
+	collection := r.db.Collection("login_attempts")
+
+	set := bson.M{"last_failure_at": failedAt, "expires_at": expiresAt}
+	if !userID.IsZero() {
+		set["user_id"] = userID
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var attempts LoginAttempts
+	err := collection.FindOneAndUpdate(ctx, bson.M{"_id": key},
+		bson.M{"$inc": bson.M{"failures": 1}, "$set": set}, opts).Decode(&attempts)
+	if err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+// SetLoginLock блокирует вход до lockedUntil. Счетчик обнуляется, чтобы после блокировки
+// задержки начинались заново, а запись хранится до конца блокировки
+func (r *Repository) SetLoginLock(ctx context.Context, key string, lockedUntil time.Time) error {
+	collection := r.db.Collection("login_attempts")
+
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": key}, bson.M{
+		"$set": bson.M{"failures": 0, "locked_until": lockedUntil},
+		"$max": bson.M{"expires_at": lockedUntil},
+	})
+	return err
+}
+
+// DeleteLoginAttempts сбрасывает неудачные попытки входа
+func (r *Repository) DeleteLoginAttempts(ctx context.Context, key string) error {
+	collection := r.db.Collection("login_attempts")
+
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+// auditAppendAttempts - сколько раз запись журнала аудита повторяется, если параллельная запись заняла номер
+const auditAppendAttempts = 10
+
+var errAuditContention = errors.New("audit log append contention")
+
+// AppendAuditRecord добавляет запись в конец журнала аудита. Номер записи служит ключом,
+// поэтому из двух параллельных записей с одним номером сохраняется одна, а вторая
+// перечитывает последнюю запись и связывается с ней заново
+func (r *Repository) AppendAuditRecord(ctx context.Context, record *audit.Record) error {
+	collection := r.db.Collection("audit_log")
+	last := options.FindOne().SetSort(bson.M{"_id": -1})
+
+	for attempt := 0; attempt < auditAppendAttempts; attempt++ {
+		var prev audit.Record
+		err := collection.FindOne(ctx, bson.M{}, last).Decode(&prev)
+		switch {
+		case err == mongo.ErrNoDocuments:
+			record.Chain(nil)
+		case err != nil:
+			return err
+		default:
+			record.Chain(&prev)
+		}
+
+		_, err = collection.InsertOne(ctx, record)
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return errAuditContention
+}
+
+// eraseAuditPersonalData стирает персональные данные в записях журнала аудита, где пользователь
+// выполнил действие или был его объектом. Записи и хеш-цепочка остаются, идентификатор удаленного
+// аккаунта больше ни с кем не связан
+func (r *Repository) eraseAuditPersonalData(ctx context.Context, userID string) error {
+	collection := r.db.Collection("audit_log")
+	filter := bson.M{
+		"$or":  []bson.M{{"actor_id": userID}, {"target_id": userID}},
+		"salt": bson.M{"$exists": true},
+	}
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var record audit.Record
+		if err := cursor.Decode(&record); err != nil {
+			return err
+		}
+		if !record.Erase() {
+			continue
+		}
+		_, err := collection.UpdateOne(ctx, bson.M{"_id": record.Seq}, bson.M{
+			"$set":   bson.M{"personal_hash": record.PersonalHash},
+			"$unset": bson.M{"salt": "", "ip": "", "user_agent": "", "device_id": "", "details": ""},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// auditFilter формирует условия выборки записей журнала аудита
+func auditFilter(filter audit.Filter) bson.M {
+	query := bson.M{}
+	if filter.ActorID != "" {
+		query["actor_id"] = filter.ActorID
+	}
+	if filter.TargetID != "" {
+		query["target_id"] = filter.TargetID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.Outcome != "" {
+		query["outcome"] = filter.Outcome
+	}
+	period := bson.M{}
+	if !filter.From.IsZero() {
+		period["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		period["$lt"] = filter.To
+	}
+	if len(period) > 0 {
+		query["time"] = period
+	}
+	return query
+}
+
+// GetAuditRecords возвращает до limit записей журнала аудита, начиная с последних.
+// Если before больше нуля, возвращаются записи с номерами меньше before
+func (r *Repository) GetAuditRecords(ctx context.Context, filter audit.Filter, before int64, limit int) ([]*audit.Record, error) {
+	query := auditFilter(filter)
+	if before > 0 {
+		query["_id"] = bson.M{"$lt": before}
+	}
+	opts := options.Find().SetSort(bson.M{"_id": -1}).SetLimit(int64(limit))
+
+	cursor, err := r.db.Collection("audit_log").Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []*audit.Record
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// StreamAuditRecords передает в fn записи журнала аудита по возрастанию номеров, не загружая журнал в память
+func (r *Repository) StreamAuditRecords(ctx context.Context, filter audit.Filter, fn func(*audit.Record) error) error {
+	opts := options.Find().SetSort(bson.M{"_id": 1})
+
+	cursor, err := r.db.Collection("audit_log").Find(ctx, auditFilter(filter), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var record audit.Record
+		if err := cursor.Decode(&record); err != nil {
+			return err
+		}
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// HasKnownDevices сообщает, входил ли пользователь раньше хотя бы с одного запомненного устройства
+func (r *Repository) HasKnownDevices(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	collection := r.db.Collection("known_devices")
+
+	err := collection.FindOne(ctx, bson.M{"user_id": userID}).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// TouchKnownDevice запоминает устройство или продлевает срок хранения уже известного.
+// Возвращает true, если устройство запомнено впервые
+func (r *Repository) TouchKnownDevice(ctx context.Context, known *KnownDevice) (bool, error) {
+	collection := r.db.Collection("known_devices")
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"user_id": known.UserID, "key": known.Key},
+		bson.M{
+			"$set": bson.M{"last_seen_at": known.LastSeenAt, "expires_at": known.ExpiresAt, "ip": known.IP},
+			"$setOnInsert": bson.M{
+				"device_id":     known.DeviceID,
+				"ip_range":      known.IPRange,
+				"agent":         known.Agent,
+				"first_seen_at": known.FirstSeenAt,
+			},
+		},
+		options.Update().SetUpsert(true))
+	// Параллельный вход с того же устройства уже запомнил его
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+// GetKnownDevices возвращает запомненные устройства пользователя, начиная с последних использованных
+func (r *Repository) GetKnownDevices(ctx context.Context, userID primitive.ObjectID) ([]*KnownDevice, error) {
+	opts := options.Find().SetSort(bson.M{"last_seen_at": -1})
+
+	cursor, err := r.db.Collection("known_devices").Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var devices []*KnownDevice
+	if err = cursor.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// DeleteKnownDevice забывает устройство, чтобы следующий вход с него снова вызвал уведомление
+func (r *Repository) DeleteKnownDevice(ctx context.Context, userID primitive.ObjectID, key string) error {
+	collection := r.db.Collection("known_devices")
+
+	_, err := collection.DeleteOne(ctx, bson.M{"user_id": userID, "key": key})
+	return err
+}
+
+// SetFieldEncryption включает шифрование чувствительных полей аккаунтов. Открытые значения,
+// записанные раньше, читаются как есть и шифруются задачей ReencryptAccounts
+func (r *Repository) SetFieldEncryption(keyring *fieldcrypt.Keyring) {
+	r.crypt = keyring
+}
+
+// sealUser возвращает копию пользователя с зашифрованными полями для записи в базу
+func (r *Repository) sealUser(user *User) (*User, error) {
+	if r.crypt == nil {
+		return user, nil
+	}
+	if user.ID.IsZero() {
+		return nil, errors.New("account id is required to encrypt fields")
+	}
+	sealed := *user
+	deviceToken, err := r.crypt.Encrypt("device_token", user.ID.Hex(), user.DeviceToken)
+	if err != nil {
+		return nil, err
+	}
+	sealed.DeviceToken = deviceToken
+	sealed.DeviceTokenIndex = r.crypt.BlindIndex("device_token", user.DeviceToken)
+	sealed.EncryptionKeyVersion = r.crypt.CurrentVersion()
+	return &sealed, nil
+}
+
+// openUser расшифровывает поля пользователя, прочитанного из базы
+func (r *Repository) openUser(user *User) error {
+	if !fieldcrypt.IsEncrypted(user.DeviceToken) {
+		return nil
+	}
+	if r.crypt == nil {
+		return fmt.Errorf("account %s has encrypted fields, but field encryption is not configured", user.ID.Hex())
+	}
+	deviceToken, err := r.crypt.Decrypt("device_token", user.ID.Hex(), user.DeviceToken)
+	if err != nil {
+		return fmt.Errorf("decrypt device token of account %s: %w", user.ID.Hex(), err)
+	}
+	user.DeviceToken = deviceToken
+	return nil
+}
+
+// ReencryptAccounts шифрует текущим мастер-ключом поля аккаунтов, которые открыты или зашифрованы
+// старым ключом. Запись обновляется, только если поле не изменилось с момента чтения.
+// Возвращает число перешифрованных аккаунтов и аккаунтов, которые не удалось расшифровать
+func (r *Repository) ReencryptAccounts(ctx context.Context) (updated, failed int, err error) {
+	if r.crypt == nil {
+		return 0, 0, nil
+	}
+	collection := r.db.Collection("accounts")
+	filter := bson.M{
+		"device_token":           bson.M{"$gt": ""},
+		"encryption_key_version": bson.M{"$ne": r.crypt.CurrentVersion()},
+	}
+	opts := options.Find().SetProjection(bson.M{"device_token": 1}).SetBatchSize(100)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user User
+		if err := cursor.Decode(&user); err != nil {
+			return updated, failed, err
+		}
+		stored := user.DeviceToken
+		if err := r.openUser(&user); err != nil {
+			log.Printf("[ReencryptAccounts] %v", err)
+			failed++
+			continue
+		}
+		sealed, err := r.sealUser(&user)
+		if err != nil {
+			return updated, failed, err
+		}
+		result, err := collection.UpdateOne(ctx,
+			bson.M{"_id": user.ID, "device_token": stored},
+			bson.M{"$set": bson.M{
+				"device_token":           sealed.DeviceToken,
+				"device_token_bidx":      sealed.DeviceTokenIndex,
+				"encryption_key_version": sealed.EncryptionKeyVersion,
+			}},
+		)
+		if err != nil {
+			return updated, failed, err
+		}
+		updated += int(result.ModifiedCount)
+	}
+	return updated, failed, cursor.Err()
+}
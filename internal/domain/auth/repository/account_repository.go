@@ -12,4 +12,5 @@ type AccountRepository interface {
 	Save(ctx context.Context, user dto.UserDTO) error
 	Update(ctx context.Context, user dto.UserDTO, searchedEmail values.Email) error
 	GetById(ctx context.Context, id uuid.UUID) (dto.UserDTO, error)
+	Delete(ctx context.Context, id uuid.UUID) error
 }
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+)
+
+// ErrAggregateKeyNotFound - ключ агрегата отсутствует или был уничтожен при удалении аккаунта
+var ErrAggregateKeyNotFound = errors.New("aggregate key not found")
+
+// AggregateKeyRepository хранит ключи шифрования персональных данных в событиях агрегата.
+// Удаление ключа делает персональные данные в истории событий невосстановимыми
+type AggregateKeyRepository interface {
+	GetOrCreateKey(ctx context.Context, aggregateId uuid.UUID) ([]byte, error)
+	GetKey(ctx context.Context, aggregateId uuid.UUID) ([]byte, error)
+	DeleteKey(ctx context.Context, aggregateId uuid.UUID) error
+}
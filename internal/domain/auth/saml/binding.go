@@ -0,0 +1,174 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const (
+	paramRequest  = "SAMLRequest"
+	paramResponse = "SAMLResponse"
+
+	// maxMessageSize ограничивает размер распакованного сообщения SP
+	maxMessageSize = 64 << 10
+)
+
+var (
+	ErrMalformedMessage     = errors.New("malformed saml message")
+	ErrSignatureMissing     = errors.New("saml message is not signed")
+	ErrSignatureInvalid     = errors.New("invalid saml message signature")
+	ErrUnsupportedAlgorithm = errors.New("unsupported saml signature algorithm")
+)
+
+// RedirectMessage - сообщение SP, полученное через HTTP-Redirect binding
+type RedirectMessage struct {
+	// Param - SAMLRequest или SAMLResponse
+	Param      string
+	XML        []byte
+	RelayState string
+
+	sigAlg    string
+	signature []byte
+	// signed - строка запроса в исходной кодировке, которую подписал SP
+	signed string
+}
+
+// ParseRedirect разбирает строку запроса HTTP-Redirect binding: сообщение сжато deflate
+// и закодировано в base64, подпись (если есть) передается отдельными параметрами
+func ParseRedirect(rawQuery string) (*RedirectMessage, error) {
+	raw := map[string]string{}
+	for _, pair := range strings.Split(rawQuery, "&") {
+		name, value, _ := strings.Cut(pair, "=")
+		if _, ok := raw[name]; !ok {
+			raw[name] = value
+		}
+	}
+
+	msg := &RedirectMessage{Param: paramRequest}
+	if _, ok := raw[paramResponse]; ok {
+		msg.Param = paramResponse
+	}
+	encoded, err := url.QueryUnescape(raw[msg.Param])
+	if err != nil || encoded == "" {
+		return nil, ErrMalformedMessage
+	}
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrMalformedMessage
+	}
+	msg.XML, err = io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(compressed)), maxMessageSize))
+	if err != nil {
+		return nil, ErrMalformedMessage
+	}
+
+	if msg.RelayState, err = url.QueryUnescape(raw["RelayState"]); err != nil {
+		return nil, ErrMalformedMessage
+	}
+
+	if sig, ok := raw["Signature"]; ok {
+		if msg.sigAlg, err = url.QueryUnescape(raw["SigAlg"]); err != nil {
+			return nil, ErrMalformedMessage
+		}
+		decoded, err := url.QueryUnescape(sig)
+		if err != nil {
+			return nil, ErrMalformedMessage
+		}
+		if msg.signature, err = base64.StdEncoding.DecodeString(decoded); err != nil {
+			return nil, ErrMalformedMessage
+		}
+		msg.signed = msg.Param + "=" + raw[msg.Param]
+		if _, ok := raw["RelayState"]; ok {
+			msg.signed += "&RelayState=" + raw["RelayState"]
+		}
+		msg.signed += "&SigAlg=" + raw["SigAlg"]
+	}
+	return msg, nil
+}
+
+// Signed сообщает, передал ли SP подпись сообщения
+func (m *RedirectMessage) Signed() bool {
+	return m.signature != nil
+}
+
+// Verify проверяет подпись сообщения сертификатом SP
+func (m *RedirectMessage) Verify(cert *x509.Certificate) error {
+	if !m.Signed() {
+		return ErrSignatureMissing
+	}
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return ErrUnsupportedAlgorithm
+	}
+
+	var err error
+	switch m.sigAlg {
+	case algRSASHA256:
+		hashed := sha256.Sum256([]byte(m.signed))
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], m.signature)
+	case algRSASHA1:
+		hashed := sha1.Sum([]byte(m.signed))
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA1, hashed[:], m.signature)
+	default:
+		return ErrUnsupportedAlgorithm
+	}
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// Decode разбирает XML сообщения в AuthnRequest, LogoutRequest или LogoutResponse
+func (m *RedirectMessage) Decode(v interface{}) error {
+	if err := xml.Unmarshal(m.XML, v); err != nil {
+		return ErrMalformedMessage
+	}
+	return nil
+}
+
+// redirectURL кодирует сообщение для HTTP-Redirect binding и подписывает строку запроса
+func (idp *IdentityProvider) redirectURL(destination, param, message, relayState string) (string, error) {
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := writer.Write([]byte(message)); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	query := param + "=" + url.QueryEscape(base64.StdEncoding.EncodeToString(compressed.Bytes()))
+	if relayState != "" {
+		query += "&RelayState=" + url.QueryEscape(relayState)
+	}
+	query += "&SigAlg=" + url.QueryEscape(algRSASHA256)
+
+	signature, err := idp.signBytes([]byte(query))
+	if err != nil {
+		return "", err
+	}
+	query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(signature))
+
+	separator := "?"
+	if strings.Contains(destination, "?") {
+		separator = "&"
+	}
+	return destination + separator + query, nil
+}
+
+func stripSpaces(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
@@ -0,0 +1,146 @@
+package saml
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+var (
+	ErrInvalidKey         = errors.New("invalid saml signing key")
+	ErrInvalidCertificate = errors.New("invalid saml certificate")
+)
+
+// Config - параметры identity provider, которые публикуются в метаданных
+type Config struct {
+	// EntityID - идентификатор IdP, обычно адрес метаданных
+	EntityID string
+	// SSOURL и SLOURL - адреса, на которые SP отправляет AuthnRequest и LogoutRequest
+	SSOURL string
+	SLOURL string
+	// AssertionTTL - сколько времени SP может принять выданное утверждение
+	AssertionTTL time.Duration
+}
+
+// IdentityProvider выпускает подписанные SAML утверждения
+type IdentityProvider struct {
+	cfg  Config
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+// New создает identity provider. Если сертификат не задан, он выпускается
+// самоподписанным из ключа, так что метаданные не меняются между перезапусками
+func New(cfg Config, key *rsa.PrivateKey, cert *x509.Certificate) (*IdentityProvider, error) {
+	if cfg.AssertionTTL <= 0 {
+		cfg.AssertionTTL = 5 * time.Minute
+	}
+	if cert == nil {
+		var err error
+		if cert, err = selfSignedCertificate(key, cfg.EntityID); err != nil {
+			return nil, err
+		}
+	}
+	if pub, ok := cert.PublicKey.(*rsa.PublicKey); !ok || !pub.Equal(&key.PublicKey) {
+		return nil, ErrInvalidCertificate
+	}
+	return &IdentityProvider{cfg: cfg, key: key, cert: cert}, nil
+}
+
+// EntityID возвращает идентификатор IdP
+func (idp *IdentityProvider) EntityID() string {
+	return idp.cfg.EntityID
+}
+
+// LoadKey читает RSA ключ в формате PEM (PKCS#1 или PKCS#8), тот же, которым подписываются JWT
+func LoadKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// LoadCertificate читает сертификат в формате PEM
+func LoadCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCertificate(string(data))
+}
+
+// ParseCertificate разбирает сертификат в формате PEM или base64 DER, как он записан в метаданных SP
+func ParseCertificate(data string) (*x509.Certificate, error) {
+	var der []byte
+	if block, _ := pem.Decode([]byte(data)); block != nil {
+		der = block.Bytes
+	} else {
+		decoded, err := base64.StdEncoding.DecodeString(stripSpaces(data))
+		if err != nil {
+			return nil, ErrInvalidCertificate
+		}
+		der = decoded
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+	return cert, nil
+}
+
+// selfSignedCertificate выпускает сертификат с фиксированными серийным номером и сроком.
+// Подпись PKCS#1 v1.5 детерминирована, поэтому сертификат для одного ключа всегда одинаковый
+func selfSignedCertificate(key *rsa.PrivateKey, entityID string) (*x509.Certificate, error) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: entityID},
+		NotBefore:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2044, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// Metadata возвращает EntityDescriptor IdP с сертификатом подписи и адресами SSO и SLO
+func (idp *IdentityProvider) Metadata() []byte {
+	descriptor := newElement("md:EntityDescriptor").declare("md", nsMetadata).declare("ds", nsDSig).
+		attr("entityID", idp.cfg.EntityID).add(
+		newElement("md:IDPSSODescriptor").
+			attr("WantAuthnRequestsSigned", "false").
+			attr("protocolSupportEnumeration", nsProtocol).add(
+			newElement("md:KeyDescriptor").attr("use", "signing").add(idp.keyInfo()),
+			newElement("md:SingleLogoutService").attr("Binding", bindingRedir).attr("Location", idp.cfg.SLOURL),
+			newElement("md:NameIDFormat").setText(nameIDEmail),
+			newElement("md:SingleSignOnService").attr("Binding", bindingRedir).attr("Location", idp.cfg.SSOURL),
+		),
+	)
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n" + descriptor.render())
+}
@@ -0,0 +1,160 @@
+package saml
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"time"
+)
+
+// Attribute - атрибут пользователя в утверждении
+type Attribute struct {
+	Name   string
+	Values []string
+}
+
+// AssertionParams - данные для утверждения о входе пользователя
+type AssertionParams struct {
+	// Audience - entity id SP, которому выдается утверждение
+	Audience string
+	ACSURL   string
+	// InResponseTo - ID запроса SP, пусто для входа по инициативе IdP
+	InResponseTo string
+	NameID       string
+	SessionIndex string
+	AuthnInstant time.Time
+	Attributes   []Attribute
+}
+
+// Response возвращает подписанный samlp:Response в base64 для отправки на ACS через HTTP-POST.
+// Подписывается утверждение, ответ целиком не подписывается
+func (idp *IdentityProvider) Response(p AssertionParams) (string, error) {
+	now := time.Now()
+	assertionID := newID()
+	notOnOrAfter := formatTime(now.Add(idp.cfg.AssertionTTL))
+
+	attributes := newElement("saml:AttributeStatement")
+	for _, attribute := range p.Attributes {
+		attr := newElement("saml:Attribute").attr("Name", attribute.Name).attr("NameFormat", attrNameBasic)
+		for _, value := range attribute.Values {
+			attr.add(newElement("saml:AttributeValue").setText(value))
+		}
+		attributes.add(attr)
+	}
+
+	assertion := newElement("saml:Assertion").declare("saml", nsAssertion).
+		attr("ID", assertionID).attr("IssueInstant", formatTime(now)).attr("Version", "2.0").add(
+		newElement("saml:Issuer").setText(idp.cfg.EntityID),
+		newElement("saml:Subject").add(
+			newElement("saml:NameID").attr("Format", nameIDEmail).setText(p.NameID),
+			newElement("saml:SubjectConfirmation").attr("Method", cmBearer).add(
+				newElement("saml:SubjectConfirmationData").
+					attr("InResponseTo", p.InResponseTo).
+					attr("NotOnOrAfter", notOnOrAfter).
+					attr("Recipient", p.ACSURL),
+			),
+		),
+		newElement("saml:Conditions").
+			attr("NotBefore", formatTime(now.Add(-time.Minute))).
+			attr("NotOnOrAfter", notOnOrAfter).add(
+			newElement("saml:AudienceRestriction").add(
+				newElement("saml:Audience").setText(p.Audience),
+			),
+		),
+		newElement("saml:AuthnStatement").
+			attr("AuthnInstant", formatTime(p.AuthnInstant)).
+			attr("SessionIndex", p.SessionIndex).add(
+			newElement("saml:AuthnContext").add(
+				newElement("saml:AuthnContextClassRef").setText(authnPassword),
+			),
+		),
+	)
+	if len(attributes.children) > 0 {
+		assertion.add(attributes)
+	}
+	if err := idp.sign(assertion, assertionID); err != nil {
+		return "", err
+	}
+
+	response := newElement("samlp:Response").declare("samlp", nsProtocol).declare("saml", nsAssertion).
+		attr("Destination", p.ACSURL).
+		attr("ID", newID()).
+		attr("InResponseTo", p.InResponseTo).
+		attr("IssueInstant", formatTime(now)).
+		attr("Version", "2.0").add(
+		newElement("saml:Issuer").setText(idp.cfg.EntityID),
+		successStatus(),
+		assertion,
+	)
+	return base64.StdEncoding.EncodeToString([]byte(response.render())), nil
+}
+
+// LogoutRequestURL возвращает адрес SLO сервиса SP с подписанным LogoutRequest (HTTP-Redirect)
+func (idp *IdentityProvider) LogoutRequestURL(destination, nameID, sessionIndex, relayState string) (string, error) {
+	request := newElement("samlp:LogoutRequest").declare("samlp", nsProtocol).declare("saml", nsAssertion).
+		attr("Destination", destination).
+		attr("ID", newID()).
+		attr("IssueInstant", formatTime(time.Now())).
+		attr("Version", "2.0").add(
+		newElement("saml:Issuer").setText(idp.cfg.EntityID),
+		newElement("saml:NameID").attr("Format", nameIDEmail).setText(nameID),
+	)
+	if sessionIndex != "" {
+		request.add(newElement("samlp:SessionIndex").setText(sessionIndex))
+	}
+	return idp.redirectURL(destination, paramRequest, request.render(), relayState)
+}
+
+// LogoutResponseURL возвращает адрес SLO сервиса SP с подписанным LogoutResponse (HTTP-Redirect)
+func (idp *IdentityProvider) LogoutResponseURL(destination, inResponseTo, relayState string) (string, error) {
+	response := newElement("samlp:LogoutResponse").declare("samlp", nsProtocol).declare("saml", nsAssertion).
+		attr("Destination", destination).
+		attr("ID", newID()).
+		attr("InResponseTo", inResponseTo).
+		attr("IssueInstant", formatTime(time.Now())).
+		attr("Version", "2.0").add(
+		newElement("saml:Issuer").setText(idp.cfg.EntityID),
+		successStatus(),
+	)
+	return idp.redirectURL(destination, paramResponse, response.render(), relayState)
+}
+
+func successStatus() *element {
+	return newElement("samlp:Status").add(newElement("samlp:StatusCode").attr("Value", statusSuccess))
+}
+
+// AuthnRequest - запрос SP на вход пользователя
+type AuthnRequest struct {
+	XMLName      xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	ID           string   `xml:"ID,attr"`
+	IssueInstant string   `xml:"IssueInstant,attr"`
+	Destination  string   `xml:"Destination,attr"`
+	ACSURL       string   `xml:"AssertionConsumerServiceURL,attr"`
+	Issuer       string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+}
+
+// LogoutRequest - запрос SP на завершение сессии пользователя
+type LogoutRequest struct {
+	XMLName      xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol LogoutRequest"`
+	ID           string   `xml:"ID,attr"`
+	Issuer       string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	NameID       string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion NameID"`
+	SessionIndex []string `xml:"urn:oasis:names:tc:SAML:2.0:protocol SessionIndex"`
+}
+
+// LogoutResponse - ответ SP на LogoutRequest, отправленный IdP
+type LogoutResponse struct {
+	XMLName      xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol LogoutResponse"`
+	ID           string   `xml:"ID,attr"`
+	InResponseTo string   `xml:"InResponseTo,attr"`
+	Issuer       string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	Status       struct {
+		Code struct {
+			Value string `xml:"Value,attr"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:protocol StatusCode"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:protocol Status"`
+}
+
+// Success сообщает, завершил ли SP сессию
+func (r *LogoutResponse) Success() bool {
+	return r.Status.Code.Value == statusSuccess
+}
@@ -0,0 +1,127 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestIdP(t *testing.T) *IdentityProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp, err := New(Config{EntityID: "https://sso.test/saml/metadata", SSOURL: "https://sso.test/saml/sso",
+		SLOURL: "https://sso.test/saml/slo"}, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idp
+}
+
+func between(s, from, to string) string {
+	start := strings.Index(s, from)
+	end := strings.Index(s, to)
+	if start < 0 || end < 0 {
+		return ""
+	}
+	return s[start : end+len(to)]
+}
+
+func TestResponseSignature(t *testing.T) {
+	idp := newTestIdP(t)
+
+	encoded, err := idp.Response(AssertionParams{
+		Audience:     "https://lms.test",
+		ACSURL:       "https://lms.test/acs?tenant=a&b",
+		InResponseTo: "_request",
+		NameID:       "gopher@kubercode.com",
+		SessionIndex: "_session",
+		AuthnInstant: time.Now(),
+		Attributes:   []Attribute{{Name: "roles", Values: []string{"user", "<mentor>"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(encoded)
+	doc := string(raw)
+
+	assertion := between(doc, "<saml:Assertion", "</saml:Assertion>")
+	signature := between(assertion, "<ds:Signature", "</ds:Signature>")
+	if signature == "" {
+		t.Fatal("assertion is not signed")
+	}
+
+	// enveloped-signature: дайджест считается по утверждению без подписи
+	digest := sha256.Sum256([]byte(strings.Replace(assertion, signature, "", 1)))
+	digestValue := regexp.MustCompile(`<ds:DigestValue>(.*?)</ds:DigestValue>`).FindStringSubmatch(signature)[1]
+	if base64.StdEncoding.EncodeToString(digest[:]) != digestValue {
+		t.Fatal("digest mismatch")
+	}
+
+	signedInfo := strings.Replace(between(signature, "<ds:SignedInfo>", "</ds:SignedInfo>"),
+		"<ds:SignedInfo>", `<ds:SignedInfo xmlns:ds="`+nsDSig+`">`, 1)
+	signatureValue := regexp.MustCompile(`<ds:SignatureValue>(.*?)</ds:SignatureValue>`).FindStringSubmatch(signature)[1]
+	sig, _ := base64.StdEncoding.DecodeString(signatureValue)
+	hashed := sha256.Sum256([]byte(signedInfo))
+	if err := rsa.VerifyPKCS1v15(&idp.key.PublicKey, crypto.SHA256, hashed[:], sig); err != nil {
+		t.Fatalf("signature: %v", err)
+	}
+}
+
+func TestRedirectBinding(t *testing.T) {
+	idp := newTestIdP(t)
+
+	location, err := idp.LogoutRequestURL("https://lms.test/slo?x=1", "gopher@kubercode.com", "_session", "back to lms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := ParseRedirect(u.RawQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Verify(idp.cert); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	var request LogoutRequest
+	if err := msg.Decode(&request); err != nil {
+		t.Fatal(err)
+	}
+	if request.NameID != "gopher@kubercode.com" || request.Issuer != idp.EntityID() ||
+		len(request.SessionIndex) != 1 || msg.RelayState != "back to lms" {
+		t.Fatalf("unexpected request: %+v, relay state %q", request, msg.RelayState)
+	}
+
+	tampered, err := ParseRedirect(strings.Replace(u.RawQuery, "RelayState=back", "RelayState=evil", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tampered.Verify(idp.cert); !errors.Is(err, ErrSignatureInvalid) {
+		t.Fatalf("expected ErrSignatureInvalid, got %v", err)
+	}
+}
+
+func TestSelfSignedCertificateIsStable(t *testing.T) {
+	idp := newTestIdP(t)
+	again, err := New(idp.cfg, idp.key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(idp.Metadata()) != string(again.Metadata()) {
+		t.Fatal("metadata changed for the same key")
+	}
+}
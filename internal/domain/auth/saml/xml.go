@@ -0,0 +1,177 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+	nsDSig      = "http://www.w3.org/2000/09/xmldsig#"
+
+	algExcC14N     = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	algEnveloped   = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	algSHA256      = "http://www.w3.org/2001/04/xmlenc#sha256"
+	algRSASHA256   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algRSASHA1     = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	statusSuccess  = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bindingRedir   = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	nameIDEmail    = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	attrNameBasic  = "urn:oasis:names:tc:SAML:2.0:attrname-format:basic"
+	cmBearer       = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	authnPassword  = "urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport"
+	timeFormatSAML = "2006-01-02T15:04:05Z"
+)
+
+// element - узел XML документа. Документ сразу выводится в каноническом виде
+// (Exclusive XML Canonicalization): пространства имен и атрибуты отсортированы,
+// пустые элементы закрываются парным тегом. Благодаря этому подписанный фрагмент
+// совпадает с тем, что получит SP после канонизации, и отдельная реализация c14n не нужна
+type element struct {
+	name     string
+	ns       [][2]string
+	attrs    [][2]string
+	text     string
+	children []*element
+}
+
+func newElement(name string) *element {
+	return &element{name: name}
+}
+
+// declare объявляет пространство имен на элементе. Пустой prefix - пространство по умолчанию
+func (e *element) declare(prefix, uri string) *element {
+	e.ns = append(e.ns, [2]string{prefix, uri})
+	return e
+}
+
+// attr добавляет атрибут, пустые значения пропускаются
+func (e *element) attr(name, value string) *element {
+	if value != "" {
+		e.attrs = append(e.attrs, [2]string{name, value})
+	}
+	return e
+}
+
+func (e *element) setText(text string) *element {
+	e.text = text
+	return e
+}
+
+func (e *element) add(children ...*element) *element {
+	e.children = append(e.children, children...)
+	return e
+}
+
+func (e *element) insert(index int, child *element) {
+	e.children = append(e.children[:index], append([]*element{child}, e.children[index:]...)...)
+}
+
+func (e *element) render() string {
+	var b strings.Builder
+	e.write(&b)
+	return b.String()
+}
+
+func (e *element) write(b *strings.Builder) {
+	b.WriteString("<" + e.name)
+
+	ns := append([][2]string(nil), e.ns...)
+	sort.Slice(ns, func(i, j int) bool { return ns[i][0] < ns[j][0] })
+	for _, decl := range ns {
+		if decl[0] == "" {
+			b.WriteString(` xmlns="` + escapeAttr(decl[1]) + `"`)
+		} else {
+			b.WriteString(` xmlns:` + decl[0] + `="` + escapeAttr(decl[1]) + `"`)
+		}
+	}
+
+	attrs := append([][2]string(nil), e.attrs...)
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i][0] < attrs[j][0] })
+	for _, attr := range attrs {
+		b.WriteString(" " + attr[0] + `="` + escapeAttr(attr[1]) + `"`)
+	}
+	b.WriteString(">")
+
+	b.WriteString(escapeText(e.text))
+	for _, child := range e.children {
+		child.write(b)
+	}
+	b.WriteString("</" + e.name + ">")
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;",
+		"\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(s string) string { return textEscaper.Replace(s) }
+func escapeAttr(s string) string { return attrEscaper.Replace(s) }
+
+// sign добавляет в элемент enveloped подпись RSA-SHA256 сразу после Issuer.
+// Элемент должен сам объявлять все используемые им пространства имен
+func (idp *IdentityProvider) sign(e *element, id string) error {
+	digest := sha256.Sum256([]byte(e.render()))
+
+	signedInfo := newElement("ds:SignedInfo").declare("ds", nsDSig).add(
+		newElement("ds:CanonicalizationMethod").attr("Algorithm", algExcC14N),
+		newElement("ds:SignatureMethod").attr("Algorithm", algRSASHA256),
+		newElement("ds:Reference").attr("URI", "#"+id).add(
+			newElement("ds:Transforms").add(
+				newElement("ds:Transform").attr("Algorithm", algEnveloped),
+				newElement("ds:Transform").attr("Algorithm", algExcC14N),
+			),
+			newElement("ds:DigestMethod").attr("Algorithm", algSHA256),
+			newElement("ds:DigestValue").setText(base64.StdEncoding.EncodeToString(digest[:])),
+		),
+	)
+	signature, err := idp.signBytes([]byte(signedInfo.render()))
+	if err != nil {
+		return err
+	}
+	// Пространство ds объявлено на Signature, при канонизации SignedInfo оно вернется на место
+	signedInfo.ns = nil
+
+	e.insert(1, newElement("ds:Signature").declare("ds", nsDSig).add(
+		signedInfo,
+		newElement("ds:SignatureValue").setText(base64.StdEncoding.EncodeToString(signature)),
+		idp.keyInfo(),
+	))
+	return nil
+}
+
+func (idp *IdentityProvider) signBytes(data []byte) ([]byte, error) {
+	hashed := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, hashed[:])
+}
+
+func (idp *IdentityProvider) keyInfo() *element {
+	return newElement("ds:KeyInfo").add(
+		newElement("ds:X509Data").add(
+			newElement("ds:X509Certificate").setText(base64.StdEncoding.EncodeToString(idp.cert.Raw)),
+		),
+	)
+}
+
+// newID генерирует идентификатор сообщения. ID в SAML должен начинаться с буквы или подчеркивания
+func newID() string {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return "_" + hex.EncodeToString(b)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormatSAML)
+}
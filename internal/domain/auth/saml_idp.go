@@ -0,0 +1,523 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"kubercode/internal/domain/auth/saml"
+)
+
+var (
+	ErrSAMLNotConfigured  = errors.New("saml identity provider is not configured")
+	ErrSAMLUnknownSP      = errors.New("unknown saml service provider")
+	ErrSAMLSPExists       = errors.New("saml service provider is already registered")
+	ErrSAMLInvalidSP      = errors.New("invalid saml service provider settings")
+	ErrSAMLInvalidACS     = errors.New("assertion consumer service url is not registered")
+	ErrSAMLInvalidRequest = errors.New("invalid saml request")
+	ErrSAMLRequestExpired = errors.New("saml login request not found or expired")
+	ErrSAMLAccessDenied   = errors.New("account has no access to the service provider")
+)
+
+// samlSessionTTL - время хранения сессии у SP, как у refresh токена
+const samlSessionTTL = 30 * 24 * time.Hour
+
+// SAMLAttributeMapping - имена атрибутов утверждения для полей аккаунта, пустые поля берутся по умолчанию
+type SAMLAttributeMapping struct {
+	Email    string `bson:"email,omitempty" json:"email,omitempty"`
+	Roles    string `bson:"roles,omitempty" json:"roles,omitempty"`
+	IsMentor string `bson:"is_mentor,omitempty" json:"is_mentor,omitempty"`
+}
+
+func (m SAMLAttributeMapping) withDefaults() SAMLAttributeMapping {
+	if m.Email == "" {
+		m.Email = "email"
+	}
+	if m.Roles == "" {
+		m.Roles = "roles"
+	}
+	if m.IsMentor == "" {
+		m.IsMentor = "isMentor"
+	}
+	return m
+}
+
+// SAMLServiceProvider - зарегистрированный SAML SP (LMS, HR система и т.п.)
+type SAMLServiceProvider struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EntityID string             `bson:"entity_id" json:"entity_id"`
+	Name     string             `bson:"name" json:"name"`
+	// ACSURLs - адреса приема утверждений, первый используется по умолчанию
+	ACSURLs []string `bson:"acs_urls" json:"acs_urls"`
+	SLOURL  string   `bson:"slo_url,omitempty" json:"slo_url,omitempty"`
+	// Certificate - сертификат подписи SP в PEM. Если задан, запросы SP должны быть подписаны
+	Certificate string               `bson:"certificate,omitempty" json:"certificate,omitempty"`
+	Attributes  SAMLAttributeMapping `bson:"attributes" json:"attributes"`
+	// OrgID - организация, участникам которой доступен SP. Пусто - SP доступен всем
+	OrgID     *primitive.ObjectID `bson:"org_id,omitempty" json:"org_id,omitempty"`
+	CreatedAt time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// SAMLServiceProviderSettings - параметры регистрации SP
+type SAMLServiceProviderSettings struct {
+	EntityID    string
+	Name        string
+	ACSURLs     []string
+	SLOURL      string
+	Certificate string
+	Attributes  SAMLAttributeMapping
+	OrgID       string
+}
+
+// SAMLLoginRequest - AuthnRequest SP, ожидающий входа пользователя на фронтенде
+type SAMLLoginRequest struct {
+	ID         string             `bson:"_id"`
+	SPID       primitive.ObjectID `bson:"sp_id"`
+	RequestID  string             `bson:"request_id"`
+	ACSURL     string             `bson:"acs_url"`
+	RelayState string             `bson:"relay_state,omitempty"`
+	ExpiresAt  time.Time          `bson:"expires_at"`
+}
+
+// SAMLSession - выданное SP утверждение, по нему выполняется SingleLogout
+type SAMLSession struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	SPID      primitive.ObjectID `bson:"sp_id"`
+	NameID    string             `bson:"name_id"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+}
+
+// sessionIndex - значение SessionIndex в утверждении и LogoutRequest
+func (s *SAMLSession) sessionIndex() string {
+	return "_" + s.ID.Hex()
+}
+
+// SAMLPostForm - данные для отправки ответа на ACS SP через HTTP-POST binding.
+// Фронтенд отправляет их формой в браузере пользователя
+type SAMLPostForm struct {
+	ACSURL       string `json:"acs_url"`
+	SAMLResponse string `json:"saml_response"`
+	RelayState   string `json:"relay_state,omitempty"`
+}
+
+// SetSAMLProvider подключает SAML identity provider. Без него SAML эндпоинты недоступны
+func (s *Service) SetSAMLProvider(idp *saml.IdentityProvider) {
+	s.samlIdP = idp
+}
+
+// SAMLMetadata возвращает метаданные IdP для регистрации в SP
+func (s *Service) SAMLMetadata() ([]byte, error) {
+	if s.samlIdP == nil {
+		return nil, ErrSAMLNotConfigured
+	}
+	return s.samlIdP.Metadata(), nil
+}
+
+// RegisterSAMLServiceProvider регистрирует SP. Доступно администраторам сервиса
+func (s *Service) RegisterSAMLServiceProvider(ctx context.Context, actorID string, settings SAMLServiceProviderSettings) (*SAMLServiceProvider, error) {
+	if settings.EntityID == "" || len(settings.ACSURLs) == 0 {
+		return nil, ErrSAMLInvalidSP
+	}
+	for _, acs := range append([]string{settings.SLOURL}, settings.ACSURLs...) {
+		if acs != "" && !isHTTPURL(acs) {
+			return nil, ErrSAMLInvalidSP
+		}
+	}
+	if settings.Certificate != "" {
+		if _, err := saml.ParseCertificate(settings.Certificate); err != nil {
+			return nil, ErrSAMLInvalidSP
+		}
+	}
+
+	sp := &SAMLServiceProvider{
+		EntityID:    settings.EntityID,
+		Name:        settings.Name,
+		ACSURLs:     settings.ACSURLs,
+		SLOURL:      settings.SLOURL,
+		Certificate: settings.Certificate,
+		Attributes:  settings.Attributes,
+		CreatedAt:   time.Now(),
+	}
+	if settings.OrgID != "" {
+		orgID, err := primitive.ObjectIDFromHex(settings.OrgID)
+		if err != nil {
+			return nil, ErrOrganizationNotFound
+		}
+		if _, err := s.repo.GetOrganization(ctx, orgID); err != nil {
+			return nil, err
+		}
+		sp.OrgID = &orgID
+	}
+
+	if err := s.repo.SaveSAMLServiceProvider(ctx, sp); err != nil {
+		return nil, err
+	}
+
+	actor, _ := primitive.ObjectIDFromHex(actorID)
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventSAMLServiceProviderRegistered,
+		UserID:  actor,
+		ActorID: actor,
+		Data:    map[string]interface{}{"sp_id": sp.ID.Hex(), "entity_id": sp.EntityID},
+	})
+	log.Printf("[RegisterSAMLServiceProvider] Администратор %s зарегистрировал SP %s", actorID, sp.EntityID)
+	return sp, nil
+}
+
+// GetSAMLServiceProviders возвращает зарегистрированные SP
+func (s *Service) GetSAMLServiceProviders(ctx context.Context) ([]*SAMLServiceProvider, error) {
+	return s.repo.GetSAMLServiceProviders(ctx)
+}
+
+// DeleteSAMLServiceProvider удаляет SP вместе с его сессиями
+func (s *Service) DeleteSAMLServiceProvider(ctx context.Context, actorID, spID string) error {
+	id, err := primitive.ObjectIDFromHex(spID)
+	if err != nil {
+		return ErrSAMLUnknownSP
+	}
+	sp, err := s.repo.GetSAMLServiceProvider(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteSAMLServiceProvider(ctx, id); err != nil {
+		return err
+	}
+
+	actor, _ := primitive.ObjectIDFromHex(actorID)
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventSAMLServiceProviderDeleted,
+		UserID:  actor,
+		ActorID: actor,
+		Data:    map[string]interface{}{"sp_id": spID, "entity_id": sp.EntityID},
+	})
+	return nil
+}
+
+// BeginSAMLLogin принимает AuthnRequest SP (HTTP-Redirect binding) и возвращает адрес
+// страницы входа фронтенда. После входа фронтенд завершает запрос через CompleteSAMLLogin
+func (s *Service) BeginSAMLLogin(ctx context.Context, rawQuery string) (string, error) {
+	if s.samlIdP == nil {
+		return "", ErrSAMLNotConfigured
+	}
+
+	msg, err := saml.ParseRedirect(rawQuery)
+	if err != nil {
+		return "", ErrSAMLInvalidRequest
+	}
+	var request saml.AuthnRequest
+	if err := msg.Decode(&request); err != nil {
+		return "", ErrSAMLInvalidRequest
+	}
+
+	sp, err := s.repo.GetSAMLServiceProviderByEntityID(ctx, request.Issuer)
+	if err != nil {
+		return "", err
+	}
+	if err := verifySAMLMessage(msg, sp); err != nil {
+		log.Printf("[BeginSAMLLogin] Подпись запроса SP %s не прошла проверку: %v", sp.EntityID, err)
+		return "", ErrSAMLInvalidRequest
+	}
+
+	acsURL, err := sp.acsURL(request.ACSURL)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := generateInvitationToken()
+	if err != nil {
+		return "", err
+	}
+	pending := &SAMLLoginRequest{
+		ID:         id,
+		SPID:       sp.ID,
+		RequestID:  request.ID,
+		ACSURL:     acsURL,
+		RelayState: msg.RelayState,
+		ExpiresAt:  time.Now().Add(s.settings.SAMLRequestTTL),
+	}
+	if err := s.repo.SaveSAMLRequest(ctx, pending); err != nil {
+		return "", err
+	}
+
+	return s.settings.AppURL + "/saml/login?request=" + url.QueryEscape(id), nil
+}
+
+// CompleteSAMLLogin выпускает утверждение для запроса SP от имени вошедшего пользователя
+func (s *Service) CompleteSAMLLogin(ctx context.Context, userID, requestID string, authTime time.Time) (*SAMLPostForm, error) {
+	if s.samlIdP == nil {
+		return nil, ErrSAMLNotConfigured
+	}
+
+	pending, err := s.repo.TakeSAMLRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(pending.ExpiresAt) {
+		return nil, ErrSAMLRequestExpired
+	}
+
+	sp, err := s.repo.GetSAMLServiceProvider(ctx, pending.SPID)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueSAMLResponse(ctx, userID, sp, pending.ACSURL, pending.RequestID, pending.RelayState, authTime)
+}
+
+// StartIdPInitiatedSAML выпускает утверждение без запроса SP (вход по инициативе IdP)
+func (s *Service) StartIdPInitiatedSAML(ctx context.Context, userID, spID, relayState string, authTime time.Time) (*SAMLPostForm, error) {
+	if s.samlIdP == nil {
+		return nil, ErrSAMLNotConfigured
+	}
+
+	id, err := primitive.ObjectIDFromHex(spID)
+	if err != nil {
+		return nil, ErrSAMLUnknownSP
+	}
+	sp, err := s.repo.GetSAMLServiceProvider(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueSAMLResponse(ctx, userID, sp, sp.ACSURLs[0], "", relayState, authTime)
+}
+
+// issueSAMLResponse проверяет доступ пользователя к SP, сохраняет сессию и подписывает утверждение
+func (s *Service) issueSAMLResponse(ctx context.Context, userID string, sp *SAMLServiceProvider,
+	acsURL, inResponseTo, relayState string, authTime time.Time) (*SAMLPostForm, error) {
+	uid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	user, err := s.repo.GetUserByID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAccountRestrictions(user); err != nil {
+		return nil, err
+	}
+
+	var membership *OrgMembership
+	if sp.OrgID != nil {
+		membership, err = s.repo.GetMembership(ctx, *sp.OrgID, user.ID)
+		if err != nil {
+			if errors.Is(err, ErrNotOrgMember) {
+				return nil, ErrSAMLAccessDenied
+			}
+			return nil, err
+		}
+	}
+
+	session := &SAMLSession{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		SPID:      sp.ID,
+		NameID:    user.Email,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(samlSessionTTL),
+	}
+	if err := s.repo.SaveSAMLSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	if authTime.IsZero() {
+		authTime = time.Now()
+	}
+	response, err := s.samlIdP.Response(saml.AssertionParams{
+		Audience:     sp.EntityID,
+		ACSURL:       acsURL,
+		InResponseTo: inResponseTo,
+		NameID:       session.NameID,
+		SessionIndex: session.sessionIndex(),
+		AuthnInstant: authTime,
+		Attributes:   s.samlAttributes(user, sp, membership),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[issueSAMLResponse] Пользователь %s вошел в SP %s", user.ID.Hex(), sp.EntityID)
+	return &SAMLPostForm{ACSURL: acsURL, SAMLResponse: response, RelayState: relayState}, nil
+}
+
+// samlAttributes формирует атрибуты утверждения из полей аккаунта. Роли: user, mentor и admin
+// по аккаунту, для SP организации - еще роль в организации с префиксом org:
+func (s *Service) samlAttributes(user *User, sp *SAMLServiceProvider, membership *OrgMembership) []saml.Attribute {
+	mapping := sp.Attributes.withDefaults()
+	isMentor := s.effectiveIsMentor(user)
+
+	roles := []string{"user"}
+	if isMentor {
+		roles = append(roles, "mentor")
+	}
+	if user.IsAdmin {
+		roles = append(roles, "admin")
+	}
+	if membership != nil {
+		roles = append(roles, "org:"+string(membership.Role))
+	}
+
+	mentor := "false"
+	if isMentor {
+		mentor = "true"
+	}
+	return []saml.Attribute{
+		{Name: mapping.Email, Values: []string{user.Email}},
+		{Name: mapping.Roles, Values: roles},
+		{Name: mapping.IsMentor, Values: []string{mentor}},
+	}
+}
+
+// HandleSAMLLogout обрабатывает сообщение на SLO эндпоинте (HTTP-Redirect binding).
+// LogoutRequest от SP завершает все сессии пользователя в сервисе и у SP, ответ
+// отправляется на SLO адрес SP. LogoutResponse завершает выход, начатый через SAMLLogout.
+// Возвращает адрес, на который нужно перенаправить браузер
+func (s *Service) HandleSAMLLogout(ctx context.Context, rawQuery string) (string, error) {
+	if s.samlIdP == nil {
+		return "", ErrSAMLNotConfigured
+	}
+
+	msg, err := saml.ParseRedirect(rawQuery)
+	if err != nil {
+		return "", ErrSAMLInvalidRequest
+	}
+
+	if msg.Param == "SAMLResponse" {
+		var response saml.LogoutResponse
+		if err := msg.Decode(&response); err != nil {
+			return "", ErrSAMLInvalidRequest
+		}
+		log.Printf("[HandleSAMLLogout] SP %s завершил сессию, успешно: %t", response.Issuer, response.Success())
+		return s.settings.AppURL, nil
+	}
+
+	var request saml.LogoutRequest
+	if err := msg.Decode(&request); err != nil {
+		return "", ErrSAMLInvalidRequest
+	}
+	sp, err := s.repo.GetSAMLServiceProviderByEntityID(ctx, request.Issuer)
+	if err != nil {
+		return "", err
+	}
+	// Без подписи кто угодно мог бы завершить чужую сессию, поэтому LogoutRequest принимается
+	// только от SP с зарегистрированным сертификатом
+	if sp.Certificate == "" {
+		log.Printf("[HandleSAMLLogout] SP %s не зарегистрировал сертификат, LogoutRequest отклонен", sp.EntityID)
+		return "", ErrSAMLInvalidRequest
+	}
+	if err := verifySAMLMessage(msg, sp); err != nil {
+		log.Printf("[HandleSAMLLogout] Подпись запроса SP %s не прошла проверку: %v", sp.EntityID, err)
+		return "", ErrSAMLInvalidRequest
+	}
+
+	// Завершаются только сессии этого SP, выданные для NameID и SessionIndex из запроса.
+	// Сессии сервиса и других SP остаются
+	sessionIDs := samlSessionIDs(request.SessionIndex)
+	deleted, err := s.repo.DeleteSAMLSessions(ctx, sp.ID, request.NameID, sessionIDs)
+	if err != nil {
+		return "", err
+	}
+	if deleted == 0 {
+		// Сессия уже завершена или не существовала, SP получает обычный ответ
+		log.Printf("[HandleSAMLLogout] SP %s: сессия для %s не найдена", sp.EntityID, request.NameID)
+	} else {
+		log.Printf("[HandleSAMLLogout] SP %s завершил сессий: %d", sp.EntityID, deleted)
+	}
+
+	if sp.SLOURL == "" {
+		return s.settings.AppURL, nil
+	}
+	return s.samlIdP.LogoutResponseURL(sp.SLOURL, request.ID, msg.RelayState)
+}
+
+// SAMLLogout завершает сессии пользователя в сервисе и возвращает адреса SLO
+// с подписанными LogoutRequest для SP, в которые он входил. Фронтенд открывает их по очереди
+func (s *Service) SAMLLogout(ctx context.Context, userID string) ([]string, error) {
+	if s.samlIdP == nil {
+		return nil, ErrSAMLNotConfigured
+	}
+
+	uid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	user, err := s.repo.GetUserByID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.repo.GetUserSAMLSessions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	urls := []string{}
+	for _, session := range sessions {
+		sp, err := s.repo.GetSAMLServiceProvider(ctx, session.SPID)
+		if err != nil || sp.SLOURL == "" {
+			continue
+		}
+		location, err := s.samlIdP.LogoutRequestURL(sp.SLOURL, session.NameID, session.sessionIndex(), "")
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, location)
+	}
+
+	if err := s.repo.DeleteUserSAMLSessions(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if err := s.revokeAllSessions(ctx, user); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// acsURL возвращает адрес из запроса, если он зарегистрирован, или адрес по умолчанию
+func (sp *SAMLServiceProvider) acsURL(requested string) (string, error) {
+	if requested == "" {
+		return sp.ACSURLs[0], nil
+	}
+	for _, acs := range sp.ACSURLs {
+		if acs == requested {
+			return acs, nil
+		}
+	}
+	return "", ErrSAMLInvalidACS
+}
+
+// samlSessionIDs возвращает идентификаторы сессий из значений SessionIndex, см. SAMLSession.sessionIndex
+func samlSessionIDs(indexes []string) []primitive.ObjectID {
+	ids := []primitive.ObjectID{}
+	for _, index := range indexes {
+		id, err := primitive.ObjectIDFromHex(strings.TrimPrefix(strings.TrimSpace(index), "_"))
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// verifySAMLMessage проверяет подпись сообщения, если у SP зарегистрирован сертификат
+func verifySAMLMessage(msg *saml.RedirectMessage, sp *SAMLServiceProvider) error {
+	if sp.Certificate == "" {
+		return nil
+	}
+	cert, err := saml.ParseCertificate(sp.Certificate)
+	if err != nil {
+		return err
+	}
+	return msg.Verify(cert)
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return u.Scheme == "https" || u.Scheme == "http"
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSAMLAttributes(t *testing.T) {
+	s := &Service{settings: DefaultSettings()}
+	user := &User{Email: "gopher@kubercode.com", IsMentor: true, IsAdmin: true}
+	sp := &SAMLServiceProvider{Attributes: SAMLAttributeMapping{Email: "mail"}}
+
+	attributes := s.samlAttributes(user, sp, &OrgMembership{Role: OrgRoleAdmin})
+	got := map[string][]string{}
+	for _, attribute := range attributes {
+		got[attribute.Name] = attribute.Values
+	}
+
+	want := map[string][]string{
+		"mail":     {"gopher@kubercode.com"},
+		"roles":    {"user", "mentor", "admin", "org:admin"},
+		"isMentor": {"true"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("attributes = %v, want %v", got, want)
+	}
+}
+
+func TestSAMLServiceProviderACSURL(t *testing.T) {
+	sp := &SAMLServiceProvider{ACSURLs: []string{"https://lms.test/acs", "https://lms.test/acs/2"}}
+
+	if acs, _ := sp.acsURL(""); acs != "https://lms.test/acs" {
+		t.Errorf("expected default acs, got %q", acs)
+	}
+	if acs, _ := sp.acsURL("https://lms.test/acs/2"); acs != "https://lms.test/acs/2" {
+		t.Errorf("expected requested acs, got %q", acs)
+	}
+	if _, err := sp.acsURL("https://evil.test/acs"); !errors.Is(err, ErrSAMLInvalidACS) {
+		t.Errorf("expected ErrSAMLInvalidACS, got %v", err)
+	}
+}
@@ -0,0 +1,736 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"kubercode/internal/domain/auth/scim"
+)
+
+var (
+	ErrSCIMUnauthorized  = errors.New("invalid scim token")
+	ErrSCIMTokenNotFound = errors.New("scim token not found")
+	ErrSCIMTokenName     = errors.New("scim token name must be between 1 and 100 characters")
+	ErrSCIMGroupNotFound = errors.New("scim group not found")
+	ErrSCIMGroupExists   = errors.New("group with this name already exists")
+	ErrSCIMEmailDomain   = errors.New("user email is outside verified domains of the organization")
+)
+
+// scimTokenPrefix отличает токены SCIM от остальных bearer токенов в логах и настройках каталогов
+const scimTokenPrefix = "scim_"
+
+// SCIMToken - токен, с которым каталог организации (Okta, Azure AD) обращается к SCIM API.
+// В базе хранится только хеш токена
+type SCIMToken struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID      primitive.ObjectID `bson:"org_id" json:"org_id"`
+	Name       string             `bson:"name" json:"name"`
+	TokenHash  string             `bson:"token_hash" json:"-"`
+	CreatedBy  primitive.ObjectID `bson:"created_by" json:"created_by"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt time.Time          `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+}
+
+// CreatedSCIMToken - новый токен SCIM. Значение токена показывается только при создании
+type CreatedSCIMToken struct {
+	SCIMToken
+	Token string `json:"token"`
+}
+
+// OrgGroup - группа организации, которой управляет каталог через SCIM. Состав групп
+// определяет роль участника, если в настройках SSO организации задан role_mapping
+type OrgGroup struct {
+	ID          primitive.ObjectID   `bson:"_id,omitempty"`
+	OrgID       primitive.ObjectID   `bson:"org_id"`
+	DisplayName string               `bson:"display_name"`
+	ExternalID  string               `bson:"external_id,omitempty"`
+	Members     []primitive.ObjectID `bson:"members"`
+	CreatedAt   time.Time            `bson:"created_at"`
+	UpdatedAt   time.Time            `bson:"updated_at"`
+}
+
+// CreateSCIMToken выпускает токен SCIM для организации. Доступно владельцу и администраторам
+func (s *Service) CreateSCIMToken(ctx context.Context, userID, orgID, name string) (*CreatedSCIMToken, error) {
+	admin, err := s.requireOrgAdmin(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if n := len([]rune(name)); n < 1 || n > 100 {
+		return nil, ErrSCIMTokenName
+	}
+
+	secret, err := generateInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+	token := scimTokenPrefix + secret
+
+	created := &CreatedSCIMToken{
+		SCIMToken: SCIMToken{
+			OrgID:     admin.OrgID,
+			Name:      name,
+			TokenHash: s.hashCode(token),
+			CreatedBy: admin.UserID,
+			CreatedAt: time.Now(),
+		},
+		Token: token,
+	}
+	if err := s.repo.SaveSCIMToken(ctx, &created.SCIMToken); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[CreateSCIMToken] Пользователь %s выпустил токен SCIM %s организации %s",
+		userID, created.ID.Hex(), orgID)
+	return created, nil
+}
+
+// GetSCIMTokens возвращает токены SCIM организации
+func (s *Service) GetSCIMTokens(ctx context.Context, userID, orgID string) ([]*SCIMToken, error) {
+	admin, err := s.requireOrgAdmin(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetSCIMTokens(ctx, admin.OrgID)
+}
+
+// RevokeSCIMToken отзывает токен SCIM организации
+func (s *Service) RevokeSCIMToken(ctx context.Context, userID, orgID, tokenID string) error {
+	admin, err := s.requireOrgAdmin(ctx, userID, orgID)
+	if err != nil {
+		return err
+	}
+	id, err := primitive.ObjectIDFromHex(tokenID)
+	if err != nil {
+		return ErrSCIMTokenNotFound
+	}
+	return s.repo.DeleteSCIMToken(ctx, admin.OrgID, id)
+}
+
+// AuthenticateSCIM проверяет токен SCIM и возвращает ID организации, от имени которой действует каталог
+func (s *Service) AuthenticateSCIM(ctx context.Context, token string) (string, error) {
+	if !strings.HasPrefix(token, scimTokenPrefix) {
+		return "", ErrSCIMUnauthorized
+	}
+
+	stored, err := s.repo.GetSCIMTokenByHash(ctx, s.hashCode(token))
+	if err != nil {
+		if errors.Is(err, ErrSCIMTokenNotFound) {
+			return "", ErrSCIMUnauthorized
+		}
+		return "", err
+	}
+
+	if err := s.repo.TouchSCIMToken(ctx, stored.ID, time.Now()); err != nil {
+		log.Printf("[AuthenticateSCIM] Ошибка обновления времени использования токена %s: %v", stored.ID.Hex(), err)
+	}
+	return stored.OrgID.Hex(), nil
+}
+
+// SCIMListUsers возвращает участников организации, подходящих под фильтр
+func (s *Service) SCIMListUsers(ctx context.Context, orgID, filter string, page scim.Page) (*scim.ListResponse, error) {
+	org, err := scimOrgID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	match, err := parseSCIMFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	memberships, err := s.repo.GetOrganizationMembers(ctx, org)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(memberships))
+	for _, membership := range memberships {
+		ids = append(ids, membership.UserID)
+	}
+	users, err := s.repo.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.repo.GetOrgGroups(ctx, org)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[primitive.ObjectID]*User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	resources := []*scim.User{}
+	for _, membership := range memberships {
+		user, ok := byID[membership.UserID]
+		if !ok {
+			continue
+		}
+		resource := toSCIMUser(user, membership, groups)
+		if match == nil || match.Match(scim.ToMap(resource)) {
+			resources = append(resources, resource)
+		}
+	}
+
+	start, end := page.Bounds(len(resources))
+	return newSCIMList(resources[start:end], len(resources), page), nil
+}
+
+// SCIMGetUser возвращает участника организации
+func (s *Service) SCIMGetUser(ctx context.Context, orgID, userID string) (*scim.User, error) {
+	user, membership, err := s.getSCIMMember(ctx, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.scimUserResource(ctx, user, membership)
+}
+
+// SCIMCreateUser добавляет пользователя в организацию. Email должен принадлежать подтвержденному
+// домену организации: существующий аккаунт с этим адресом привязывается, иначе создается аккаунт
+// без пароля, в который пользователь входит через SSO организации или восстановление пароля
+func (s *Service) SCIMCreateUser(ctx context.Context, orgID string, resource *scim.User) (*scim.User, error) {
+	org, err := scimOrgID(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(resource.PrimaryEmail()))
+	if err := s.checkSCIMEmail(ctx, org, email); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	switch {
+	case err == nil:
+		if _, err := s.repo.GetMembership(ctx, org, user.ID); err == nil {
+			return nil, ErrAlreadyOrgMember
+		}
+		if !user.EmailVerified {
+			if err := s.repo.SetEmailVerified(ctx, user.ID); err != nil {
+				return nil, err
+			}
+			user.EmailVerified = true
+		}
+	case errors.Is(err, ErrUserNotFound):
+		user = &User{
+			ID:            primitive.NewObjectID(),
+			Email:         email,
+			EmailVerified: true,
+			Profile:       Profile{DisplayName: scimDisplayName(resource)},
+		}
+		if err := s.repo.CreateUser(ctx, user); err != nil {
+			return nil, err
+		}
+		s.recordEvent(ctx, &DomainEvent{
+			Type:   EventAccountRegistered,
+			UserID: user.ID,
+			Data:   map[string]interface{}{"method": "scim", "org_id": orgID},
+		})
+		log.Printf("[SCIMCreateUser] Создан аккаунт %s для организации %s", user.ID.Hex(), orgID)
+	default:
+		return nil, err
+	}
+
+	role := OrgRoleMember
+	if cfg, err := s.repo.GetOrgSSOConfig(ctx, org); err == nil && cfg.DefaultRole != "" {
+		role = cfg.DefaultRole
+	}
+	membership := &OrgMembership{
+		OrgID:      org,
+		UserID:     user.ID,
+		Role:       role,
+		ExternalID: resource.ExternalID,
+		JoinedAt:   time.Now(),
+	}
+	if err := s.repo.SaveMembership(ctx, membership); err != nil {
+		return nil, err
+	}
+	s.publishEvent(ctx, &DomainEvent{
+		Type:   EventOrgMemberJoined,
+		UserID: user.ID,
+		Data:   map[string]interface{}{"org_id": orgID, "role": string(role), "scim": true},
+	})
+
+	if err := s.setSCIMActive(ctx, user, membership, resource.Active); err != nil {
+		return nil, err
+	}
+	return s.scimUserResource(ctx, user, membership)
+}
+
+// SCIMReplaceUser заменяет атрибуты участника (PUT)
+func (s *Service) SCIMReplaceUser(ctx context.Context, orgID, userID string, resource *scim.User) (*scim.User, error) {
+	user, membership, err := s.getSCIMMember(ctx, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateSCIMUser(ctx, user, membership, resource)
+}
+
+// SCIMPatchUser применяет к участнику операции PATCH
+func (s *Service) SCIMPatchUser(ctx context.Context, orgID, userID string, req *scim.PatchRequest) (*scim.User, error) {
+	user, membership, err := s.getSCIMMember(ctx, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := s.scimUserResource(ctx, user, membership)
+	if err != nil {
+		return nil, err
+	}
+	if err := resource.ApplyPatch(req); err != nil {
+		return nil, err
+	}
+	return s.updateSCIMUser(ctx, user, membership, resource)
+}
+
+// SCIMDeleteUser отключает аккаунт, завершает его сессии и удаляет пользователя из организации и ее групп
+func (s *Service) SCIMDeleteUser(ctx context.Context, orgID, userID string) error {
+	user, membership, err := s.getSCIMMember(ctx, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	inactive := false
+	if err := s.setSCIMActive(ctx, user, membership, &inactive); err != nil {
+		return err
+	}
+	if err := s.repo.PullGroupMember(ctx, membership.OrgID, user.ID); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteMembership(ctx, membership.OrgID, user.ID); err != nil {
+		return err
+	}
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:   EventOrgMemberRemoved,
+		UserID: user.ID,
+		Data:   map[string]interface{}{"org_id": orgID, "scim": true},
+	})
+	return nil
+}
+
+// SCIMListGroups возвращает группы организации, подходящие под фильтр
+func (s *Service) SCIMListGroups(ctx context.Context, orgID, filter string, page scim.Page) (*scim.ListResponse, error) {
+	org, err := scimOrgID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	match, err := parseSCIMFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.repo.GetOrgGroups(ctx, org)
+	if err != nil {
+		return nil, err
+	}
+	resources := []*scim.Group{}
+	for _, group := range groups {
+		resource := toSCIMGroup(group)
+		if match == nil || match.Match(scim.ToMap(resource)) {
+			resources = append(resources, resource)
+		}
+	}
+
+	start, end := page.Bounds(len(resources))
+	return newSCIMList(resources[start:end], len(resources), page), nil
+}
+
+// SCIMGetGroup возвращает группу организации
+func (s *Service) SCIMGetGroup(ctx context.Context, orgID, groupID string) (*scim.Group, error) {
+	group, err := s.getSCIMGroup(ctx, orgID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return toSCIMGroup(group), nil
+}
+
+// SCIMCreateGroup создает группу организации
+func (s *Service) SCIMCreateGroup(ctx context.Context, orgID string, resource *scim.Group) (*scim.Group, error) {
+	org, err := scimOrgID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(resource.DisplayName) == "" {
+		return nil, scim.ErrInvalidValue
+	}
+
+	members, err := s.scimGroupMembers(ctx, org, resource.Members)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	group := &OrgGroup{
+		OrgID:       org,
+		DisplayName: strings.TrimSpace(resource.DisplayName),
+		ExternalID:  resource.ExternalID,
+		Members:     members,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.repo.SaveOrgGroup(ctx, group); err != nil {
+		return nil, err
+	}
+
+	s.syncSCIMRoles(ctx, org, members)
+	return toSCIMGroup(group), nil
+}
+
+// SCIMReplaceGroup заменяет название и состав группы (PUT)
+func (s *Service) SCIMReplaceGroup(ctx context.Context, orgID, groupID string, resource *scim.Group) (*scim.Group, error) {
+	group, err := s.getSCIMGroup(ctx, orgID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateSCIMGroup(ctx, group, resource)
+}
+
+// SCIMPatchGroup применяет к группе операции PATCH
+func (s *Service) SCIMPatchGroup(ctx context.Context, orgID, groupID string, req *scim.PatchRequest) (*scim.Group, error) {
+	group, err := s.getSCIMGroup(ctx, orgID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	resource := toSCIMGroup(group)
+	if err := resource.ApplyPatch(req); err != nil {
+		return nil, err
+	}
+	return s.updateSCIMGroup(ctx, group, resource)
+}
+
+// SCIMDeleteGroup удаляет группу организации. Роли бывших участников пересчитываются
+func (s *Service) SCIMDeleteGroup(ctx context.Context, orgID, groupID string) error {
+	group, err := s.getSCIMGroup(ctx, orgID, groupID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteOrgGroup(ctx, group.OrgID, group.ID); err != nil {
+		return err
+	}
+
+	s.syncSCIMRoles(ctx, group.OrgID, group.Members)
+	return nil
+}
+
+// updateSCIMUser сохраняет изменения ресурса пользователя. userName (email) меняется
+// только через смену email самим пользователем, каталог может изменить лишь регистр
+func (s *Service) updateSCIMUser(ctx context.Context, user *User, membership *OrgMembership, resource *scim.User) (*scim.User, error) {
+	if !strings.EqualFold(strings.TrimSpace(resource.UserName), user.Email) {
+		return nil, scim.ErrMutability
+	}
+
+	if displayName := scimDisplayName(resource); displayName != "" && displayName != user.Profile.DisplayName {
+		user.Profile.DisplayName = displayName
+		if err := s.repo.UpdateUser(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	if resource.ExternalID != membership.ExternalID {
+		if err := s.repo.SetMembershipExternalID(ctx, membership.OrgID, user.ID, resource.ExternalID); err != nil {
+			return nil, err
+		}
+		membership.ExternalID = resource.ExternalID
+	}
+
+	if err := s.setSCIMActive(ctx, user, membership, resource.Active); err != nil {
+		return nil, err
+	}
+	return s.scimUserResource(ctx, user, membership)
+}
+
+// setSCIMActive применяет атрибут active. Деактивация отключает аккаунт от имени организации
+// и завершает все его сессии. Повторно включить можно только аккаунт, отключенный этой же
+// организацией: отключение администратором сервиса каталог не снимает
+func (s *Service) setSCIMActive(ctx context.Context, user *User, membership *OrgMembership, active *bool) error {
+	if active == nil {
+		return nil
+	}
+
+	if !*active {
+		if user.Disabled {
+			return nil
+		}
+		if membership.Role == OrgRoleOwner {
+			return ErrOwnerRemoval
+		}
+		user.Disabled = true
+		user.DisabledByOrg = membership.OrgID
+		if err := s.repo.SetAccountRestrictions(ctx, user.ID, user.AccountRestrictions); err != nil {
+			return err
+		}
+		if err := s.revokeAllSessions(ctx, user); err != nil {
+			return err
+		}
+		s.publishEvent(ctx, &DomainEvent{
+			Type:   EventAccountDisabled,
+			UserID: user.ID,
+			Data:   map[string]interface{}{"org_id": membership.OrgID.Hex(), "scim": true},
+		})
+		log.Printf("[setSCIMActive] Аккаунт %s отключен каталогом организации %s", user.ID.Hex(), membership.OrgID.Hex())
+		return nil
+	}
+
+	if !user.Disabled || user.DisabledByOrg != membership.OrgID {
+		return nil
+	}
+	user.Disabled = false
+	user.DisabledByOrg = primitive.NilObjectID
+	if err := s.repo.SetAccountRestrictions(ctx, user.ID, user.AccountRestrictions); err != nil {
+		return err
+	}
+	s.publishEvent(ctx, &DomainEvent{
+		Type:   EventAccountEnabled,
+		UserID: user.ID,
+		Data:   map[string]interface{}{"org_id": membership.OrgID.Hex(), "scim": true},
+	})
+	return nil
+}
+
+// updateSCIMGroup сохраняет название и состав группы и пересчитывает роли затронутых участников
+func (s *Service) updateSCIMGroup(ctx context.Context, group *OrgGroup, resource *scim.Group) (*scim.Group, error) {
+	if strings.TrimSpace(resource.DisplayName) == "" {
+		return nil, scim.ErrInvalidValue
+	}
+	members, err := s.scimGroupMembers(ctx, group.OrgID, resource.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	affected := append(append([]primitive.ObjectID{}, group.Members...), members...)
+	group.DisplayName = strings.TrimSpace(resource.DisplayName)
+	group.ExternalID = resource.ExternalID
+	group.Members = members
+	group.UpdatedAt = time.Now()
+	if err := s.repo.ReplaceOrgGroup(ctx, group); err != nil {
+		return nil, err
+	}
+
+	s.syncSCIMRoles(ctx, group.OrgID, affected)
+	return toSCIMGroup(group), nil
+}
+
+// scimGroupMembers проверяет, что все участники группы состоят в организации
+func (s *Service) scimGroupMembers(ctx context.Context, org primitive.ObjectID, values []scim.MultiValue) ([]primitive.ObjectID, error) {
+	members := []primitive.ObjectID{}
+	seen := map[primitive.ObjectID]bool{}
+	for _, value := range values {
+		id, err := primitive.ObjectIDFromHex(value.Value)
+		if err != nil {
+			return nil, scim.ErrInvalidValue
+		}
+		if seen[id] {
+			continue
+		}
+		if _, err := s.repo.GetMembership(ctx, org, id); err != nil {
+			if errors.Is(err, ErrNotOrgMember) {
+				return nil, scim.ErrInvalidValue
+			}
+			return nil, err
+		}
+		seen[id] = true
+		members = append(members, id)
+	}
+	return members, nil
+}
+
+// syncSCIMRoles приводит роли участников к их группам, если в настройках SSO организации
+// задан role_mapping. Группы сопоставляются по названию, роль владельца не меняется
+func (s *Service) syncSCIMRoles(ctx context.Context, org primitive.ObjectID, userIDs []primitive.ObjectID) {
+	cfg, err := s.repo.GetOrgSSOConfig(ctx, org)
+	if err != nil || len(cfg.RoleMapping) == 0 {
+		return
+	}
+	groups, err := s.repo.GetOrgGroups(ctx, org)
+	if err != nil {
+		log.Printf("[syncSCIMRoles] Ошибка загрузки групп организации %s: %v", org.Hex(), err)
+		return
+	}
+
+	synced := map[primitive.ObjectID]bool{}
+	for _, userID := range userIDs {
+		if synced[userID] {
+			continue
+		}
+		synced[userID] = true
+
+		membership, err := s.repo.GetMembership(ctx, org, userID)
+		if err != nil {
+			continue
+		}
+		role := mapSSORole(cfg, userGroupNames(groups, userID))
+		if membership.Role == OrgRoleOwner || membership.Role == role {
+			continue
+		}
+		if err := s.repo.SetMembershipRole(ctx, org, userID, role); err != nil {
+			log.Printf("[syncSCIMRoles] Ошибка изменения роли пользователя %s: %v", userID.Hex(), err)
+			continue
+		}
+		if user, err := s.repo.GetUserByID(ctx, userID); err == nil {
+			if err := s.refreshSessionClaims(ctx, user); err != nil {
+				log.Printf("[syncSCIMRoles] Ошибка обновления claims пользователя %s: %v", userID.Hex(), err)
+			}
+		}
+	}
+}
+
+// checkSCIMEmail проверяет, что email принадлежит подтвержденному домену организации
+func (s *Service) checkSCIMEmail(ctx context.Context, org primitive.ObjectID, email string) error {
+	domain := emailDomain(email)
+	if domain == "" {
+		return scim.ErrInvalidValue
+	}
+	if _, err := s.repo.GetVerifiedDomain(ctx, org, domain); err != nil {
+		if errors.Is(err, ErrDomainNotFound) {
+			return ErrSCIMEmailDomain
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Service) getSCIMMember(ctx context.Context, orgID, userID string) (*User, *OrgMembership, error) {
+	org, err := scimOrgID(orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, nil, ErrUserNotFound
+	}
+
+	membership, err := s.repo.GetMembership(ctx, org, id)
+	if err != nil {
+		if errors.Is(err, ErrNotOrgMember) {
+			return nil, nil, ErrUserNotFound
+		}
+		return nil, nil, err
+	}
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, membership, nil
+}
+
+func (s *Service) getSCIMGroup(ctx context.Context, orgID, groupID string) (*OrgGroup, error) {
+	org, err := scimOrgID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	id, err := primitive.ObjectIDFromHex(groupID)
+	if err != nil {
+		return nil, ErrSCIMGroupNotFound
+	}
+	return s.repo.GetOrgGroup(ctx, org, id)
+}
+
+func (s *Service) scimUserResource(ctx context.Context, user *User, membership *OrgMembership) (*scim.User, error) {
+	groups, err := s.repo.GetOrgGroups(ctx, membership.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	return toSCIMUser(user, membership, groups), nil
+}
+
+func toSCIMUser(user *User, membership *OrgMembership, groups []*OrgGroup) *scim.User {
+	active := !user.Disabled && !user.Banned
+	resource := &scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		ID:          user.ID.Hex(),
+		ExternalID:  membership.ExternalID,
+		UserName:    user.Email,
+		DisplayName: user.Profile.DisplayName,
+		Emails:      []scim.MultiValue{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+		},
+	}
+	if user.Profile.DisplayName != "" {
+		resource.Name = &scim.Name{Formatted: user.Profile.DisplayName}
+	}
+	for _, group := range groups {
+		if containsObjectID(group.Members, user.ID) {
+			resource.Groups = append(resource.Groups, scim.MultiValue{Value: group.ID.Hex(), Display: group.DisplayName})
+		}
+	}
+	return resource
+}
+
+func toSCIMGroup(group *OrgGroup) *scim.Group {
+	resource := &scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          group.ID.Hex(),
+		ExternalID:  group.ExternalID,
+		DisplayName: group.DisplayName,
+		Meta: &scim.Meta{
+			ResourceType: "Group",
+			Created:      group.CreatedAt,
+			LastModified: group.UpdatedAt,
+		},
+	}
+	for _, member := range group.Members {
+		resource.Members = append(resource.Members, scim.MultiValue{Value: member.Hex()})
+	}
+	return resource
+}
+
+func newSCIMList(resources interface{}, total int, page scim.Page) *scim.ListResponse {
+	start, end := page.Bounds(total)
+	return &scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   page.StartIndex,
+		ItemsPerPage: end - start,
+		Resources:    resources,
+	}
+}
+
+// userGroupNames возвращает названия групп, в которых состоит пользователь
+func userGroupNames(groups []*OrgGroup, userID primitive.ObjectID) []string {
+	var names []string
+	for _, group := range groups {
+		if containsObjectID(group.Members, userID) {
+			names = append(names, group.DisplayName)
+		}
+	}
+	return names
+}
+
+// scimDisplayName берет отображаемое имя из displayName или name
+func scimDisplayName(resource *scim.User) string {
+	if name := strings.TrimSpace(resource.DisplayName); name != "" {
+		return name
+	}
+	return resource.Name.Display()
+}
+
+func parseSCIMFilter(filter string) (scim.Filter, error) {
+	if strings.TrimSpace(filter) == "" {
+		return nil, nil
+	}
+	return scim.ParseFilter(filter)
+}
+
+func scimOrgID(orgID string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(orgID)
+	if err != nil {
+		return primitive.NilObjectID, ErrSCIMUnauthorized
+	}
+	return id, nil
+}
+
+func containsObjectID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
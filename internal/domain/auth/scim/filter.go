@@ -0,0 +1,422 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidFilter = errors.New("invalid scim filter")
+	ErrInvalidPath   = errors.New("invalid scim path")
+)
+
+// Filter - разобранный фильтр SCIM (RFC 7644, раздел 3.4.2.2). Фильтр применяется
+// к ресурсу в виде JSON объекта, имена атрибутов сравниваются без учета регистра
+type Filter interface {
+	Match(resource map[string]interface{}) bool
+}
+
+// ParseFilter разбирает выражение фильтра: сравнения eq, ne, co, sw, ew, gt, ge, lt, le,
+// проверку pr, логические and, or, not, скобки и фильтры по сложным атрибутам emails[type eq "work"]
+func ParseFilter(expr string) (Filter, error) {
+	p, err := newParser(expr)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, ErrInvalidFilter
+	}
+	return filter, nil
+}
+
+// Path - путь атрибута в PATCH операции: attr, attr.sub, attr[filter] или attr[filter].sub
+type Path struct {
+	Attr   string
+	Filter Filter
+	Sub    string
+}
+
+// ParsePath разбирает путь PATCH операции
+func ParsePath(path string) (*Path, error) {
+	path = stripSchema(strings.TrimSpace(path))
+	if path == "" {
+		return nil, ErrInvalidPath
+	}
+
+	result := &Path{}
+	if open := strings.Index(path, "["); open >= 0 {
+		end := strings.LastIndex(path, "]")
+		if end < open {
+			return nil, ErrInvalidPath
+		}
+		filter, err := ParseFilter(path[open+1 : end])
+		if err != nil {
+			return nil, ErrInvalidPath
+		}
+		result.Attr, result.Filter = path[:open], filter
+		rest := path[end+1:]
+		if rest != "" {
+			if !strings.HasPrefix(rest, ".") {
+				return nil, ErrInvalidPath
+			}
+			result.Sub = rest[1:]
+		}
+	} else {
+		result.Attr, result.Sub, _ = strings.Cut(path, ".")
+	}
+
+	if result.Attr == "" {
+		return nil, ErrInvalidPath
+	}
+	return result, nil
+}
+
+type logicalFilter struct {
+	and         bool
+	left, right Filter
+}
+
+func (f *logicalFilter) Match(resource map[string]interface{}) bool {
+	if f.and {
+		return f.left.Match(resource) && f.right.Match(resource)
+	}
+	return f.left.Match(resource) || f.right.Match(resource)
+}
+
+type notFilter struct {
+	inner Filter
+}
+
+func (f *notFilter) Match(resource map[string]interface{}) bool {
+	return !f.inner.Match(resource)
+}
+
+// valuePathFilter выбирает элементы сложного многозначного атрибута, например emails[type eq "work"]
+type valuePathFilter struct {
+	attr  string
+	inner Filter
+}
+
+func (f *valuePathFilter) Match(resource map[string]interface{}) bool {
+	for _, value := range lookup(resource, []string{f.attr}) {
+		if item, ok := value.(map[string]interface{}); ok && f.inner.Match(item) {
+			return true
+		}
+	}
+	return false
+}
+
+type compareFilter struct {
+	path  []string
+	op    string
+	value interface{}
+}
+
+func (f *compareFilter) Match(resource map[string]interface{}) bool {
+	values := resolve(resource, f.path)
+	switch f.op {
+	case "pr":
+		for _, value := range values {
+			if value != nil && value != "" {
+				return true
+			}
+		}
+		return false
+	case "ne":
+		for _, value := range values {
+			if compare(value, "eq", f.value) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, value := range values {
+		if compare(value, f.op, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup возвращает значения атрибута по пути, многозначные атрибуты разворачиваются
+func lookup(resource map[string]interface{}, path []string) []interface{} {
+	current := []interface{}{resource}
+	for _, name := range path {
+		var next []interface{}
+		for _, item := range current {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for key, value := range object {
+				if !strings.EqualFold(key, name) {
+					continue
+				}
+				if list, ok := value.([]interface{}); ok {
+					next = append(next, list...)
+				} else {
+					next = append(next, value)
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// resolve возвращает простые значения атрибута. Для многозначных атрибутов без
+// податрибута сравнивается value, как в фильтре emails eq "..."
+func resolve(resource map[string]interface{}, path []string) []interface{} {
+	var values []interface{}
+	for _, item := range lookup(resource, path) {
+		if object, ok := item.(map[string]interface{}); ok {
+			values = append(values, lookup(object, []string{"value"})...)
+		} else {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+func compare(actual interface{}, op string, expected interface{}) bool {
+	switch a := actual.(type) {
+	case string:
+		e, ok := expected.(string)
+		if !ok {
+			return false
+		}
+		a, e = strings.ToLower(a), strings.ToLower(e)
+		switch op {
+		case "eq":
+			return a == e
+		case "co":
+			return strings.Contains(a, e)
+		case "sw":
+			return strings.HasPrefix(a, e)
+		case "ew":
+			return strings.HasSuffix(a, e)
+		case "gt":
+			return a > e
+		case "ge":
+			return a >= e
+		case "lt":
+			return a < e
+		case "le":
+			return a <= e
+		}
+	case float64:
+		e, ok := expected.(float64)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return a == e
+		case "gt":
+			return a > e
+		case "ge":
+			return a >= e
+		case "lt":
+			return a < e
+		case "le":
+			return a <= e
+		}
+	case bool:
+		e, ok := expected.(bool)
+		return ok && op == "eq" && a == e
+	case nil:
+		return op == "eq" && expected == nil
+	}
+	return false
+}
+
+var compareOps = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true,
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func newParser(expr string) (*parser, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		switch c := expr[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(expr) && expr[end] != '"' {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, ErrInvalidFilter
+			}
+			tokens = append(tokens, expr[i:end+1])
+			i = end + 1
+		default:
+			end := i
+			for end < len(expr) && !strings.ContainsRune(" \t()[]\"", rune(expr[end])) {
+				end++
+			}
+			tokens = append(tokens, expr[i:end])
+			i = end
+		}
+	}
+	return &parser{tokens: tokens}, nil
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *parser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Filter, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, ErrInvalidFilter
+	case strings.EqualFold(token, "not"):
+		if p.next() != "(" {
+			return nil, ErrInvalidFilter
+		}
+		inner, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return &notFilter{inner: inner}, nil
+	case token == "(":
+		return p.parseGroup()
+	}
+
+	attr := stripSchema(token)
+	if p.peek() == "[" {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != "]" {
+			return nil, ErrInvalidFilter
+		}
+		return &valuePathFilter{attr: attr, inner: inner}, nil
+	}
+
+	path := strings.Split(attr, ".")
+	op := strings.ToLower(p.next())
+	if op == "pr" {
+		return &compareFilter{path: path, op: op}, nil
+	}
+	if !compareOps[op] {
+		return nil, ErrInvalidFilter
+	}
+	value, err := parseValue(p.next())
+	if err != nil {
+		return nil, err
+	}
+	return &compareFilter{path: path, op: op, value: value}, nil
+}
+
+func (p *parser) parseGroup() (Filter, error) {
+	inner, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.next() != ")" {
+		return nil, ErrInvalidFilter
+	}
+	return inner, nil
+}
+
+func parseValue(token string) (interface{}, error) {
+	switch {
+	case token == "":
+		return nil, ErrInvalidFilter
+	case strings.HasPrefix(token, `"`):
+		var value string
+		if err := json.Unmarshal([]byte(token), &value); err != nil {
+			return nil, ErrInvalidFilter
+		}
+		return value, nil
+	case strings.EqualFold(token, "true"):
+		return true, nil
+	case strings.EqualFold(token, "false"):
+		return false, nil
+	case strings.EqualFold(token, "null"):
+		return nil, nil
+	}
+	number, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return nil, ErrInvalidFilter
+	}
+	return number, nil
+}
+
+// stripSchema убирает из имени атрибута URN схемы:
+// urn:ietf:params:scim:schemas:core:2.0:User:userName -> userName
+func stripSchema(attr string) string {
+	if strings.HasPrefix(strings.ToLower(attr), "urn:") {
+		if i := strings.LastIndex(attr, ":"); i >= 0 {
+			return attr[i+1:]
+		}
+	}
+	return attr
+}
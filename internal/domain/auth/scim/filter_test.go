@@ -0,0 +1,87 @@
+package scim
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	user := ToMap(&User{
+		Schemas:     []string{SchemaUser},
+		ID:          "42",
+		UserName:    "Ivan@Corp.example",
+		DisplayName: "Ivan Petrov",
+		Emails: []MultiValue{
+			{Value: "ivan@corp.example", Type: "work", Primary: true},
+			{Value: "ivan@home.example", Type: "home"},
+		},
+	})
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`userName eq "ivan@corp.example"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "IVAN@corp.example"`, true},
+		{`userName ne "ivan@corp.example"`, false},
+		{`displayName sw "ivan" and displayName ew "petrov"`, true},
+		{`displayName co "sidorov" or id eq "42"`, true},
+		{`not (displayName co "ivan")`, false},
+		{`emails[type eq "home" and value co "home"]`, true},
+		{`emails[type eq "other"]`, false},
+		{`emails.value eq "ivan@home.example"`, true},
+		{`emails eq "ivan@corp.example"`, true},
+		{`externalId pr`, false},
+		{`(id eq "1" or id eq "42") and userName pr`, true},
+	}
+	for _, tt := range tests {
+		filter, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q): %v", tt.filter, err)
+		}
+		if got := filter.Match(user); got != tt.want {
+			t.Errorf("%q matched %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{``, `userName`, `userName zz "a"`, `(userName eq "a"`, `userName eq "a`} {
+		if _, err := ParseFilter(invalid); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("ParseFilter(%q) = %v, want ErrInvalidFilter", invalid, err)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	path, err := ParsePath(`members[value eq "42"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Attr != "members" || path.Filter == nil || !path.Filter.Match(map[string]interface{}{"value": "42"}) {
+		t.Fatalf("unexpected path: %+v", path)
+	}
+
+	path, err = ParsePath(`emails[type eq "work"].value`)
+	if err != nil || path.Attr != "emails" || path.Sub != "value" {
+		t.Fatalf("unexpected path: %+v, %v", path, err)
+	}
+
+	path, err = ParsePath("name.givenName")
+	if err != nil || path.Attr != "name" || path.Sub != "givenName" {
+		t.Fatalf("unexpected path: %+v, %v", path, err)
+	}
+}
+
+func TestPageBounds(t *testing.T) {
+	page := NewPage(0, 2)
+	if start, end := page.Bounds(5); start != 0 || end != 2 {
+		t.Errorf("got %d..%d", start, end)
+	}
+	page = NewPage(5, 10)
+	if start, end := page.Bounds(5); start != 4 || end != 5 {
+		t.Errorf("got %d..%d", start, end)
+	}
+	page = NewPage(10, 10)
+	if start, end := page.Bounds(5); start != 5 || end != 5 {
+		t.Errorf("got %d..%d", start, end)
+	}
+}
@@ -0,0 +1,298 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidValue = errors.New("invalid scim attribute value")
+	ErrMutability   = errors.New("scim attribute cannot be modified")
+	ErrInvalidPatch = errors.New("invalid scim patch operation")
+)
+
+const (
+	opAdd     = "add"
+	opReplace = "replace"
+	opRemove  = "remove"
+)
+
+// ApplyPatch применяет операции PATCH к пользователю. Атрибуты, которые сервис
+// не хранит (телефоны, адреса, атрибуты расширений), пропускаются
+func (u *User) ApplyPatch(req *PatchRequest) error {
+	return applyPatch(req, u.set)
+}
+
+// ApplyPatch применяет операции PATCH к группе
+func (g *Group) ApplyPatch(req *PatchRequest) error {
+	return applyPatch(req, g.set)
+}
+
+type setter func(op string, path *Path, value json.RawMessage) error
+
+func applyPatch(req *PatchRequest, set setter) error {
+	for _, operation := range req.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != opAdd && op != opReplace && op != opRemove {
+			return ErrInvalidPatch
+		}
+
+		if operation.Path != "" {
+			path, err := ParsePath(operation.Path)
+			if err != nil {
+				return err
+			}
+			if err := set(op, path, operation.Value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Без path значение - объект с заменяемыми атрибутами
+		if op == opRemove {
+			return ErrInvalidPath
+		}
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &attrs); err != nil {
+			return ErrInvalidValue
+		}
+		for name, value := range attrs {
+			path, err := ParsePath(name)
+			if err != nil {
+				return err
+			}
+			if err := set(op, path, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (u *User) set(op string, path *Path, value json.RawMessage) error {
+	remove := op == opRemove
+	switch strings.ToLower(path.Attr) {
+	case "username":
+		if remove {
+			return ErrMutability
+		}
+		return decodeString(value, &u.UserName)
+	case "displayname":
+		if remove {
+			u.DisplayName = ""
+			return nil
+		}
+		return decodeString(value, &u.DisplayName)
+	case "externalid":
+		if remove {
+			u.ExternalID = ""
+			return nil
+		}
+		return decodeString(value, &u.ExternalID)
+	case "active":
+		if remove {
+			return ErrMutability
+		}
+		active, err := decodeBool(value)
+		if err != nil {
+			return err
+		}
+		u.Active = &active
+	case "name":
+		return u.setName(remove, path.Sub, value)
+	case "emails":
+		return u.setEmails(op, path, value)
+	}
+	return nil
+}
+
+func (u *User) setName(remove bool, sub string, value json.RawMessage) error {
+	if remove {
+		if sub == "" {
+			u.Name = nil
+			return nil
+		}
+		value = json.RawMessage(`""`)
+	}
+	if u.Name == nil {
+		u.Name = &Name{}
+	}
+
+	switch strings.ToLower(sub) {
+	case "":
+		var name Name
+		if err := json.Unmarshal(value, &name); err != nil {
+			return ErrInvalidValue
+		}
+		u.Name = &name
+		return nil
+	case "formatted":
+		return decodeString(value, &u.Name.Formatted)
+	case "givenname":
+		return decodeString(value, &u.Name.GivenName)
+	case "familyname":
+		return decodeString(value, &u.Name.FamilyName)
+	}
+	return nil
+}
+
+func (u *User) setEmails(op string, path *Path, value json.RawMessage) error {
+	if path.Filter == nil {
+		switch op {
+		case opRemove:
+			u.Emails = nil
+			return nil
+		case opReplace:
+			u.Emails = nil
+		}
+		var emails []MultiValue
+		if err := json.Unmarshal(value, &emails); err != nil {
+			return ErrInvalidValue
+		}
+		u.Emails = append(u.Emails, emails...)
+		return nil
+	}
+
+	matched := false
+	kept := u.Emails[:0]
+	for _, email := range u.Emails {
+		if !path.Filter.Match(ToMap(email)) {
+			kept = append(kept, email)
+			continue
+		}
+		matched = true
+		if op == opRemove {
+			continue
+		}
+		if err := setMultiValue(&email, path.Sub, value); err != nil {
+			return err
+		}
+		kept = append(kept, email)
+	}
+	u.Emails = kept
+
+	// emails[type eq "work"].value для отсутствующего адреса добавляет новый основной адрес
+	if !matched && op != opRemove && strings.EqualFold(path.Sub, "value") {
+		email := MultiValue{Primary: len(u.Emails) == 0}
+		if err := decodeString(value, &email.Value); err != nil {
+			return err
+		}
+		u.Emails = append(u.Emails, email)
+	}
+	return nil
+}
+
+func setMultiValue(item *MultiValue, sub string, value json.RawMessage) error {
+	switch strings.ToLower(sub) {
+	case "":
+		return json.Unmarshal(value, item)
+	case "value":
+		return decodeString(value, &item.Value)
+	case "type":
+		return decodeString(value, &item.Type)
+	case "display":
+		return decodeString(value, &item.Display)
+	case "primary":
+		primary, err := decodeBool(value)
+		item.Primary = primary
+		return err
+	}
+	return nil
+}
+
+func (g *Group) set(op string, path *Path, value json.RawMessage) error {
+	switch strings.ToLower(path.Attr) {
+	case "displayname":
+		if op == opRemove {
+			return ErrMutability
+		}
+		return decodeString(value, &g.DisplayName)
+	case "externalid":
+		if op == opRemove {
+			g.ExternalID = ""
+			return nil
+		}
+		return decodeString(value, &g.ExternalID)
+	case "members":
+		return g.setMembers(op, path, value)
+	}
+	return nil
+}
+
+func (g *Group) setMembers(op string, path *Path, value json.RawMessage) error {
+	var members []MultiValue
+	if len(value) > 0 {
+		if err := json.Unmarshal(value, &members); err != nil {
+			return ErrInvalidValue
+		}
+	}
+
+	switch op {
+	case opAdd:
+		for _, member := range members {
+			if !g.hasMember(member.Value) {
+				g.Members = append(g.Members, MultiValue{Value: member.Value})
+			}
+		}
+	case opReplace:
+		g.Members = nil
+		for _, member := range members {
+			if !g.hasMember(member.Value) {
+				g.Members = append(g.Members, MultiValue{Value: member.Value})
+			}
+		}
+	case opRemove:
+		// members[value eq "id"] удаляет выбранных участников, members со значением - перечисленных,
+		// members без значения - всех
+		removed := map[string]bool{}
+		for _, member := range members {
+			removed[member.Value] = true
+		}
+		kept := g.Members[:0]
+		for _, member := range g.Members {
+			switch {
+			case path.Filter != nil && path.Filter.Match(ToMap(member)):
+			case path.Filter == nil && (len(members) == 0 || removed[member.Value]):
+			default:
+				kept = append(kept, member)
+			}
+		}
+		g.Members = kept
+	}
+	return nil
+}
+
+func (g *Group) hasMember(id string) bool {
+	for _, member := range g.Members {
+		if member.Value == id {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeString(value json.RawMessage, target *string) error {
+	if err := json.Unmarshal(value, target); err != nil {
+		return ErrInvalidValue
+	}
+	return nil
+}
+
+// decodeBool принимает как JSON boolean, так и строку "True"/"False", которую отправляют некоторые каталоги
+func decodeBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return false, ErrInvalidValue
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, ErrInvalidValue
+	}
+	return b, nil
+}
@@ -0,0 +1,98 @@
+package scim
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func patchRequest(t *testing.T, body string) *PatchRequest {
+	t.Helper()
+	var req PatchRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	return &req
+}
+
+func TestUserApplyPatch(t *testing.T) {
+	active := true
+	user := &User{
+		UserName: "anna@example.com",
+		Emails:   []MultiValue{{Value: "anna@example.com", Type: "work", Primary: true}},
+		Active:   &active,
+	}
+
+	err := user.ApplyPatch(patchRequest(t, `{"Operations": [
+		{"op": "Replace", "path": "active", "value": "False"},
+		{"op": "replace", "path": "name.givenName", "value": "Anna"},
+		{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "anna@corp.example.com"},
+		{"op": "add", "value": {"displayName": "Anna K", "externalId": "00u1"}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Active == nil || *user.Active {
+		t.Error("active was not set to false")
+	}
+	if user.Name == nil || user.Name.GivenName != "Anna" {
+		t.Errorf("name = %+v", user.Name)
+	}
+	if user.PrimaryEmail() != "anna@corp.example.com" {
+		t.Errorf("primary email = %q", user.PrimaryEmail())
+	}
+	if user.DisplayName != "Anna K" || user.ExternalID != "00u1" {
+		t.Errorf("displayName = %q, externalId = %q", user.DisplayName, user.ExternalID)
+	}
+
+	if err := user.ApplyPatch(patchRequest(t, `{"Operations": [{"op": "remove", "path": "userName"}]}`)); err != ErrMutability {
+		t.Errorf("removing userName: err = %v", err)
+	}
+	if err := user.ApplyPatch(patchRequest(t, `{"Operations": [{"op": "move", "path": "userName"}]}`)); err != ErrInvalidPatch {
+		t.Errorf("unknown op: err = %v", err)
+	}
+}
+
+func TestGroupApplyPatch(t *testing.T) {
+	group := &Group{DisplayName: "Engineering", Members: []MultiValue{{Value: "a"}, {Value: "b"}}}
+
+	err := group.ApplyPatch(patchRequest(t, `{"Operations": [
+		{"op": "add", "path": "members", "value": [{"value": "b"}, {"value": "c"}]},
+		{"op": "remove", "path": "members[value eq \"a\"]"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := memberIDs(group); got != "b,c" {
+		t.Errorf("members = %s, want b,c", got)
+	}
+
+	err = group.ApplyPatch(patchRequest(t, `{"Operations": [
+		{"op": "replace", "path": "members", "value": [{"value": "d"}]},
+		{"op": "replace", "path": "displayName", "value": "Platform"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := memberIDs(group); got != "d" || group.DisplayName != "Platform" {
+		t.Errorf("members = %s, displayName = %q", got, group.DisplayName)
+	}
+
+	if err := group.ApplyPatch(patchRequest(t, `{"Operations": [{"op": "remove", "path": "members"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(group.Members) != 0 {
+		t.Errorf("members were not removed: %v", group.Members)
+	}
+}
+
+func memberIDs(g *Group) string {
+	ids := ""
+	for i, member := range g.Members {
+		if i > 0 {
+			ids += ","
+		}
+		ids += member.Value
+	}
+	return ids
+}
@@ -4,13 +4,19 @@ import (
 	"context"
 	"errors"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/crypto/bcrypt"
+
+	"kubercode/internal/domain/auth/email"
+	"kubercode/internal/domain/auth/identity"
+	"kubercode/internal/domain/auth/password"
+	"kubercode/internal/domain/auth/push"
+	"kubercode/internal/domain/auth/saml"
 )
 
 var (
@@ -19,6 +25,7 @@ var (
 	ErrInvalidToken      = errors.New("invalid token")
 	ErrUserAlreadyExists = errors.New("user already exists")
 	ErrTokenRevoked      = errors.New("token has been revoked")
+	ErrRefreshTokenRequired = errors.New("refresh token of the session is required")
 )
 
 type Service struct {
@@ -26,14 +33,39 @@ type Service struct {
 	jwtSecret   []byte
 	tokenExpiry time.Duration
 	redis       *redis.Client
+	mailer      email.EmailSender
+	settings    Settings
+	history     EventHistory
+	providers   map[string]*identity.Provider
+	lookupTXT   func(ctx context.Context, name string) ([]string, error)
+	samlIdP     *saml.IdentityProvider
+	breached    *password.BreachedDataset
+	hasher      *password.Hasher
+	hashPool    *password.Pool
+	push        push.Sender
+	// dummyHash сравнивается с паролем, когда аккаунт не найден, чтобы время ответа
+	// не выдавало, зарегистрирован ли логин
+	dummyHash string
 }
 
-func NewService(repo *Repository, jwtSecret string, tokenExpiry time.Duration, redis *redis.Client) *Service {
+func NewService(repo *Repository, jwtSecret string, tokenExpiry time.Duration, redis *redis.Client,
+	mailer email.EmailSender, settings Settings) *Service {
+	hasher := password.NewHasher(settings.PasswordHashing)
+	dummyHash, err := hasher.Hash("kubercode-dummy-password")
+	if err != nil {
+		log.Printf("[NewService] Ошибка хеширования пароля для неизвестных аккаунтов: %v", err)
+	}
 	return &Service{
 		repo:        repo,
 		jwtSecret:   []byte(jwtSecret),
 		tokenExpiry: tokenExpiry,
 		redis:       redis,
+		mailer:      mailer,
+		settings:    settings,
+		lookupTXT:   defaultLookupTXT,
+		hasher:      hasher,
+		hashPool:    password.NewPool(settings.PasswordHashingPool),
+		dummyHash:   dummyHash,
 	}
 }
 
@@ -41,35 +73,115 @@ func (s *Service) Register(ctx context.Context, user *User) error {
 	return s.repo.CreateUser(ctx, user)
 }
 
-func (s *Service) Login(ctx context.Context, email, password string) (*LoginResponse, error) {
-	user, err := s.repo.GetUserByEmail(ctx, email)
-	if err != nil {
-		log.Printf("[Login] Пользователь не найден: %v", err)
+// Login аутентифицирует пользователя по email или имени пользователя и паролю
+func (s *Service) Login(ctx context.Context, login, password string) (resp *LoginResponse, err error) {
+	var user *User
+	defer func() { s.auditAttempt(ctx, AuditLogin, user, err, s.loginAuditDetails(user, login)) }()
+
+	user, err = s.findUserByLogin(ctx, login)
+
+	// Для email домен проверяется до пароля и независимо от наличия аккаунта
+	if strings.Contains(login, "@") {
+		if ssoErr := s.checkSSOEnforced(ctx, login, user); ssoErr != nil {
+			log.Printf("[Login] Для домена %s обязателен вход через провайдера организации", emailDomain(login))
+			return nil, ssoErr
+		}
+	}
+
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		log.Printf("[Login] Ошибка поиска пользователя: %v", err)
+		return nil, err
+	}
+
+	// Задержки и блокировка после неудачных попыток проверяются до пароля
+	// и действуют одинаково для существующих и несуществующих логинов
+	attemptKey := s.loginAttemptKey(user, login)
+	if err := s.checkLoginThrottle(ctx, attemptKey); err != nil {
+		log.Printf("[Login] Попытка входа до окончания задержки: %v", err)
+		return nil, err
+	}
+
+	if user == nil {
+		log.Printf("[Login] Пользователь не найден")
+		if err := s.verifyPassword(ctx, s.dummyHash, password); isHashingBusy(err) {
+			return nil, err
+		}
+		s.recordLoginFailure(ctx, attemptKey, nil)
 		return nil, errors.New("invalid credentials")
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	// Перегрузка пула хеширования не считается неудачной попыткой входа
+	err = s.verifyPassword(ctx, user.Password, password)
+	if isHashingBusy(err) {
+		log.Printf("[Login] Пул хеширования перегружен: %v", err)
+		return nil, err
+	}
 	if err != nil {
 		log.Printf("[Login] Ошибка сравнения паролей: %v", err)
+		s.recordLogin(ctx, user, false)
+		s.recordLoginFailure(ctx, attemptKey, user)
 		return nil, errors.New("invalid credentials")
 	}
+	s.resetLoginFailures(ctx, attemptKey)
+	s.rehashPassword(ctx, user, password)
+
+	// При входе по имени пользователя домен известен только после проверки пароля
+	if err := s.checkSSOEnforced(ctx, user.Email, user); err != nil {
+		return nil, err
+	}
+
+	// Ограничения проверяются только после пароля, чтобы не раскрывать статус аккаунта
+	if err := checkAccountRestrictions(user); err != nil {
+		log.Printf("[Login] Вход в аккаунт %s запрещен: %v", user.ID.Hex(), err)
+		s.recordLogin(ctx, user, false)
+		return nil, err
+	}
 
+	// Требование смены пароля могло появиться только что, если пароль найден в утечках
+	s.requireBreachedPasswordChange(ctx, user, password)
+	if user.PasswordResetRequired {
+		log.Printf("[Login] Пользователь %s должен восстановить пароль", user.ID.Hex())
+		return nil, ErrPasswordResetRequired
+	}
+
+	if err := s.checkEmailVerified(user); err != nil {
+		log.Printf("[Login] Email пользователя %s не подтвержден", user.ID.Hex())
+		return nil, err
+	}
+
+	resp, err = s.issueTokens(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	s.recordLogin(ctx, user, true)
+	s.checkNewDevice(ctx, user, resp)
+	return resp, nil
+}
+
+// issueTokens выпускает пару токенов для пользователя и сохраняет refresh token
+func (s *Service) issueTokens(ctx context.Context, user *User) (*LoginResponse, error) {
+	return s.issueTokensWithContext(ctx, user, time.Now(), nil)
+}
+
+// issueTokensWithContext выпускает пару токенов с заданным временем аутентификации и контекстом организации
+func (s *Service) issueTokensWithContext(ctx context.Context, user *User, authTime time.Time, org *OrgContext) (*LoginResponse, error) {
 	// Генерируем access token
-	accessToken, err := s.generateToken(user, false)
+	accessToken, err := s.generateToken(user, false, authTime, org)
 	if err != nil {
-		log.Printf("[Login] Ошибка генерации access token: %v", err)
+		log.Printf("[issueTokens] Ошибка генерации access token: %v", err)
 		return nil, err
 	}
 
 	// Генерируем refresh token
-	refreshToken, err := s.generateToken(user, true)
+	refreshToken, err := s.generateToken(user, true, authTime, org)
 	if err != nil {
-		log.Printf("[Login] Ошибка генерации refresh token: %v", err)
+		log.Printf("[issueTokens] Ошибка генерации refresh token: %v", err)
 		return nil, err
 	}
 
 	// Сохраняем refresh token в базу данных
 	token := &Token{
+		ID:           primitive.NewObjectID(),
 		UserID:       user.ID,
 		RefreshToken: refreshToken,
 		ExpiresAt:    time.Now().Add(time.Hour * 24 * 30), // Refresh token живет 30 дней
@@ -78,29 +190,78 @@ func (s *Service) Login(ctx context.Context, email, password string) (*LoginResp
 	}
 
 	if err := s.repo.SaveToken(ctx, token); err != nil {
-		log.Printf("[Login] Ошибка сохранения refresh token: %v", err)
+		log.Printf("[issueTokens] Ошибка сохранения refresh token: %v", err)
 		return nil, err
 	}
 
+	info := s.userInfo(user)
+	if org != nil {
+		info.OrgID = org.OrgID.Hex()
+		info.OrgRole = org.Role
+	}
 	return &LoginResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-		User: UserInfo{
-			ID:       user.ID,
-			Email:    user.Email,
-			IsMentor: user.IsMentor,
-		},
+		User:         info,
+		sessionID:    token.ID,
 	}, nil
 }
 
-func (s *Service) generateToken(user *User, isRefresh bool) (string, error) {
+// userInfo формирует публичную информацию о пользователе
+func (s *Service) userInfo(user *User) UserInfo {
+	info := UserInfo{
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username,
+		IsMentor:      s.effectiveIsMentor(user),
+		EmailVerified: user.EmailVerified,
+		IsAdmin:       user.IsAdmin,
+	}
+	if !user.DeletionScheduledAt.IsZero() {
+		scheduledAt := user.DeletionScheduledAt
+		info.DeletionScheduledAt = &scheduledAt
+	}
+	return info
+}
+
+// generateToken выпускает токен пользователя. authTime - момент, когда пользователь
+// последний раз вводил учетные данные, он переносится в access токены при refresh.
+// org - выбранная организация, nil для личного контекста
+func (s *Service) generateToken(user *User, isRefresh bool, authTime time.Time, org *OrgContext) (string, error) {
+	issuedAt := time.Now()
 	claims := jwt.MapClaims{
-		"user_id": user.ID.Hex(),
-		"email":   user.Email,
-		"exp":     time.Now().Add(time.Hour).Unix(), // Access token живет 1 час
+		"user_id":        user.ID.Hex(),
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"is_mentor":      s.effectiveIsMentor(user),
+		"is_admin":       user.IsAdmin,
+		"iat":            issuedAt.Unix(),
+		"iat_ms":         issuedAt.UnixMilli(), // для сравнения с моментом отзыва сессий
+		"auth_time":      authTime.Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(), // Access token живет 1 час
+	}
+
+	if user.Username != "" {
+		claims["username"] = user.Username
+	}
+
+	if org != nil {
+		claims["org_id"] = org.OrgID.Hex()
+		claims["org_role"] = string(org.Role)
+	}
+
+	if s.settings.ProfileClaims && !isRefresh {
+		if user.Profile.DisplayName != "" {
+			claims["name"] = user.Profile.DisplayName
+		}
+		if user.Profile.Locale != "" {
+			claims["locale"] = user.Profile.Locale
+		}
 	}
 
 	if isRefresh {
+		// Refresh токен принимается только для обновления, access токен - только для доступа к API
+		claims["typ"] = tokenTypeRefresh
 		claims["exp"] = time.Now().Add(time.Hour * 24 * 30).Unix() // Refresh token живет 30 дней
 	}
 
@@ -109,12 +270,23 @@ func (s *Service) generateToken(user *User, isRefresh bool) (string, error) {
 }
 
 func (s *Service) ValidateToken(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
 		return s.jwtSecret, nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Токены из писем не могут использоваться для доступа к API
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		if _, isAction := claims["purpose"]; isAction {
+			return nil, ErrInvalidToken
+		}
+	}
+	return token, nil
 }
 
 func (s *Service) GetUserFromToken(token *jwt.Token) (*User, error) {
@@ -140,61 +312,86 @@ func (s *Service) SignUp(ctx context.Context, req *SignUpRequest) (*LoginRespons
 	}
 
 	// Создаем нового пользователя
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, err
-	}
-
 	user := &User{
 		ID:          primitive.NewObjectID(),
 		Email:       req.Email,
-		Password:    string(hashedPassword),
 		IsMentor:    req.IsMentor,
 		DeviceToken: req.DeviceToken,
 	}
-
-	if err := s.repo.CreateUser(ctx, user); err != nil {
+	if err := s.setPassword(ctx, user, req.Password); err != nil {
 		return nil, err
 	}
 
-	// Генерируем токены
-	accessToken, err := s.generateToken(user, false)
-	if err != nil {
-		return nil, err
+	// Имя пользователя при регистрации необязательно
+	if req.Username != "" {
+		normalized := normalizeUsername(req.Username)
+		if err := s.validateUsername(normalized); err != nil {
+			return nil, err
+		}
+		user.Username = strings.TrimSpace(req.Username)
+		user.UsernameNormalized = normalized
 	}
 
-	refreshToken, err := s.generateToken(user, true)
-	if err != nil {
+	if err := s.repo.CreateUser(ctx, user); err != nil {
 		return nil, err
 	}
+	s.recordEvent(ctx, &DomainEvent{
+		Type:    EventAccountRegistered,
+		UserID:  user.ID,
+		ActorID: user.ID,
+		Data:    map[string]interface{}{"method": "password"},
+	})
 
-	return &LoginResponse{
-        AccessToken:  accessToken,
-        RefreshToken: refreshToken,
-        User: UserInfo{
-            ID:       user.ID,
-            Email:    user.Email,
-            IsMentor: user.IsMentor,
-        },
-    }, nil
+	// Отправляем письмо для подтверждения email
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("[SignUp] Ошибка отправки письма подтверждения: %v", err)
+	}
+
+	// Генерируем токены, refresh token сохраняется как у обычного входа
+	return s.issueTokens(ctx, user)
 }
 
 // RefreshToken обновляет токен доступа
-func (s *Service) RefreshToken(ctx context.Context, refreshToken string) (*RefreshTokenResponse, error) {
+func (s *Service) RefreshToken(ctx context.Context, refreshToken string) (resp *RefreshTokenResponse, err error) {
+	var user *User
+	defer func() { s.auditAttempt(ctx, AuditTokenRefreshed, user, err, nil) }()
+
 	// Валидируем refresh token
 	token, err := s.ValidateToken(refreshToken)
 	if err != nil {
 		return nil, errors.New("invalid token")
 	}
 
+	claims := token.Claims.(jwt.MapClaims)
+	if typ, _ := claims["typ"].(string); typ != tokenTypeRefresh {
+		return nil, ErrInvalidToken
+	}
+
 	// Получаем пользователя
-	user, err := s.GetUserFromToken(token)
+	user, err = s.GetUserFromToken(token)
 	if err != nil {
 		return nil, errors.New("invalid token")
 	}
 
+	// Токен должен быть сохранен при входе и не отозван выходом
+	if err := s.checkRefreshTokenActive(ctx, user, refreshToken); err != nil {
+		return nil, err
+	}
+
+	// Проверяем, что сессии пользователя не были отозваны после выпуска токена
+	if err := checkTokenIssuedAt(claims, user); err != nil {
+		return nil, err
+	}
+	if err := checkAccountRestrictions(user); err != nil {
+		return nil, err
+	}
+
+	// Контекст организации сохраняется, пока пользователь в ней состоит
+	orgID, _ := claims["org_id"].(string)
+	org := s.orgContextFromClaims(ctx, user, orgID)
+
 	// Генерируем новый access token
-	accessToken, err := s.generateToken(user, false)
+	accessToken, err := s.generateToken(user, false, claimTime(claims, "auth_time"), org)
 	if err != nil {
 		return nil, err
 	}
@@ -204,35 +401,46 @@ func (s *Service) RefreshToken(ctx context.Context, refreshToken string) (*Refre
 	}, nil
 }
 
-// Logout выполняет выход пользователя
-func (s *Service) Logout(ctx context.Context, userID string) (*gin.H, error) {
+// Logout выполняет выход пользователя: access токен из контекста попадает в черный список,
+// refresh токен сессии удаляется. refreshToken передает клиент (в теле или cookie), остальные
+// сессии пользователя не затрагиваются
+func (s *Service) Logout(ctx context.Context, userID, refreshToken string) (resp *gin.H, err error) {
+	defer func() { s.auditResult(ctx, AuditLogout, userID, err) }()
+
+	if refreshToken == "" {
+		return nil, ErrRefreshTokenRequired
+	}
+
 	// Получаем токен из контекста
-	token := ctx.Value("token").(string)
+	token, _ := ctx.Value("token").(string)
 	if token == "" {
 		log.Printf("[Logout] Токен не найден в контексте")
 		return nil, errors.New("token not found")
 	}
 
 	// Добавляем токен в черный список
-	expiration := time.Hour * 24 * 30 // 30 дней
-	err := s.redis.Set(ctx, "blacklist:"+token, "revoked", expiration).Err()
-	if err != nil {
+	if err = s.blacklistToken(ctx, token); err != nil {
 		log.Printf("[Logout] Ошибка добавления токена в черный список: %v", err)
 		return nil, err
 	}
 
+	if err = s.revokeRefreshToken(ctx, userID, refreshToken); err != nil {
+		log.Printf("[Logout] Ошибка отзыва refresh токена: %v", err)
+		return nil, err
+	}
+
 	return &gin.H{"message": "Successfully logged out"}, nil
 }
 
 // VerifyToken проверяет токен и возвращает информацию о пользователе
 func (s *Service) VerifyToken(ctx context.Context, token string) (*UserInfo, error) {
 	// Проверяем, не отозван ли токен
-	exists, err := s.redis.Exists(ctx, "blacklist:"+token).Result()
+	blacklisted, err := s.isTokenBlacklisted(ctx, token)
 	if err != nil {
 		log.Printf("[VerifyToken] Ошибка проверки черного списка: %v", err)
 		return nil, ErrInvalidToken
 	}
-	if exists > 0 {
+	if blacklisted {
 		log.Printf("[VerifyToken] Токен находится в черном списке")
 		return nil, ErrTokenRevoked
 	}
@@ -251,6 +459,12 @@ func (s *Service) VerifyToken(ctx context.Context, token string) (*UserInfo, err
 		return nil, ErrInvalidToken
 	}
 
+	// Refresh токен не дает доступа к API
+	if typ, _ := claims["typ"].(string); typ == tokenTypeRefresh {
+		log.Printf("[VerifyToken] Передан refresh токен вместо access токена")
+		return nil, ErrInvalidToken
+	}
+
 	// Проверяем время жизни токена
 	exp, ok := claims["exp"].(float64)
 	if !ok {
@@ -275,40 +489,31 @@ func (s *Service) VerifyToken(ctx context.Context, token string) (*UserInfo, err
 		return nil, ErrInvalidToken
 	}
 
-	return &UserInfo{
-		ID:       user.ID,
-		Email:    user.Email,
-		IsMentor: user.IsMentor,
-	}, nil
-}
-
-// ChangePassword изменяет пароль пользователя
-func (s *Service) ChangePassword(ctx context.Context, userID string, oldPassword, newPassword string) error {
-	id, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		return err
-	}
-
-	user, err := s.repo.GetUserByID(ctx, id)
-	if err != nil {
-		return err
+	if err := checkAccessTokenIssuedAt(claims, user); err != nil {
+		log.Printf("[VerifyToken] Токен выпущен до отзыва сессий или изменения данных пользователя")
+		return nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(oldPassword)); err != nil {
-		return ErrInvalidCredentials
+	if err := checkAccountRestrictions(user); err != nil {
+		log.Printf("[VerifyToken] Доступ к аккаунту %s запрещен: %v", user.ID.Hex(), err)
+		return nil, err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return err
+	info := s.userInfo(user)
+	info.AuthTime = claimTime(claims, "auth_time")
+	// Claims актуальны: при удалении из организации старые access токены отзываются
+	if orgID, ok := claims["org_id"].(string); ok {
+		info.OrgID = orgID
+		role, _ := claims["org_role"].(string)
+		info.OrgRole = OrgRole(role)
 	}
-
-	user.Password = string(hashedPassword)
-	return s.repo.UpdateUser(ctx, user)
+	return &info, nil
 }
 
-// ChangeEmail изменяет email пользователя
-func (s *Service) ChangeEmail(ctx context.Context, userID string, newEmail, password string) error {
+// ChangePassword изменяет пароль пользователя
+func (s *Service) ChangePassword(ctx context.Context, userID string, oldPassword, newPassword string) (err error) {
+	defer func() { s.auditResult(ctx, AuditPasswordChanged, userID, err) }()
+
 	id, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return err
@@ -319,55 +524,36 @@ func (s *Service) ChangeEmail(ctx context.Context, userID string, newEmail, pass
 		return err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return ErrInvalidCredentials
+	if err := s.checkPassword(ctx, user, oldPassword); err != nil {
+		return err
 	}
 
-	existingUser, err := s.repo.GetUserByEmail(ctx, newEmail)
-	if err == nil && existingUser != nil {
-		return ErrUserAlreadyExists
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return err
 	}
-
-	user.Email = newEmail
-	return s.repo.UpdateUser(ctx, user)
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	s.recordEvent(ctx, &DomainEvent{Type: EventPasswordChanged, UserID: user.ID, ActorID: user.ID})
+	return nil
 }
 
-// LogoutFromAllDevices выполняет выход со всех устройств
-func (s *Service) LogoutFromAllDevices(ctx context.Context, userID string) (*gin.H, error) {
-	// Получаем текущий токен из контекста
-	currentToken := ctx.Value("token").(string)
-	if currentToken == "" {
-		log.Printf("[LogoutFromAllDevices] Токен не найден в контексте")
-		return nil, errors.New("token not found")
-	}
+// LogoutFromAllDevices выполняет выход со всех устройств: refresh токены удаляются,
+// а уже выданные access и refresh токены перестают приниматься
+func (s *Service) LogoutFromAllDevices(ctx context.Context, userID string) (resp *gin.H, err error) {
+	defer func() { s.auditResult(ctx, AuditLogoutAllDevices, userID, err) }()
 
-	// Добавляем текущий токен в черный список
-	expiration := time.Hour * 24 * 30 // 30 дней
-	err := s.redis.Set(ctx, "blacklist:"+currentToken, "revoked", expiration).Err()
+	id, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		log.Printf("[LogoutFromAllDevices] Ошибка добавления текущего токена в черный список: %v", err)
-		return nil, err
+		return nil, ErrUserNotFound
 	}
-
-	// Получаем все refresh токены пользователя
-	tokens, err := s.repo.GetUserTokens(ctx, userID)
+	user, err := s.repo.GetUserByID(ctx, id)
 	if err != nil {
-		log.Printf("[LogoutFromAllDevices] Ошибка получения токенов пользователя: %v", err)
 		return nil, err
 	}
 
-	// Добавляем все токены в черный список
-	for _, token := range tokens {
-		err := s.redis.Set(ctx, "blacklist:"+token.RefreshToken, "revoked", expiration).Err()
-		if err != nil {
-			log.Printf("[LogoutFromAllDevices] Ошибка добавления токена в черный список: %v", err)
-			continue
-		}
-	}
-
-	// Удаляем все токены из базы данных
-	if err := s.repo.DeleteUserTokens(ctx, userID); err != nil {
-		log.Printf("[LogoutFromAllDevices] Ошибка удаления токенов из базы данных: %v", err)
+	if err = s.revokeAllSessions(ctx, user); err != nil {
+		log.Printf("[LogoutFromAllDevices] Ошибка отзыва сессий пользователя: %v", err)
 		return nil, err
 	}
 
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// tokenTypeRefresh - значение claim typ у refresh токена
+const tokenTypeRefresh = "refresh"
+
+// blacklistTTL - срок хранения отозванного токена в черном списке, не меньше жизни refresh токена
+const blacklistTTL = 30 * 24 * time.Hour
+
+// blacklistToken добавляет токен в черный список до истечения его срока
+func (s *Service) blacklistToken(ctx context.Context, token string) error {
+	return s.redis.Set(ctx, "blacklist:"+token, "revoked", blacklistTTL).Err()
+}
+
+// isTokenBlacklisted сообщает, что токен отозван выходом
+func (s *Service) isTokenBlacklisted(ctx context.Context, token string) (bool, error) {
+	exists, err := s.redis.Exists(ctx, "blacklist:"+token).Result()
+	if err != nil {
+		return false, err
+	}
+	return exists > 0, nil
+}
+
+// checkRefreshTokenActive проверяет, что refresh токен не отозван и принадлежит сохраненной
+// сессии пользователя: после выхода, отзыва сессий или нового входа токена в базе нет
+func (s *Service) checkRefreshTokenActive(ctx context.Context, user *User, refreshToken string) error {
+	blacklisted, err := s.isTokenBlacklisted(ctx, refreshToken)
+	if err != nil {
+		log.Printf("[checkRefreshTokenActive] Ошибка проверки черного списка: %v", err)
+		return ErrInvalidToken
+	}
+	if blacklisted {
+		return ErrTokenRevoked
+	}
+
+	stored, err := s.repo.GetToken(ctx, refreshToken)
+	if err != nil || stored.UserID != user.ID {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// revokeRefreshToken удаляет сессию refresh токена и добавляет его в черный список.
+// Чужой токен не отзывается
+func (s *Service) revokeRefreshToken(ctx context.Context, userID, refreshToken string) error {
+	stored, err := s.repo.GetToken(ctx, refreshToken)
+	if err != nil {
+		// Сессия уже удалена, но токен все равно не должен приниматься
+		return s.blacklistToken(ctx, refreshToken)
+	}
+	if stored.UserID.Hex() != userID {
+		return ErrInvalidToken
+	}
+	if err := s.repo.DeleteToken(ctx, refreshToken); err != nil {
+		return err
+	}
+	return s.blacklistToken(ctx, refreshToken)
+}
+
+// revokeAllSessions отзывает все выданные пользователю токены. Refresh токены
+// удаляются из базы, а access и refresh токены, выпущенные раньше текущего
+// момента, перестают приниматься по времени выпуска (claim iat_ms)
+func (s *Service) revokeAllSessions(ctx context.Context, user *User) error {
+	// MongoDB хранит время с точностью до миллисекунды
+	validAfter := time.Now().Truncate(time.Millisecond)
+	if err := s.repo.SetTokensValidAfter(ctx, user.ID, validAfter); err != nil {
+		return err
+	}
+	user.TokensValidAfter = validAfter
+
+	return s.repo.DeleteUserTokens(ctx, user.ID.Hex())
+}
+
+// checkTokenIssuedAt проверяет, что токен выпущен после последнего отзыва сессий пользователя
+func checkTokenIssuedAt(claims jwt.MapClaims, user *User) error {
+	if user.TokensValidAfter.IsZero() {
+		return nil
+	}
+	if tokenIssuedAtMillis(claims) < user.TokensValidAfter.UnixMilli() {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// refreshSessionClaims помечает claims в выданных access токенах как устаревшие.
+// Такие access токены перестают приниматься, а клиенты получают новые через
+// refresh, при этом сами сессии (refresh токены) остаются действительными
+func (s *Service) refreshSessionClaims(ctx context.Context, user *User) error {
+	changedAt := time.Now().Truncate(time.Millisecond)
+	if err := s.repo.SetClaimsChangedAt(ctx, user.ID, changedAt); err != nil {
+		return err
+	}
+	user.ClaimsChangedAt = changedAt
+	return nil
+}
+
+// checkAccessTokenIssuedAt проверяет, что access токен не отозван и содержит актуальные claims
+func checkAccessTokenIssuedAt(claims jwt.MapClaims, user *User) error {
+	if err := checkTokenIssuedAt(claims, user); err != nil {
+		return err
+	}
+	if user.ClaimsChangedAt.IsZero() {
+		return nil
+	}
+	if tokenIssuedAtMillis(claims) < user.ClaimsChangedAt.UnixMilli() {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// tokenIssuedAtMillis возвращает время выпуска токена в миллисекундах. iat хранит только секунды,
+// поэтому отзыв сравнивается с iat_ms. У токенов, выпущенных до появления iat_ms, берется начало
+// секунды из iat
+func tokenIssuedAtMillis(claims jwt.MapClaims) int64 {
+	if iatMillis, ok := claims["iat_ms"].(float64); ok {
+		return int64(iatMillis)
+	}
+	iat, _ := claims["iat"].(float64)
+	return int64(iat) * 1000
+}
+
+// claimTime возвращает время из числового claim токена или нулевое время, если claim отсутствует
+func claimTime(claims jwt.MapClaims, name string) time.Time {
+	value, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(int64(value), 0)
+}
+
+// IsRecentAuth проверяет, что пользователь вводил учетные данные не позднее ReauthMaxAge назад
+func (s *Service) IsRecentAuth(authTime time.Time) bool {
+	return !authTime.IsZero() && time.Since(authTime) <= s.settings.ReauthMaxAge
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestCheckTokenIssuedAt(t *testing.T) {
+	revokedAt := time.Date(2026, 1, 1, 12, 0, 0, 500*int(time.Millisecond), time.UTC)
+	user := &User{TokensValidAfter: revokedAt}
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   error
+	}{
+		{"issued before revocation", jwt.MapClaims{"iat": float64(revokedAt.Unix() - 60), "iat_ms": float64(revokedAt.UnixMilli() - 60000)}, ErrTokenRevoked},
+		{"same second before revocation", jwt.MapClaims{"iat": float64(revokedAt.Unix()), "iat_ms": float64(revokedAt.UnixMilli() - 200)}, ErrTokenRevoked},
+		{"same second after revocation", jwt.MapClaims{"iat": float64(revokedAt.Unix()), "iat_ms": float64(revokedAt.UnixMilli() + 200)}, nil},
+		{"issued at revocation", jwt.MapClaims{"iat": float64(revokedAt.Unix()), "iat_ms": float64(revokedAt.UnixMilli())}, nil},
+		{"legacy token in revocation second", jwt.MapClaims{"iat": float64(revokedAt.Unix())}, ErrTokenRevoked},
+		{"legacy token after revocation", jwt.MapClaims{"iat": float64(revokedAt.Unix() + 1)}, nil},
+	}
+	for _, tt := range tests {
+		if err := checkTokenIssuedAt(tt.claims, user); !errors.Is(err, tt.want) {
+			t.Errorf("%s: checkTokenIssuedAt = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if err := checkTokenIssuedAt(jwt.MapClaims{"iat": float64(0)}, &User{}); err != nil {
+		t.Errorf("without revocation any token should pass, got %v", err)
+	}
+}
+
+func TestCheckAccessTokenIssuedAt(t *testing.T) {
+	changedAt := time.Date(2026, 1, 1, 12, 0, 0, 300*int(time.Millisecond), time.UTC)
+	user := &User{ClaimsChangedAt: changedAt}
+
+	stale := jwt.MapClaims{"iat": float64(changedAt.Unix()), "iat_ms": float64(changedAt.UnixMilli() - 1)}
+	if err := checkAccessTokenIssuedAt(stale, user); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token with stale claims: err = %v, want ErrInvalidToken", err)
+	}
+	// Токены выпускаются сразу после изменения claims в том же запросе (например, при смене email)
+	fresh := jwt.MapClaims{"iat": float64(changedAt.Unix()), "iat_ms": float64(changedAt.UnixMilli())}
+	if err := checkAccessTokenIssuedAt(fresh, user); err != nil {
+		t.Errorf("token issued right after the change should pass, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"time"
+
+	"kubercode/internal/domain/auth/password"
+)
+
+// UnverifiedPolicy определяет, что разрешено аккаунтам с неподтвержденным email
+type UnverifiedPolicy string
+
+const (
+	// UnverifiedAllow - неподтвержденный аккаунт работает без ограничений
+	UnverifiedAllow UnverifiedPolicy = "allow"
+	// UnverifiedRestricted - вход разрешен, но возможности ментора отключены до подтверждения
+	UnverifiedRestricted UnverifiedPolicy = "restricted"
+	// UnverifiedDeny - вход запрещен до подтверждения email
+	UnverifiedDeny UnverifiedPolicy = "deny"
+)
+
+// Settings содержит настраиваемые параметры сервиса аутентификации
+type Settings struct {
+	// AppURL - адрес фронтенда, используется для ссылок в письмах
+	AppURL string
+
+	// EmailVerificationTTL - время жизни токена подтверждения email
+	EmailVerificationTTL time.Duration
+	// EmailVerificationResendInterval - минимальный интервал между повторными отправками письма
+	EmailVerificationResendInterval time.Duration
+	// UnverifiedPolicy - ограничения для аккаунтов с неподтвержденным email
+	UnverifiedPolicy UnverifiedPolicy
+
+	// PasswordResetTTL - время жизни кода восстановления пароля
+	PasswordResetTTL time.Duration
+	// PasswordResetResendInterval - минимальный интервал между запросами на восстановление
+	PasswordResetResendInterval time.Duration
+	// PasswordResetMaxAttempts - количество попыток ввода кода восстановления
+	PasswordResetMaxAttempts int
+
+	// EmailChangeTTL - время жизни кода подтверждения нового email
+	EmailChangeTTL time.Duration
+	// EmailChangeRevertTTL - время, в течение которого смену email можно отменить по ссылке со старого адреса
+	EmailChangeRevertTTL time.Duration
+	// EmailChangeMaxAttempts - количество попыток ввода кода подтверждения нового email
+	EmailChangeMaxAttempts int
+
+	// AccountDeletionGracePeriod - время, в течение которого удаление аккаунта можно отменить
+	AccountDeletionGracePeriod time.Duration
+	// AccountPurgeInterval - период запуска окончательного удаления аккаунтов
+	AccountPurgeInterval time.Duration
+
+	// ReauthMaxAge - сколько времени после ввода пароля доступны чувствительные операции
+	ReauthMaxAge time.Duration
+	// DataExportLinkTTL - время жизни ссылки на скачивание архива с данными пользователя
+	DataExportLinkTTL time.Duration
+
+	// ProfileClaims - добавлять имя и локаль из профиля в claims access токенов
+	ProfileClaims bool
+
+	// ReservedUsernames - имена пользователей, которые нельзя занять
+	ReservedUsernames []string
+	// ProfanityBlocklist - слова, которые не могут входить в имя пользователя
+	ProfanityBlocklist []string
+	// UsernameChangeCooldown - минимальный интервал между сменами имени пользователя
+	UsernameChangeCooldown time.Duration
+
+	// OAuthStateTTL - время, за которое нужно завершить авторизацию у внешнего провайдера
+	OAuthStateTTL time.Duration
+
+	// OrgInvitationTTL - время жизни приглашения в организацию
+	OrgInvitationTTL time.Duration
+	// SSORedirectURL - адрес фронтенда, на который корпоративный провайдер возвращает пользователя
+	SSORedirectURL string
+
+	// SAMLRequestTTL - время, за которое пользователь должен войти после запроса SAML SP
+	SAMLRequestTTL time.Duration
+
+	// LoginBackoffAfter - после стольких неудачных попыток входа подряд включаются задержки
+	LoginBackoffAfter int
+	// LoginBackoffBase - первая задержка, каждая следующая неудачная попытка ее удваивает
+	LoginBackoffBase time.Duration
+	// LoginBackoffMax - максимальная задержка между попытками
+	LoginBackoffMax time.Duration
+	// LoginLockThreshold - после стольких неудачных попыток подряд вход блокируется
+	LoginLockThreshold int
+	// LoginLockDuration - время блокировки входа
+	LoginLockDuration time.Duration
+	// LoginFailureWindow - счетчик неудачных попыток сбрасывается, если их не было это время
+	LoginFailureWindow time.Duration
+
+	// PasswordPolicy - требования к паролю при регистрации, смене и восстановлении
+	PasswordPolicy password.Policy
+	// PasswordHashing - алгоритм и параметры хеширования новых паролей
+	PasswordHashing password.HashConfig
+	// PasswordHashingPool - ограничение одновременных хеширований и очередь к ним
+	PasswordHashingPool password.PoolConfig
+	// PasswordHistorySize - сколько последних паролей, включая текущий, нельзя использовать повторно
+	PasswordHistorySize int
+	// BreachedPasswordMinCount - пароль отклоняется, если встречался в утечках не меньше стольких раз
+	BreachedPasswordMinCount int
+	// BreachedPasswordCheckOnLogin - при входе с утекшим паролем требовать его смены
+	BreachedPasswordCheckOnLogin bool
+
+	// NewDeviceAlerts - уведомлять о входе с нового устройства, подсети или браузера
+	NewDeviceAlerts bool
+	// NewDeviceReportTTL - сколько действует ссылка "это был не я" из уведомления
+	NewDeviceReportTTL time.Duration
+	// KnownDeviceTTL - устройство забывается, если с него не входили это время
+	KnownDeviceTTL time.Duration
+
+	// FieldReencryptionInterval - период проверки полей, зашифрованных старым мастер-ключом
+	FieldReencryptionInterval time.Duration
+}
+
+// DefaultSettings возвращает настройки по умолчанию
+func DefaultSettings() Settings {
+	return Settings{
+		AppURL:                          "http://localhost:3000",
+		EmailVerificationTTL:            24 * time.Hour,
+		EmailVerificationResendInterval: time.Minute,
+		UnverifiedPolicy:                UnverifiedAllow,
+		PasswordResetTTL:                15 * time.Minute,
+		PasswordResetResendInterval:     time.Minute,
+		PasswordResetMaxAttempts:        5,
+		EmailChangeTTL:                  15 * time.Minute,
+		EmailChangeRevertTTL:            72 * time.Hour,
+		EmailChangeMaxAttempts:          5,
+		AccountDeletionGracePeriod:      14 * 24 * time.Hour,
+		AccountPurgeInterval:            time.Hour,
+		ReauthMaxAge:                    5 * time.Minute,
+		DataExportLinkTTL:               time.Hour,
+		ReservedUsernames:               DefaultReservedUsernames,
+		ProfanityBlocklist:              DefaultProfanityBlocklist,
+		UsernameChangeCooldown:          30 * 24 * time.Hour,
+		OAuthStateTTL:                   10 * time.Minute,
+		OrgInvitationTTL:                7 * 24 * time.Hour,
+		SSORedirectURL:                  "http://localhost:3000/sso/callback",
+		SAMLRequestTTL:                  10 * time.Minute,
+		LoginBackoffAfter:               3,
+		LoginBackoffBase:                time.Second,
+		LoginBackoffMax:                 time.Minute,
+		LoginLockThreshold:              10,
+		LoginLockDuration:               15 * time.Minute,
+		LoginFailureWindow:              24 * time.Hour,
+		PasswordPolicy:                  password.DefaultPolicy(),
+		PasswordHashing:                 password.DefaultHashConfig(),
+		PasswordHashingPool:             password.DefaultPoolConfig(),
+		PasswordHistorySize:             5,
+		BreachedPasswordMinCount:        1,
+		NewDeviceAlerts:                 true,
+		NewDeviceReportTTL:              7 * 24 * time.Hour,
+		KnownDeviceTTL:                  180 * 24 * time.Hour,
+		FieldReencryptionInterval:       time.Hour,
+	}
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrUsernameInvalid  = errors.New("username must be 3-30 characters: latin letters, digits, '_', '.' or '-', starting with a letter")
+	ErrUsernameReserved = errors.New("username is reserved")
+	ErrUsernameTaken    = errors.New("username is already taken")
+)
+
+// UsernameCooldownError возвращается, если имя пользователя менялось недавно
+type UsernameCooldownError struct {
+	RetryAt time.Time
+}
+
+func (e *UsernameCooldownError) Error() string {
+	return fmt.Sprintf("username can be changed again after %s", e.RetryAt.UTC().Format(time.RFC3339))
+}
+
+var usernamePattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]{2,29}$`)
+
+// DefaultReservedUsernames - имена, которые нельзя занять, чтобы пользователи не выдавали себя за сервис
+var DefaultReservedUsernames = []string{
+	"admin", "administrator", "root", "system", "support", "help", "security", "moderator", "staff",
+	"kubercode", "api", "auth", "login", "logout", "signup", "me", "settings", "mentor", "mentors",
+	"null", "undefined", "anonymous", "noreply", "no-reply", "postmaster", "webmaster",
+}
+
+// DefaultProfanityBlocklist - корни слов, которые не могут входить в имя пользователя
+var DefaultProfanityBlocklist = []string{
+	"fuck", "shit", "bitch", "cunt", "dick", "pussy", "whore", "slut", "nazi",
+	"huy", "hui", "pizd", "blyad", "blyat", "ebat", "eblan", "mudak", "suka", "pidor",
+}
+
+// UsernameAvailability - результат проверки имени пользователя
+type UsernameAvailability struct {
+	Username  string `json:"username"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// normalizeUsername приводит имя к виду, в котором оно хранится для поиска без учета регистра
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// validateUsername проверяет формат имени и списки запрещенных имен
+func (s *Service) validateUsername(normalized string) error {
+	if !usernamePattern.MatchString(normalized) {
+		return ErrUsernameInvalid
+	}
+	for _, reserved := range s.settings.ReservedUsernames {
+		if normalized == reserved {
+			return ErrUsernameReserved
+		}
+	}
+	// Разделители убираются, чтобы запрещенные слова нельзя было разбить точками или подчеркиваниями
+	compact := strings.NewReplacer("_", "", ".", "", "-", "").Replace(normalized)
+	for _, word := range s.settings.ProfanityBlocklist {
+		if strings.Contains(compact, word) {
+			return ErrUsernameReserved
+		}
+	}
+	return nil
+}
+
+// CheckUsernameAvailability проверяет, можно ли занять имя пользователя
+func (s *Service) CheckUsernameAvailability(ctx context.Context, username string) (*UsernameAvailability, error) {
+	normalized := normalizeUsername(username)
+	result := &UsernameAvailability{Username: username}
+
+	if err := s.validateUsername(normalized); err != nil {
+		result.Reason = err.Error()
+		return result, nil
+	}
+
+	_, err := s.repo.GetUserByUsername(ctx, normalized)
+	switch {
+	case err == nil:
+		result.Reason = ErrUsernameTaken.Error()
+	case errors.Is(err, ErrUserNotFound):
+		result.Available = true
+	default:
+		return nil, err
+	}
+	return result, nil
+}
+
+// ChangeUsername задает или меняет имя пользователя. Повторная смена возможна
+// только после UsernameChangeCooldown, чтобы старое имя нельзя было быстро перехватить
+func (s *Service) ChangeUsername(ctx context.Context, userID string, username string) (*UserInfo, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := normalizeUsername(username)
+	if err := s.validateUsername(normalized); err != nil {
+		return nil, err
+	}
+	if normalized == user.UsernameNormalized && strings.TrimSpace(username) == user.Username {
+		info := s.userInfo(user)
+		return &info, nil
+	}
+
+	// Смена регистра своего имени не считается переименованием
+	if normalized != user.UsernameNormalized && !user.UsernameChangedAt.IsZero() {
+		retryAt := user.UsernameChangedAt.Add(s.settings.UsernameChangeCooldown)
+		if time.Now().Before(retryAt) {
+			return nil, &UsernameCooldownError{RetryAt: retryAt}
+		}
+	}
+
+	oldUsername := user.Username
+	changedAt := user.UsernameChangedAt
+	if normalized != user.UsernameNormalized {
+		changedAt = time.Now()
+	}
+	// Уникальность гарантирует индекс, поэтому занятое имя определяется при сохранении
+	if err := s.repo.SetUsername(ctx, user.ID, strings.TrimSpace(username), normalized, changedAt); err != nil {
+		return nil, err
+	}
+	user.Username = strings.TrimSpace(username)
+	user.UsernameNormalized = normalized
+	user.UsernameChangedAt = changedAt
+
+	// Имя пользователя входит в claims токенов
+	if err := s.refreshSessionClaims(ctx, user); err != nil {
+		log.Printf("[ChangeUsername] Ошибка обновления claims сессий: %v", err)
+		return nil, err
+	}
+
+	s.publishEvent(ctx, &DomainEvent{
+		Type:    EventUsernameChanged,
+		UserID:  user.ID,
+		ActorID: user.ID,
+		Data:    map[string]interface{}{"old_username": oldUsername, "new_username": user.Username},
+	})
+
+	info := s.userInfo(user)
+	return &info, nil
+}
+
+// findUserByLogin ищет пользователя по email или имени пользователя
+func (s *Service) findUserByLogin(ctx context.Context, login string) (*User, error) {
+	login = strings.TrimSpace(login)
+	if strings.Contains(login, "@") {
+		return s.repo.GetUserByEmail(ctx, login)
+	}
+	return s.repo.GetUserByUsername(ctx, normalizeUsername(login))
+}
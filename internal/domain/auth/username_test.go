@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateUsername(t *testing.T) {
+	s := &Service{settings: DefaultSettings()}
+
+	tests := []struct {
+		username string
+		want     error
+	}{
+		{"gopher", nil},
+		{"go_pher.42", nil},
+		{"ab", ErrUsernameInvalid},
+		{"1gopher", ErrUsernameInvalid},
+		{"gopher!", ErrUsernameInvalid},
+		{"admin", ErrUsernameReserved},
+		{"kubercode", ErrUsernameReserved},
+		{"sh.i_t-happens", ErrUsernameReserved},
+	}
+	for _, tt := range tests {
+		err := s.validateUsername(normalizeUsername(tt.username))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("validateUsername(%q) = %v, want %v", tt.username, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	if got := normalizeUsername("  GoPher "); got != "gopher" {
+		t.Errorf("expected gopher, got %q", got)
+	}
+}
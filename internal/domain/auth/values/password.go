@@ -3,10 +3,7 @@ package values
 import (
 	"encoding/json"
 	"fmt"
-	"golang.org/x/crypto/bcrypt"
-
-	//"golang.org/x/crypto/bcrypt"
-	"unicode"
+	"kubercode/internal/domain/auth/password"
 )
 
 type Password struct {
@@ -17,39 +14,24 @@ func NewPassword(password string) (*Password, error) {
 	if !isValidPassword(password) {
 		return nil, fmt.Errorf("invalid password: %s", password)
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := PasswordHasher.Hash(password)
 	if err != nil {
 		return nil, err
 	}
 	return &Password{
-		Password: hashedPassword,
+		Password: []byte(hashedPassword),
 	}, nil
 }
 
+// PasswordHasher создает хеши паролей аккаунтов и проверяет пароли по ним
+var PasswordHasher = password.NewHasher(password.DefaultHashConfig())
+
+// PasswordPolicy - требования к паролю аккаунта. При старте сервиса заменяется настроенной
+// политикой Settings.PasswordPolicy, чтобы пароли везде проверялись по одним правилам
+var PasswordPolicy = password.DefaultPolicy()
+
 func isValidPassword(password string) bool {
-	var (
-		hasMinLen  = false
-		hasUpper   = false
-		hasLower   = false
-		hasNumber  = false
-		hasSpecial = false
-	)
-	if len(password) >= 7 {
-		hasMinLen = true
-	}
-	for _, char := range password {
-		switch {
-		case unicode.IsUpper(char):
-			hasUpper = true
-		case unicode.IsLower(char):
-			hasLower = true
-		case unicode.IsNumber(char):
-			hasNumber = true
-		case unicode.IsPunct(char) || unicode.IsSymbol(char):
-			hasSpecial = true
-		}
-	}
-	return hasMinLen && hasUpper && hasLower && hasNumber && hasSpecial
+	return len(PasswordPolicy.Validate(password, "")) == 0
 }
 
 func (password *Password) UnmarshalJSON(data []byte) error {
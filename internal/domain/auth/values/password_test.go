@@ -2,18 +2,17 @@ package values
 
 import (
 	"encoding/json"
-	"golang.org/x/crypto/bcrypt"
 	"testing"
 )
 
 func TestNewPassword_ValidPassword(t *testing.T) {
-	passwordStr := "Valid1@password"
+	passwordStr := "Valid1@secret"
 	password, err := NewPassword(passwordStr)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
 	// Проверка того, что пароль хэширован
-	if err := bcrypt.CompareHashAndPassword(password.Password, []byte(passwordStr)); err != nil {
+	if err := PasswordHasher.Verify(string(password.Password), passwordStr); err != nil {
 		t.Errorf("expected hashed password to match, got %v", err)
 	}
 }
@@ -31,12 +30,11 @@ func TestIsValidPassword(t *testing.T) {
 		password string
 		valid    bool
 	}{
-		{"Valid1@", true},        // валидный пароль
-		{"short", false},         // слишком короткий
-		{"NoSpecial1", false},    // нет спецсимвола
-		{"nouppercase1@", false}, // нет заглавных букв
+		{"valid1secret", true},   // валидный пароль
+		{"short1", false},        // слишком короткий
 		{"NOLOWERCASE1@", false}, // нет строчных букв
-		{"NoNumber@", false},     // нет цифры
+		{"NoNumberHere", false},  // нет цифры
+		{"myqwerty123", false},   // запрещенное слово
 	}
 
 	for _, tt := range tests {
@@ -69,7 +67,7 @@ func TestUnmarshalJSON_InvalidPassword(t *testing.T) {
 }
 
 func TestPassword_ToString(t *testing.T) {
-	passwordStr := "Valid1@password"
+	passwordStr := "Valid1@secret"
 	password, err := NewPassword(passwordStr)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
@@ -80,12 +78,12 @@ func TestPassword_ToString(t *testing.T) {
 }
 
 func TestPassword_GetPassword(t *testing.T) {
-	passwordStr := "Valid1@password"
+	passwordStr := "Valid1@secret"
 	password, err := NewPassword(passwordStr)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
-	if err := bcrypt.CompareHashAndPassword(password.GetPassword(), []byte(passwordStr)); err != nil {
+	if err := PasswordHasher.Verify(string(password.GetPassword()), passwordStr); err != nil {
 		t.Errorf("expected hashed password to match, got %v", err)
 	}
 }
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrEmailNotVerified     = errors.New("email is not verified")
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+)
+
+// sendVerificationEmail выпускает токен подтверждения и отправляет письмо на email пользователя
+func (s *Service) sendVerificationEmail(ctx context.Context, user *User) error {
+	token, err := s.generateActionToken(user, purposeEmailVerification, s.settings.EmailVerificationTTL, nil)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := s.repo.SetVerificationSentAt(ctx, user.ID, now); err != nil {
+		return err
+	}
+	user.VerificationSentAt = now
+
+	link := s.settings.AppURL + "/verify-email?token=" + url.QueryEscape(token)
+	subject, body := verificationEmail(link, token)
+	s.sendEmail(user.Email, subject, body)
+	return nil
+}
+
+// VerifyEmail подтверждает email пользователя по токену из письма
+func (s *Service) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := s.parseActionToken(token, purposeEmailVerification)
+	if err != nil {
+		log.Printf("[VerifyEmail] Невалидный токен подтверждения: %v", err)
+		return ErrInvalidToken
+	}
+
+	userID, err := primitive.ObjectIDFromHex(claims["user_id"].(string))
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("[VerifyEmail] Пользователь не найден: %v", err)
+		return ErrInvalidToken
+	}
+
+	// Токен выпущен для конкретного адреса и после смены email недействителен
+	if email, _ := claims["email"].(string); email != user.Email {
+		log.Printf("[VerifyEmail] Email в токене не совпадает с текущим email пользователя %s", user.ID.Hex())
+		return ErrInvalidToken
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	if err := s.repo.SetEmailVerified(ctx, user.ID); err != nil {
+		return err
+	}
+	s.recordEvent(ctx, &DomainEvent{Type: EventEmailVerified, UserID: user.ID, ActorID: user.ID})
+	return nil
+}
+
+// ResendVerificationEmail повторно отправляет письмо для подтверждения email.
+// Не сообщает, существует ли пользователь: для неизвестного или уже подтвержденного
+// адреса, а также при слишком частых запросах письмо просто не отправляется
+func (s *Service) ResendVerificationEmail(ctx context.Context, email string) error {
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		log.Printf("[ResendVerificationEmail] Пользователь не найден: %v", err)
+		return nil
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	if !canResendVerification(s.settings, user, time.Now()) {
+		log.Printf("[ResendVerificationEmail] Слишком частый запрос для пользователя %s", user.ID.Hex())
+		return nil
+	}
+
+	return s.sendVerificationEmail(ctx, user)
+}
+
+// canResendVerification сообщает, прошел ли с последней отправки письма подтверждения
+// интервал, после которого письмо можно отправить повторно
+func canResendVerification(settings Settings, user *User, now time.Time) bool {
+	return now.Sub(user.VerificationSentAt) >= settings.EmailVerificationResendInterval
+}
+
+// checkEmailVerified запрещает вход с неподтвержденным email, если этого требует политика
+func (s *Service) checkEmailVerified(user *User) error {
+	if !user.EmailVerified && s.settings.UnverifiedPolicy == UnverifiedDeny {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// effectiveIsMentor возвращает признак ментора с учетом политики для неподтвержденных аккаунтов
+func (s *Service) effectiveIsMentor(user *User) bool {
+	if !user.EmailVerified && s.settings.UnverifiedPolicy == UnverifiedRestricted {
+		return false
+	}
+	return user.IsMentor
+}
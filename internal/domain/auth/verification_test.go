@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestVerificationToken(t *testing.T) {
+	s := &Service{jwtSecret: []byte("test-secret"), settings: DefaultSettings()}
+	user := &User{ID: primitive.NewObjectID(), Email: "user@example.com"}
+
+	tests := []struct {
+		name    string
+		purpose string
+		ttl     time.Duration
+		want    error
+	}{
+		{"valid", purposeEmailVerification, s.settings.EmailVerificationTTL, nil},
+		{"expired", purposeEmailVerification, -time.Minute, ErrInvalidToken},
+		{"other purpose", purposeEmailChangeRevert, s.settings.EmailVerificationTTL, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		token, err := s.generateActionToken(user, tt.purpose, tt.ttl, nil)
+		if err != nil {
+			t.Fatalf("%s: generateActionToken: %v", tt.name, err)
+		}
+		claims, err := s.parseActionToken(token, purposeEmailVerification)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: parseActionToken = %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		if err == nil && claims["email"] != user.Email {
+			t.Errorf("%s: email claim = %v, want %s", tt.name, claims["email"], user.Email)
+		}
+	}
+
+	// Access токены подписаны тем же секретом, но не подходят для подтверждения email
+	access, err := s.generateToken(user, false, time.Now(), nil)
+	if err != nil {
+		t.Fatalf("generateToken: %v", err)
+	}
+	if _, err := s.parseActionToken(access, purposeEmailVerification); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("access token: parseActionToken = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestCanResendVerification(t *testing.T) {
+	settings := DefaultSettings()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		sentAt time.Time
+		want   bool
+	}{
+		{"never sent", time.Time{}, true},
+		{"just sent", now.Add(-time.Second), false},
+		{"interval elapsed", now.Add(-settings.EmailVerificationResendInterval), true},
+		{"long ago", now.Add(-time.Hour), true},
+	}
+	for _, tt := range tests {
+		user := &User{VerificationSentAt: tt.sentAt}
+		if got := canResendVerification(settings, user, now); got != tt.want {
+			t.Errorf("%s: canResendVerification = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnverifiedPolicy(t *testing.T) {
+	tests := []struct {
+		policy     UnverifiedPolicy
+		verified   bool
+		wantErr    error
+		wantMentor bool
+	}{
+		{UnverifiedAllow, false, nil, true},
+		{UnverifiedRestricted, false, nil, false},
+		{UnverifiedRestricted, true, nil, true},
+		{UnverifiedDeny, false, ErrEmailNotVerified, true},
+		{UnverifiedDeny, true, nil, true},
+	}
+	for _, tt := range tests {
+		settings := DefaultSettings()
+		settings.UnverifiedPolicy = tt.policy
+		s := &Service{settings: settings}
+		user := &User{EmailVerified: tt.verified, IsMentor: true}
+
+		if err := s.checkEmailVerified(user); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s, verified=%v: checkEmailVerified = %v, want %v", tt.policy, tt.verified, err, tt.wantErr)
+		}
+		if got := s.effectiveIsMentor(user); got != tt.wantMentor {
+			t.Errorf("%s, verified=%v: effectiveIsMentor = %v, want %v", tt.policy, tt.verified, got, tt.wantMentor)
+		}
+	}
+}
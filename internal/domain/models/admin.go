@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+type AdminActionRequest struct {
+	Reason string `json:"reason"`
+}
+
+type LockAccountRequest struct {
+	Until  time.Time `json:"until" binding:"required"`
+	Reason string    `json:"reason"`
+}
+
+type BanAccountRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// SAMLServiceProviderRequest - регистрация SAML SP. Certificate - сертификат подписи SP в PEM
+type SAMLServiceProviderRequest struct {
+	EntityID    string               `json:"entity_id" binding:"required"`
+	Name        string               `json:"name" binding:"required"`
+	ACSURLs     []string             `json:"acs_urls" binding:"required,min=1,dive,url"`
+	SLOURL      string               `json:"slo_url" binding:"omitempty,url"`
+	Certificate string               `json:"certificate"`
+	Attributes  SAMLAttributeMapping `json:"attributes"`
+	OrgID       string               `json:"org_id"`
+}
+
+// SAMLAttributeMapping - имена атрибутов утверждения, пустые поля берутся по умолчанию
+type SAMLAttributeMapping struct {
+	Email    string `json:"email"`
+	Roles    string `json:"roles"`
+	IsMentor string `json:"is_mentor"`
+}
@@ -1,14 +1,20 @@
 package models
 
+import "time"
+
 type SignUpRequest struct {
 	Email       string `json:"email" validate:"required,email"`
-	Password    string `json:"password" validate:"required,min=8"`
+	Username    string `json:"username"`
+	Password    string `json:"password" validate:"required"`
 	DeviceToken string `json:"deviceToken" validate:"required"`
 	IsMentor    bool   `json:"isMentor"`
 }
 
+// LoginRequest принимает email или имя пользователя в поле login.
+// Поле email поддерживается для клиентов, которые еще не перешли на login
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
+	Login    string `json:"login"`
+	Email    string `json:"email"`
 	Password string `json:"password" validate:"required"`
 }
 
@@ -36,7 +42,7 @@ type VerifyTokenResponse struct {
 
 type ChangePasswordRequest struct {
 	OldPassword string `json:"oldPassword" validate:"required"`
-	NewPassword string `json:"newPassword" validate:"required,min=8"`
+	NewPassword string `json:"newPassword" validate:"required"`
 }
 
 type ChangeEmailRequest struct {
@@ -44,6 +50,42 @@ type ChangeEmailRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type ConfirmChangeEmailRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type DeleteAccountResponse struct {
+	DeletionScheduledAt time.Time `json:"deletionScheduledAt"`
+}
+
+type RevertChangeEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RefreshTokenRequest - refresh токен клиента без cookie
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// LogoutRequest - refresh токен завершаемой сессии клиента без cookie
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// IntrospectRequest - токен, который проверяет внутренний сервис
+type IntrospectRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ReportLoginRequest - токен из уведомления о входе с нового устройства
+type ReportLoginRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
 type OTPRequest struct {
 	Email string `json:"email" validate:"required,email"`
 	Code  string `json:"code" validate:"required"`
@@ -54,6 +96,100 @@ type OTPResponse struct {
 }
 
 type RestorePasswordRequest struct {
-	Email       string `json:"email" validate:"required,email"`
-	NewPassword string `json:"newPassword" validate:"required,min=8"`
-}
\ No newline at end of file
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ConfirmRestorePasswordRequest struct {
+	Email       string `json:"email" binding:"required,email"`
+	Code        string `json:"code" binding:"required,len=6,numeric"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// UnlockLoginRequest - токен из письма о блокировке входа
+type UnlockLoginRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// UpdateProfileRequest - частичное изменение профиля: отсутствующие поля не меняются, пустые строки очищают поле
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name"`
+	AvatarURL   *string `json:"avatar_url"`
+	Locale      *string `json:"locale"`
+	Timezone    *string `json:"timezone"`
+	MentorBio   *string `json:"mentor_bio"`
+}
+
+type ChangeUsernameRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+// OAuthCallbackRequest - параметры, которые провайдер передал на redirect URL фронтенда
+type OAuthCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type InviteMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=admin mentor member"`
+}
+
+// InvitationTokenRequest - токен из письма с приглашением в организацию
+type InvitationTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// TransferOwnershipRequest - новый владелец организации из числа ее участников
+type TransferOwnershipRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+// SwitchOrganizationRequest - пустой org_id возвращает в личный контекст
+type SwitchOrganizationRequest struct {
+	OrgID string `json:"org_id"`
+}
+
+// OrgSSORequest - настройки корпоративного OIDC провайдера. Пустой client_secret оставляет сохраненный
+type OrgSSORequest struct {
+	Issuer       string            `json:"issuer" binding:"required,url"`
+	ClientID     string            `json:"client_id" binding:"required"`
+	ClientSecret string            `json:"client_secret"`
+	Claims       SSOClaimMapping   `json:"claims"`
+	RoleMapping  map[string]string `json:"role_mapping"`
+	DefaultRole  string            `json:"default_role"`
+	EnforceSSO   bool              `json:"enforce_sso"`
+}
+
+// SSOClaimMapping - имена claims провайдера, пустые поля берутся по умолчанию
+type SSOClaimMapping struct {
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified string `json:"email_verified"`
+	Name          string `json:"name"`
+	Groups        string `json:"groups"`
+}
+
+type AddOrgDomainRequest struct {
+	Domain string `json:"domain" binding:"required"`
+}
+
+// SAMLIdPInitiatedRequest - вход в SP по инициативе IdP, relay_state передается SP без изменений
+type SAMLIdPInitiatedRequest struct {
+	RelayState string `json:"relay_state"`
+}
+
+// CreateSCIMTokenRequest - название токена SCIM, например имя каталога
+type CreateSCIMTokenRequest struct {
+	Name string `json:"name" binding:"required"`
+}
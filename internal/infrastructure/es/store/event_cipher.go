@@ -0,0 +1,61 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// eventMetadata - служебные метаданные события в eventStoreDB
+type eventMetadata struct {
+	Encrypted bool `json:"encrypted"`
+}
+
+// encryptEventData шифрует данные события ключом агрегата (AES-GCM, nonce в начале результата)
+func encryptEventData(key []byte, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// decryptEventData расшифровывает данные события ключом агрегата
+func decryptEventData(key []byte, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted event data is too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// isEncrypted проверяет по метаданным, зашифрованы ли данные события
+func isEncrypted(metadata []byte) bool {
+	if len(metadata) == 0 {
+		return false
+	}
+	var meta eventMetadata
+	if err := json.Unmarshal(metadata, &meta); err != nil {
+		return false
+	}
+	return meta.Encrypted
+}
@@ -2,11 +2,14 @@ package store
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"kubercode-sso/config"
+	"kubercode-sso/internal/domain/auth/events"
 	"kubercode-sso/internal/domain/auth/projections"
+	"kubercode-sso/internal/domain/auth/repository"
 	"kubercode-sso/internal/infrastructure/es"
 	"log/slog"
 
@@ -24,15 +27,19 @@ type EventStore interface {
 }
 
 // eventStoreDB представляет собой реализацию EventStore для eventStoreDB.
+// Данные событий шифруются ключом агрегата, поэтому после уничтожения ключа
+// персональные данные в истории событий становятся невосстановимыми.
 type eventStoreDB struct {
 	cfg       *config.Config
 	log       *slog.Logger
 	Client    *esdb.Client
 	processor projections.ProjectionProcessor
+	keys      repository.AggregateKeyRepository
 }
 
 // NewEventStore создает новый экземпляр eventStoreDB.
-func NewEventStore(cfg *config.Config, log *slog.Logger, processor projections.ProjectionProcessor) (*eventStoreDB, error) {
+func NewEventStore(cfg *config.Config, log *slog.Logger, processor projections.ProjectionProcessor,
+	keys repository.AggregateKeyRepository) (*eventStoreDB, error) {
 	settings, err := esdb.ParseConnectionString(cfg.EventStoreConnectionString)
 	if err != nil {
 		panic(err)
@@ -46,6 +53,7 @@ func NewEventStore(cfg *config.Config, log *slog.Logger, processor projections.P
 		log:       log,
 		Client:    db,
 		processor: processor,
+		keys:      keys,
 	}, nil
 }
 
@@ -55,7 +63,11 @@ func (esd *eventStoreDB) SaveEvents(ctx context.Context, aggregateId uuid.UUID,
 	esd.log.Info("saving events", slog.String("streamName", streamName))
 	var eventData []esdb.EventData
 	for _, event := range events {
-		eventData = append(eventData, esd.EventDataFromEvent(event))
+		encrypted, err := esd.encryptEvent(ctx, aggregateId, event)
+		if err != nil {
+			return err
+		}
+		eventData = append(eventData, esd.EventDataFromEvent(encrypted))
 	}
 	esd.log.Info("eventData", eventData)
 	_, err := esd.Client.AppendToStream(ctx, streamName, esdb.AppendToStreamOptions{}, eventData...)
@@ -83,6 +95,10 @@ func (esd *eventStoreDB) LoadEvents(ctx context.Context, aggregateId uuid.UUID)
 			break
 		}
 		myEvent, err := esd.EventFromData(event.OriginalEvent())
+		if errors.Is(err, es.ErrPersonalDataErased) {
+			// События удаленного аккаунта пропускаются, в потоке остается только AccountErased
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -126,6 +142,10 @@ func (esd *eventStoreDB) SubscribeToStream(ctx context.Context) error {
 				sub.Ack(event.EventAppeared.Event)
 				esd.log.Info("MY EVENTS LMAO QEQOQEQ", slog.Any("aaa", event.EventAppeared.Event.Event))
 				myEvent, err := esd.EventFromData(event.EventAppeared.Event.Event)
+				if errors.Is(err, es.ErrPersonalDataErased) {
+					esd.log.Info("Skipping event of erased account", slog.String("eventId", myEvent.EventID.String()))
+					continue
+				}
 				if err != nil {
 					esd.log.Error("Failed to parse event", "err", err)
 					close(eventChannel)
@@ -151,6 +171,7 @@ func (esd *eventStoreDB) EventDataFromEvent(event es.Event) esdb.EventData {
 		EventType: string(event.EventType),
 		Data:      event.Data,
 		EventID:   event.EventID,
+		Metadata:  event.Metadata,
 	}
 }
 
@@ -161,5 +182,47 @@ func (esd *eventStoreDB) EventFromData(recordedEvent *esdb.RecordedEvent) (es.Ev
 	if err != nil {
 		return event, err
 	}
+	if !isEncrypted(event.Metadata) {
+		return event, nil
+	}
+
+	key, err := esd.keys.GetKey(context.Background(), event.AggregateID)
+	if errors.Is(err, repository.ErrAggregateKeyNotFound) {
+		event.Data = nil
+		return event, es.ErrPersonalDataErased
+	}
+	if err != nil {
+		return event, err
+	}
+	data, err := decryptEventData(key, event.Data)
+	if err != nil {
+		return event, fmt.Errorf("failed to decrypt event %s: %w", event.EventID, err)
+	}
+	event.Data = data
+	return event, nil
+}
+
+// encryptEvent шифрует данные события ключом агрегата. Событие удаления аккаунта
+// не содержит персональных данных и сохраняется открыто, чтобы факт удаления оставался в истории.
+func (esd *eventStoreDB) encryptEvent(ctx context.Context, aggregateId uuid.UUID, event es.Event) (es.Event, error) {
+	if event.EventType == events.AccountErased {
+		return event, nil
+	}
+
+	key, err := esd.keys.GetOrCreateKey(ctx, aggregateId)
+	if err != nil {
+		return es.Event{}, err
+	}
+	data, err := encryptEventData(key, event.Data)
+	if err != nil {
+		return es.Event{}, err
+	}
+	metadata, err := json.Marshal(eventMetadata{Encrypted: true})
+	if err != nil {
+		return es.Event{}, err
+	}
+
+	event.Data = data
+	event.Metadata = metadata
 	return event, nil
 }
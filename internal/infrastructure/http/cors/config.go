@@ -0,0 +1,167 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrInvalidOrigin = errors.New("invalid cors origin")
+
+// Origin - источник, которому разрешены запросы из браузера
+type Origin struct {
+	// Pattern - источник вида "https://app.kubercode.ru", "https://*.kubercode.ru" для любого
+	// поддомена или "*" для любого источника
+	Pattern string
+	// Credentials - разрешить запросы с cookie и заголовком Authorization
+	// (Access-Control-Allow-Credentials). Для "*" недоступно
+	Credentials bool
+	// Methods и Headers - ограничения для этого источника. Пустые - действуют общие из Config
+	Methods []string
+	Headers []string
+}
+
+// Config - настройки CORS
+type Config struct {
+	Enabled bool
+	Origins []Origin
+	// Methods - методы, разрешенные по умолчанию
+	Methods []string
+	// Headers - заголовки запроса, разрешенные по умолчанию
+	Headers []string
+	// ExposedHeaders - заголовки ответа, доступные JavaScript
+	ExposedHeaders []string
+	// MaxAge - сколько браузер кеширует ответ на preflight запрос
+	MaxAge time.Duration
+}
+
+// DefaultConfig возвращает настройки по умолчанию, CORS выключен и ни один источник не разрешен
+func DefaultConfig() Config {
+	return Config{
+		Methods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		Headers: []string{"Authorization", "Content-Type", "X-Device-ID", "X-Session-Mode", "X-CSRF-Token"},
+		ExposedHeaders: []string{"X-CSRF-Token", "Retry-After",
+			"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
+		MaxAge: 10 * time.Minute,
+	}
+}
+
+// ParseOrigins разбирает источники в формате
+// "https://app.kubercode.ru credentials, https://*.partner.io methods=GET|POST headers=Authorization".
+// После источника через пробел перечисляются его параметры
+func ParseOrigins(spec string) ([]Origin, error) {
+	var origins []Origin
+	for _, item := range strings.Split(spec, ",") {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+
+		origin := Origin{Pattern: strings.ToLower(strings.TrimSuffix(fields[0], "/"))}
+		if err := validatePattern(origin.Pattern); err != nil {
+			return nil, err
+		}
+		for _, option := range fields[1:] {
+			name, value, _ := strings.Cut(option, "=")
+			switch strings.ToLower(name) {
+			case "credentials":
+				origin.Credentials = true
+			case "methods":
+				origin.Methods = splitList(strings.ToUpper(value))
+			case "headers":
+				origin.Headers = splitList(value)
+			default:
+				return nil, fmt.Errorf("%w: unknown option %q for %s", ErrInvalidOrigin, option, origin.Pattern)
+			}
+		}
+		// Браузер не принимает Allow-Credentials вместе с любым источником
+		if origin.Pattern == "*" && origin.Credentials {
+			return nil, fmt.Errorf("%w: credentials are not allowed for \"*\"", ErrInvalidOrigin)
+		}
+		origins = append(origins, origin)
+	}
+	return origins, nil
+}
+
+func validatePattern(pattern string) error {
+	if pattern == "*" {
+		return nil
+	}
+	u, err := url.Parse(strings.Replace(pattern, "*.", "wildcard.", 1))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+		return fmt.Errorf("%w: %q", ErrInvalidOrigin, pattern)
+	}
+	if strings.Contains(pattern, "*") && !strings.HasPrefix(pattern, u.Scheme+"://*.") {
+		return fmt.Errorf("%w: wildcard is allowed only for subdomains: %q", ErrInvalidOrigin, pattern)
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, "|") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Match возвращает настройки источника запроса. Первое подходящее правило побеждает
+func (c Config) Match(origin string) (Origin, bool) {
+	origin = strings.ToLower(origin)
+	for _, o := range c.Origins {
+		if matchPattern(o.Pattern, origin) {
+			return o, true
+		}
+	}
+	return Origin{}, false
+}
+
+func matchPattern(pattern, origin string) bool {
+	if pattern == "*" || pattern == origin {
+		return true
+	}
+	scheme, rest, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return false
+	}
+	// "https://*.kubercode.ru" подходит для "https://app.kubercode.ru", но не для "https://kubercode.ru"
+	return strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+rest) &&
+		len(origin) > len(scheme+"://."+rest)
+}
+
+// AllowedMethods возвращает методы, разрешенные источнику
+func (c Config) AllowedMethods(o Origin) []string {
+	if len(o.Methods) > 0 {
+		return o.Methods
+	}
+	return c.Methods
+}
+
+// AllowedHeaders возвращает заголовки запроса, разрешенные источнику
+func (c Config) AllowedHeaders(o Origin) []string {
+	if len(o.Headers) > 0 {
+		return o.Headers
+	}
+	return c.Headers
+}
+
+// Allows сообщает, что все элементы requested есть в allowed без учета регистра
+func Allows(allowed []string, requested ...string) bool {
+	for _, r := range requested {
+		found := false
+		for _, a := range allowed {
+			if strings.EqualFold(a, r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package cors
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseOrigins(t *testing.T) {
+	origins, err := ParseOrigins("https://App.kubercode.ru/ credentials, https://*.partner.io methods=get|post headers=Authorization, *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Origin{
+		{Pattern: "https://app.kubercode.ru", Credentials: true},
+		{Pattern: "https://*.partner.io", Methods: []string{"GET", "POST"}, Headers: []string{"Authorization"}},
+		{Pattern: "*"},
+	}
+	if !reflect.DeepEqual(origins, want) {
+		t.Errorf("ParseOrigins = %+v, want %+v", origins, want)
+	}
+
+	for _, spec := range []string{
+		"app.kubercode.ru",
+		"ftp://app.kubercode.ru",
+		"https://app.kubercode.ru/path",
+		"https://app.*.kubercode.ru",
+		"https://app.kubercode.ru cookies",
+		"* credentials",
+	} {
+		if _, err := ParseOrigins(spec); !errors.Is(err, ErrInvalidOrigin) {
+			t.Errorf("ParseOrigins(%q) error = %v, want ErrInvalidOrigin", spec, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Origins = []Origin{
+		{Pattern: "https://app.kubercode.ru", Credentials: true},
+		{Pattern: "https://*.kubercode.ru"},
+	}
+
+	tests := []struct {
+		origin      string
+		ok          bool
+		credentials bool
+	}{
+		{"https://app.kubercode.ru", true, true},
+		{"https://APP.kubercode.ru", true, true},
+		{"https://admin.kubercode.ru", true, false},
+		{"https://kubercode.ru", false, false},
+		{"http://admin.kubercode.ru", false, false},
+		{"https://evilkubercode.ru", false, false},
+		{"https://kubercode.ru.evil.example", false, false},
+	}
+	for _, tt := range tests {
+		o, ok := cfg.Match(tt.origin)
+		if ok != tt.ok || o.Credentials != tt.credentials {
+			t.Errorf("Match(%q) = %+v, %v; want ok %v, credentials %v", tt.origin, o, ok, tt.ok, tt.credentials)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"kubercode/internal/domain/auth"
+	"kubercode/internal/domain/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	service *auth.Service
+}
+
+func NewAdminHandler(service *auth.Service) *AdminHandler {
+	return &AdminHandler{service: service}
+}
+
+// @Summary     Удаление аккаунта администратором
+// @Description Немедленно и безвозвратно удаляет аккаунт и все связанные с ним данные
+// @Tags        admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID пользователя"
+// @Success     200 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Router      /admin/accounts/{id} [delete]
+func (h *AdminHandler) EraseAccount(c *gin.Context) {
+	adminID := c.GetString("userID")
+	userID := c.Param("id")
+	log.Printf("[Admin.EraseAccount] Администратор %s удаляет аккаунт %s", adminID, userID)
+
+	if err := h.service.EraseAccount(c.Request.Context(), adminID, userID); err != nil {
+		log.Printf("[Admin.EraseAccount] Ошибка удаления аккаунта: %v", err)
+		switch {
+		case errors.Is(err, auth.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case errors.Is(err, auth.ErrOrganizationOwner):
+			c.JSON(http.StatusConflict, gin.H{"error": "Account owns organizations, ownership must be transferred first"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account erased"})
+}
+
+// @Summary     Отключение аккаунта
+// @Description Отключает аккаунт до повторного включения и отзывает все его токены
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID пользователя"
+// @Param       request body models.AdminActionRequest false "Причина"
+// @Success     200 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /admin/accounts/{id}/disable [post]
+func (h *AdminHandler) DisableAccount(c *gin.Context) {
+	var req models.AdminActionRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	err := h.service.DisableAccount(c.Request.Context(), c.GetString("userID"), c.Param("id"), req.Reason)
+	h.respond(c, "DisableAccount", err, "Account disabled")
+}
+
+// @Summary     Включение аккаунта
+// @Description Снимает отключение аккаунта
+// @Tags        admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID пользователя"
+// @Success     200 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /admin/accounts/{id}/enable [post]
+func (h *AdminHandler) EnableAccount(c *gin.Context) {
+	err := h.service.EnableAccount(c.Request.Context(), c.GetString("userID"), c.Param("id"))
+	h.respond(c, "EnableAccount", err, "Account enabled")
+}
+
+// @Summary     Временная блокировка аккаунта
+// @Description Запрещает вход в аккаунт до указанного момента и отзывает все его токены
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID пользователя"
+// @Param       request body models.LockAccountRequest true "Срок блокировки и причина"
+// @Success     200 {object} SuccessResponse
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /admin/accounts/{id}/lock [post]
+// @Example     request - {"until": "2025-01-01T00:00:00Z", "reason": "Подозрительная активность"}
+func (h *AdminHandler) LockAccount(c *gin.Context) {
+	var req models.LockAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[Admin.LockAccount] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	err := h.service.LockAccount(c.Request.Context(), c.GetString("userID"), c.Param("id"), req.Until, req.Reason)
+	h.respond(c, "LockAccount", err, "Account locked")
+}
+
+// @Summary     Снятие временной блокировки
+// @Description Снимает временную блокировку аккаунта
+// @Tags        admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID пользователя"
+// @Success     200 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /admin/accounts/{id}/unlock [post]
+func (h *AdminHandler) UnlockAccount(c *gin.Context) {
+	err := h.service.UnlockAccount(c.Request.Context(), c.GetString("userID"), c.Param("id"))
+	h.respond(c, "UnlockAccount", err, "Account unlocked")
+}
+
+// @Summary     Бан аккаунта
+// @Description Бессрочно блокирует аккаунт с указанием причины и отзывает все его токены
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID пользователя"
+// @Param       request body models.BanAccountRequest true "Причина"
+// @Success     200 {object} SuccessResponse
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /admin/accounts/{id}/ban [post]
+// @Example     request - {"reason": "Нарушение правил сообщества"}
+func (h *AdminHandler) BanAccount(c *gin.Context) {
+	var req models.BanAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[Admin.BanAccount] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	err := h.service.BanAccount(c.Request.Context(), c.GetString("userID"), c.Param("id"), req.Reason)
+	h.respond(c, "BanAccount", err, "Account banned")
+}
+
+// @Summary     Снятие бана
+// @Description Снимает бессрочную блокировку аккаунта
+// @Tags        admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID пользователя"
+// @Success     200 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /admin/accounts/{id}/unban [post]
+func (h *AdminHandler) UnbanAccount(c *gin.Context) {
+	err := h.service.UnbanAccount(c.Request.Context(), c.GetString("userID"), c.Param("id"))
+	h.respond(c, "UnbanAccount", err, "Account unbanned")
+}
+
+// @Summary     Принудительная смена пароля
+// @Description Отзывает все токены аккаунта и требует восстановить пароль перед следующим входом
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID пользователя"
+// @Param       request body models.AdminActionRequest false "Причина"
+// @Success     200 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /admin/accounts/{id}/force-password-reset [post]
+func (h *AdminHandler) ForcePasswordReset(c *gin.Context) {
+	var req models.AdminActionRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	err := h.service.ForcePasswordReset(c.Request.Context(), c.GetString("userID"), c.Param("id"), req.Reason)
+	h.respond(c, "ForcePasswordReset", err, "Password reset required at next login")
+}
+
+// @Summary     Метрики хеширования паролей
+// @Description Возвращает загрузку пула хеширования паролей: глубину очереди, отказы и время хеширования
+// @Tags        admin
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {object} password.PoolStats
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Router      /admin/metrics/password-hashing [get]
+func (h *AdminHandler) GetHashingMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.HashingStats())
+}
+
+// respond отправляет результат действия администратора над аккаунтом
+func (h *AdminHandler) respond(c *gin.Context, action string, err error, message string) {
+	if err != nil {
+		log.Printf("[Admin.%s] Ошибка выполнения действия над аккаунтом %s: %v", action, c.Param("id"), err)
+		switch {
+		case errors.Is(err, auth.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case errors.Is(err, auth.ErrInvalidLockTime):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Lock time must be in the future"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	log.Printf("[Admin.%s] Администратор %s выполнил действие над аккаунтом %s", action, c.GetString("userID"), c.Param("id"))
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// bindOptionalJSON разбирает тело запроса, если оно передано
+func bindOptionalJSON(c *gin.Context, req interface{}) bool {
+	if c.Request.ContentLength == 0 {
+		return true
+	}
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Printf("[bindOptionalJSON] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return false
+	}
+	return true
+}
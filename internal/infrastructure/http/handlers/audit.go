@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kubercode/internal/domain/auth/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary     Журнал аудита
+// @Description Возвращает записи журнала аудита, начиная с последних. Для следующей страницы next_cursor передается в before
+// @Tags        admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       actor_id  query string false "Кто выполнил действие"
+// @Param       target_id query string false "Над чьим аккаунтом выполнено действие"
+// @Param       action    query string false "Действие, например Login или AccountBanned"
+// @Param       outcome   query string false "Результат: success, failure или denied"
+// @Param       from      query string false "Начало периода, RFC 3339"
+// @Param       to        query string false "Конец периода, RFC 3339"
+// @Param       before    query int    false "Номер записи, с которой продолжить"
+// @Param       limit     query int    false "Размер страницы, до 500"
+// @Success     200 {object} auth.AuditPage
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Router      /admin/audit [get]
+func (h *AdminHandler) GetAuditLog(c *gin.Context) {
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+	before, err := queryInt(c, "before")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.service.AuditLog(c.Request.Context(), filter, int64(before), limit)
+	if err != nil {
+		log.Printf("[Admin.GetAuditLog] Ошибка чтения журнала аудита: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// @Summary     Выгрузка журнала аудита
+// @Description Выгружает записи журнала аудита в формате JSON Lines по возрастанию номеров. Поддерживает те же фильтры, что и просмотр журнала
+// @Tags        admin
+// @Produce     application/x-ndjson
+// @Security    BearerAuth
+// @Param       actor_id  query string false "Кто выполнил действие"
+// @Param       target_id query string false "Над чьим аккаунтом выполнено действие"
+// @Param       action    query string false "Действие"
+// @Param       outcome   query string false "Результат: success, failure или denied"
+// @Param       from      query string false "Начало периода, RFC 3339"
+// @Param       to        query string false "Конец периода, RFC 3339"
+// @Success     200 {file} file
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Router      /admin/audit/export [get]
+func (h *AdminHandler) ExportAuditLog(c *gin.Context) {
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+	log.Printf("[Admin.ExportAuditLog] Администратор %s выгружает журнал аудита", c.GetString("userID"))
+
+	filename := fmt.Sprintf("audit-%s.jsonl", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	// Заголовки уже отправлены, поэтому ошибка посреди выгрузки только обрывает ответ
+	if err := h.service.ExportAuditLog(c.Request.Context(), filter, c.Writer); err != nil {
+		log.Printf("[Admin.ExportAuditLog] Ошибка выгрузки журнала аудита: %v", err)
+	}
+}
+
+// @Summary     Проверка журнала аудита
+// @Description Проверяет хеш-цепочку журнала аудита и возвращает номер первой удаленной или измененной записи
+// @Tags        admin
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {object} auth.AuditVerification
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Router      /admin/audit/verify [get]
+func (h *AdminHandler) VerifyAuditLog(c *gin.Context) {
+	result, err := h.service.VerifyAuditLog(c.Request.Context())
+	if err != nil {
+		log.Printf("[Admin.VerifyAuditLog] Ошибка проверки журнала аудита: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// auditFilter читает фильтры журнала аудита из параметров запроса
+func auditFilter(c *gin.Context) (audit.Filter, bool) {
+	filter := audit.Filter{
+		ActorID:  c.Query("actor_id"),
+		TargetID: c.Query("target_id"),
+		Action:   c.Query("action"),
+		Outcome:  audit.Outcome(c.Query("outcome")),
+	}
+	switch filter.Outcome {
+	case "", audit.OutcomeSuccess, audit.OutcomeFailure, audit.OutcomeDenied:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid outcome"})
+		return filter, false
+	}
+	for name, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + ", expected RFC 3339 time"})
+			return filter, false
+		}
+		*target = t
+	}
+	return filter, true
+}
+
+// queryInt читает неотрицательный целый параметр запроса, 0 - параметр не задан
+func queryInt(c *gin.Context, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return n, nil
+}
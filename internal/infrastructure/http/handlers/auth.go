@@ -4,16 +4,21 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"kubercode/internal/domain/auth"
+	"kubercode/internal/domain/auth/password"
 	"kubercode/internal/domain/models"
+	"kubercode/internal/infrastructure/http/session"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type AuthHandler struct {
-	service *auth.Service
+	service  *auth.Service
+	sessions *session.Cookies
 }
 
 func NewAuthHandler(service *auth.Service) *AuthHandler {
@@ -27,10 +32,11 @@ func NewAuthHandler(service *auth.Service) *AuthHandler {
 // @Produce     json
 // @Param       request body models.SignUpRequest true "Данные для регистрации"
 // @Success     200 {object} models.TokenResponse
-// @Failure     400 {object} ErrorResponse
+// @Failure     400 {object} PasswordPolicyErrorResponse
 // @Failure     409 {object} ErrorResponse
+// @Failure     503 {object} ErrorResponse
 // @Router      /auth/signup [post]
-// @Example     request - {"email": "test@example.com", "password": "password123", "deviceToken": "device123", "isMentor": false}
+// @Example     request - {"email": "test@example.com", "password": "correct horse 42", "deviceToken": "device123", "isMentor": false}
 func (h *AuthHandler) SignUp(c *gin.Context) {
 	
 	var req models.SignUpRequest
@@ -43,6 +49,7 @@ func (h *AuthHandler) SignUp(c *gin.Context) {
 	// Конвертируем models.SignUpRequest в auth.SignUpRequest
 	authReq := &auth.SignUpRequest{
 		Email:       req.Email,
+		Username:    req.Username,
 		Password:    req.Password,
 		DeviceToken: req.DeviceToken,
 		IsMentor:    req.IsMentor,
@@ -50,9 +57,19 @@ func (h *AuthHandler) SignUp(c *gin.Context) {
 
 	_, err := h.service.SignUp(c.Request.Context(), authReq)
 	if err != nil {
+		var policyErr *auth.PasswordPolicyError
+		var busy *password.BusyError
 		switch {
+		case errors.As(err, &policyErr):
+			respondPasswordPolicy(c, policyErr)
+		case errors.As(err, &busy):
+			respondBusy(c, busy)
 		case errors.Is(err, auth.ErrUserAlreadyExists):
 			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+		case errors.Is(err, auth.ErrUsernameTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "Username is already taken"})
+		case errors.Is(err, auth.ErrUsernameInvalid), errors.Is(err, auth.ErrUsernameReserved):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		default:
 			log.Printf("[SignUp] Внутренняя ошибка сервера: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
@@ -72,6 +89,8 @@ func (h *AuthHandler) SignUp(c *gin.Context) {
 // @Success     200 {object} models.TokenResponse
 // @Failure     400 {object} ErrorResponse
 // @Failure     401 {object} ErrorResponse
+// @Failure     429 {object} ErrorResponse
+// @Failure     503 {object} ErrorResponse
 // @Router      /auth/login [post]
 // @Example     request - {"email": "test@example.com", "password": "password123", "deviceToken": "device123"}
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -83,23 +102,58 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.service.Login(c.Request.Context(), req.Email, req.Password)
+	login := req.Login
+	if login == "" {
+		login = req.Email
+	}
+	if login == "" || req.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login and password are required"})
+		return
+	}
+
+	resp, err := h.service.Login(c.Request.Context(), login, req.Password)
 	if err != nil {
 		log.Printf("[Login] Ошибка входа: %v", err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		var throttled *auth.LoginThrottledError
+		var busy *password.BusyError
+		switch {
+		case errors.As(err, &busy):
+			respondBusy(c, busy)
+		case errors.As(err, &throttled):
+			c.Header("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts",
+				"retry_after": int(throttled.RetryAfter.Seconds())})
+		case errors.Is(err, auth.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email is not verified"})
+		case errors.Is(err, auth.ErrAccountDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+		case errors.Is(err, auth.ErrAccountLocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is locked"})
+		case errors.Is(err, auth.ErrAccountBanned):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is banned"})
+		case errors.Is(err, auth.ErrPasswordResetRequired):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Password reset required"})
+		case errors.Is(err, auth.ErrSSORequired):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Single sign-on required", "sso_required": true})
+		default:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	respondTokens(c, h.sessions, "Login", resp)
 }
 
 // @Summary     Выход из системы
-// @Description Выходит пользователя из системы и инвалидирует токены
+// @Description Выходит пользователя из системы и инвалидирует токены. Refresh токен сессии берется
+// @Description из cookie или из тела запроса, cookie сессии очищается
 // @Tags        auth
 // @Accept      json
 // @Produce     json
 // @Security    BearerAuth
+// @Param       request body models.LogoutRequest false "Refresh токен"
 // @Success     200 {object} SuccessResponse
+// @Failure     400 {object} ErrorResponse
 // @Failure     401 {object} ErrorResponse
 // @Router      /auth/logout [post]
 func (h *AuthHandler) Logout(c *gin.Context) {
@@ -112,32 +166,61 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.service.Logout(c.Request.Context(), userID)
+	var req models.LogoutRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+	refreshToken := h.sessions.RefreshToken(c)
+	if refreshToken == "" {
+		refreshToken = req.RefreshToken
+	}
+
+	resp, err := h.service.Logout(c.Request.Context(), userID, refreshToken)
 	if err != nil {
 		log.Printf("[Logout] Ошибка выхода: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		switch {
+		case errors.Is(err, auth.ErrRefreshTokenRequired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token is required"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
 		return
 	}
 
+	h.sessions.Clear(c)
 	log.Printf("[Logout] Успешный выход пользователя: %s", userID)
 	c.JSON(http.StatusOK, resp)
 }
 
 // @Summary     Обновление токена
-// @Description Обновляет access token используя refresh token
+// @Description Обновляет access token используя refresh token. Браузерный клиент в режиме cookie
+// @Description передает refresh токен в cookie и CSRF токен в заголовке X-CSRF-Token, остальные -
+// @Description в теле запроса или в заголовке Authorization
 // @Tags        auth
 // @Accept      json
 // @Produce     json
-// @Security    BearerAuth
+// @Param       request body models.RefreshTokenRequest false "Refresh токен"
 // @Success     200 {object} models.TokenResponse
 // @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
 // @Router      /auth/refresh [post]
+// @Example     request - {"refreshToken": "<refresh_token>"}
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	log.Printf("[RefreshToken] Получен запрос на обновление токена от %s", c.ClientIP())
-	
-	token := c.GetHeader("Authorization")
+
+	var req models.RefreshTokenRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+	token := h.sessions.RefreshToken(c)
+	if token == "" {
+		token = req.RefreshToken
+	}
+	if token == "" {
+		token = bearerToken(c)
+	}
 	if token == "" {
-		log.Printf("[RefreshToken] Токен отсутствует в заголовке")
+		log.Printf("[RefreshToken] Токен отсутствует в запросе")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is required"})
 		return
 	}
@@ -161,10 +244,11 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 // @Security    BearerAuth
 // @Param       request body models.ChangePasswordRequest true "Данные для смены пароля"
 // @Success     200 {object} SuccessResponse
-// @Failure     400 {object} ErrorResponse
+// @Failure     400 {object} PasswordPolicyErrorResponse
 // @Failure     401 {object} ErrorResponse
+// @Failure     503 {object} ErrorResponse
 // @Router      /auth/change-password [post]
-// @Example     request - {"oldPassword": "oldpass123", "newPassword": "newpass123"}
+// @Example     request - {"oldPassword": "correct horse 42", "newPassword": "battery staple 43"}
 func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	log.Printf("[ChangePassword] Получен запрос на смену пароля от %s", c.ClientIP())
 	
@@ -178,7 +262,18 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	userID := c.GetString("userID")
 	if err := h.service.ChangePassword(c.Request.Context(), userID, req.OldPassword, req.NewPassword); err != nil {
 		log.Printf("[ChangePassword] Ошибка смены пароля: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		var policyErr *auth.PasswordPolicyError
+		var busy *password.BusyError
+		switch {
+		case errors.As(err, &policyErr):
+			respondPasswordPolicy(c, policyErr)
+		case errors.As(err, &busy):
+			respondBusy(c, busy)
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid old password"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
@@ -187,15 +282,17 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 }
 
 // @Summary     Изменение email
-// @Description Изменяет email пользователя
+// @Description Отправляет код подтверждения на новый email. Email меняется только после подтверждения кода
 // @Tags        auth
 // @Accept      json
 // @Produce     json
 // @Security    BearerAuth
 // @Param       request body models.ChangeEmailRequest true "Данные для смены email"
-// @Success     200 {object} SuccessResponse
+// @Success     202 {object} SuccessResponse
 // @Failure     400 {object} ErrorResponse
 // @Failure     401 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Failure     503 {object} ErrorResponse
 // @Router      /auth/change-email [post]
 // @Example     request - {"newEmail": "new@example.com", "password": "password123"}
 func (h *AuthHandler) ChangeEmail(c *gin.Context) {
@@ -209,14 +306,138 @@ func (h *AuthHandler) ChangeEmail(c *gin.Context) {
 	}
 
 	userID := c.GetString("userID")
-	if err := h.service.ChangeEmail(c.Request.Context(), userID, req.NewEmail, req.Password); err != nil {
+	if err := h.service.RequestEmailChange(c.Request.Context(), userID, req.NewEmail, req.Password); err != nil {
 		log.Printf("[ChangeEmail] Ошибка смены email: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		var busy *password.BusyError
+		switch {
+		case errors.As(err, &busy):
+			respondBusy(c, busy)
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		case errors.Is(err, auth.ErrUserAlreadyExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is already in use"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
 		return
 	}
 
-	log.Printf("[ChangeEmail] Email успешно изменен")
-	c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully"})
+	log.Printf("[ChangeEmail] Код подтверждения отправлен на новый email")
+	c.JSON(http.StatusAccepted, gin.H{"message": "Confirmation code sent to the new email"})
+}
+
+// @Summary     Подтверждение смены email
+// @Description Подтверждает смену email кодом, отправленным на новый адрес, и возвращает новые токены
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body models.ConfirmChangeEmailRequest true "Код подтверждения"
+// @Success     200 {object} models.TokenResponse
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Router      /auth/change-email/confirm [post]
+// @Example     request - {"code": "123456"}
+func (h *AuthHandler) ConfirmChangeEmail(c *gin.Context) {
+	log.Printf("[ConfirmChangeEmail] Получен запрос на подтверждение смены email от %s", c.ClientIP())
+
+	var req models.ConfirmChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[ConfirmChangeEmail] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetString("userID")
+	resp, err := h.service.ConfirmEmailChange(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		log.Printf("[ConfirmChangeEmail] Ошибка подтверждения смены email: %v", err)
+		switch {
+		case errors.Is(err, auth.ErrInvalidCode):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired code"})
+		case errors.Is(err, auth.ErrUserAlreadyExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is already in use"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	log.Printf("[ConfirmChangeEmail] Email успешно изменен")
+	respondTokens(c, h.sessions, "ConfirmChangeEmail", resp)
+}
+
+// @Summary     Отмена смены email
+// @Description Возвращает прежний email по ссылке из уведомления и завершает все сессии пользователя
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       request body models.RevertChangeEmailRequest true "Токен из письма"
+// @Success     200 {object} SuccessResponse
+// @Failure     400 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Router      /auth/change-email/revert [post]
+// @Example     request - {"token": "eyJhbGciOiJIUzI1NiIs..."}
+func (h *AuthHandler) RevertChangeEmail(c *gin.Context) {
+	log.Printf("[RevertChangeEmail] Получен запрос на отмену смены email от %s", c.ClientIP())
+
+	var req models.RevertChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[RevertChangeEmail] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.service.RevertEmailChange(c.Request.Context(), req.Token); err != nil {
+		log.Printf("[RevertChangeEmail] Ошибка отмены смены email: %v", err)
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		case errors.Is(err, auth.ErrUserAlreadyExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Previous email is already in use"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	log.Printf("[RevertChangeEmail] Смена email отменена")
+	c.JSON(http.StatusOK, gin.H{"message": "Email change reverted, please restore your password"})
+}
+
+// @Summary     Сообщение о чужом входе
+// @Description Обрабатывает ссылку "это был не я" из уведомления о входе с нового устройства: завершает сессию этого входа и все остальные сессии и требует восстановить пароль
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       request body models.ReportLoginRequest true "Токен из уведомления"
+// @Success     200 {object} SuccessResponse
+// @Failure     400 {object} ErrorResponse
+// @Router      /auth/login/report [post]
+// @Example     request - {"token": "eyJhbGciOiJIUzI1NiIs..."}
+func (h *AuthHandler) ReportLogin(c *gin.Context) {
+	log.Printf("[ReportLogin] Получено сообщение о чужом входе от %s", c.ClientIP())
+
+	var req models.ReportLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[ReportLogin] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.service.ReportSuspiciousLogin(c.Request.Context(), req.Token); err != nil {
+		log.Printf("[ReportLogin] Ошибка обработки сообщения о чужом входе: %v", err)
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked, please restore your password"})
 }
 
 // @Summary     Отправка OTP
@@ -278,16 +499,16 @@ func (h *AuthHandler) VerifyOTP(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// @Summary     Восстановление пароля
-// @Description Восстанавливает пароль пользователя
+// @Summary     Запрос на восстановление пароля
+// @Description Отправляет код восстановления пароля на email. Ответ не зависит от того, зарегистрирован ли адрес
 // @Tags        auth
 // @Accept      json
 // @Produce     json
-// @Param       request body models.RestorePasswordRequest true "Данные для восстановления пароля"
-// @Success     200 {object} SuccessResponse
+// @Param       request body models.RestorePasswordRequest true "Email пользователя"
+// @Success     202 {object} SuccessResponse
 // @Failure     400 {object} ErrorResponse
 // @Router      /auth/restore-password [post]
-// @Example     request - {"email": "test@example.com", "newPassword": "newpass123"}
+// @Example     request - {"email": "test@example.com"}
 func (h *AuthHandler) RestorePassword(c *gin.Context) {
 	log.Printf("[RestorePassword] Получен запрос на восстановление пароля от %s", c.ClientIP())
 	
@@ -298,15 +519,56 @@ func (h *AuthHandler) RestorePassword(c *gin.Context) {
 		return
 	}
 
-	// Тестовая реализация
-	response := struct {
-		Status bool `json:"status"`
-	}{
-		Status: true,
+	if err := h.service.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		log.Printf("[RestorePassword] Ошибка запроса на восстановление пароля: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
 	}
 
-	log.Printf("[RestorePassword] Пароль успешно восстановлен")
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered, a restore code has been sent"})
+}
+
+// @Summary     Подтверждение восстановления пароля
+// @Description Устанавливает новый пароль по коду из письма и завершает все сессии пользователя
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       request body models.ConfirmRestorePasswordRequest true "Код и новый пароль"
+// @Success     200 {object} SuccessResponse
+// @Failure     400 {object} PasswordPolicyErrorResponse
+// @Failure     503 {object} ErrorResponse
+// @Router      /auth/restore-password/confirm [post]
+// @Example     request - {"email": "test@example.com", "code": "123456", "newPassword": "battery staple 43"}
+func (h *AuthHandler) ConfirmRestorePassword(c *gin.Context) {
+	log.Printf("[ConfirmRestorePassword] Получен запрос на подтверждение восстановления пароля от %s", c.ClientIP())
+
+	var req models.ConfirmRestorePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[ConfirmRestorePassword] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.service.ConfirmPasswordReset(c.Request.Context(), req.Email, req.Code, req.NewPassword)
+	if err != nil {
+		log.Printf("[ConfirmRestorePassword] Ошибка восстановления пароля: %v", err)
+		var policyErr *auth.PasswordPolicyError
+		var busy *password.BusyError
+		switch {
+		case errors.As(err, &policyErr):
+			respondPasswordPolicy(c, policyErr)
+		case errors.As(err, &busy):
+			respondBusy(c, busy)
+		case errors.Is(err, auth.ErrInvalidCode):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired code"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	log.Printf("[ConfirmRestorePassword] Пароль успешно восстановлен")
+	c.JSON(http.StatusOK, gin.H{"message": "Password restored successfully"})
 }
 
 // @Summary     Выход со всех устройств
@@ -335,6 +597,7 @@ func (h *AuthHandler) LogoutFromAllDevices(c *gin.Context) {
 		return
 	}
 
+	h.sessions.Clear(c)
 	log.Printf("[LogoutFromAllDevices] Успешный выход со всех устройств пользователя: %s", userID)
 	c.JSON(http.StatusOK, resp)
 }
@@ -354,6 +617,7 @@ func (h *AuthHandler) VerifyToken(c *gin.Context) {
 	userID := c.GetString("userID")
 	userEmail := c.GetString("userEmail")
 	userIsMentor := c.GetBool("userIsMentor")
+	userEmailVerified := c.GetBool("userEmailVerified")
 
 	if userID == "" || userEmail == "" {
 		log.Printf("[VerifyToken] Информация о пользователе не найдена в контексте")
@@ -370,15 +634,396 @@ func (h *AuthHandler) VerifyToken(c *gin.Context) {
 	}
 
 	resp := &auth.UserInfo{
-		ID:       id,
-		Email:    userEmail,
-		IsMentor: userIsMentor,
+		ID:            id,
+		Email:         userEmail,
+		IsMentor:      userIsMentor,
+		EmailVerified: userEmailVerified,
 	}
 
 	log.Printf("[VerifyToken] Токен успешно проверен для пользователя: %s", userEmail)
 	c.JSON(http.StatusOK, resp)
 }
 
+// @Summary     Подтверждение email
+// @Description Подтверждает email пользователя по токену из письма
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       request body models.VerifyEmailRequest true "Токен подтверждения"
+// @Success     200 {object} SuccessResponse
+// @Failure     400 {object} ErrorResponse
+// @Router      /auth/verify-email [post]
+// @Example     request - {"token": "eyJhbGciOiJIUzI1NiIs..."}
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	log.Printf("[VerifyEmail] Получен запрос на подтверждение email от %s", c.ClientIP())
+
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[VerifyEmail] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.service.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		log.Printf("[VerifyEmail] Ошибка подтверждения email: %v", err)
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	log.Printf("[VerifyEmail] Email успешно подтвержден")
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// @Summary     Разблокировка входа
+// @Description Снимает блокировку входа после неудачных попыток по токену из письма о блокировке
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       request body models.UnlockLoginRequest true "Токен из письма"
+// @Success     200 {object} SuccessResponse
+// @Failure     400 {object} ErrorResponse
+// @Router      /auth/unlock [post]
+func (h *AuthHandler) UnlockLogin(c *gin.Context) {
+	var req models.UnlockLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[UnlockLogin] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.service.UnlockLogin(c.Request.Context(), req.Token); err != nil {
+		log.Printf("[UnlockLogin] Ошибка разблокировки входа: %v", err)
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Login unlocked"})
+}
+
+// @Summary     Повторная отправка письма подтверждения
+// @Description Повторно отправляет письмо для подтверждения email. Ответ не зависит от того, зарегистрирован ли адрес
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       request body models.ResendVerificationRequest true "Email пользователя"
+// @Success     202 {object} SuccessResponse
+// @Failure     400 {object} ErrorResponse
+// @Router      /auth/verify-email/resend [post]
+// @Example     request - {"email": "test@example.com"}
+func (h *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	log.Printf("[ResendVerificationEmail] Получен запрос на повторную отправку письма от %s", c.ClientIP())
+
+	var req models.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[ResendVerificationEmail] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.service.ResendVerificationEmail(c.Request.Context(), req.Email); err != nil {
+		log.Printf("[ResendVerificationEmail] Ошибка отправки письма: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered and not verified, a verification email has been sent"})
+}
+
+// @Summary     Удаление аккаунта
+// @Description Планирует удаление аккаунта после проверки пароля. До окончания периода ожидания удаление можно отменить
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body models.DeleteAccountRequest true "Текущий пароль"
+// @Success     202 {object} models.DeleteAccountResponse
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Failure     503 {object} ErrorResponse
+// @Router      /auth/me/delete [post]
+// @Example     request - {"password": "password123"}
+func (h *AuthHandler) DeleteAccount(c *gin.Context) {
+	log.Printf("[DeleteAccount] Получен запрос на удаление аккаунта от %s", c.ClientIP())
+
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[DeleteAccount] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID := c.GetString("userID")
+	scheduledAt, err := h.service.RequestAccountDeletion(c.Request.Context(), userID, req.Password)
+	if err != nil {
+		log.Printf("[DeleteAccount] Ошибка удаления аккаунта: %v", err)
+		var busy *password.BusyError
+		switch {
+		case errors.As(err, &busy):
+			respondBusy(c, busy)
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		case errors.Is(err, auth.ErrDeletionAlreadyScheduled):
+			c.JSON(http.StatusConflict, gin.H{"error": "Account deletion is already scheduled"})
+		case errors.Is(err, auth.ErrOrganizationOwner):
+			c.JSON(http.StatusConflict, gin.H{"error": "Transfer ownership of your organizations before deleting the account"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	log.Printf("[DeleteAccount] Удаление аккаунта %s запланировано", userID)
+	c.JSON(http.StatusAccepted, models.DeleteAccountResponse{DeletionScheduledAt: scheduledAt})
+}
+
+// @Summary     Отмена удаления аккаунта
+// @Description Отменяет запланированное удаление аккаунта
+// @Tags        auth
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Router      /auth/me/delete/cancel [post]
+func (h *AuthHandler) CancelDeleteAccount(c *gin.Context) {
+	log.Printf("[CancelDeleteAccount] Получен запрос на отмену удаления аккаунта от %s", c.ClientIP())
+
+	userID := c.GetString("userID")
+	if err := h.service.CancelAccountDeletion(c.Request.Context(), userID); err != nil {
+		log.Printf("[CancelDeleteAccount] Ошибка отмены удаления: %v", err)
+		switch {
+		case errors.Is(err, auth.ErrDeletionNotScheduled):
+			c.JSON(http.StatusConflict, gin.H{"error": "Account deletion is not scheduled"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	log.Printf("[CancelDeleteAccount] Удаление аккаунта %s отменено", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
+
+// @Summary     Текущий пользователь
+// @Description Возвращает данные текущего пользователя и его профиль
+// @Tags        auth
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {object} auth.MeResponse
+// @Failure     401 {object} ErrorResponse
+// @Router      /auth/me [get]
+func (h *AuthHandler) GetMe(c *gin.Context) {
+	userID := c.GetString("userID")
+	resp, err := h.service.GetMe(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[GetMe] Ошибка получения профиля пользователя %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary     Изменение профиля
+// @Description Частично изменяет профиль: отсутствующие поля не меняются, пустые строки очищают поле.
+// @Description Биография доступна только менторам
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body models.UpdateProfileRequest true "Изменяемые поля профиля"
+// @Success     200 {object} auth.MeResponse
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Router      /auth/me [patch]
+// @Example     request - {"display_name": "Иван", "locale": "ru-RU", "timezone": "Europe/Moscow"}
+func (h *AuthHandler) UpdateMe(c *gin.Context) {
+	log.Printf("[UpdateMe] Получен запрос на изменение профиля от %s", c.ClientIP())
+
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[UpdateMe] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID := c.GetString("userID")
+	resp, err := h.service.UpdateProfile(c.Request.Context(), userID, auth.ProfileUpdate{
+		DisplayName: req.DisplayName,
+		AvatarURL:   req.AvatarURL,
+		Locale:      req.Locale,
+		Timezone:    req.Timezone,
+		MentorBio:   req.MentorBio,
+	})
+	if err != nil {
+		log.Printf("[UpdateMe] Ошибка изменения профиля: %v", err)
+		var validationErr *auth.ProfileValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile", "fields": validationErr.Fields})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary     Проверка имени пользователя
+// @Description Проверяет, свободно ли имя пользователя и допустимо ли оно
+// @Tags        auth
+// @Produce     json
+// @Param       username query string true "Имя пользователя"
+// @Success     200 {object} auth.UsernameAvailability
+// @Failure     400 {object} ErrorResponse
+// @Router      /auth/username/available [get]
+func (h *AuthHandler) CheckUsername(c *gin.Context) {
+	username := c.Query("username")
+	if username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username is required"})
+		return
+	}
+
+	resp, err := h.service.CheckUsernameAvailability(c.Request.Context(), username)
+	if err != nil {
+		log.Printf("[CheckUsername] Ошибка проверки имени пользователя: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary     Смена имени пользователя
+// @Description Задает или меняет имя пользователя. Повторная смена доступна после USERNAME_CHANGE_COOLDOWN
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body models.ChangeUsernameRequest true "Новое имя пользователя"
+// @Success     200 {object} auth.UserInfo
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Failure     429 {object} ErrorResponse
+// @Router      /auth/me/username [put]
+// @Example     request - {"username": "gopher"}
+func (h *AuthHandler) ChangeUsername(c *gin.Context) {
+	log.Printf("[ChangeUsername] Получен запрос на смену имени пользователя от %s", c.ClientIP())
+
+	var req models.ChangeUsernameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[ChangeUsername] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	resp, err := h.service.ChangeUsername(c.Request.Context(), c.GetString("userID"), req.Username)
+	if err != nil {
+		log.Printf("[ChangeUsername] Ошибка смены имени пользователя: %v", err)
+		var cooldownErr *auth.UsernameCooldownError
+		switch {
+		case errors.As(err, &cooldownErr):
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(cooldownErr.RetryAt).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case errors.Is(err, auth.ErrUsernameTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "Username is already taken"})
+		case errors.Is(err, auth.ErrUsernameInvalid), errors.Is(err, auth.ErrUsernameReserved):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary     Выгрузка персональных данных
+// @Description Запускает подготовку архива со всеми данными пользователя. Ссылка на скачивание придет на email.
+// @Description Требует токен, выпущенный не раньше чем REAUTH_MAX_AGE назад: при необходимости нужно войти заново
+// @Tags        auth
+// @Produce     json
+// @Security    BearerAuth
+// @Success     202 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Router      /auth/me/export [get]
+func (h *AuthHandler) ExportData(c *gin.Context) {
+	log.Printf("[ExportData] Получен запрос на выгрузку данных от %s", c.ClientIP())
+
+	userID := c.GetString("userID")
+	if err := h.service.RequestDataExport(c.Request.Context(), userID); err != nil {
+		log.Printf("[ExportData] Ошибка запуска выгрузки данных: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	log.Printf("[ExportData] Выгрузка данных пользователя %s запущена", userID)
+	c.JSON(http.StatusAccepted, gin.H{"message": "Data export started, download link will be sent by email"})
+}
+
+// @Summary     Скачивание архива с данными
+// @Description Возвращает архив с данными пользователя по токену из письма
+// @Tags        auth
+// @Produce     json
+// @Param       token query string true "Токен из письма"
+// @Success     200 {object} auth.DataExport
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Router      /auth/export/download [get]
+func (h *AuthHandler) DownloadDataExport(c *gin.Context) {
+	log.Printf("[DownloadDataExport] Получен запрос на скачивание архива от %s", c.ClientIP())
+
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token is required"})
+		return
+	}
+
+	data, err := h.service.DownloadDataExport(c.Request.Context(), token)
+	if err != nil {
+		log.Printf("[DownloadDataExport] Ошибка скачивания архива: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired download link"})
+		return
+	}
+
+	filename := "kubercode-data-" + time.Now().Format("2006-01-02") + ".json"
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/json", data)
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
+}
+
+// PasswordPolicyErrorResponse - ответ на пароль, не прошедший политику, со списком нарушенных правил
+type PasswordPolicyErrorResponse struct {
+	Error      string               `json:"error"`
+	Violations []password.Violation `json:"violations"`
+}
+
+// respondBusy отвечает 503, если пароль не проверен из-за перегрузки пула хеширования
+func respondBusy(c *gin.Context, err *password.BusyError) {
+	seconds := int(err.RetryAfter.Seconds())
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service is busy, try again later", "retry_after": seconds})
+}
+
+func respondPasswordPolicy(c *gin.Context, err *auth.PasswordPolicyError) {
+	c.JSON(http.StatusBadRequest, PasswordPolicyErrorResponse{
+		Error:      "Password does not meet the password policy",
+		Violations: err.Violations,
+	})
 }
\ No newline at end of file
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"kubercode/internal/domain/auth"
+	"kubercode/internal/domain/auth/identity"
+	"kubercode/internal/domain/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary     Вход через внешнего провайдера
+// @Description Возвращает адрес страницы авторизации GitHub или Google. После авторизации провайдер
+// @Description перенаправит пользователя на redirect URL фронтенда с параметрами code и state.
+// @Description Авторизация привязывается к браузеру HttpOnly cookie, которую нужно вернуть в callback
+// @Tags        auth
+// @Produce     json
+// @Param       provider path string true "Провайдер (github, google)"
+// @Success     200 {object} map[string]string
+// @Failure     404 {object} ErrorResponse
+// @Router      /auth/oauth/{provider} [get]
+func (h *AuthHandler) StartExternalLogin(c *gin.Context) {
+	start, err := h.service.StartExternalLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		respondIdentityError(c, "StartExternalLogin", err)
+		return
+	}
+
+	h.sessions.SetOAuthBinding(c, start.Binding, start.TTL)
+	c.JSON(http.StatusOK, gin.H{"authorization_url": start.AuthorizationURL})
+}
+
+// @Summary     Завершение входа через внешнего провайдера
+// @Description Обменивает код авторизации на токены. Аккаунт находится по привязке провайдера или по
+// @Description подтвержденному email, при отсутствии создается новый. Для привязки к текущему аккаунту
+// @Description возвращает токены того же аккаунта, привязку завершает только этот аккаунт с access токеном.
+// @Description Запрос должен прийти с cookie, выданной при начале авторизации
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       provider path string true "Провайдер (github, google)"
+// @Param       request body models.OAuthCallbackRequest true "Параметры от провайдера"
+// @Success     200 {object} auth.LoginResponse
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Router      /auth/oauth/{provider}/callback [post]
+// @Example     request - {"code": "4/0Ad...", "state": "m2Vx..."}
+func (h *AuthHandler) CompleteExternalLogin(c *gin.Context) {
+	log.Printf("[CompleteExternalLogin] Получен ответ провайдера %s от %s", c.Param("provider"), c.ClientIP())
+
+	var req models.OAuthCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[CompleteExternalLogin] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	// Маршрут публичный, поэтому пользователь определяется по access токену, если он передан
+	callerID := ""
+	if token := bearerToken(c); token != "" {
+		if caller, err := h.service.VerifyToken(c.Request.Context(), token); err == nil {
+			callerID = caller.ID.Hex()
+		}
+	}
+
+	resp, err := h.service.CompleteExternalAuth(c.Request.Context(), c.Param("provider"), req.Code, req.State,
+		h.sessions.OAuthBinding(c), callerID)
+	if err != nil {
+		respondIdentityError(c, "CompleteExternalLogin", err)
+		return
+	}
+
+	respondTokens(c, h.sessions, "CompleteExternalLogin", resp)
+}
+
+// @Summary     Привязанные провайдеры
+// @Description Возвращает внешних провайдеров, привязанных к аккаунту
+// @Tags        auth
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {array} auth.ExternalIdentity
+// @Failure     401 {object} ErrorResponse
+// @Router      /auth/me/identities [get]
+func (h *AuthHandler) GetIdentities(c *gin.Context) {
+	identities, err := h.service.GetIdentities(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		respondIdentityError(c, "GetIdentities", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+// @Summary     Привязка внешнего провайдера
+// @Description Возвращает адрес авторизации у провайдера. Код завершается через /auth/oauth/{provider}/callback
+// @Description с тем же access токеном и cookie, выданной в ответе
+// @Tags        auth
+// @Produce     json
+// @Security    BearerAuth
+// @Param       provider path string true "Провайдер (github, google)"
+// @Success     200 {object} map[string]string
+// @Failure     401 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /auth/me/identities/{provider} [post]
+func (h *AuthHandler) LinkIdentity(c *gin.Context) {
+	start, err := h.service.StartIdentityLink(c.Request.Context(), c.GetString("userID"), c.Param("provider"))
+	if err != nil {
+		respondIdentityError(c, "LinkIdentity", err)
+		return
+	}
+
+	h.sessions.SetOAuthBinding(c, start.Binding, start.TTL)
+	c.JSON(http.StatusOK, gin.H{"authorization_url": start.AuthorizationURL})
+}
+
+// @Summary     Отвязка внешнего провайдера
+// @Description Отвязывает провайдера от аккаунта. У аккаунта должен остаться пароль или другой провайдер
+// @Tags        auth
+// @Produce     json
+// @Security    BearerAuth
+// @Param       provider path string true "Провайдер (github, google)"
+// @Success     200 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Router      /auth/me/identities/{provider} [delete]
+func (h *AuthHandler) UnlinkIdentity(c *gin.Context) {
+	if err := h.service.UnlinkIdentity(c.Request.Context(), c.GetString("userID"), c.Param("provider")); err != nil {
+		respondIdentityError(c, "UnlinkIdentity", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked successfully"})
+}
+
+// @Summary     Проверка корпоративного входа
+// @Description Определяет по домену email, нужно ли входить через провайдера организации, и возвращает адрес авторизации.
+// @Description После авторизации провайдер перенаправит пользователя на SSO_REDIRECT_URL с параметрами code и state
+// @Tags        auth
+// @Produce     json
+// @Param       email query string true "Email пользователя"
+// @Success     200 {object} auth.SSODiscovery
+// @Failure     400 {object} ErrorResponse
+// @Router      /auth/sso/discover [get]
+func (h *AuthHandler) DiscoverSSO(c *gin.Context) {
+	email := c.Query("email")
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is required"})
+		return
+	}
+
+	resp, err := h.service.DiscoverSSO(c.Request.Context(), email)
+	if err != nil {
+		respondIdentityError(c, "DiscoverSSO", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary     Завершение корпоративного входа
+// @Description Обменивает код провайдера организации на токены. При первом входе аккаунт создается
+// @Description и добавляется в организацию, токены выпускаются в контексте организации
+// @Tags        auth
+// @Accept      json
+// @Produce     json
+// @Param       request body models.OAuthCallbackRequest true "Параметры от провайдера"
+// @Success     200 {object} auth.LoginResponse
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Router      /auth/sso/callback [post]
+func (h *AuthHandler) CompleteSSOLogin(c *gin.Context) {
+	log.Printf("[CompleteSSOLogin] Получен ответ корпоративного провайдера от %s", c.ClientIP())
+
+	var req models.OAuthCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[CompleteSSOLogin] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	resp, err := h.service.CompleteSSOLogin(c.Request.Context(), req.Code, req.State)
+	if err != nil {
+		respondIdentityError(c, "CompleteSSOLogin", err)
+		return
+	}
+
+	respondTokens(c, h.sessions, "CompleteSSOLogin", resp)
+}
+
+func respondIdentityError(c *gin.Context, op string, err error) {
+	log.Printf("[%s] Ошибка: %v", op, err)
+	switch {
+	case errors.Is(err, auth.ErrSSORequired), errors.Is(err, auth.ErrSSODomainMismatch):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrSSONotConfigured):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrUnknownProvider):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+	case errors.Is(err, auth.ErrIdentityNotLinked):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity is not linked"})
+	case errors.Is(err, auth.ErrInvalidOAuthState), errors.Is(err, identity.ErrExchangeFailed):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired authorization"})
+	case errors.Is(err, auth.ErrExternalEmailMissing):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrIdentityLinkRequired), errors.Is(err, auth.ErrIdentityAlreadyLinked),
+		errors.Is(err, auth.ErrLastLoginMethod), errors.Is(err, auth.ErrUsernameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email is not verified"})
+	case errors.Is(err, auth.ErrAccountDisabled), errors.Is(err, auth.ErrAccountLocked),
+		errors.Is(err, auth.ErrAccountBanned), errors.Is(err, auth.ErrPasswordResetRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, identity.ErrUserInfoFailed):
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
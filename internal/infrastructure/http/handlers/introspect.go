@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"kubercode/internal/domain/models"
+)
+
+// @Summary     Проверка токена внутренним сервисом
+// @Description Сообщает, действителен ли access токен, и возвращает его пользователя. Доступно только
+// @Description на mTLS listener внутренним сервисам. Недействительный токен - не ошибка, а active=false
+// @Tags        internal
+// @Accept      json
+// @Produce     json
+// @Param       request body models.IntrospectRequest true "Токен"
+// @Success     200 {object} map[string]interface{}
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Router      /internal/v1/introspect [post]
+// @Example     request - {"token": "<access_token>"}
+func (h *AuthHandler) IntrospectToken(c *gin.Context) {
+	var req models.IntrospectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	info, err := h.service.VerifyToken(c.Request.Context(), req.Token)
+	if err != nil {
+		log.Printf("[IntrospectToken] Сервис %s: токен недействителен: %v", c.GetString("serviceIdentity"), err)
+		c.JSON(http.StatusOK, gin.H{"active": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"active": true, "user": info})
+}
@@ -0,0 +1,550 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"kubercode/internal/domain/auth"
+	"kubercode/internal/domain/auth/identity"
+	"kubercode/internal/domain/models"
+	"kubercode/internal/infrastructure/http/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationHandler struct {
+	service  *auth.Service
+	sessions *session.Cookies
+}
+
+func NewOrganizationHandler(service *auth.Service) *OrganizationHandler {
+	return &OrganizationHandler{service: service}
+}
+
+// @Summary     Создание организации
+// @Description Создает организацию, текущий пользователь становится ее владельцем
+// @Tags        organizations
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body models.CreateOrganizationRequest true "Название организации"
+// @Success     201 {object} auth.Organization
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Router      /orgs [post]
+// @Example     request - {"name": "KuberCode"}
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req models.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[CreateOrganization] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	org, err := h.service.CreateOrganization(c.Request.Context(), c.GetString("userID"), req.Name)
+	if err != nil {
+		h.respondError(c, "CreateOrganization", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// @Summary     Организации пользователя
+// @Description Возвращает организации, в которых состоит пользователь, и его роли в них
+// @Tags        organizations
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {array} auth.UserOrganization
+// @Failure     401 {object} ErrorResponse
+// @Router      /orgs [get]
+func (h *OrganizationHandler) GetOrganizations(c *gin.Context) {
+	orgs, err := h.service.GetUserOrganizations(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		h.respondError(c, "GetOrganizations", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, orgs)
+}
+
+// @Summary     Участники организации
+// @Description Возвращает участников организации. Доступно только участникам
+// @Tags        organizations
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Success     200 {array} auth.OrgMembership
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Router      /orgs/{id}/members [get]
+func (h *OrganizationHandler) GetMembers(c *gin.Context) {
+	members, err := h.service.GetOrganizationMembers(c.Request.Context(), c.GetString("userID"), c.Param("id"))
+	if err != nil {
+		h.respondError(c, "GetMembers", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// @Summary     Приглашение в организацию
+// @Description Отправляет приглашение на email. Приглашать могут владелец и администраторы,
+// @Description администратор может пригласить только ментора или участника
+// @Tags        organizations
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Param       request body models.InviteMemberRequest true "Email и роль"
+// @Success     201 {object} auth.OrgInvitation
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Router      /orgs/{id}/invitations [post]
+// @Example     request - {"email": "mentor@example.com", "role": "mentor"}
+func (h *OrganizationHandler) InviteMember(c *gin.Context) {
+	var req models.InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[InviteMember] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	invitation, err := h.service.InviteToOrganization(c.Request.Context(), c.GetString("userID"), c.Param("id"),
+		req.Email, auth.OrgRole(req.Role))
+	if err != nil {
+		h.respondError(c, "InviteMember", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// @Summary     Принятие приглашения
+// @Description Добавляет пользователя в организацию. Email аккаунта должен быть подтвержден и совпадать с адресом приглашения
+// @Tags        organizations
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body models.InvitationTokenRequest true "Токен из письма"
+// @Success     200 {object} auth.OrgMembership
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /orgs/invitations/accept [post]
+func (h *OrganizationHandler) AcceptInvitation(c *gin.Context) {
+	var req models.InvitationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[AcceptInvitation] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	membership, err := h.service.AcceptInvitation(c.Request.Context(), c.GetString("userID"), req.Token)
+	if err != nil {
+		h.respondError(c, "AcceptInvitation", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, membership)
+}
+
+// @Summary     Отклонение приглашения
+// @Description Отклоняет приглашение по токену из письма, вход не требуется
+// @Tags        organizations
+// @Accept      json
+// @Produce     json
+// @Param       request body models.InvitationTokenRequest true "Токен из письма"
+// @Success     200 {object} SuccessResponse
+// @Failure     400 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /orgs/invitations/decline [post]
+func (h *OrganizationHandler) DeclineInvitation(c *gin.Context) {
+	var req models.InvitationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[DeclineInvitation] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.service.DeclineInvitation(c.Request.Context(), req.Token); err != nil {
+		h.respondError(c, "DeclineInvitation", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation declined"})
+}
+
+// @Summary     Удаление участника
+// @Description Удаляет участника из организации. Участник может выйти сам, владельца удалить нельзя
+// @Tags        organizations
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Param       userId path string true "ID участника"
+// @Success     200 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /orgs/{id}/members/{userId} [delete]
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	err := h.service.RemoveOrganizationMember(c.Request.Context(), c.GetString("userID"), c.Param("id"),
+		c.Param("userId"))
+	if err != nil {
+		h.respondError(c, "RemoveMember", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// @Summary     Передача владения организацией
+// @Description Передает владение другому участнику, прежний владелец становится администратором
+// @Tags        organizations
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Param       request body models.TransferOwnershipRequest true "Новый владелец"
+// @Success     200 {object} SuccessResponse
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /orgs/{id}/owner [post]
+// @Example     request - {"user_id": "65f0c2a4e1b2c3d4e5f60719"}
+func (h *OrganizationHandler) TransferOwnership(c *gin.Context) {
+	var req models.TransferOwnershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[TransferOwnership] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	err := h.service.TransferOrganizationOwnership(c.Request.Context(), c.GetString("userID"), c.Param("id"),
+		req.UserID)
+	if err != nil {
+		h.respondError(c, "TransferOwnership", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ownership transferred"})
+}
+
+// @Summary     Смена контекста организации
+// @Description Выпускает токены с claims org_id и org_role выбранной организации. Пустой org_id возвращает в личный контекст
+// @Tags        organizations
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body models.SwitchOrganizationRequest true "ID организации"
+// @Success     200 {object} auth.LoginResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Router      /auth/switch-org [post]
+// @Example     request - {"org_id": "65f0c2a4e1b2c3d4e5f60718"}
+func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
+	var req models.SwitchOrganizationRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	authTime, _ := c.Get("userAuthTime")
+	t, _ := authTime.(time.Time)
+	resp, err := h.service.SwitchOrganization(c.Request.Context(), c.GetString("userID"), req.OrgID, t)
+	if err != nil {
+		h.respondError(c, "SwitchOrganization", err)
+		return
+	}
+
+	respondTokens(c, h.sessions, "SwitchOrganization", resp)
+}
+
+// @Summary     Настройка корпоративного входа
+// @Description Подключает OIDC провайдера организации. Адреса провайдера определяются по issuer,
+// @Description группы пользователя сопоставляются с ролями через role_mapping. Доступно владельцу и администраторам
+// @Tags        organizations
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Param       request body models.OrgSSORequest true "Настройки провайдера"
+// @Success     200 {object} auth.OrgSSOConfig
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Router      /orgs/{id}/sso [put]
+// @Example     request - {"issuer": "https://login.corp.example", "client_id": "kubercode", "client_secret": "secret", "role_mapping": {"teachers": "mentor"}, "enforce_sso": true}
+func (h *OrganizationHandler) ConfigureSSO(c *gin.Context) {
+	var req models.OrgSSORequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[ConfigureSSO] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	settings := auth.OrgSSOSettings{
+		Issuer:       req.Issuer,
+		ClientID:     req.ClientID,
+		ClientSecret: req.ClientSecret,
+		Claims: identity.ClaimMapping{
+			Subject:       req.Claims.Subject,
+			Email:         req.Claims.Email,
+			EmailVerified: req.Claims.EmailVerified,
+			Name:          req.Claims.Name,
+			Groups:        req.Claims.Groups,
+		},
+		DefaultRole: auth.OrgRole(req.DefaultRole),
+		EnforceSSO:  req.EnforceSSO,
+	}
+	if len(req.RoleMapping) > 0 {
+		settings.RoleMapping = make(map[string]auth.OrgRole, len(req.RoleMapping))
+		for group, role := range req.RoleMapping {
+			settings.RoleMapping[group] = auth.OrgRole(role)
+		}
+	}
+
+	cfg, err := h.service.ConfigureOrgSSO(c.Request.Context(), c.GetString("userID"), c.Param("id"), settings)
+	if err != nil {
+		h.respondError(c, "ConfigureSSO", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cfg)
+}
+
+// @Summary     Настройки корпоративного входа
+// @Description Возвращает настройки OIDC провайдера организации без client secret
+// @Tags        organizations
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Success     200 {object} auth.OrgSSOConfig
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /orgs/{id}/sso [get]
+func (h *OrganizationHandler) GetSSO(c *gin.Context) {
+	cfg, err := h.service.GetOrgSSO(c.Request.Context(), c.GetString("userID"), c.Param("id"))
+	if err != nil {
+		h.respondError(c, "GetSSO", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cfg)
+}
+
+// @Summary     Отключение корпоративного входа
+// @Tags        organizations
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Success     200 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /orgs/{id}/sso [delete]
+func (h *OrganizationHandler) DeleteSSO(c *gin.Context) {
+	if err := h.service.DeleteOrgSSO(c.Request.Context(), c.GetString("userID"), c.Param("id")); err != nil {
+		h.respondError(c, "DeleteSSO", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "SSO disabled"})
+}
+
+// @Summary     Домены организации
+// @Tags        organizations
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Success     200 {array} auth.OrgDomain
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Router      /orgs/{id}/domains [get]
+func (h *OrganizationHandler) GetDomains(c *gin.Context) {
+	domains, err := h.service.GetOrgDomains(c.Request.Context(), c.GetString("userID"), c.Param("id"))
+	if err != nil {
+		h.respondError(c, "GetDomains", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, domains)
+}
+
+// @Summary     Добавление домена
+// @Description Добавляет email домен организации и возвращает TXT запись, которую нужно создать для его подтверждения
+// @Tags        organizations
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Param       request body models.AddOrgDomainRequest true "Домен"
+// @Success     201 {object} auth.DomainVerification
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Router      /orgs/{id}/domains [post]
+// @Example     request - {"domain": "corp.example"}
+func (h *OrganizationHandler) AddDomain(c *gin.Context) {
+	var req models.AddOrgDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[AddDomain] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	verification, err := h.service.AddOrgDomain(c.Request.Context(), c.GetString("userID"), c.Param("id"), req.Domain)
+	if err != nil {
+		h.respondError(c, "AddDomain", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, verification)
+}
+
+// @Summary     Подтверждение домена
+// @Description Проверяет TXT запись домена. Подтвержденный домен может принадлежать только одной организации
+// @Tags        organizations
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Param       domain path string true "Домен"
+// @Success     200 {object} auth.OrgDomain
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Failure     422 {object} ErrorResponse
+// @Router      /orgs/{id}/domains/{domain}/verify [post]
+func (h *OrganizationHandler) VerifyDomain(c *gin.Context) {
+	domain, err := h.service.VerifyOrgDomain(c.Request.Context(), c.GetString("userID"), c.Param("id"),
+		c.Param("domain"))
+	if err != nil {
+		h.respondError(c, "VerifyDomain", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, domain)
+}
+
+// @Summary     Удаление домена
+// @Tags        organizations
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Param       domain path string true "Домен"
+// @Success     200 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /orgs/{id}/domains/{domain} [delete]
+func (h *OrganizationHandler) RemoveDomain(c *gin.Context) {
+	err := h.service.RemoveOrgDomain(c.Request.Context(), c.GetString("userID"), c.Param("id"), c.Param("domain"))
+	if err != nil {
+		h.respondError(c, "RemoveDomain", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Domain removed"})
+}
+
+// @Summary     Выпуск токена SCIM
+// @Description Выпускает токен, с которым каталог организации управляет пользователями и группами
+// @Description через /scim/v2. Значение токена возвращается только в этом ответе
+// @Tags        organizations
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Param       request body models.CreateSCIMTokenRequest true "Название токена"
+// @Success     201 {object} auth.CreatedSCIMToken
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Router      /orgs/{id}/scim/tokens [post]
+func (h *OrganizationHandler) CreateSCIMToken(c *gin.Context) {
+	var req models.CreateSCIMTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[CreateSCIMToken] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	token, err := h.service.CreateSCIMToken(c.Request.Context(), c.GetString("userID"), c.Param("id"), req.Name)
+	if err != nil {
+		h.respondError(c, "CreateSCIMToken", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, token)
+}
+
+// @Summary     Токены SCIM
+// @Tags        organizations
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Success     200 {array} auth.SCIMToken
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Router      /orgs/{id}/scim/tokens [get]
+func (h *OrganizationHandler) GetSCIMTokens(c *gin.Context) {
+	tokens, err := h.service.GetSCIMTokens(c.Request.Context(), c.GetString("userID"), c.Param("id"))
+	if err != nil {
+		h.respondError(c, "GetSCIMTokens", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// @Summary     Отзыв токена SCIM
+// @Tags        organizations
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID организации"
+// @Param       tokenId path string true "ID токена"
+// @Success     200 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /orgs/{id}/scim/tokens/{tokenId} [delete]
+func (h *OrganizationHandler) RevokeSCIMToken(c *gin.Context) {
+	err := h.service.RevokeSCIMToken(c.Request.Context(), c.GetString("userID"), c.Param("id"), c.Param("tokenId"))
+	if err != nil {
+		h.respondError(c, "RevokeSCIMToken", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "SCIM token revoked"})
+}
+
+func (h *OrganizationHandler) respondError(c *gin.Context, op string, err error) {
+	log.Printf("[%s] Ошибка: %v", op, err)
+	switch {
+	case errors.Is(err, auth.ErrOrgNameInvalid), errors.Is(err, auth.ErrOrgRoleInvalid),
+		errors.Is(err, auth.ErrDomainInvalid), errors.Is(err, identity.ErrDiscoveryFailed),
+		errors.Is(err, auth.ErrSCIMTokenName), errors.Is(err, auth.ErrOwnershipTransfer):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrNotOrgMember), errors.Is(err, auth.ErrOrgPermissionDenied),
+		errors.Is(err, auth.ErrInvitationEmail), errors.Is(err, auth.ErrOwnerRemoval):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrOrganizationNotFound), errors.Is(err, auth.ErrInvitationNotFound),
+		errors.Is(err, auth.ErrUserNotFound), errors.Is(err, auth.ErrSSONotConfigured),
+		errors.Is(err, auth.ErrDomainNotFound), errors.Is(err, auth.ErrSCIMTokenNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrDomainVerification):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrAlreadyOrgMember), errors.Is(err, auth.ErrDomainTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
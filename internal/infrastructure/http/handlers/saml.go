@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"kubercode/internal/domain/auth"
+	"kubercode/internal/domain/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary     Метаданные SAML IdP
+// @Description Возвращает EntityDescriptor с сертификатом подписи и адресами SSO и SingleLogout
+// @Tags        saml
+// @Produce     xml
+// @Success     200 {string} string
+// @Failure     404 {object} ErrorResponse
+// @Router      /saml/metadata [get]
+func (h *AuthHandler) SAMLMetadata(c *gin.Context) {
+	metadata, err := h.service.SAMLMetadata()
+	if err != nil {
+		respondSAMLError(c, "SAMLMetadata", err)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// @Summary     SAML SSO (вход по инициативе SP)
+// @Description Принимает AuthnRequest через HTTP-Redirect binding и перенаправляет браузер на страницу
+// @Description входа фронтенда. Фронтенд завершает вход через POST /auth/saml/login/{request}
+// @Tags        saml
+// @Param       SAMLRequest query string true "AuthnRequest (deflate + base64)"
+// @Param       RelayState query string false "Состояние SP"
+// @Success     302
+// @Failure     400 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /saml/sso [get]
+func (h *AuthHandler) SAMLSingleSignOn(c *gin.Context) {
+	location, err := h.service.BeginSAMLLogin(c.Request.Context(), c.Request.URL.RawQuery)
+	if err != nil {
+		respondSAMLError(c, "SAMLSingleSignOn", err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, location)
+}
+
+// @Summary     SAML SingleLogout
+// @Description Принимает LogoutRequest или LogoutResponse SP через HTTP-Redirect binding. LogoutRequest
+// @Description завершает все сессии пользователя, браузер перенаправляется на SLO адрес SP с ответом
+// @Tags        saml
+// @Param       SAMLRequest query string false "LogoutRequest (deflate + base64)"
+// @Param       SAMLResponse query string false "LogoutResponse (deflate + base64)"
+// @Success     302
+// @Failure     400 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /saml/slo [get]
+func (h *AuthHandler) SAMLSingleLogout(c *gin.Context) {
+	location, err := h.service.HandleSAMLLogout(c.Request.Context(), c.Request.URL.RawQuery)
+	if err != nil {
+		respondSAMLError(c, "SAMLSingleLogout", err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, location)
+}
+
+// @Summary     Завершение SAML входа
+// @Description Выпускает подписанное утверждение для запроса SP от имени текущего пользователя.
+// @Description Фронтенд отправляет saml_response и relay_state формой POST на acs_url
+// @Tags        saml
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request path string true "ID запроса из адреса страницы входа"
+// @Success     200 {object} auth.SAMLPostForm
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     410 {object} ErrorResponse
+// @Router      /auth/saml/login/{request} [post]
+func (h *AuthHandler) CompleteSAMLLogin(c *gin.Context) {
+	form, err := h.service.CompleteSAMLLogin(c.Request.Context(), c.GetString("userID"), c.Param("request"),
+		c.GetTime("userAuthTime"))
+	if err != nil {
+		respondSAMLError(c, "CompleteSAMLLogin", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, form)
+}
+
+// @Summary     Вход в SP по инициативе IdP
+// @Description Выпускает подписанное утверждение для SP без запроса с его стороны
+// @Tags        saml
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       sp path string true "ID SP"
+// @Param       request body models.SAMLIdPInitiatedRequest false "RelayState"
+// @Success     200 {object} auth.SAMLPostForm
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /auth/saml/idp-initiated/{sp} [post]
+func (h *AuthHandler) StartIdPInitiatedSAML(c *gin.Context) {
+	var req models.SAMLIdPInitiatedRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	form, err := h.service.StartIdPInitiatedSAML(c.Request.Context(), c.GetString("userID"), c.Param("sp"),
+		req.RelayState, c.GetTime("userAuthTime"))
+	if err != nil {
+		respondSAMLError(c, "StartIdPInitiatedSAML", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, form)
+}
+
+// @Summary     SAML SingleLogout по инициативе IdP
+// @Description Завершает все сессии пользователя и возвращает адреса SLO с LogoutRequest для SP,
+// @Description в которые он входил. Фронтенд открывает их по очереди
+// @Tags        saml
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {object} map[string][]string
+// @Failure     401 {object} ErrorResponse
+// @Router      /auth/saml/logout [post]
+func (h *AuthHandler) SAMLLogout(c *gin.Context) {
+	urls, err := h.service.SAMLLogout(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		respondSAMLError(c, "SAMLLogout", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"logout_urls": urls})
+}
+
+// @Summary     Регистрация SAML SP
+// @Description Регистрирует SP с адресами ACS и SLO, сертификатом подписи и именами атрибутов
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body models.SAMLServiceProviderRequest true "SP"
+// @Success     201 {object} auth.SAMLServiceProvider
+// @Failure     400 {object} ErrorResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Router      /admin/saml/service-providers [post]
+func (h *AdminHandler) RegisterSAMLServiceProvider(c *gin.Context) {
+	var req models.SAMLServiceProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[Admin.RegisterSAMLServiceProvider] Ошибка декодирования запроса: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	sp, err := h.service.RegisterSAMLServiceProvider(c.Request.Context(), c.GetString("userID"), auth.SAMLServiceProviderSettings{
+		EntityID:    req.EntityID,
+		Name:        req.Name,
+		ACSURLs:     req.ACSURLs,
+		SLOURL:      req.SLOURL,
+		Certificate: req.Certificate,
+		Attributes: auth.SAMLAttributeMapping{
+			Email:    req.Attributes.Email,
+			Roles:    req.Attributes.Roles,
+			IsMentor: req.Attributes.IsMentor,
+		},
+		OrgID: req.OrgID,
+	})
+	if err != nil {
+		respondSAMLError(c, "Admin.RegisterSAMLServiceProvider", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, sp)
+}
+
+// @Summary     SAML SP
+// @Description Возвращает зарегистрированные SAML SP
+// @Tags        admin
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {array} auth.SAMLServiceProvider
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Router      /admin/saml/service-providers [get]
+func (h *AdminHandler) GetSAMLServiceProviders(c *gin.Context) {
+	providers, err := h.service.GetSAMLServiceProviders(c.Request.Context())
+	if err != nil {
+		respondSAMLError(c, "Admin.GetSAMLServiceProviders", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, providers)
+}
+
+// @Summary     Удаление SAML SP
+// @Description Удаляет SP и сессии пользователей в нем
+// @Tags        admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "ID SP"
+// @Success     200 {object} SuccessResponse
+// @Failure     401 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Router      /admin/saml/service-providers/{id} [delete]
+func (h *AdminHandler) DeleteSAMLServiceProvider(c *gin.Context) {
+	if err := h.service.DeleteSAMLServiceProvider(c.Request.Context(), c.GetString("userID"), c.Param("id")); err != nil {
+		respondSAMLError(c, "Admin.DeleteSAMLServiceProvider", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Service provider deleted"})
+}
+
+func respondSAMLError(c *gin.Context, op string, err error) {
+	log.Printf("[%s] Ошибка: %v", op, err)
+	switch {
+	case errors.Is(err, auth.ErrSAMLNotConfigured), errors.Is(err, auth.ErrSAMLUnknownSP),
+		errors.Is(err, auth.ErrOrganizationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrSAMLInvalidRequest), errors.Is(err, auth.ErrSAMLInvalidACS),
+		errors.Is(err, auth.ErrSAMLInvalidSP):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrSAMLSPExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrSAMLRequestExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrSAMLAccessDenied), errors.Is(err, auth.ErrAccountDisabled),
+		errors.Is(err, auth.ErrAccountLocked), errors.Is(err, auth.ErrAccountBanned),
+		errors.Is(err, auth.ErrPasswordResetRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"kubercode/internal/domain/auth"
+	"kubercode/internal/domain/auth/scim"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SCIMHandler обслуживает SCIM 2.0 API (RFC 7644), через который каталог организации
+// создает, изменяет и отключает пользователей и группы. Организация определяется по токену
+type SCIMHandler struct {
+	service *auth.Service
+}
+
+func NewSCIMHandler(service *auth.Service) *SCIMHandler {
+	return &SCIMHandler{service: service}
+}
+
+// @Summary     Возможности SCIM
+// @Tags        scim
+// @Produce     json
+// @Security    SCIMToken
+// @Success     200 {object} map[string]interface{}
+// @Router      /scim/v2/ServiceProviderConfig [get]
+func (h *SCIMHandler) ServiceProviderConfig(c *gin.Context) {
+	respondSCIM(c, http.StatusOK, scim.ServiceProviderConfig())
+}
+
+// @Summary     Пользователи SCIM
+// @Description Возвращает участников организации. Поддерживаются filter, startIndex и count
+// @Tags        scim
+// @Produce     json
+// @Security    SCIMToken
+// @Param       filter query string false "Фильтр, например userName eq \"anna@example.com\""
+// @Param       startIndex query int false "Номер первого результата, с единицы"
+// @Param       count query int false "Размер страницы"
+// @Success     200 {object} scim.ListResponse
+// @Failure     400 {object} scim.ErrorResponse
+// @Failure     401 {object} scim.ErrorResponse
+// @Router      /scim/v2/Users [get]
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	page, ok := scimPage(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.service.SCIMListUsers(c.Request.Context(), c.GetString("scimOrgID"), c.Query("filter"), page)
+	if err != nil {
+		respondSCIMError(c, "SCIM.ListUsers", err)
+		return
+	}
+
+	respondSCIM(c, http.StatusOK, list)
+}
+
+// @Summary     Пользователь SCIM
+// @Tags        scim
+// @Produce     json
+// @Security    SCIMToken
+// @Param       id path string true "ID пользователя"
+// @Success     200 {object} scim.User
+// @Failure     401 {object} scim.ErrorResponse
+// @Failure     404 {object} scim.ErrorResponse
+// @Router      /scim/v2/Users/{id} [get]
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	user, err := h.service.SCIMGetUser(c.Request.Context(), c.GetString("scimOrgID"), c.Param("id"))
+	if err != nil {
+		respondSCIMError(c, "SCIM.GetUser", err)
+		return
+	}
+
+	respondSCIM(c, http.StatusOK, user)
+}
+
+// @Summary     Создание пользователя SCIM
+// @Description Добавляет пользователя в организацию. Email должен принадлежать подтвержденному домену
+// @Description организации, существующий аккаунт с этим адресом привязывается
+// @Tags        scim
+// @Accept      json
+// @Produce     json
+// @Security    SCIMToken
+// @Param       request body scim.User true "Пользователь"
+// @Success     201 {object} scim.User
+// @Failure     400 {object} scim.ErrorResponse
+// @Failure     401 {object} scim.ErrorResponse
+// @Failure     409 {object} scim.ErrorResponse
+// @Router      /scim/v2/Users [post]
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	var req scim.User
+	if !bindSCIM(c, "SCIM.CreateUser", &req) {
+		return
+	}
+
+	user, err := h.service.SCIMCreateUser(c.Request.Context(), c.GetString("scimOrgID"), &req)
+	if err != nil {
+		respondSCIMError(c, "SCIM.CreateUser", err)
+		return
+	}
+
+	respondSCIM(c, http.StatusCreated, user)
+}
+
+// @Summary     Замена пользователя SCIM
+// @Description Заменяет атрибуты пользователя. active=false отключает аккаунт и завершает его сессии
+// @Tags        scim
+// @Accept      json
+// @Produce     json
+// @Security    SCIMToken
+// @Param       id path string true "ID пользователя"
+// @Param       request body scim.User true "Пользователь"
+// @Success     200 {object} scim.User
+// @Failure     400 {object} scim.ErrorResponse
+// @Failure     401 {object} scim.ErrorResponse
+// @Failure     404 {object} scim.ErrorResponse
+// @Router      /scim/v2/Users/{id} [put]
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	var req scim.User
+	if !bindSCIM(c, "SCIM.ReplaceUser", &req) {
+		return
+	}
+
+	user, err := h.service.SCIMReplaceUser(c.Request.Context(), c.GetString("scimOrgID"), c.Param("id"), &req)
+	if err != nil {
+		respondSCIMError(c, "SCIM.ReplaceUser", err)
+		return
+	}
+
+	respondSCIM(c, http.StatusOK, user)
+}
+
+// @Summary     Изменение пользователя SCIM
+// @Description Применяет операции add, replace и remove. active=false отключает аккаунт и завершает его сессии
+// @Tags        scim
+// @Accept      json
+// @Produce     json
+// @Security    SCIMToken
+// @Param       id path string true "ID пользователя"
+// @Param       request body scim.PatchRequest true "Операции"
+// @Success     200 {object} scim.User
+// @Failure     400 {object} scim.ErrorResponse
+// @Failure     401 {object} scim.ErrorResponse
+// @Failure     404 {object} scim.ErrorResponse
+// @Router      /scim/v2/Users/{id} [patch]
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	var req scim.PatchRequest
+	if !bindSCIM(c, "SCIM.PatchUser", &req) {
+		return
+	}
+
+	user, err := h.service.SCIMPatchUser(c.Request.Context(), c.GetString("scimOrgID"), c.Param("id"), &req)
+	if err != nil {
+		respondSCIMError(c, "SCIM.PatchUser", err)
+		return
+	}
+
+	respondSCIM(c, http.StatusOK, user)
+}
+
+// @Summary     Удаление пользователя SCIM
+// @Description Отключает аккаунт, завершает его сессии и удаляет пользователя из организации
+// @Tags        scim
+// @Security    SCIMToken
+// @Param       id path string true "ID пользователя"
+// @Success     204
+// @Failure     401 {object} scim.ErrorResponse
+// @Failure     404 {object} scim.ErrorResponse
+// @Router      /scim/v2/Users/{id} [delete]
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	if err := h.service.SCIMDeleteUser(c.Request.Context(), c.GetString("scimOrgID"), c.Param("id")); err != nil {
+		respondSCIMError(c, "SCIM.DeleteUser", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary     Группы SCIM
+// @Description Возвращает группы организации. Поддерживаются filter, startIndex и count
+// @Tags        scim
+// @Produce     json
+// @Security    SCIMToken
+// @Param       filter query string false "Фильтр, например displayName eq \"Engineering\""
+// @Param       startIndex query int false "Номер первого результата, с единицы"
+// @Param       count query int false "Размер страницы"
+// @Success     200 {object} scim.ListResponse
+// @Failure     400 {object} scim.ErrorResponse
+// @Failure     401 {object} scim.ErrorResponse
+// @Router      /scim/v2/Groups [get]
+func (h *SCIMHandler) ListGroups(c *gin.Context) {
+	page, ok := scimPage(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.service.SCIMListGroups(c.Request.Context(), c.GetString("scimOrgID"), c.Query("filter"), page)
+	if err != nil {
+		respondSCIMError(c, "SCIM.ListGroups", err)
+		return
+	}
+
+	respondSCIM(c, http.StatusOK, list)
+}
+
+// @Summary     Группа SCIM
+// @Tags        scim
+// @Produce     json
+// @Security    SCIMToken
+// @Param       id path string true "ID группы"
+// @Success     200 {object} scim.Group
+// @Failure     401 {object} scim.ErrorResponse
+// @Failure     404 {object} scim.ErrorResponse
+// @Router      /scim/v2/Groups/{id} [get]
+func (h *SCIMHandler) GetGroup(c *gin.Context) {
+	group, err := h.service.SCIMGetGroup(c.Request.Context(), c.GetString("scimOrgID"), c.Param("id"))
+	if err != nil {
+		respondSCIMError(c, "SCIM.GetGroup", err)
+		return
+	}
+
+	respondSCIM(c, http.StatusOK, group)
+}
+
+// @Summary     Создание группы SCIM
+// @Description Создает группу организации. Участниками могут быть только пользователи организации
+// @Tags        scim
+// @Accept      json
+// @Produce     json
+// @Security    SCIMToken
+// @Param       request body scim.Group true "Группа"
+// @Success     201 {object} scim.Group
+// @Failure     400 {object} scim.ErrorResponse
+// @Failure     401 {object} scim.ErrorResponse
+// @Failure     409 {object} scim.ErrorResponse
+// @Router      /scim/v2/Groups [post]
+func (h *SCIMHandler) CreateGroup(c *gin.Context) {
+	var req scim.Group
+	if !bindSCIM(c, "SCIM.CreateGroup", &req) {
+		return
+	}
+
+	group, err := h.service.SCIMCreateGroup(c.Request.Context(), c.GetString("scimOrgID"), &req)
+	if err != nil {
+		respondSCIMError(c, "SCIM.CreateGroup", err)
+		return
+	}
+
+	respondSCIM(c, http.StatusCreated, group)
+}
+
+// @Summary     Замена группы SCIM
+// @Tags        scim
+// @Accept      json
+// @Produce     json
+// @Security    SCIMToken
+// @Param       id path string true "ID группы"
+// @Param       request body scim.Group true "Группа"
+// @Success     200 {object} scim.Group
+// @Failure     400 {object} scim.ErrorResponse
+// @Failure     401 {object} scim.ErrorResponse
+// @Failure     404 {object} scim.ErrorResponse
+// @Router      /scim/v2/Groups/{id} [put]
+func (h *SCIMHandler) ReplaceGroup(c *gin.Context) {
+	var req scim.Group
+	if !bindSCIM(c, "SCIM.ReplaceGroup", &req) {
+		return
+	}
+
+	group, err := h.service.SCIMReplaceGroup(c.Request.Context(), c.GetString("scimOrgID"), c.Param("id"), &req)
+	if err != nil {
+		respondSCIMError(c, "SCIM.ReplaceGroup", err)
+		return
+	}
+
+	respondSCIM(c, http.StatusOK, group)
+}
+
+// @Summary     Изменение группы SCIM
+// @Description Применяет операции add, replace и remove к названию и участникам группы
+// @Tags        scim
+// @Accept      json
+// @Produce     json
+// @Security    SCIMToken
+// @Param       id path string true "ID группы"
+// @Param       request body scim.PatchRequest true "Операции"
+// @Success     200 {object} scim.Group
+// @Failure     400 {object} scim.ErrorResponse
+// @Failure     401 {object} scim.ErrorResponse
+// @Failure     404 {object} scim.ErrorResponse
+// @Router      /scim/v2/Groups/{id} [patch]
+func (h *SCIMHandler) PatchGroup(c *gin.Context) {
+	var req scim.PatchRequest
+	if !bindSCIM(c, "SCIM.PatchGroup", &req) {
+		return
+	}
+
+	group, err := h.service.SCIMPatchGroup(c.Request.Context(), c.GetString("scimOrgID"), c.Param("id"), &req)
+	if err != nil {
+		respondSCIMError(c, "SCIM.PatchGroup", err)
+		return
+	}
+
+	respondSCIM(c, http.StatusOK, group)
+}
+
+// @Summary     Удаление группы SCIM
+// @Tags        scim
+// @Security    SCIMToken
+// @Param       id path string true "ID группы"
+// @Success     204
+// @Failure     401 {object} scim.ErrorResponse
+// @Failure     404 {object} scim.ErrorResponse
+// @Router      /scim/v2/Groups/{id} [delete]
+func (h *SCIMHandler) DeleteGroup(c *gin.Context) {
+	if err := h.service.SCIMDeleteGroup(c.Request.Context(), c.GetString("scimOrgID"), c.Param("id")); err != nil {
+		respondSCIMError(c, "SCIM.DeleteGroup", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// scimPage читает параметры startIndex и count
+func scimPage(c *gin.Context) (scim.Page, bool) {
+	startIndex, count := 1, scim.DefaultCount
+	for name, target := range map[string]*int{"startIndex": &startIndex, "count": &count} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			respondSCIM(c, http.StatusBadRequest, scim.NewError(http.StatusBadRequest, "invalidValue", "Invalid "+name))
+			return scim.Page{}, false
+		}
+		*target = n
+	}
+	return scim.NewPage(startIndex, count), true
+}
+
+func bindSCIM(c *gin.Context, op string, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.Printf("[%s] Ошибка декодирования запроса: %v", op, err)
+		respondSCIM(c, http.StatusBadRequest, scim.NewError(http.StatusBadRequest, "invalidSyntax", "Invalid request body"))
+		return false
+	}
+	return true
+}
+
+func respondSCIM(c *gin.Context, status int, body interface{}) {
+	// gin не перезаписывает заданный заранее Content-Type
+	c.Header("Content-Type", scim.ContentType)
+	c.JSON(status, body)
+}
+
+func respondSCIMError(c *gin.Context, op string, err error) {
+	log.Printf("[%s] Ошибка: %v", op, err)
+
+	status, scimType := http.StatusInternalServerError, ""
+	detail := err.Error()
+	switch {
+	case errors.Is(err, scim.ErrInvalidFilter):
+		status, scimType = http.StatusBadRequest, "invalidFilter"
+	case errors.Is(err, scim.ErrInvalidPath):
+		status, scimType = http.StatusBadRequest, "invalidPath"
+	case errors.Is(err, scim.ErrInvalidValue), errors.Is(err, scim.ErrInvalidPatch),
+		errors.Is(err, auth.ErrSCIMEmailDomain):
+		status, scimType = http.StatusBadRequest, "invalidValue"
+	case errors.Is(err, scim.ErrMutability), errors.Is(err, auth.ErrOwnerRemoval):
+		status, scimType = http.StatusBadRequest, "mutability"
+	case errors.Is(err, auth.ErrAlreadyOrgMember), errors.Is(err, auth.ErrSCIMGroupExists):
+		status, scimType = http.StatusConflict, "uniqueness"
+	case errors.Is(err, auth.ErrUserNotFound), errors.Is(err, auth.ErrSCIMGroupNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, auth.ErrSCIMUnauthorized):
+		status = http.StatusUnauthorized
+	default:
+		detail = "Internal server error"
+	}
+
+	respondSCIM(c, status, scim.NewError(status, scimType, detail))
+}
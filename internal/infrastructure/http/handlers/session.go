@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"kubercode/internal/domain/auth"
+	"kubercode/internal/infrastructure/http/session"
+)
+
+// SetSessionCookies включает режим cookie для браузерных клиентов
+func (h *AuthHandler) SetSessionCookies(cookies *session.Cookies) {
+	h.sessions = cookies
+}
+
+// SetSessionCookies включает режим cookie для браузерных клиентов
+func (h *OrganizationHandler) SetSessionCookies(cookies *session.Cookies) {
+	h.sessions = cookies
+}
+
+// respondTokens отправляет токены после входа. В режиме cookie refresh токен записывается
+// в HttpOnly cookie и не попадает в тело ответа
+func respondTokens(c *gin.Context, cookies *session.Cookies, op string, resp *auth.LoginResponse) {
+	if cookies.Requested(c) && resp.RefreshToken != "" {
+		if _, err := cookies.Set(c, resp.RefreshToken); err != nil {
+			log.Printf("[%s] Ошибка выдачи cookie сессии: %v", op, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		resp.RefreshToken = ""
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// bearerToken возвращает токен из заголовка Authorization без префикса Bearer
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
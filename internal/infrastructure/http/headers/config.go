@@ -0,0 +1,94 @@
+package headers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Set - значения заголовков безопасности. Пустое значение означает, что заголовок не отправляется
+type Set struct {
+	// ContentSecurityPolicy - политика CSP, в том числе frame-ancestors, запрещающая
+	// встраивать страницы сервиса во фреймы чужих сайтов
+	ContentSecurityPolicy string
+	ReferrerPolicy        string
+	// FrameOptions - X-Frame-Options для браузеров без поддержки frame-ancestors
+	FrameOptions string
+}
+
+// Route - заголовки для путей с префиксом PathPrefix. Пустые поля берутся из Config.Default
+type Route struct {
+	PathPrefix string
+	Set
+}
+
+// Config - настройки заголовков безопасности
+type Config struct {
+	Enabled bool
+	// HSTSMaxAge - срок Strict-Transport-Security, 0 отключает заголовок
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	Default               Set
+	Routes                []Route
+}
+
+// DefaultConfig возвращает строгие заголовки для API и ослабленную политику для Swagger UI,
+// которому нужны собственные скрипты, стили и картинки
+func DefaultConfig() Config {
+	return Config{
+		Enabled:               true,
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		Default: Set{
+			ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+			ReferrerPolicy:        "no-referrer",
+			FrameOptions:          "DENY",
+		},
+		Routes: []Route{{
+			PathPrefix: "/swagger/",
+			Set: Set{
+				ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'unsafe-inline'; " +
+					"style-src 'self' 'unsafe-inline'; img-src 'self' data:; frame-ancestors 'none'",
+				ReferrerPolicy: "same-origin",
+			},
+		}},
+	}
+}
+
+// HSTS возвращает значение Strict-Transport-Security или пустую строку
+func (c Config) HSTS() string {
+	if c.HSTSMaxAge <= 0 {
+		return ""
+	}
+	value := fmt.Sprintf("max-age=%d", int64(c.HSTSMaxAge.Seconds()))
+	if c.HSTSIncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	return value
+}
+
+// For возвращает заголовки для пути. Из нескольких подходящих маршрутов выбирается
+// маршрут с самым длинным префиксом
+func (c Config) For(path string) Set {
+	set := c.Default
+	var best *Route
+	for i := range c.Routes {
+		route := &c.Routes[i]
+		if strings.HasPrefix(path, route.PathPrefix) && (best == nil || len(route.PathPrefix) > len(best.PathPrefix)) {
+			best = route
+		}
+	}
+	if best == nil {
+		return set
+	}
+	if best.ContentSecurityPolicy != "" {
+		set.ContentSecurityPolicy = best.ContentSecurityPolicy
+	}
+	if best.ReferrerPolicy != "" {
+		set.ReferrerPolicy = best.ReferrerPolicy
+	}
+	if best.FrameOptions != "" {
+		set.FrameOptions = best.FrameOptions
+	}
+	return set
+}
@@ -0,0 +1,37 @@
+package headers
+
+import "testing"
+
+func TestFor(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Routes = append(cfg.Routes, Route{PathPrefix: "/swagger/embed/", Set: Set{FrameOptions: "SAMEORIGIN"}})
+
+	api := cfg.For("/api/v1/auth/login")
+	if api != cfg.Default {
+		t.Errorf("API route should use defaults, got %+v", api)
+	}
+
+	swagger := cfg.For("/swagger/index.html")
+	if swagger.ContentSecurityPolicy == cfg.Default.ContentSecurityPolicy || swagger.ReferrerPolicy != "same-origin" {
+		t.Errorf("swagger should have relaxed policy, got %+v", swagger)
+	}
+	if swagger.FrameOptions != "DENY" {
+		t.Errorf("empty override should keep default, got %q", swagger.FrameOptions)
+	}
+
+	embed := cfg.For("/swagger/embed/doc.json")
+	if embed.FrameOptions != "SAMEORIGIN" || embed.ContentSecurityPolicy != cfg.Default.ContentSecurityPolicy {
+		t.Errorf("longest prefix should win, got %+v", embed)
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	cfg := DefaultConfig()
+	if got := cfg.HSTS(); got != "max-age=31536000; includeSubDomains" {
+		t.Errorf("HSTS = %q", got)
+	}
+	cfg.HSTSMaxAge = 0
+	if got := cfg.HSTS(); got != "" {
+		t.Errorf("disabled HSTS = %q", got)
+	}
+}
@@ -39,6 +39,11 @@ func AuthMiddleware(authService *auth.Service) gin.HandlerFunc {
 		c.Set("userID", resp.ID.Hex())
 		c.Set("userEmail", resp.Email)
 		c.Set("userIsMentor", resp.IsMentor)
+		c.Set("userEmailVerified", resp.EmailVerified)
+		c.Set("userIsAdmin", resp.IsAdmin)
+		c.Set("userAuthTime", resp.AuthTime)
+		c.Set("orgID", resp.OrgID)
+		c.Set("orgRole", string(resp.OrgRole))
 
 		// Добавляем токен в контекст запроса
 		ctx := context.WithValue(c.Request.Context(), "token", token)
@@ -46,4 +51,31 @@ func AuthMiddleware(authService *auth.Service) gin.HandlerFunc {
 
 		c.Next()
 	}
-} 
\ No newline at end of file
+} 
+
+// RequireAdmin пропускает только администраторов. Должен стоять после AuthMiddleware
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("userIsAdmin") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRecentAuth пропускает только запросы с токеном, выпущенным недавно после ввода пароля.
+// Должен стоять после AuthMiddleware
+func RequireRecentAuth(authService *auth.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authService.IsRecentAuth(c.GetTime("userAuthTime")) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Re-authentication required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"kubercode/internal/domain/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientInfo сохраняет IP, User-Agent и идентификатор устройства клиента в контексте запроса для сервиса аутентификации
+func ClientInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := auth.WithClientInfo(c.Request.Context(), auth.ClientInfo{
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			DeviceID:  c.GetHeader("X-Device-ID"),
+		})
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
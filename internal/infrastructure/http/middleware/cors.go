@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"kubercode/internal/infrastructure/http/cors"
+)
+
+// CORS разрешает запросы из браузера с источников из настроек и отвечает на preflight запросы.
+// Запросы с неразрешенных источников проходят без заголовков CORS, и браузер не отдает ответ
+// странице, а preflight для них отклоняется
+func CORS(cfg cors.Config) gin.HandlerFunc {
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if !cfg.Enabled || origin == "" {
+			c.Next()
+			return
+		}
+		// Ответ зависит от источника, кеши не должны отдавать его другим источникам
+		c.Writer.Header().Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		allowed, ok := cfg.Match(origin)
+		if !ok {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if allowed.Pattern == "*" {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if allowed.Credentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if exposed != "" {
+				c.Header("Access-Control-Expose-Headers", exposed)
+			}
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+		c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		allowedMethods, allowedHeaders := cfg.AllowedMethods(allowed), cfg.AllowedHeaders(allowed)
+		if !cors.Allows(allowedMethods, c.GetHeader("Access-Control-Request-Method")) ||
+			!cors.Allows(allowedHeaders, requestedHeaders(c)...) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Header("Access-Control-Allow-Methods", strings.Join(allowedMethods, ", "))
+		c.Header("Access-Control-Allow-Headers", strings.Join(allowedHeaders, ", "))
+		c.Header("Access-Control-Max-Age", maxAge)
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+func requestedHeaders(c *gin.Context) []string {
+	var list []string
+	for _, h := range strings.Split(c.GetHeader("Access-Control-Request-Headers"), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			list = append(list, h)
+		}
+	}
+	return list
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"kubercode/internal/infrastructure/http/session"
+)
+
+// CSRF проверяет запросы, которые аутентифицируются refresh токеном из cookie: источник
+// запроса должен быть доверенным, а заголовок X-CSRF-Token совпадать с CSRF cookie.
+// Запросы без cookie (мобильные клиенты, токен в теле) браузер подделать не может, они пропускаются
+func CSRF(cookies *session.Cookies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cookies.RefreshToken(c) == "" {
+			c.Next()
+			return
+		}
+		if err := cookies.CheckCSRF(c); err != nil {
+			log.Printf("[CSRF] Отклонен запрос %s от %s: %v", c.Request.URL.Path, c.ClientIP(), err)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "CSRF check failed"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kubercode/internal/infrastructure/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRateLimitBody - сколько байт тела читается для поиска email и устройства
+const maxRateLimitBody = 64 << 10
+
+// RateLimit ограничивает частоту запросов к маршруту по политике policy. Ключи - IP клиента,
+// email или логин из тела запроса и идентификатор устройства (заголовок X-Device-ID или deviceToken).
+// Ответ содержит заголовки RateLimit-*, при превышении - 429 с Retry-After
+func RateLimit(limiter *ratelimit.Limiter, policy string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		if !limiter.Enabled(policy) || limiter.Exempt(ip) {
+			c.Next()
+			return
+		}
+
+		email, device := rateLimitIdentity(c)
+		result := limiter.Check(c.Request.Context(), policy, map[ratelimit.KeyKind]string{
+			ratelimit.KeyIP:     ip,
+			ratelimit.KeyEmail:  email,
+			ratelimit.KeyDevice: device,
+		})
+
+		if result.Limit > 0 {
+			c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+			c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			c.Header("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+		}
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitIdentity достает email (или логин) и устройство из JSON тела, не расходуя его для обработчика
+func rateLimitIdentity(c *gin.Context) (string, string) {
+	device := c.GetHeader("X-Device-ID")
+	if c.Request.Body == nil {
+		return "", device
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxRateLimitBody))
+	if err != nil {
+		return "", device
+	}
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+
+	var fields struct {
+		Email       string `json:"email"`
+		Login       string `json:"login"`
+		DeviceToken string `json:"deviceToken"`
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return "", device
+	}
+
+	email := fields.Email
+	if email == "" {
+		email = fields.Login
+	}
+	if device == "" {
+		device = fields.DeviceToken
+	}
+	return email, device
+}
+
+// seconds округляет длительность вверх до целых секунд
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"kubercode/internal/domain/auth"
+	"kubercode/internal/domain/auth/scim"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SCIMAuth проверяет bearer токен SCIM организации и сохраняет ее ID в контексте (scimOrgID).
+// Ошибки возвращаются в формате SCIM, который ожидают каталоги
+func SCIMAuth(authService *auth.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			abortSCIM(c, http.StatusUnauthorized, "Authorization header is required")
+			return
+		}
+
+		orgID, err := authService.AuthenticateSCIM(c.Request.Context(), parts[1])
+		if err != nil {
+			if errors.Is(err, auth.ErrSCIMUnauthorized) {
+				abortSCIM(c, http.StatusUnauthorized, "Invalid token")
+			} else {
+				abortSCIM(c, http.StatusInternalServerError, "Internal server error")
+			}
+			return
+		}
+
+		c.Set("scimOrgID", orgID)
+		c.Next()
+	}
+}
+
+func abortSCIM(c *gin.Context, status int, detail string) {
+	if status == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+	}
+	// gin не перезаписывает заданный заранее Content-Type
+	c.Header("Content-Type", scim.ContentType)
+	c.AbortWithStatusJSON(status, scim.NewError(status, "", detail))
+}
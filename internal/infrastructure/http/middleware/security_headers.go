@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"kubercode/internal/infrastructure/http/headers"
+)
+
+// SecurityHeaders добавляет к ответам заголовки безопасности: HSTS, X-Content-Type-Options,
+// CSP с frame-ancestors и Referrer-Policy. Значения зависят от маршрута, см. headers.Config.Routes
+func SecurityHeaders(cfg headers.Config) gin.HandlerFunc {
+	hsts := cfg.HSTS()
+
+	return func(c *gin.Context) {
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+		set := cfg.For(c.Request.URL.Path)
+
+		h := c.Writer.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		if hsts != "" {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		if set.ContentSecurityPolicy != "" {
+			h.Set("Content-Security-Policy", set.ContentSecurityPolicy)
+		}
+		if set.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", set.ReferrerPolicy)
+		}
+		if set.FrameOptions != "" {
+			h.Set("X-Frame-Options", set.FrameOptions)
+		}
+		c.Next()
+	}
+}
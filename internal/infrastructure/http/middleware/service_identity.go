@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"kubercode/internal/domain/auth"
+	"kubercode/internal/infrastructure/tlsutil"
+)
+
+// ServiceIdentity определяет внутренний сервис по клиентскому сертификату mTLS. Сертификат уже
+// проверен TLS сервером по доверенным CA, здесь он только сопоставляется с именем сервиса
+func ServiceIdentity(identities tlsutil.Identities) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Client certificate is required"})
+			return
+		}
+		cert := c.Request.TLS.VerifiedChains[0][0]
+		service, ok := identities.Match(cert)
+		if !ok {
+			log.Printf("[ServiceIdentity] Неизвестный клиентский сертификат %q от %s", cert.Subject.String(), c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Unknown service certificate"})
+			return
+		}
+
+		ctx := auth.WithClientInfo(c.Request.Context(), auth.ClientInfo{
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			Service:   service,
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Set("serviceIdentity", service)
+
+		c.Next()
+	}
+}
+
+// RequireService пропускает только перечисленные сервисы. Должен стоять после ServiceIdentity
+func RequireService(services []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(services))
+	for _, service := range services {
+		allowed[service] = true
+	}
+	return func(c *gin.Context) {
+		if !allowed[c.GetString("serviceIdentity")] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Service is not allowed"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -7,12 +7,24 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	"kubercode/internal/domain/auth"
+	"kubercode/internal/infrastructure/http/cors"
 	"kubercode/internal/infrastructure/http/handlers"
+	"kubercode/internal/infrastructure/http/headers"
 	"kubercode/internal/infrastructure/http/middleware"
+	"kubercode/internal/infrastructure/http/session"
+	"kubercode/internal/infrastructure/ratelimit"
+	"kubercode/internal/infrastructure/tlsutil"
 )
 
-func NewRouter(authHandler *handlers.AuthHandler, authService *auth.Service, redis *redis.Client) *gin.Engine {
+func NewRouter(authHandler *handlers.AuthHandler, adminHandler *handlers.AdminHandler,
+	orgHandler *handlers.OrganizationHandler, scimHandler *handlers.SCIMHandler, authService *auth.Service,
+	redis *redis.Client, rateLimits ratelimit.Config, sessionCookies *session.Cookies,
+	corsConfig cors.Config, securityHeaders headers.Config) *gin.Engine {
 	router := gin.Default()
+	// CORS подключается до маршрутов, чтобы отвечать на preflight запросы, для которых маршрутов нет
+	router.Use(middleware.SecurityHeaders(securityHeaders), middleware.CORS(corsConfig), middleware.ClientInfo())
+
+	limiter := ratelimit.NewLimiter(redis, rateLimits)
 
 	// Swagger
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -24,11 +36,31 @@ func NewRouter(authHandler *handlers.AuthHandler, authService *auth.Service, red
 		auth := v1.Group("/auth")
 		{
 			// Публичные маршруты
-			auth.POST("/signup", authHandler.SignUp)
-			auth.POST("/login", authHandler.Login)
-			auth.POST("/restore-password", authHandler.RestorePassword)
-			auth.POST("/otp/send", authHandler.SendOTP)
-			auth.POST("/otp/verify", authHandler.VerifyOTP)
+			auth.POST("/signup", middleware.RateLimit(limiter, ratelimit.PolicySignup), authHandler.SignUp)
+			auth.POST("/login", middleware.RateLimit(limiter, ratelimit.PolicyLogin), authHandler.Login)
+			// Refresh токен передается в теле, в заголовке или в cookie, поэтому маршрут не требует access токена
+			auth.POST("/refresh", middleware.RateLimit(limiter, ratelimit.PolicyRefresh),
+				middleware.CSRF(sessionCookies), authHandler.RefreshToken)
+			auth.POST("/restore-password", middleware.RateLimit(limiter, ratelimit.PolicyCodeSend), authHandler.RestorePassword)
+			auth.POST("/restore-password/confirm", middleware.RateLimit(limiter, ratelimit.PolicyCodeConfirm),
+				authHandler.ConfirmRestorePassword)
+			auth.POST("/change-email/revert", middleware.RateLimit(limiter, ratelimit.PolicyActionLink),
+				authHandler.RevertChangeEmail)
+			auth.POST("/login/report", middleware.RateLimit(limiter, ratelimit.PolicyActionLink), authHandler.ReportLogin)
+			auth.POST("/otp/send", middleware.RateLimit(limiter, ratelimit.PolicyOTPSend), authHandler.SendOTP)
+			auth.POST("/otp/verify", middleware.RateLimit(limiter, ratelimit.PolicyOTPVerify), authHandler.VerifyOTP)
+			auth.POST("/verify-email", middleware.RateLimit(limiter, ratelimit.PolicyActionLink), authHandler.VerifyEmail)
+			auth.POST("/unlock", middleware.RateLimit(limiter, ratelimit.PolicyActionLink), authHandler.UnlockLogin)
+			auth.POST("/verify-email/resend", middleware.RateLimit(limiter, ratelimit.PolicyCodeSend),
+				authHandler.ResendVerificationEmail)
+			auth.GET("/export/download", authHandler.DownloadDataExport)
+			auth.GET("/username/available", authHandler.CheckUsername)
+			auth.GET("/oauth/:provider", authHandler.StartExternalLogin)
+			auth.POST("/oauth/:provider/callback", middleware.RateLimit(limiter, ratelimit.PolicyOAuthCallback),
+				authHandler.CompleteExternalLogin)
+			auth.GET("/sso/discover", authHandler.DiscoverSSO)
+			auth.POST("/sso/callback", middleware.RateLimit(limiter, ratelimit.PolicyOAuthCallback),
+				authHandler.CompleteSSOLogin)
 
 			// Защищенные маршруты
 			protected := auth.Group("")
@@ -37,12 +69,125 @@ func NewRouter(authHandler *handlers.AuthHandler, authService *auth.Service, red
 				protected.GET("/verify", authHandler.VerifyToken)
 				protected.POST("/change-password", authHandler.ChangePassword)
 				protected.POST("/change-email", authHandler.ChangeEmail)
+				protected.POST("/change-email/confirm", middleware.RateLimit(limiter, ratelimit.PolicyCodeConfirm),
+					authHandler.ConfirmChangeEmail)
 				protected.POST("/logout", authHandler.Logout)
-				protected.POST("/refresh", authHandler.RefreshToken)
 				protected.POST("/logout-all", authHandler.LogoutFromAllDevices)
+				protected.POST("/me/delete", authHandler.DeleteAccount)
+				protected.POST("/me/delete/cancel", authHandler.CancelDeleteAccount)
+				protected.GET("/me", authHandler.GetMe)
+				protected.PATCH("/me", authHandler.UpdateMe)
+				protected.PUT("/me/username", authHandler.ChangeUsername)
+				protected.GET("/me/export", middleware.RequireRecentAuth(authService), authHandler.ExportData)
+				protected.GET("/me/identities", authHandler.GetIdentities)
+				protected.POST("/me/identities/:provider", authHandler.LinkIdentity)
+				protected.DELETE("/me/identities/:provider", authHandler.UnlinkIdentity)
+				protected.POST("/switch-org", orgHandler.SwitchOrganization)
+				protected.POST("/saml/login/:request", authHandler.CompleteSAMLLogin)
+				protected.POST("/saml/idp-initiated/:sp", authHandler.StartIdPInitiatedSAML)
+				protected.POST("/saml/logout", authHandler.SAMLLogout)
+			}
+		}
+
+		// SAML identity provider
+		saml := v1.Group("/saml")
+		{
+			saml.GET("/metadata", authHandler.SAMLMetadata)
+			saml.GET("/sso", authHandler.SAMLSingleSignOn)
+			saml.GET("/slo", authHandler.SAMLSingleLogout)
+		}
+
+		// Организации
+		orgs := v1.Group("/orgs")
+		{
+			orgs.POST("/invitations/decline", orgHandler.DeclineInvitation)
+
+			protected := orgs.Group("")
+			protected.Use(middleware.AuthMiddleware(authService))
+			{
+				protected.POST("", orgHandler.CreateOrganization)
+				protected.GET("", orgHandler.GetOrganizations)
+				protected.POST("/invitations/accept", orgHandler.AcceptInvitation)
+				protected.GET("/:id/members", orgHandler.GetMembers)
+				protected.POST("/:id/invitations", orgHandler.InviteMember)
+				protected.DELETE("/:id/members/:userId", orgHandler.RemoveMember)
+				protected.POST("/:id/owner", orgHandler.TransferOwnership)
+				protected.GET("/:id/sso", orgHandler.GetSSO)
+				protected.PUT("/:id/sso", orgHandler.ConfigureSSO)
+				protected.DELETE("/:id/sso", orgHandler.DeleteSSO)
+				protected.GET("/:id/domains", orgHandler.GetDomains)
+				protected.POST("/:id/domains", orgHandler.AddDomain)
+				protected.POST("/:id/domains/:domain/verify", orgHandler.VerifyDomain)
+				protected.DELETE("/:id/domains/:domain", orgHandler.RemoveDomain)
+				protected.GET("/:id/scim/tokens", orgHandler.GetSCIMTokens)
+				protected.POST("/:id/scim/tokens", orgHandler.CreateSCIMToken)
+				protected.DELETE("/:id/scim/tokens/:tokenId", orgHandler.RevokeSCIMToken)
 			}
 		}
+
+		// Admin группа
+		admin := v1.Group("/admin")
+		admin.Use(middleware.AuthMiddleware(authService), middleware.RequireAdmin())
+		adminRoutes(admin, adminHandler)
+	}
+
+	// SCIM 2.0 для каталогов организаций, путь фиксирован стандартом и не входит в /api/v1
+	scim := router.Group("/scim/v2")
+	scim.Use(middleware.SCIMAuth(authService))
+	{
+		scim.GET("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+		scim.GET("/Users", scimHandler.ListUsers)
+		scim.POST("/Users", scimHandler.CreateUser)
+		scim.GET("/Users/:id", scimHandler.GetUser)
+		scim.PUT("/Users/:id", scimHandler.ReplaceUser)
+		scim.PATCH("/Users/:id", scimHandler.PatchUser)
+		scim.DELETE("/Users/:id", scimHandler.DeleteUser)
+		scim.GET("/Groups", scimHandler.ListGroups)
+		scim.POST("/Groups", scimHandler.CreateGroup)
+		scim.GET("/Groups/:id", scimHandler.GetGroup)
+		scim.PUT("/Groups/:id", scimHandler.ReplaceGroup)
+		scim.PATCH("/Groups/:id", scimHandler.PatchGroup)
+		scim.DELETE("/Groups/:id", scimHandler.DeleteGroup)
+	}
+
+	return router
+} 
+
+// NewInternalRouter создает роутер mTLS listener для внутренних сервисов: проверка токенов
+// и администрирование без пользовательского токена. Сервис определяется по клиентскому сертификату,
+// администрирование доступно только сервисам из adminServices
+func NewInternalRouter(authHandler *handlers.AuthHandler, adminHandler *handlers.AdminHandler,
+	identities tlsutil.Identities, adminServices []string) *gin.Engine {
+	router := gin.Default()
+	router.Use(middleware.ServiceIdentity(identities))
+
+	internal := router.Group("/internal/v1")
+	{
+		internal.POST("/introspect", authHandler.IntrospectToken)
+
+		admin := internal.Group("/admin")
+		admin.Use(middleware.RequireService(adminServices))
+		adminRoutes(admin, adminHandler)
 	}
 
 	return router
-} 
\ No newline at end of file
+}
+
+// adminRoutes регистрирует маршруты администрирования
+func adminRoutes(admin *gin.RouterGroup, adminHandler *handlers.AdminHandler) {
+	admin.DELETE("/accounts/:id", adminHandler.EraseAccount)
+	admin.POST("/accounts/:id/disable", adminHandler.DisableAccount)
+	admin.POST("/accounts/:id/enable", adminHandler.EnableAccount)
+	admin.POST("/accounts/:id/lock", adminHandler.LockAccount)
+	admin.POST("/accounts/:id/unlock", adminHandler.UnlockAccount)
+	admin.POST("/accounts/:id/ban", adminHandler.BanAccount)
+	admin.POST("/accounts/:id/unban", adminHandler.UnbanAccount)
+	admin.POST("/accounts/:id/force-password-reset", adminHandler.ForcePasswordReset)
+	admin.GET("/metrics/password-hashing", adminHandler.GetHashingMetrics)
+	admin.GET("/audit", adminHandler.GetAuditLog)
+	admin.GET("/audit/export", adminHandler.ExportAuditLog)
+	admin.GET("/audit/verify", adminHandler.VerifyAuditLog)
+	admin.GET("/saml/service-providers", adminHandler.GetSAMLServiceProviders)
+	admin.POST("/saml/service-providers", adminHandler.RegisterSAMLServiceProvider)
+	admin.DELETE("/saml/service-providers/:id", adminHandler.DeleteSAMLServiceProvider)
+}
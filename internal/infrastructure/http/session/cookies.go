@@ -0,0 +1,216 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrCSRFOrigin = errors.New("request origin is not trusted")
+	ErrCSRFToken  = errors.New("csrf token is missing or does not match")
+)
+
+const (
+	// ModeHeader - заголовок, которым браузерный клиент запрашивает режим cookie
+	ModeHeader = "X-Session-Mode"
+	// ModeCookie - значение ModeHeader для режима cookie
+	ModeCookie = "cookie"
+	// CSRFHeader - заголовок, в котором клиент повторяет значение CSRF cookie
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// Config - настройки режима cookie для браузерных клиентов
+type Config struct {
+	// Enabled - разрешить клиентам режим cookie. Без него refresh токен всегда возвращается в теле ответа
+	Enabled bool
+	// RefreshCookie - имя HttpOnly cookie с refresh токеном
+	RefreshCookie string
+	// CSRFCookie - имя cookie с CSRF токеном, доступной JavaScript
+	CSRFCookie string
+	// OAuthCookie - имя HttpOnly cookie, привязывающей авторизацию у внешнего провайдера к браузеру.
+	// Выдается всем клиентам, независимо от Enabled
+	OAuthCookie string
+	// Domain - домен cookie, например ".kubercode.ru", чтобы сессия работала на поддоменах.
+	// Пустой - cookie только для домена API
+	Domain string
+	// RefreshPath - путь, на который браузер отправляет cookie с refresh токеном
+	RefreshPath string
+	SameSite    http.SameSite
+	// Secure - отправлять cookie только по HTTPS. Отключается только для локальной разработки
+	Secure bool
+	// MaxAge - время жизни cookie, совпадает со временем жизни refresh токена
+	MaxAge time.Duration
+	// TrustedOrigins - источники фронтендов, с которых разрешены запросы с cookie,
+	// например "https://app.kubercode.ru". Источник самого API разрешен всегда
+	TrustedOrigins []string
+}
+
+// DefaultConfig возвращает настройки по умолчанию, режим cookie выключен
+func DefaultConfig() Config {
+	return Config{
+		RefreshCookie: "kc_refresh",
+		CSRFCookie:    "kc_csrf",
+		OAuthCookie:   "kc_oauth",
+		RefreshPath:   "/api/v1/auth",
+		SameSite:      http.SameSiteStrictMode,
+		Secure:        true,
+		MaxAge:        30 * 24 * time.Hour,
+	}
+}
+
+// ParseSameSite разбирает значение SameSite: strict, lax или none
+func ParseSameSite(value string) (http.SameSite, bool) {
+	switch strings.ToLower(value) {
+	case "strict":
+		return http.SameSiteStrictMode, true
+	case "lax":
+		return http.SameSiteLaxMode, true
+	case "none":
+		return http.SameSiteNoneMode, true
+	}
+	return 0, false
+}
+
+// Cookies выдает и проверяет cookie сессии браузерного клиента
+type Cookies struct {
+	cfg Config
+}
+
+// New создает Cookies
+func New(cfg Config) *Cookies {
+	return &Cookies{cfg: cfg}
+}
+
+// Requested сообщает, что клиент запросил режим cookie и режим разрешен
+func (k *Cookies) Requested(c *gin.Context) bool {
+	return k != nil && k.cfg.Enabled && strings.EqualFold(c.GetHeader(ModeHeader), ModeCookie)
+}
+
+// Set сохраняет refresh токен в HttpOnly cookie и выдает новый CSRF токен. CSRF токен
+// записывается в cookie, доступную JavaScript, и возвращается для заголовка ответа
+func (k *Cookies) Set(c *gin.Context, refreshToken string) (string, error) {
+	csrf, err := newCSRFToken()
+	if err != nil {
+		return "", err
+	}
+	maxAge := int(k.cfg.MaxAge.Seconds())
+	http.SetCookie(c.Writer, k.cookie(k.cfg.RefreshCookie, refreshToken, k.cfg.RefreshPath, maxAge, true))
+	http.SetCookie(c.Writer, k.cookie(k.cfg.CSRFCookie, csrf, "/", maxAge, false))
+	c.Header(CSRFHeader, csrf)
+	return csrf, nil
+}
+
+// Clear удаляет cookie сессии
+func (k *Cookies) Clear(c *gin.Context) {
+	if k == nil || !k.cfg.Enabled {
+		return
+	}
+	http.SetCookie(c.Writer, k.cookie(k.cfg.RefreshCookie, "", k.cfg.RefreshPath, -1, true))
+	http.SetCookie(c.Writer, k.cookie(k.cfg.CSRFCookie, "", "/", -1, false))
+}
+
+// RefreshToken возвращает refresh токен из cookie или пустую строку
+func (k *Cookies) RefreshToken(c *gin.Context) string {
+	if k == nil || !k.cfg.Enabled {
+		return ""
+	}
+	token, err := c.Cookie(k.cfg.RefreshCookie)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// SetOAuthBinding сохраняет секрет авторизации у внешнего провайдера в HttpOnly cookie на время ttl
+func (k *Cookies) SetOAuthBinding(c *gin.Context, binding string, ttl time.Duration) {
+	if k == nil {
+		return
+	}
+	http.SetCookie(c.Writer, k.cookie(k.cfg.OAuthCookie, binding, k.cfg.RefreshPath, int(ttl.Seconds()), true))
+}
+
+// OAuthBinding возвращает секрет авторизации у внешнего провайдера и удаляет cookie: state одноразовый
+func (k *Cookies) OAuthBinding(c *gin.Context) string {
+	if k == nil {
+		return ""
+	}
+	binding, err := c.Cookie(k.cfg.OAuthCookie)
+	if err != nil {
+		return ""
+	}
+	http.SetCookie(c.Writer, k.cookie(k.cfg.OAuthCookie, "", k.cfg.RefreshPath, -1, true))
+	return binding
+}
+
+// CheckCSRF проверяет запрос, аутентифицированный cookie: источник из Origin (или Referer)
+// должен быть доверенным, а заголовок X-CSRF-Token - совпадать с CSRF cookie (double submit)
+func (k *Cookies) CheckCSRF(c *gin.Context) error {
+	if origin := requestOrigin(c.Request); origin != "" && !k.trustedOrigin(c.Request, origin) {
+		return ErrCSRFOrigin
+	}
+
+	cookie, err := c.Cookie(k.cfg.CSRFCookie)
+	header := c.GetHeader(CSRFHeader)
+	if err != nil || cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+		return ErrCSRFToken
+	}
+	return nil
+}
+
+func (k *Cookies) cookie(name, value, path string, maxAge int, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   k.cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   k.cfg.Secure,
+		HttpOnly: httpOnly,
+		SameSite: k.cfg.SameSite,
+	}
+}
+
+// requestOrigin возвращает источник запроса из Origin, а если его нет - из Referer
+func requestOrigin(r *http.Request) string {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		return origin
+	}
+	referer, err := url.Parse(r.Header.Get("Referer"))
+	if err != nil || referer.Host == "" {
+		return ""
+	}
+	return referer.Scheme + "://" + referer.Host
+}
+
+func (k *Cookies) trustedOrigin(r *http.Request, origin string) bool {
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	// Запрос со страницы самого API
+	if strings.EqualFold(parsed.Host, r.Host) {
+		return true
+	}
+	for _, trusted := range k.cfg.TrustedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(trusted, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
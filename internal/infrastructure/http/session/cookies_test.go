@@ -0,0 +1,88 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testContext(headers map[string]string, cookies ...*http.Cookie) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "http://api.kubercode.ru/api/v1/auth/refresh", nil)
+	for name, value := range headers {
+		c.Request.Header.Set(name, value)
+	}
+	for _, cookie := range cookies {
+		c.Request.AddCookie(cookie)
+	}
+	return c
+}
+
+func TestSetCookies(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Enabled = true
+	cfg.Domain = ".kubercode.ru"
+	k := New(cfg)
+
+	c := testContext(map[string]string{ModeHeader: "cookie"})
+	if !k.Requested(c) {
+		t.Fatal("cookie mode should be requested")
+	}
+	csrf, err := k.Set(c, "refresh-jwt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var refresh, csrfCookie string
+	for _, header := range c.Writer.Header().Values("Set-Cookie") {
+		switch {
+		case strings.HasPrefix(header, "kc_refresh="):
+			refresh = header
+		case strings.HasPrefix(header, "kc_csrf="):
+			csrfCookie = header
+		}
+	}
+	for _, attr := range []string{"Path=/api/v1/auth", "Domain=kubercode.ru", "HttpOnly", "Secure", "SameSite=Strict"} {
+		if !strings.Contains(refresh, attr) {
+			t.Errorf("refresh cookie %q lacks %s", refresh, attr)
+		}
+	}
+	if strings.Contains(csrfCookie, "HttpOnly") || !strings.Contains(csrfCookie, "kc_csrf="+csrf) {
+		t.Errorf("csrf cookie %q should be readable and carry the token", csrfCookie)
+	}
+}
+
+func TestCheckCSRF(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Enabled = true
+	cfg.TrustedOrigins = []string{"https://app.kubercode.ru"}
+	k := New(cfg)
+	token := &http.Cookie{Name: "kc_csrf", Value: "secret"}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		cookies []*http.Cookie
+		want    error
+	}{
+		{"trusted origin", map[string]string{"Origin": "https://app.kubercode.ru", CSRFHeader: "secret"}, []*http.Cookie{token}, nil},
+		{"same origin", map[string]string{"Origin": "http://api.kubercode.ru", CSRFHeader: "secret"}, []*http.Cookie{token}, nil},
+		{"no origin", map[string]string{CSRFHeader: "secret"}, []*http.Cookie{token}, nil},
+		{"foreign origin", map[string]string{"Origin": "https://evil.example", CSRFHeader: "secret"}, []*http.Cookie{token}, ErrCSRFOrigin},
+		{"foreign referer", map[string]string{"Referer": "https://evil.example/page", CSRFHeader: "secret"}, []*http.Cookie{token}, ErrCSRFOrigin},
+		{"missing header", map[string]string{"Origin": "https://app.kubercode.ru"}, []*http.Cookie{token}, ErrCSRFToken},
+		{"wrong header", map[string]string{CSRFHeader: "guess"}, []*http.Cookie{token}, ErrCSRFToken},
+		{"missing cookie", map[string]string{CSRFHeader: "secret"}, nil, ErrCSRFToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := k.CheckCSRF(testContext(tt.headers, tt.cookies...)); err != tt.want {
+				t.Errorf("CheckCSRF = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"strings"
+
+	"kubercode/internal/domain/auth/email"
+)
+
+// SMTPMailer отправляет письма через SMTP сервер. Реализует email.EmailSender
+type SMTPMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+var _ email.EmailSender = (*SMTPMailer)(nil)
+
+// NewSMTPMailer создает новый SMTPMailer. Если username пустой, авторизация на сервере не выполняется
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+// SendEmail отправляет HTML письмо
+func (m *SMTPMailer) SendEmail(to string, subject string, body string) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	return m.send(auth, to, subject, body)
+}
+
+// SendTestEmail отправляет письмо без авторизации (например, в MailHog)
+func (m *SMTPMailer) SendTestEmail(to string, subject string, body string) error {
+	return m.send(nil, to, subject, body)
+}
+
+func (m *SMTPMailer) send(auth smtp.Auth, to string, subject string, body string) error {
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("From: %s\r\n", m.from))
+	msg.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	// Заголовки допускают только ASCII, поэтому тема на кириллице кодируется по RFC 2047
+	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject)))
+	msg.WriteString("MIME-version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(body)
+
+	addr := m.host + ":" + m.port
+	if err := smtp.SendMail(addr, auth, m.from, []string{to}, []byte(msg.String())); err != nil {
+		log.Printf("[Mailer] Ошибка при отправке письма на %s: %v", to, err)
+		return fmt.Errorf("ошибка при отправке письма: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"kubercode/internal/domain/auth/push"
+)
+
+// GatewaySender отправляет уведомления через HTTP шлюз, который доставляет их в FCM или APNs.
+// Реализует push.Sender
+type GatewaySender struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+var _ push.Sender = (*GatewaySender)(nil)
+
+// NewGatewaySender создает GatewaySender. Если token не пустой, он передается в заголовке Authorization
+func NewGatewaySender(url, token string) *GatewaySender {
+	return &GatewaySender{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type gatewayMessage struct {
+	DeviceToken string `json:"device_token"`
+	push.Notification
+}
+
+// Send отправляет уведомление на устройство
+func (g *GatewaySender) Send(ctx context.Context, deviceToken string, notification push.Notification) error {
+	body, err := json.Marshal(gatewayMessage{DeviceToken: deviceToken, Notification: notification})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("push gateway responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRule = errors.New("invalid rate limit rule")
+
+// KeyKind - признак, по которому считаются запросы
+type KeyKind string
+
+const (
+	KeyIP     KeyKind = "ip"
+	KeyEmail  KeyKind = "email"
+	KeyDevice KeyKind = "device"
+)
+
+// Имена политик маршрутов
+const (
+	PolicyLogin     = "login"
+	PolicySignup    = "signup"
+	PolicyOTPSend   = "otp_send"
+	PolicyOTPVerify = "otp_verify"
+	PolicyRefresh   = "refresh"
+	// PolicyCodeSend - отправка кодов и писем: восстановление пароля, повторное письмо подтверждения
+	PolicyCodeSend = "code_send"
+	// PolicyCodeConfirm - ввод 6-значного кода из письма
+	PolicyCodeConfirm = "code_confirm"
+	// PolicyActionLink - ссылки из писем: подтверждение email, разблокировка, отмена смены email,
+	// сообщение о чужом входе
+	PolicyActionLink = "action_link"
+	// PolicyOAuthCallback - завершение входа через внешнего или корпоративного провайдера
+	PolicyOAuthCallback = "oauth_callback"
+)
+
+// Rule - не больше Limit запросов с одним значением ключа за скользящее окно Window
+type Rule struct {
+	Key    KeyKind
+	Limit  int
+	Window time.Duration
+}
+
+// Config - политики ограничения по маршрутам и сети, на которые ограничения не действуют
+type Config struct {
+	Enabled   bool
+	Policies  map[string][]Rule
+	Allowlist []*net.IPNet
+}
+
+// DefaultConfig ограничивает подбор пароля и кодов по IP, email и устройству.
+// Ограничение по IP мягче, чтобы не блокировать пользователей за общим NAT
+func DefaultConfig() Config {
+	allowlist, _ := ParseAllowlist([]string{"127.0.0.0/8", "::1/128"})
+	return Config{
+		Enabled: true,
+		Policies: map[string][]Rule{
+			PolicyLogin: {
+				{Key: KeyIP, Limit: 30, Window: time.Minute},
+				{Key: KeyEmail, Limit: 10, Window: 15 * time.Minute},
+				{Key: KeyDevice, Limit: 20, Window: 15 * time.Minute},
+			},
+			PolicySignup: {
+				{Key: KeyIP, Limit: 10, Window: time.Hour},
+				{Key: KeyDevice, Limit: 5, Window: time.Hour},
+			},
+			PolicyOTPSend: {
+				{Key: KeyIP, Limit: 10, Window: 10 * time.Minute},
+				{Key: KeyEmail, Limit: 5, Window: 10 * time.Minute},
+			},
+			PolicyOTPVerify: {
+				{Key: KeyIP, Limit: 30, Window: 10 * time.Minute},
+				{Key: KeyEmail, Limit: 10, Window: 10 * time.Minute},
+			},
+			PolicyRefresh: {
+				{Key: KeyIP, Limit: 60, Window: time.Minute},
+				{Key: KeyDevice, Limit: 30, Window: time.Minute},
+			},
+			PolicyCodeSend: {
+				{Key: KeyIP, Limit: 10, Window: 10 * time.Minute},
+				{Key: KeyEmail, Limit: 5, Window: 10 * time.Minute},
+			},
+			PolicyCodeConfirm: {
+				{Key: KeyIP, Limit: 30, Window: 10 * time.Minute},
+				{Key: KeyEmail, Limit: 10, Window: 10 * time.Minute},
+			},
+			PolicyActionLink: {
+				{Key: KeyIP, Limit: 30, Window: 10 * time.Minute},
+			},
+			PolicyOAuthCallback: {
+				{Key: KeyIP, Limit: 30, Window: time.Minute},
+			},
+		},
+		Allowlist: allowlist,
+	}
+}
+
+// ParseRules разбирает правила политики в формате "ip:30/1m,email:10/15m".
+// Пустая строка означает, что маршрут не ограничивается
+func ParseRules(spec string) ([]Rule, error) {
+	rules := []Rule{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		key, rest, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, item)
+		}
+		limit, window, ok := strings.Cut(rest, "/")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, item)
+		}
+
+		rule := Rule{Key: KeyKind(strings.ToLower(strings.TrimSpace(key)))}
+		if rule.Key != KeyIP && rule.Key != KeyEmail && rule.Key != KeyDevice {
+			return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidRule, key)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(limit))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, item)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(window))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, item)
+		}
+		rule.Limit, rule.Window = n, d
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// FormatRules переводит правила обратно в строку для значения по умолчанию
+func FormatRules(rules []Rule) string {
+	parts := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		parts = append(parts, fmt.Sprintf("%s:%d/%s", rule.Key, rule.Limit, rule.Window))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseAllowlist разбирает список сетей в нотации CIDR. Адрес без маски - одна сеть из одного адреса
+func ParseAllowlist(cidrs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid network %q", cidr)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Result - итог проверки запроса по всем правилам политики. Limit, Remaining и Reset
+// относятся к самому строгому из сработавших правил
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Limiter проверяет запросы по политикам маршрутов. Журнал запросов хранится в Redis,
+// при его отсутствии или ошибке - в памяти процесса
+type Limiter struct {
+	cfg      Config
+	store    Store
+	fallback Store
+	now      func() time.Time
+}
+
+// NewLimiter создает ограничитель. client может быть nil
+func NewLimiter(client *redis.Client, cfg Config) *Limiter {
+	l := &Limiter{cfg: cfg, fallback: NewMemoryStore(), now: time.Now}
+	if client != nil {
+		l.store = NewRedisStore(client)
+	}
+	return l
+}
+
+// Enabled сообщает, есть ли у маршрута правила
+func (l *Limiter) Enabled(policy string) bool {
+	return l != nil && l.cfg.Enabled && len(l.cfg.Policies[policy]) > 0
+}
+
+// Exempt сообщает, что адрес входит во внутренние сети, на которые ограничения не действуют
+func (l *Limiter) Exempt(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, network := range l.cfg.Allowlist {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check учитывает запрос по каждому правилу политики, для которого известно значение ключа.
+// Запрос отклоняется, если исчерпан лимит хотя бы одного правила
+func (l *Limiter) Check(ctx context.Context, policy string, keys map[KeyKind]string) Result {
+	now := l.now()
+	result := Result{Allowed: true}
+	first := true
+
+	for _, rule := range l.cfg.Policies[policy] {
+		value := keys[rule.Key]
+		if value == "" {
+			continue
+		}
+
+		key := storageKey(policy, rule, value)
+		allowed, count, reset, err := l.take(ctx, key, rule, now)
+		if err != nil {
+			log.Printf("[RateLimit] Ошибка проверки %s: %v", policy, err)
+			continue
+		}
+
+		remaining := rule.Limit - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		wait := reset.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+
+		if !allowed {
+			if result.Allowed || wait > result.RetryAfter {
+				result = Result{Limit: rule.Limit, Remaining: 0, Reset: wait, RetryAfter: wait}
+			}
+			continue
+		}
+		if result.Allowed && (first || remaining < result.Remaining) {
+			result.Limit, result.Remaining, result.Reset = rule.Limit, remaining, wait
+			first = false
+		}
+	}
+	return result
+}
+
+func (l *Limiter) take(ctx context.Context, key string, rule Rule, now time.Time) (bool, int, time.Time, error) {
+	if l.store != nil {
+		allowed, count, reset, err := l.store.Take(ctx, key, rule.Limit, rule.Window, now)
+		if err == nil {
+			return allowed, count, reset, nil
+		}
+		log.Printf("[RateLimit] Redis недоступен, используется журнал в памяти: %v", err)
+	}
+	return l.fallback.Take(ctx, key, rule.Limit, rule.Window, now)
+}
+
+// storageKey строит ключ журнала. Email и идентификатор устройства хешируются,
+// чтобы в Redis не попадали персональные данные
+func storageKey(policy string, rule Rule, value string) string {
+	if rule.Key != KeyIP {
+		sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(value))))
+		value = hex.EncodeToString(sum[:16])
+	}
+	return "ratelimit:" + policy + ":" + string(rule.Key) + ":" + rule.Window.String() + ":" + value
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("ip:30/1m, email:10/15m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[1] != (Rule{Key: KeyEmail, Limit: 10, Window: 15 * time.Minute}) {
+		t.Errorf("rules = %+v", rules)
+	}
+	if FormatRules(rules) != "ip:30/1m0s,email:10/15m0s" {
+		t.Errorf("FormatRules = %q", FormatRules(rules))
+	}
+
+	for _, spec := range []string{"ip:30", "user:1/1m", "ip:0/1m", "ip:5/soon"} {
+		if _, err := ParseRules(spec); err == nil {
+			t.Errorf("ParseRules(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestLimiterSlidingWindow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(nil, Config{
+		Enabled: true,
+		Policies: map[string][]Rule{
+			PolicyLogin: {
+				{Key: KeyIP, Limit: 5, Window: time.Minute},
+				{Key: KeyEmail, Limit: 2, Window: time.Minute},
+			},
+		},
+	})
+	l.now = func() time.Time { return now }
+	keys := map[KeyKind]string{KeyIP: "203.0.113.7", KeyEmail: "Anna@example.com"}
+
+	if r := l.Check(context.Background(), PolicyLogin, keys); !r.Allowed || r.Limit != 2 || r.Remaining != 1 {
+		t.Errorf("first request: %+v", r)
+	}
+	now = now.Add(20 * time.Second)
+	l.Check(context.Background(), PolicyLogin, keys)
+
+	now = now.Add(20 * time.Second)
+	r := l.Check(context.Background(), PolicyLogin, map[KeyKind]string{KeyIP: "203.0.113.7", KeyEmail: "anna@example.com"})
+	if r.Allowed || r.RetryAfter != 20*time.Second {
+		t.Errorf("third request: %+v", r)
+	}
+
+	// Первый запрос выходит из окна, место освобождается
+	now = now.Add(21 * time.Second)
+	if r := l.Check(context.Background(), PolicyLogin, keys); !r.Allowed {
+		t.Errorf("request after window: %+v", r)
+	}
+
+	// Другой email ограничивается только по IP
+	if r := l.Check(context.Background(), PolicyLogin, map[KeyKind]string{KeyIP: "203.0.113.7", KeyEmail: "ivan@example.com"}); !r.Allowed || r.Limit != 5 || r.Remaining != 1 {
+		t.Errorf("other email: %+v", r)
+	}
+}
+
+func TestLimiterExempt(t *testing.T) {
+	l := NewLimiter(nil, DefaultConfig())
+	if !l.Exempt("127.0.0.1") || !l.Exempt("::1") {
+		t.Error("loopback is not exempt")
+	}
+	if l.Exempt("198.51.100.1") || l.Exempt("not-an-ip") {
+		t.Error("public address is exempt")
+	}
+}
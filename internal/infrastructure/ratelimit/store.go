@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store ведет журнал запросов в скользящем окне
+type Store interface {
+	// Take учитывает запрос, если в окне меньше limit запросов. Возвращает число запросов
+	// в окне с учетом текущего и момент, когда освободится место для следующего
+	Take(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (allowed bool, count int, reset time.Time, err error)
+}
+
+// slidingWindowScript хранит запросы в sorted set с временем в миллисекундах.
+// Устаревшие записи удаляются, новая добавляется, только если лимит не исчерпан
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], window)
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local first = now
+if oldest[2] then
+	first = tonumber(oldest[2])
+end
+return {allowed, count, first}
+`)
+
+// RedisStore - общий для всех экземпляров сервиса журнал в Redis
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (bool, int, time.Time, error) {
+	member := strconv.FormatInt(now.UnixNano(), 10) + "-" + randomSuffix()
+	result, err := slidingWindowScript.Run(ctx, s.client, []string{key},
+		now.UnixMilli(), window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return false, 0, time.Time{}, err
+	}
+	reset := time.UnixMilli(result[2]).Add(window)
+	return result[0] == 1, int(result[1]), reset, nil
+}
+
+// MemoryStore - журнал в памяти процесса. Используется без Redis и при его недоступности,
+// ограничения в этом случае действуют для каждого экземпляра сервиса отдельно
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string][]time.Time
+	windows   map[string]time.Duration
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: map[string][]time.Time{},
+		windows: map[string]time.Duration{},
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit int, window time.Duration, now time.Time) (bool, int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	entries := prune(s.entries[key], now.Add(-window))
+	allowed := len(entries) < limit
+	if allowed {
+		entries = append(entries, now)
+	}
+	s.entries[key] = entries
+	s.windows[key] = window
+
+	return allowed, len(entries), entries[0].Add(window), nil
+}
+
+// sweep раз в минуту удаляет ключи, по которым не было запросов дольше окна
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, entries := range s.entries {
+		if len(prune(entries, now.Add(-s.windows[key]))) == 0 {
+			delete(s.entries, key)
+			delete(s.windows, key)
+		}
+	}
+}
+
+// prune отбрасывает запросы, вышедшие из окна. Записи упорядочены по времени
+func prune(entries []time.Time, after time.Time) []time.Time {
+	i := 0
+	for i < len(entries) && !entries[i].After(after) {
+		i++
+	}
+	return entries[i:]
+}
+
+func randomSuffix() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "0"
+	}
+	return hex.EncodeToString(b)
+}
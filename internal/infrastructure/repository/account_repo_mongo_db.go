@@ -88,3 +88,9 @@ func (r *MongoAccountRepository) Update(ctx context.Context, user dto.UserDTO, s
 
 	return nil
 }
+
+// Delete - удаляет аккаунт из MongoDB
+func (r *MongoAccountRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
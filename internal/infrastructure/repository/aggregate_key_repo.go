@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"kubercode-sso/internal/domain/auth/repository"
+	"log/slog"
+	"time"
+)
+
+// aggregateKeySize - размер ключа AES-256
+const aggregateKeySize = 32
+
+type aggregateKey struct {
+	AggregateId uuid.UUID `bson:"_id"`
+	Key         []byte    `bson:"key"`
+	CreatedAt   time.Time `bson:"created_at"`
+}
+
+// MongoAggregateKeyRepository - хранилище ключей шифрования агрегатов в MongoDB
+type MongoAggregateKeyRepository struct {
+	log        *slog.Logger
+	collection *mongo.Collection
+}
+
+func NewMongoAggregateKeyRepository(log *slog.Logger, db *mongo.Database) *MongoAggregateKeyRepository {
+	return &MongoAggregateKeyRepository{
+		log:        log,
+		collection: db.Collection("aggregate_keys"),
+	}
+}
+
+// GetOrCreateKey - возвращает ключ агрегата, создавая его при первом обращении
+func (r *MongoAggregateKeyRepository) GetOrCreateKey(ctx context.Context, aggregateId uuid.UUID) ([]byte, error) {
+	key, err := r.GetKey(ctx, aggregateId)
+	if err == nil {
+		return key, nil
+	}
+	if !errors.Is(err, repository.ErrAggregateKeyNotFound) {
+		return nil, err
+	}
+
+	newKey := make([]byte, aggregateKeySize)
+	if _, err := rand.Read(newKey); err != nil {
+		return nil, err
+	}
+
+	// $setOnInsert защищает от гонки: если ключ уже создан параллельно, он не перезаписывается
+	_, err = r.collection.UpdateOne(ctx,
+		bson.M{"_id": aggregateId},
+		bson.M{"$setOnInsert": aggregateKey{AggregateId: aggregateId, Key: newKey, CreatedAt: time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetKey(ctx, aggregateId)
+}
+
+// GetKey - возвращает ключ агрегата
+func (r *MongoAggregateKeyRepository) GetKey(ctx context.Context, aggregateId uuid.UUID) ([]byte, error) {
+	var key aggregateKey
+	err := r.collection.FindOne(ctx, bson.M{"_id": aggregateId}).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, repository.ErrAggregateKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key.Key, nil
+}
+
+// DeleteKey - уничтожает ключ агрегата
+func (r *MongoAggregateKeyRepository) DeleteKey(ctx context.Context, aggregateId uuid.UUID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": aggregateId})
+	if err != nil {
+		return err
+	}
+	r.log.Info("aggregate key destroyed", slog.String("aggregateId", aggregateId.String()))
+	return nil
+}
@@ -0,0 +1,92 @@
+package tlsutil
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidIdentity = errors.New("invalid service identity rule")
+
+// SubjectKind - поле клиентского сертификата, по которому узнается сервис
+type SubjectKind string
+
+const (
+	// SubjectCN - Common Name субъекта
+	SubjectCN SubjectKind = "cn"
+	// SubjectDNS - DNS имя из Subject Alternative Name
+	SubjectDNS SubjectKind = "dns"
+	// SubjectURI - URI из Subject Alternative Name, например SPIFFE ID
+	SubjectURI SubjectKind = "uri"
+)
+
+// IdentityRule сопоставляет поле сертификата с именем сервиса
+type IdentityRule struct {
+	Kind    SubjectKind
+	Value   string
+	Service string
+}
+
+// Identities - правила сопоставления клиентских сертификатов с сервисами
+type Identities []IdentityRule
+
+// ParseIdentities разбирает правила в формате
+// "cn:billing-api=billing, uri:spiffe://kubercode/ns/prod/sa/backoffice=backoffice".
+// Имена сервисов приводятся к нижнему регистру
+func ParseIdentities(spec string) (Identities, error) {
+	var rules Identities
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kind, rest, ok := strings.Cut(item, ":")
+		// Имя сервиса отделяется последним "=", в значении поля "=" может встречаться
+		i := strings.LastIndex(rest, "=")
+		if !ok || i <= 0 || i == len(rest)-1 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidIdentity, item)
+		}
+		rule := IdentityRule{
+			Kind:    SubjectKind(strings.ToLower(strings.TrimSpace(kind))),
+			Value:   strings.TrimSpace(rest[:i]),
+			Service: strings.ToLower(strings.TrimSpace(rest[i+1:])),
+		}
+		if rule.Kind != SubjectCN && rule.Kind != SubjectDNS && rule.Kind != SubjectURI {
+			return nil, fmt.Errorf("%w: unknown subject field %q", ErrInvalidIdentity, kind)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Match возвращает сервис, которому принадлежит проверенный клиентский сертификат.
+// Первое подходящее правило побеждает
+func (ids Identities) Match(cert *x509.Certificate) (string, bool) {
+	for _, rule := range ids {
+		if rule.matches(cert) {
+			return rule.Service, true
+		}
+	}
+	return "", false
+}
+
+func (r IdentityRule) matches(cert *x509.Certificate) bool {
+	switch r.Kind {
+	case SubjectCN:
+		return cert.Subject.CommonName == r.Value
+	case SubjectDNS:
+		for _, name := range cert.DNSNames {
+			if strings.EqualFold(name, r.Value) {
+				return true
+			}
+		}
+	case SubjectURI:
+		for _, uri := range cert.URIs {
+			if uri.String() == r.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
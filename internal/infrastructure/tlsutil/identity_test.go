@@ -0,0 +1,57 @@
+package tlsutil
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestParseIdentities(t *testing.T) {
+	ids, err := ParseIdentities("cn:billing-api=billing, uri:spiffe://kubercode/sa/backoffice=backoffice, DNS:gateway.internal=gateway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identities{
+		{Kind: SubjectCN, Value: "billing-api", Service: "billing"},
+		{Kind: SubjectURI, Value: "spiffe://kubercode/sa/backoffice", Service: "backoffice"},
+		{Kind: SubjectDNS, Value: "gateway.internal", Service: "gateway"},
+	}
+	if len(ids) != len(want) {
+		t.Fatalf("ParseIdentities = %+v", ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("rule %d = %+v, want %+v", i, ids[i], want[i])
+		}
+	}
+
+	for _, spec := range []string{"billing-api=billing", "cn:billing-api", "cn:=billing", "cn:billing-api=", "ou:platform=platform"} {
+		if _, err := ParseIdentities(spec); !errors.Is(err, ErrInvalidIdentity) {
+			t.Errorf("ParseIdentities(%q) error = %v, want ErrInvalidIdentity", spec, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	ids, _ := ParseIdentities("cn:billing-api=billing, uri:spiffe://kubercode/sa/backoffice=backoffice, dns:gateway.internal=gateway")
+	spiffe, _ := url.Parse("spiffe://kubercode/sa/backoffice")
+
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		want string
+	}{
+		{"common name", &x509.Certificate{Subject: pkix.Name{CommonName: "billing-api"}}, "billing"},
+		{"uri san", &x509.Certificate{URIs: []*url.URL{spiffe}}, "backoffice"},
+		{"dns san", &x509.Certificate{DNSNames: []string{"Gateway.Internal"}}, "gateway"},
+		{"unknown", &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}}, ""},
+	}
+	for _, tt := range tests {
+		got, ok := ids.Match(tt.cert)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("%s: Match = %q, %v; want %q", tt.name, got, ok, tt.want)
+		}
+	}
+}
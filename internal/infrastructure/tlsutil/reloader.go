@@ -0,0 +1,164 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrNoCACertificates = errors.New("ca bundle contains no certificates")
+
+// Reloader отдает TLS серверу сертификат и доверенные CA из файлов и перечитывает их при изменении,
+// так что обновленный сертификат (например, от cert-manager) подхватывается без перезапуска.
+// Пока новые файлы не читаются (ключ еще не записан), сервер продолжает работать со старыми
+type Reloader struct {
+	certFile, keyFile, caFile string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	versions  map[string]fileVersion
+}
+
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader загружает сертификат и ключ. caFile - PEM бандл CA для проверки клиентских
+// сертификатов, пустой для обычного TLS
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload перечитывает файлы, если они изменились, и сообщает, были ли загружены новые
+func (r *Reloader) Reload() (bool, error) {
+	versions, err := r.stat()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	changed := !sameVersions(r.versions, versions)
+	r.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("load certificate %s: %w", r.certFile, err)
+	}
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		if pool, err = loadCAPool(r.caFile); err != nil {
+			return false, err
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.clientCAs, r.versions = &cert, pool, versions
+	r.mu.Unlock()
+	return true, nil
+}
+
+// Watch проверяет файлы с периодом interval до отмены ctx
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				log.Printf("[tlsutil.Watch] Ошибка перезагрузки сертификата %s, используется прежний: %v", r.certFile, err)
+				continue
+			}
+			if reloaded {
+				log.Printf("[tlsutil.Watch] Сертификат %s перезагружен", r.certFile)
+			}
+		}
+	}
+}
+
+// GetCertificate возвращает текущий сертификат сервера
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// ServerConfig возвращает настройки TLS сервера. Если задан бандл CA, сервер требует
+// клиентский сертификат, подписанный одним из них (mTLS)
+func (r *Reloader) ServerConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+	if r.caFile == "" {
+		return cfg
+	}
+	// Бандл CA может смениться, поэтому настройки собираются для каждого соединения
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: r.GetCertificate,
+			ClientAuth:     tls.RequireAndVerifyClientCert,
+			ClientCAs:      r.clientCAs,
+		}, nil
+	}
+	return cfg
+}
+
+func (r *Reloader) stat() (map[string]fileVersion, error) {
+	versions := make(map[string]fileVersion, 3)
+	for _, name := range []string{r.certFile, r.keyFile, r.caFile} {
+		if name == "" {
+			continue
+		}
+		// Stat идет по симлинкам, поэтому замена секрета в Kubernetes тоже видна
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		versions[name] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+	}
+	return versions, nil
+}
+
+func sameVersions(a, b map[string]fileVersion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, v := range a {
+		if b[name] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func loadCAPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%w: %s", ErrNoCACertificates, caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,87 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeSelfSigned(t *testing.T, dir, cn string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func currentCN(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cert, _ := r.GetCertificate(nil)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestReloadOnChange(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir, "old")
+	r, err := NewReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded, err := r.Reload(); reloaded || err != nil {
+		t.Fatalf("unchanged files: Reload = %v, %v", reloaded, err)
+	}
+
+	writeSelfSigned(t, dir, "new")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	if reloaded, err := r.Reload(); !reloaded || err != nil {
+		t.Fatalf("changed files: Reload = %v, %v", reloaded, err)
+	}
+	if cn := currentCN(t, r); cn != "new" {
+		t.Errorf("certificate CN = %q, want new", cn)
+	}
+
+	// Битый ключ не должен заменять рабочий сертификат
+	os.WriteFile(keyFile, []byte("garbage"), 0o600)
+	if _, err := r.Reload(); err == nil {
+		t.Error("broken key should fail to reload")
+	}
+	if cn := currentCN(t, r); cn != "new" {
+		t.Errorf("certificate CN after failed reload = %q, want new", cn)
+	}
+}